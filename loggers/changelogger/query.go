@@ -2,6 +2,7 @@ package changelogger
 
 import (
 	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
 	"github.com/mariotoffia/godeviceshadow/utils/reutils"
 )
 
@@ -76,3 +77,69 @@ func (cl *ChangeMergeLogger) PlainFromPath(path string, operation ...model.Merge
 
 	return lm, nil
 }
+
+// ManagedFromGlob is same as `ManagedFromPath` but the _pattern_ is a path glob (see `pathutils.Glob`) such
+// as _climate.sensors.*.t_ instead of a regexp. The style of the _pattern_ must match the style of the logged paths.
+//
+// NOTE: Since the glob is cached, this function can be invoked many times with the same pattern without paying the
+// cost of compiling the glob each time.
+func (cl *ChangeMergeLogger) ManagedFromGlob(pattern string, operation ...model.MergeOperation) (ManagedLogMap, error) {
+	g, err := pathutils.Shared.GetOrCompile(pattern)
+
+	if err != nil {
+		return nil, err
+	}
+
+	lm := make(ManagedLogMap, len(cl.ManagedLog))
+
+	for op, v := range cl.ManagedLog {
+		if len(v) == 0 || (len(operation) > 0 && !op.In(operation...)) {
+			continue
+		}
+
+		values := make([]ManagedValue, 0, len(v))
+
+		for _, mv := range v {
+			if g.Match(mv.Path) {
+				values = append(values, mv)
+			}
+		}
+
+		if len(values) > 0 {
+			lm[op] = values
+		}
+	}
+
+	return lm, nil
+}
+
+// PlainFromGlob is same as `PlainFromPath` but the _pattern_ is a path glob (see `pathutils.Glob`) instead of a regexp.
+func (cl *ChangeMergeLogger) PlainFromGlob(pattern string, operation ...model.MergeOperation) (PlainLogMap, error) {
+	g, err := pathutils.Shared.GetOrCompile(pattern)
+
+	if err != nil {
+		return nil, err
+	}
+
+	lm := make(PlainLogMap, len(cl.PlainLog))
+
+	for op, v := range cl.PlainLog {
+		if len(v) == 0 || (len(operation) > 0 && !op.In(operation...)) {
+			continue
+		}
+
+		values := make([]PlainValue, 0, len(v))
+
+		for _, pv := range v {
+			if g.Match(pv.Path) {
+				values = append(values, pv)
+			}
+		}
+
+		if len(values) > 0 {
+			lm[op] = values
+		}
+	}
+
+	return lm, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
 	"github.com/mariotoffia/godeviceshadow/utils/reutils"
 )
 
@@ -22,6 +23,21 @@ func (d *DesireLogger) Acknowledged() map[string]model.ValueAndTimestamp {
 	return d.acknowledged
 }
 
+// Reject Implements the `model.DesiredRejectLogger` interface and keeps a record on the rejected
+// values.
+func (d *DesireLogger) Reject(ctx context.Context, path string, value model.ValueAndTimestamp, rejection model.RejectedDesire) {
+	if d.rejected == nil {
+		d.rejected = map[string]RejectedValue{}
+	}
+
+	d.rejected[path] = RejectedValue{Value: value, Rejection: rejection}
+}
+
+// Rejected returns the desired values that was rejected, keyed by path.
+func (d *DesireLogger) Rejected() map[string]RejectedValue {
+	return d.rejected
+}
+
 // FromPath accepts a _path_ regexp that selects on the path of the acknowledged values.
 //
 // The _path_ regex is caches so no additional compile cost, except the first time, is paid.
@@ -42,3 +58,23 @@ func (d *DesireLogger) FromPath(path string) (map[string]model.ValueAndTimestamp
 
 	return m, nil
 }
+
+// FromGlob is same as `FromPath` but accepts a path glob (see `pathutils.Glob`) such as _climate.*.sp_
+// instead of a regexp.
+func (d *DesireLogger) FromGlob(pattern string) (map[string]model.ValueAndTimestamp, error) {
+	g, err := pathutils.Shared.GetOrCompile(pattern)
+
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]model.ValueAndTimestamp, len(d.acknowledged))
+
+	for k, v := range d.acknowledged {
+		if g.Match(k) {
+			m[k] = v
+		}
+	}
+
+	return m, nil
+}
@@ -6,8 +6,20 @@ import "github.com/mariotoffia/godeviceshadow/model"
 // a report is incoming. That is, this is done when `Report` is called.
 type DesireLogger struct {
 	acknowledged map[string]model.ValueAndTimestamp
+	rejected     map[string]RejectedValue
+}
+
+// RejectedValue is a desired value that a device did reject.
+type RejectedValue struct {
+	// Value is the desired value that was rejected.
+	Value model.ValueAndTimestamp
+	// Rejection is the rejection as submitted by the device.
+	Rejection model.RejectedDesire
 }
 
 func New() *DesireLogger {
-	return &DesireLogger{acknowledged: map[string]model.ValueAndTimestamp{}}
+	return &DesireLogger{
+		acknowledged: map[string]model.ValueAndTimestamp{},
+		rejected:     map[string]RejectedValue{},
+	}
 }
@@ -0,0 +1,381 @@
+package alarms
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/loggers/changelogger"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/notifiermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/numutils"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
+)
+
+// DefaultClientID is the client id the alarm sections are written with when none is set.
+const DefaultClientID = "alarms"
+
+// MaxAttempts is the number of times an alarm section is read, altered and written before a version conflict is
+// returned.
+const MaxAttempts = 5
+
+const (
+	// CustomAlarm is the `notifiermodel.NotifierOperation.Custom` property that holds the `managermodel.Alarm`
+	// of a transition.
+	CustomAlarm = "alarm"
+	// CustomFrom is the `notifiermodel.NotifierOperation.Custom` property that holds the previous
+	// `managermodel.AlarmState` of a transition.
+	CustomFrom = "from"
+)
+
+// Engine evaluates the alarm definitions on the `changelogger` entries of the reports and keeps the alarm
+// instances in the alarm section of each shadow. Each transition is emitted as a `notifiermodel.OperationTypeAlarm`
+// operation on the notifier.
+//
+// It is a `managermodel.Interceptor`, when registered on the manager it evaluates all reports of the manager. It
+// is also a `notifiermodel.NotificationTarget` to evaluate the report notifications of e.g. a stream processor.
+type Engine struct {
+	persistence persistencemodel.Persistence
+	notifier    notifiermodel.Notifier
+	definitions []definition
+	clientID    string
+	name        string
+	now         func() time.Time
+	onError     func(ctx context.Context, id persistencemodel.ID, err error)
+
+	mu sync.Mutex
+}
+
+type definition struct {
+	Definition
+	glob *pathutils.Glob
+}
+
+// sample is a numeric value on a path in the reported merge.
+type sample struct {
+	path  string
+	value float64
+}
+
+// Evaluate evaluates the alarm definitions on the entries of the _cl_ that was reported on the shadow _id_ and
+// returns the transitions.
+func (e *Engine) Evaluate(ctx context.Context, id persistencemodel.ID, cl changelogger.ChangeMergeLogger) ([]Transition, error) {
+	samples := samplesOf(cl)
+
+	if len(samples) == 0 {
+		return nil, nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var transitions []Transition
+
+	err := e.update(ctx, id, func(section managermodel.Alarms) (bool, error) {
+		var dirty bool
+
+		transitions, dirty = e.evaluate(id, section, samples)
+
+		return dirty, nil
+	})
+
+	if err != nil || len(transitions) == 0 {
+		return nil, err
+	}
+
+	return transitions, e.notify(ctx, transitions)
+}
+
+// evaluate evaluates the alarm definitions on the _samples_ and alters the _section_ in place. It returns the
+// transitions and whether the section was altered.
+func (e *Engine) evaluate(id persistencemodel.ID, section managermodel.Alarms, samples []sample) ([]Transition, bool) {
+	now := e.now().UTC().UnixNano()
+	dirty := false
+
+	var transitions []Transition
+
+	for _, def := range e.definitions {
+		for _, s := range samples {
+			if !def.glob.Match(s.path) {
+				continue
+			}
+
+			key := managermodel.AlarmKey(def.ID, s.path)
+			alarm, exists := section.Entries[key]
+
+			if exists && alarm.State != managermodel.AlarmCleared {
+				if def.Condition.Clears(s.value, def.Hysteresis) {
+					from := alarm.State
+
+					alarm.State = managermodel.AlarmCleared
+					alarm.Value = s.value
+					alarm.ClearedAt = now
+
+					section.Entries[key] = alarm
+					transitions = append(transitions, Transition{ID: id, From: from, Alarm: alarm})
+					dirty = true
+				}
+
+				continue
+			}
+
+			since, pending := section.Pending[key]
+
+			switch {
+			case !def.Condition.Holds(s.value):
+				if pending {
+					delete(section.Pending, key)
+					dirty = true
+				}
+
+				continue
+			case def.OnDelay > 0 && !pending:
+				section.Pending[key] = now
+				dirty = true
+
+				continue
+			case def.OnDelay > 0 && now-since < int64(def.OnDelay):
+				continue
+			}
+
+			delete(section.Pending, key)
+
+			raised := managermodel.Alarm{
+				Key:        key,
+				Definition: def.ID,
+				Path:       s.path,
+				State:      managermodel.AlarmActive,
+				Value:      s.value,
+				Message:    def.Message,
+				RaisedAt:   now,
+			}
+
+			section.Entries[key] = raised
+			transitions = append(transitions, Transition{ID: id, From: alarm.State, Alarm: raised})
+			dirty = true
+		}
+	}
+
+	return transitions, dirty
+}
+
+// ListAlarms implements the `managermodel.Alarmer` interface.
+func (e *Engine) ListAlarms(ctx context.Context, id persistencemodel.ID, states ...managermodel.AlarmState) ([]managermodel.Alarm, error) {
+	section, _, err := e.read(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return section.List(states...), nil
+}
+
+// AcknowledgeAlarm implements the `managermodel.Alarmer` interface. The transition is emitted on the notifier.
+func (e *Engine) AcknowledgeAlarm(ctx context.Context, id persistencemodel.ID, key, clientID string) (managermodel.Alarm, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var alarm managermodel.Alarm
+
+	err := e.update(ctx, id, func(section managermodel.Alarms) (bool, error) {
+		var err error
+
+		alarm, err = section.Acknowledge(key, clientID, e.now().UTC().UnixNano())
+
+		return err == nil, err
+	})
+
+	if err != nil {
+		return managermodel.Alarm{}, err
+	}
+
+	return alarm, e.notify(ctx, []Transition{{ID: id, From: managermodel.AlarmActive, Alarm: alarm}})
+}
+
+// Before implements the `managermodel.Interceptor` interface.
+func (e *Engine) Before(ctx context.Context, call *managermodel.InterceptCall) error {
+	return nil
+}
+
+// After implements the `managermodel.Interceptor` interface. It evaluates the successful reports.
+func (e *Engine) After(ctx context.Context, call *managermodel.InterceptCall) {
+	if call.Kind != managermodel.InterceptReport || call.ReportResult == nil {
+		return
+	}
+
+	res := call.ReportResult
+
+	if res.Error != nil || res.Duplicate {
+		return
+	}
+
+	cl := changelogger.Find(res.MergeLoggers)
+
+	if cl == nil {
+		return
+	}
+
+	if _, err := e.Evaluate(ctx, res.ID, *cl); err != nil && e.onError != nil {
+		e.onError(ctx, res.ID, err)
+	}
+}
+
+// Name implements the `notifiermodel.NotificationTarget` interface.
+func (e *Engine) Name() string {
+	return e.name
+}
+
+// Notify implements the `notifiermodel.NotificationTarget` interface. It evaluates the report operations.
+func (e *Engine) Notify(
+	ctx context.Context,
+	tx *persistencemodel.TransactionImpl,
+	operations ...notifiermodel.NotifierOperation,
+) []notifiermodel.NotificationTargetResult {
+	res := make([]notifiermodel.NotificationTargetResult, 0, len(operations))
+
+	for _, op := range operations {
+		var err error
+
+		if op.Operation == notifiermodel.OperationTypeReport {
+			_, err = e.Evaluate(ctx, persistencemodel.ID{ID: op.ID.ID, Name: op.ID.Name}, op.MergeLogger)
+		}
+
+		res = append(res, notifiermodel.NotificationTargetResult{Target: e, Operation: op, Error: err})
+	}
+
+	return res
+}
+
+// notify emits the _transitions_ on the notifier and returns the first error.
+func (e *Engine) notify(ctx context.Context, transitions []Transition) error {
+	if e.notifier == nil || len(transitions) == 0 {
+		return nil
+	}
+
+	ops := make([]notifiermodel.NotifierOperation, 0, len(transitions))
+
+	for _, t := range transitions {
+		ops = append(ops, notifiermodel.NotifierOperation{
+			ID:        t.ID.ToPersistenceID(persistencemodel.ModelTypeReported),
+			Operation: notifiermodel.OperationTypeAlarm,
+			Custom:    map[string]any{CustomAlarm: t.Alarm, CustomFrom: t.From},
+		})
+	}
+
+	for _, r := range e.notifier.Process(ctx, nil, ops...) {
+		if r.Error != nil {
+			return r.Error
+		}
+	}
+
+	return nil
+}
+
+// read reads the alarm section and the version of it. If not found, an empty section with version zero is returned.
+func (e *Engine) read(ctx context.Context, id persistencemodel.ID) (managermodel.Alarms, int64, error) {
+	section := managermodel.Alarms{Entries: map[string]managermodel.Alarm{}, Pending: map[string]int64{}}
+
+	res := e.persistence.Read(ctx, persistencemodel.ReadOptions{}, persistencemodel.ReadOperation{
+		ID:    managermodel.AlarmsID(id),
+		Model: reflect.TypeOf(section),
+	})
+
+	if len(res) == 0 {
+		return section, 0, nil
+	}
+
+	if res[0].Error != nil {
+		var pe persistencemodel.PersistenceError
+
+		if errors.As(res[0].Error, &pe) && pe.Code == 404 {
+			return section, 0, nil
+		}
+
+		return section, 0, res[0].Error
+	}
+
+	// Copy since the persistence may return the stored instance
+	switch m := res[0].Model.(type) {
+	case managermodel.Alarms:
+		maps.Copy(section.Entries, m.Entries)
+		maps.Copy(section.Pending, m.Pending)
+	case *managermodel.Alarms:
+		maps.Copy(section.Entries, m.Entries)
+		maps.Copy(section.Pending, m.Pending)
+	}
+
+	return section, res[0].Version, nil
+}
+
+// update reads the alarm section, lets _fn_ alter it and writes it back when _fn_ returns `true`. When the section
+// was written by someone else in between, it is read and altered again up to `MaxAttempts` times.
+func (e *Engine) update(ctx context.Context, id persistencemodel.ID, fn func(section managermodel.Alarms) (bool, error)) error {
+	for attempt := 1; ; attempt++ {
+		section, version, err := e.read(ctx, id)
+
+		if err != nil {
+			return err
+		}
+
+		if dirty, err := fn(section); err != nil || !dirty {
+			return err
+		}
+
+		err = e.write(ctx, id, section, version)
+
+		var pe persistencemodel.PersistenceError
+
+		if err == nil || attempt >= MaxAttempts || !errors.As(err, &pe) || pe.Code != 409 {
+			return err
+		}
+	}
+}
+
+// write writes the alarm section. The _version_ is the one returned by `read`.
+func (e *Engine) write(ctx context.Context, id persistencemodel.ID, section managermodel.Alarms, version int64) error {
+	res := e.persistence.Write(ctx, persistencemodel.WriteOptions{
+		Config: persistencemodel.WriteConfig{Separation: persistencemodel.SeparateModels},
+	}, persistencemodel.WriteOperation{
+		ClientID: e.clientID,
+		ID:       managermodel.AlarmsID(id),
+		Model:    section,
+		Version:  version,
+		Config:   persistencemodel.WriteOperationConfig{Separation: persistencemodel.SeparateModels},
+	})
+
+	if len(res) > 0 {
+		return res[0].Error
+	}
+
+	return nil
+}
+
+// samplesOf returns the numeric values that was added, updated or left unchanged in the _cl_.
+func samplesOf(cl changelogger.ChangeMergeLogger) []sample {
+	var samples []sample
+
+	for _, mo := range []model.MergeOperation{model.MergeOperationAdd, model.MergeOperationUpdate, model.MergeOperationNotChanged} {
+		for _, mv := range cl.ManagedLog[mo] {
+			if mv.NewValue == nil {
+				continue
+			}
+
+			if v, ok := numutils.ToFloat(mv.NewValue.GetValue()); ok {
+				samples = append(samples, sample{path: mv.Path, value: v})
+			}
+		}
+
+		for _, pv := range cl.PlainLog[mo] {
+			if v, ok := numutils.ToFloat(pv.NewValue); ok {
+				samples = append(samples, sample{path: pv.Path, value: v})
+			}
+		}
+	}
+
+	return samples
+}
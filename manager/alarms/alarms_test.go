@@ -0,0 +1,348 @@
+package alarms_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/loggers/changelogger"
+	"github.com/mariotoffia/godeviceshadow/manager/alarms"
+	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/notifiermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/notify"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+	"github.com/mariotoffia/godeviceshadow/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Sensor struct {
+	Value     any
+	TimeStamp time.Time
+}
+
+func (sp *Sensor) GetTimestamp() time.Time {
+	return sp.TimeStamp
+}
+
+func (sp *Sensor) GetValue() any {
+	return sp.Value
+}
+
+type TestModel struct {
+	Sensors map[string]Sensor
+}
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func TestAlarms(t *testing.T) {
+	ctx := context.Background()
+	clk := &clock{now: time.Now().UTC()}
+	persistence := mempersistence.New()
+
+	var notified []notifiermodel.NotifierOperation
+
+	notifier := notify.NewBuilder().
+		TargetBuilder(notifiermodel.FuncTarget(
+			func(ctx context.Context, target notifiermodel.NotificationTarget, tx *persistencemodel.TransactionImpl, operations ...notifiermodel.NotifierOperation) []notifiermodel.NotificationTargetResult {
+				notified = append(notified, operations...)
+				return nil
+			}, "alarms")).
+		Build().
+		Build()
+
+	engine, err := alarms.New(persistence).
+		WithNotifier(notifier).
+		WithClock(clk.Now).
+		WithOnError(func(ctx context.Context, id persistencemodel.ID, err error) { t.Errorf("%s: %v", id, err) }).
+		WithDefinitions(alarms.Definition{
+			ID:         "high-temp",
+			Path:       "Sensors.*",
+			Condition:  alarms.Above(30),
+			Hysteresis: 2,
+			OnDelay:    5 * time.Minute,
+			Message:    "temperature too high",
+		}).
+		Build()
+
+	require.NoError(t, err)
+
+	mgr := stdmgr.New().
+		WithPersistence(persistence).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithReportLoggers(changelogger.New()).
+		WithInterceptors(engine).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	id := persistencemodel.ID{ID: "hub-1", Name: "homeHub"}
+	key := managermodel.AlarmKey("high-temp", "Sensors.indoor")
+
+	report := func(value float64) {
+		res := mgr.Report(ctx, managermodel.ReportOperation{
+			ClientID: "device",
+			ID:       id,
+			Model:    TestModel{Sensors: map[string]Sensor{"indoor": {Value: value, TimeStamp: clk.now}}},
+		})
+
+		require.NoError(t, res[0].Error)
+	}
+
+	list := func() []managermodel.Alarm {
+		res, err := engine.ListAlarms(ctx, id)
+
+		require.NoError(t, err)
+
+		return res
+	}
+
+	// Within the on-delay
+	report(31)
+	assert.Empty(t, list())
+
+	clk.now = clk.now.Add(5 * time.Minute)
+	report(31.5)
+
+	active := list()
+
+	require.Len(t, active, 1)
+	assert.Equal(t, key, active[0].Key)
+	assert.Equal(t, managermodel.AlarmActive, active[0].State)
+	assert.Equal(t, 31.5, active[0].Value)
+	assert.Equal(t, "temperature too high", active[0].Message)
+
+	// Within the hysteresis
+	clk.now = clk.now.Add(time.Minute)
+	report(29)
+	assert.Len(t, list(), 1)
+
+	alarm, err := engine.AcknowledgeAlarm(ctx, id, key, "operator")
+
+	require.NoError(t, err)
+	assert.Equal(t, managermodel.AlarmAcknowledged, alarm.State)
+	assert.Equal(t, "operator", alarm.AcknowledgedBy)
+
+	_, err = engine.AcknowledgeAlarm(ctx, id, key, "operator")
+	assert.Error(t, err, "not active")
+
+	_, err = engine.AcknowledgeAlarm(ctx, id, "unknown", "operator")
+	assert.Error(t, err)
+
+	clk.now = clk.now.Add(time.Minute)
+	report(27.5)
+	assert.Empty(t, list())
+
+	cleared, err := engine.ListAlarms(ctx, id, managermodel.AlarmCleared)
+
+	require.NoError(t, err)
+	require.Len(t, cleared, 1)
+	assert.Equal(t, 27.5, cleared[0].Value)
+
+	// Transitions
+	require.Len(t, notified, 3)
+
+	for i, from := range []managermodel.AlarmState{"", managermodel.AlarmActive, managermodel.AlarmAcknowledged} {
+		assert.Equal(t, notifiermodel.OperationTypeAlarm, notified[i].Operation)
+		assert.Equal(t, from, notified[i].Custom[alarms.CustomFrom])
+		assert.Equal(t, id.ID, notified[i].ID.ID)
+	}
+
+	assert.Equal(t, managermodel.AlarmCleared, notified[2].Custom[alarms.CustomAlarm].(managermodel.Alarm).State)
+
+	// The alarm section is not a shadow
+	shadows, err := mgr.List(ctx)
+
+	require.NoError(t, err)
+	require.Len(t, shadows.Items, 1, "only the reported model")
+
+	for _, item := range shadows.Items {
+		assert.Equal(t, id.Name, item.ID.Name)
+	}
+}
+
+func TestAlarmsBuild(t *testing.T) {
+	_, err := alarms.New(mempersistence.New()).WithDefinitions(alarms.Definition{ID: "a", Path: "x"}).Build()
+	assert.Error(t, err, "no condition")
+
+	_, err = alarms.New(mempersistence.New()).WithDefinitions(
+		alarms.Definition{ID: "a", Path: "x", Condition: alarms.Below(0)},
+		alarms.Definition{ID: "a", Path: "y", Condition: alarms.Below(0)},
+	).Build()
+	assert.Error(t, err, "duplicate")
+}
+
+func TestAlarmsManagerAndMove(t *testing.T) {
+	ctx := context.Background()
+	persistence := mempersistence.New()
+
+	engine, err := alarms.New(persistence).
+		WithDefinitions(alarms.Definition{ID: "high-temp", Path: "Sensors.*", Condition: alarms.Above(30)}).
+		Build()
+
+	require.NoError(t, err)
+
+	mgr := stdmgr.New().
+		WithPersistence(persistence).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithReportLoggers(changelogger.New()).
+		WithInterceptors(engine).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	var alarmer managermodel.Alarmer = mgr
+
+	from := persistencemodel.ID{ID: "hub-1", Name: "homeHub"}
+	to := persistencemodel.ID{ID: "hub-2", Name: "homeHub"}
+	key := managermodel.AlarmKey("high-temp", "Sensors.indoor")
+
+	res := mgr.Report(ctx, managermodel.ReportOperation{
+		ID:    from,
+		Model: TestModel{Sensors: map[string]Sensor{"indoor": {Value: 35.0, TimeStamp: time.Now().UTC()}}},
+	})
+
+	require.NoError(t, res[0].Error)
+
+	active, err := alarmer.ListAlarms(ctx, from)
+	require.NoError(t, err)
+	require.Len(t, active, 1)
+	assert.Equal(t, key, active[0].Key)
+
+	// The alarms moves along with the shadow
+	_, err = mgr.Move(ctx, from, to, "installer")
+	require.NoError(t, err)
+
+	active, err = alarmer.ListAlarms(ctx, from)
+	require.NoError(t, err)
+	assert.Empty(t, active)
+
+	alarm, err := alarmer.AcknowledgeAlarm(ctx, to, key, "operator")
+	require.NoError(t, err)
+	assert.Equal(t, managermodel.AlarmAcknowledged, alarm.State)
+	assert.Equal(t, "operator", alarm.AcknowledgedBy)
+
+	acknowledged, err := engine.ListAlarms(ctx, to, managermodel.AlarmAcknowledged)
+	require.NoError(t, err)
+	assert.Len(t, acknowledged, 1)
+
+	_, err = alarmer.AcknowledgeAlarm(ctx, to, key, "operator")
+
+	var pe persistencemodel.PersistenceError
+
+	require.ErrorAs(t, err, &pe)
+	assert.Equal(t, 409, pe.Code)
+}
+
+// racing writes a competing alarm section before the first alarm section write is forwarded.
+type racing struct {
+	persistencemodel.Persistence
+	raced bool
+}
+
+func (r *racing) Write(ctx context.Context, opt persistencemodel.WriteOptions, operations ...persistencemodel.WriteOperation) []persistencemodel.WriteResult {
+	if !r.raced && len(operations) == 1 && strings.HasSuffix(operations[0].ID.Name, managermodel.AlarmsNameSuffix) {
+		r.raced = true
+
+		competing := operations[0]
+		competing.Version = 0
+		competing.Model = managermodel.Alarms{
+			Entries: map[string]managermodel.Alarm{"other": {Key: "other", State: managermodel.AlarmActive}},
+			Pending: map[string]int64{},
+		}
+
+		if res := r.Persistence.Write(ctx, opt, competing); res[0].Error != nil {
+			return res
+		}
+	}
+
+	return r.Persistence.Write(ctx, opt, operations...)
+}
+
+func TestAlarmsRetryOnConflict(t *testing.T) {
+	ctx := context.Background()
+	persistence := &racing{Persistence: mempersistence.New()}
+
+	var transitions int
+
+	notifier := notify.NewBuilder().
+		TargetBuilder(notifiermodel.FuncTarget(
+			func(ctx context.Context, target notifiermodel.NotificationTarget, tx *persistencemodel.TransactionImpl, operations ...notifiermodel.NotifierOperation) []notifiermodel.NotificationTargetResult {
+				transitions += len(operations)
+				return nil
+			}, "alarms")).
+		Build().
+		Build()
+
+	engine, err := alarms.New(persistence).
+		WithNotifier(notifier).
+		WithOnError(func(ctx context.Context, id persistencemodel.ID, err error) { t.Errorf("%s: %v", id, err) }).
+		WithDefinitions(alarms.Definition{ID: "high-temp", Path: "Sensors.*", Condition: alarms.Above(30)}).
+		Build()
+
+	require.NoError(t, err)
+
+	mgr := stdmgr.New().
+		WithPersistence(persistence).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithReportLoggers(changelogger.New()).
+		WithInterceptors(engine).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	id := persistencemodel.ID{ID: "hub-1", Name: "homeHub"}
+
+	seed := persistence.Persistence.Write(ctx, persistencemodel.WriteOptions{}, persistencemodel.WriteOperation{
+		ClientID: "seed",
+		ID:       managermodel.AlarmsID(id),
+		Model:    managermodel.Alarms{Entries: map[string]managermodel.Alarm{}, Pending: map[string]int64{}},
+		Config:   persistencemodel.WriteOperationConfig{Separation: persistencemodel.SeparateModels},
+	})
+
+	require.NoError(t, seed[0].Error)
+
+	res := mgr.Report(ctx, managermodel.ReportOperation{
+		ClientID: "device",
+		ID:       id,
+		Model:    TestModel{Sensors: map[string]Sensor{"indoor": {Value: 31.0, TimeStamp: time.Now().UTC()}}},
+	})
+
+	require.NoError(t, res[0].Error)
+	require.True(t, persistence.raced)
+
+	// The transition is evaluated again on the competing section
+	active, err := engine.ListAlarms(ctx, id, managermodel.AlarmActive)
+	require.NoError(t, err)
+	require.Len(t, active, 2)
+	assert.Equal(t, managermodel.AlarmKey("high-temp", "Sensors.indoor"), active[0].Key)
+	assert.Equal(t, "other", active[1].Key)
+	assert.Equal(t, 1, transitions)
+}
@@ -0,0 +1,109 @@
+package alarms
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model/notifiermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
+)
+
+type builder struct {
+	e           *Engine
+	definitions []Definition
+}
+
+// New creates a builder for a alarm `Engine`. The alarm sections are persisted in the _persistence_, it is
+// typically the same persistence as the manager uses.
+func New(persistence persistencemodel.Persistence) *builder {
+	return &builder{
+		e: &Engine{persistence: persistence},
+	}
+}
+
+// Build compiles the definitions into an `Engine`. It fails if a definition is invalid or the ids are not unique.
+func (b *builder) Build() (*Engine, error) {
+	e := &Engine{
+		persistence: b.e.persistence,
+		notifier:    b.e.notifier,
+		clientID:    b.e.clientID,
+		name:        b.e.name,
+		now:         b.e.now,
+		onError:     b.e.onError,
+	}
+
+	if e.clientID == "" {
+		e.clientID = DefaultClientID
+	}
+
+	if e.name == "" {
+		e.name = DefaultClientID
+	}
+
+	if e.now == nil {
+		e.now = time.Now
+	}
+
+	ids := map[string]bool{}
+
+	for _, d := range b.definitions {
+		if err := d.validate(); err != nil {
+			return nil, err
+		}
+
+		if ids[d.ID] {
+			return nil, fmt.Errorf("duplicate alarm definition id: %s", d.ID)
+		}
+
+		ids[d.ID] = true
+
+		g, err := pathutils.CompileGlob(d.Path)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid path in alarm definition %s: %s: %w", d.ID, d.Path, err)
+		}
+
+		e.definitions = append(e.definitions, definition{Definition: d, glob: g})
+	}
+
+	return e, nil
+}
+
+// WithDefinitions adds one or more alarm definitions.
+func (b *builder) WithDefinitions(definitions ...Definition) *builder {
+	b.definitions = append(b.definitions, definitions...)
+	return b
+}
+
+// WithNotifier sets the notifier that the transitions are emitted on.
+func (b *builder) WithNotifier(notifier notifiermodel.Notifier) *builder {
+	b.e.notifier = notifier
+	return b
+}
+
+// WithClientID sets the client id the alarm sections are written with. Default is `DefaultClientID`.
+func (b *builder) WithClientID(clientID string) *builder {
+	b.e.clientID = clientID
+	return b
+}
+
+// WithName sets the name of the engine as a `notifiermodel.NotificationTarget`. Default is `DefaultClientID`.
+func (b *builder) WithName(name string) *builder {
+	b.e.name = name
+	return b
+}
+
+// WithClock sets the function that returns the current time. It is used by the on-delay and the timestamps of the
+// alarms. Default is `time.Now`.
+func (b *builder) WithClock(now func() time.Time) *builder {
+	b.e.now = now
+	return b
+}
+
+// WithOnError sets a function that is invoked when the evaluation of a report fails in `Engine.After`.
+func (b *builder) WithOnError(f func(ctx context.Context, id persistencemodel.ID, err error)) *builder {
+	b.e.onError = f
+	return b
+}
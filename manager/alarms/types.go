@@ -0,0 +1,102 @@
+package alarms
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// Comparison is how the value is compared to the threshold of a `Condition`.
+type Comparison int
+
+const (
+	// ComparisonAbove holds when the value is greater than the threshold.
+	ComparisonAbove Comparison = 1
+	// ComparisonBelow holds when the value is less than the threshold.
+	ComparisonBelow Comparison = 2
+)
+
+// Condition is a threshold condition on a numeric value.
+type Condition struct {
+	Comparison Comparison
+	Threshold  float64
+}
+
+// Above returns a condition that holds when the value is greater than _threshold_.
+func Above(threshold float64) Condition {
+	return Condition{Comparison: ComparisonAbove, Threshold: threshold}
+}
+
+// Below returns a condition that holds when the value is less than _threshold_.
+func Below(threshold float64) Condition {
+	return Condition{Comparison: ComparisonBelow, Threshold: threshold}
+}
+
+// Holds returns `true` if the condition holds for _v_.
+func (c Condition) Holds(v float64) bool {
+	switch c.Comparison {
+	case ComparisonAbove:
+		return v > c.Threshold
+	case ComparisonBelow:
+		return v < c.Threshold
+	}
+
+	return false
+}
+
+// Clears returns `true` if _v_ is back within the _hysteresis_ of the threshold.
+func (c Condition) Clears(v, hysteresis float64) bool {
+	switch c.Comparison {
+	case ComparisonAbove:
+		return v <= c.Threshold-hysteresis
+	case ComparisonBelow:
+		return v >= c.Threshold+hysteresis
+	}
+
+	return false
+}
+
+// Definition defines a alarm on the reported values.
+type Definition struct {
+	// ID is the unique id of the definition.
+	ID string
+	// Path is a glob (see `pathutils.Glob`) of the paths of the values. Each matching path is a alarm instance.
+	Path string
+	// Condition raises the alarm when it holds.
+	Condition Condition
+	// Hysteresis is how far within the threshold the value must return before the alarm is cleared. This avoids
+	// a flapping alarm when the value is close to the threshold.
+	Hysteresis float64
+	// OnDelay is for how long the condition must hold before the alarm is raised. Since the alarms are fed by
+	// reports, it is raised on the first report after the delay where the condition still holds.
+	OnDelay time.Duration
+	// Message is a human readable message of the alarm.
+	Message string
+}
+
+func (d Definition) validate() error {
+	switch {
+	case d.ID == "":
+		return fmt.Errorf("alarm definition id is required")
+	case d.Path == "":
+		return fmt.Errorf("path is required in alarm definition %s", d.ID)
+	case d.Condition.Comparison != ComparisonAbove && d.Condition.Comparison != ComparisonBelow:
+		return fmt.Errorf("invalid condition in alarm definition %s", d.ID)
+	case d.Hysteresis < 0 || d.OnDelay < 0:
+		return fmt.Errorf("hysteresis and on-delay must not be negative in alarm definition %s", d.ID)
+	}
+
+	return nil
+}
+
+// Transition is a state change of a alarm instance.
+type Transition struct {
+	// ID is the shadow of the alarm.
+	ID persistencemodel.ID
+	// From is the previous state. It is empty when the alarm is raised the first time.
+	From managermodel.AlarmState
+	// Alarm is the alarm instance in its new state.
+	Alarm managermodel.Alarm
+}
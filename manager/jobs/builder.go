@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+type builder struct {
+	r *Runner
+}
+
+// New creates a builder for a `Runner` that applies jobs using _manager_. The jobs are persisted in the
+// _persistence_, it is typically the same persistence as the _manager_ uses.
+func New(manager Manager, persistence persistencemodel.Persistence) *builder {
+	return &builder{
+		r: &Runner{manager: manager, persistence: persistence},
+	}
+}
+
+func (b *builder) Build() *Runner {
+	now := b.r.now
+
+	if now == nil {
+		now = time.Now
+	}
+
+	return &Runner{
+		manager:      b.r.manager,
+		persistence:  b.r.persistence,
+		typeRegistry: b.r.typeRegistry,
+		now:          now,
+	}
+}
+
+// WithTypeRegistry is used to restore the `Template.Model` from its `Template.ModelType` when the persistence
+// do serialize the job.
+func (b *builder) WithTypeRegistry(typeRegistry model.TypeRegistry) *builder {
+	b.r.typeRegistry = typeRegistry
+	return b
+}
+
+// WithClock sets the function that returns the current time. It is used for the rate limit and acknowledge
+// timeouts. Default is `time.Now`.
+func (b *builder) WithClock(now func() time.Time) *builder {
+	b.r.now = now
+	return b
+}
@@ -0,0 +1,388 @@
+package jobs_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/manager/jobs"
+	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+	"github.com/mariotoffia/godeviceshadow/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Sensor struct {
+	Value     any
+	TimeStamp time.Time
+}
+
+func (sp *Sensor) GetTimestamp() time.Time {
+	return sp.TimeStamp
+}
+
+func (sp *Sensor) GetValue() any {
+	return sp.Value
+}
+
+type TestModel struct {
+	Sensors map[string]Sensor
+}
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newManager(persistence persistencemodel.Persistence) *stdmgr.ManagerImpl {
+	return stdmgr.New().
+		WithPersistence(persistence).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithDesiredStatusTracking().
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+}
+
+// report reports the set-point _sp_ and optionally rejects it.
+func report(t *testing.T, mgr *stdmgr.ManagerImpl, id string, sp float64, reject bool) {
+	op := managermodel.ReportOperation{
+		ID:    persistencemodel.ID{ID: id, Name: "homeHub"},
+		Model: TestModel{Sensors: map[string]Sensor{"sp": {Value: sp, TimeStamp: time.Now().UTC()}}},
+	}
+
+	if reject {
+		op.Model = TestModel{Sensors: map[string]Sensor{"temp": {Value: 20.0, TimeStamp: time.Now().UTC()}}}
+		op.RejectedDesires = []model.RejectedDesire{{Path: "Sensors.sp", Code: "out-of-range"}}
+	}
+
+	res := mgr.Report(context.Background(), op)
+
+	require.Len(t, res, 1)
+	require.NoError(t, res[0].Error)
+}
+
+func setup(t *testing.T, devices int) (*stdmgr.ManagerImpl, *mempersistence.Persistence) {
+	persistence := mempersistence.New()
+	mgr := newManager(persistence)
+
+	for i := range devices {
+		report(t, mgr, fmt.Sprintf("device-%02d", i), 18.0, false)
+	}
+
+	report(t, mgr, "gateway-01", 18.0, false) // not selected
+
+	return mgr, persistence
+}
+
+func newJob(id string, rollout jobs.Rollout) jobs.Job {
+	return jobs.Job{
+		ID: id,
+		Template: jobs.Template{
+			ClientID: "operator",
+			Model:    TestModel{Sensors: map[string]Sensor{"sp": {Value: 21.5, TimeStamp: time.Now().UTC()}}},
+		},
+		Selection: jobs.Selection{IDPrefix: "device-", Name: "homeHub"},
+		Rollout:   rollout,
+	}
+}
+
+func TestJobCanaryRateLimitAndPause(t *testing.T) {
+	ctx := context.Background()
+	mgr, persistence := setup(t, 10)
+	clk := &clock{now: time.Now()}
+	runner := jobs.New(mgr, persistence).WithClock(clk.Now).Build()
+
+	job, err := runner.Create(ctx, newJob("setpoint", jobs.Rollout{
+		BatchSize: 4, Interval: time.Minute, CanaryPercent: 10,
+	}))
+
+	require.NoError(t, err)
+	require.Len(t, job.Devices, 10)
+	assert.True(t, job.Devices[0].Canary)
+	assert.False(t, job.Devices[1].Canary)
+
+	_, err = runner.Create(ctx, newJob("setpoint", jobs.Rollout{}))
+	require.Error(t, err)
+
+	// Only the canary is applied
+	job, err = runner.Step(ctx, "setpoint")
+	require.NoError(t, err)
+	assert.Equal(t, 1, job.Counts()[jobs.DeviceApplied])
+
+	// Waits for the canary, even if not rate limited
+	clk.now = clk.now.Add(time.Minute)
+	job, err = runner.Step(ctx, "setpoint")
+	require.NoError(t, err)
+	assert.Equal(t, 1, job.Counts()[jobs.DeviceApplied])
+	assert.Equal(t, 9, job.Counts()[jobs.DevicePending])
+
+	// The canary acknowledges and the first batch is applied
+	report(t, mgr, "device-00", 21.5, false)
+
+	job, err = runner.Step(ctx, "setpoint")
+	require.NoError(t, err)
+	assert.Equal(t, jobs.DeviceAcknowledged, job.Devices[0].State)
+	assert.Equal(t, 4, job.Counts()[jobs.DeviceApplied])
+
+	// Rate limited
+	job, err = runner.Step(ctx, "setpoint")
+	require.NoError(t, err)
+	assert.Equal(t, 5, job.Counts()[jobs.DevicePending])
+
+	// Paused jobs do not progress
+	_, err = runner.Pause(ctx, "setpoint")
+	require.NoError(t, err)
+
+	clk.now = clk.now.Add(time.Minute)
+	job, err = runner.Step(ctx, "setpoint")
+	require.NoError(t, err)
+	assert.Equal(t, jobs.JobPaused, job.Status)
+	assert.Equal(t, 5, job.Counts()[jobs.DevicePending])
+
+	_, err = runner.Resume(ctx, "setpoint")
+	require.NoError(t, err)
+
+	// A new runner continues the job e.g. after a restart
+	runner = jobs.New(mgr, persistence).WithClock(clk.Now).Build()
+
+	for i := range 10 {
+		report(t, mgr, fmt.Sprintf("device-%02d", i), 21.5, false)
+	}
+
+	for range 3 {
+		job, err = runner.Step(ctx, "setpoint")
+		require.NoError(t, err)
+
+		for i := range 10 {
+			report(t, mgr, fmt.Sprintf("device-%02d", i), 21.5, false)
+		}
+
+		clk.now = clk.now.Add(time.Minute)
+	}
+
+	job, err = runner.Get(ctx, "setpoint")
+	require.NoError(t, err)
+	assert.Equal(t, jobs.JobCompleted, job.Status)
+	assert.Equal(t, 10, job.Counts()[jobs.DeviceAcknowledged])
+
+	// Jobs are not listed as shadows
+	res, err := mgr.List(ctx)
+	require.NoError(t, err)
+
+	for _, item := range res.Items {
+		assert.NotEqual(t, managermodel.JobsID, item.ID.ID)
+	}
+}
+
+func TestJobAbortOnRejection(t *testing.T) {
+	ctx := context.Background()
+	mgr, persistence := setup(t, 4)
+	runner := jobs.New(mgr, persistence).Build()
+
+	_, err := runner.Create(ctx, newJob("reject", jobs.Rollout{BatchSize: 2, MaxRejected: 1}))
+	require.NoError(t, err)
+
+	job, err := runner.Step(ctx, "reject")
+	require.NoError(t, err)
+	assert.Equal(t, 2, job.Counts()[jobs.DeviceApplied])
+
+	report(t, mgr, "device-01", 0, true)
+
+	job, err = runner.Step(ctx, "reject")
+	require.NoError(t, err)
+	assert.Equal(t, jobs.JobAborted, job.Status)
+	assert.Equal(t, "1 device(s) rejected", job.Reason)
+	assert.Equal(t, jobs.DeviceRejected, job.Devices[1].State)
+	assert.Equal(t, "Sensors.sp: out-of-range", job.Devices[1].Error)
+	assert.Equal(t, 2, job.Counts()[jobs.DevicePending])
+
+	_, err = runner.Resume(ctx, "reject")
+	require.Error(t, err)
+}
+
+func TestJobAckTimeout(t *testing.T) {
+	ctx := context.Background()
+	mgr, persistence := setup(t, 2)
+	clk := &clock{now: time.Now()}
+	runner := jobs.New(mgr, persistence).WithClock(clk.Now).Build()
+
+	_, err := runner.Create(ctx, newJob("timeout", jobs.Rollout{AckTimeout: time.Minute, MaxTimedOut: 2}))
+	require.NoError(t, err)
+
+	job, err := runner.Step(ctx, "timeout")
+	require.NoError(t, err)
+	assert.Equal(t, 2, job.Counts()[jobs.DeviceApplied])
+
+	report(t, mgr, "device-00", 21.5, false)
+	clk.now = clk.now.Add(time.Minute)
+
+	job, err = runner.Step(ctx, "timeout")
+	require.NoError(t, err)
+	assert.Equal(t, jobs.JobCompleted, job.Status)
+	assert.Equal(t, jobs.DeviceAcknowledged, job.Devices[0].State)
+	assert.Equal(t, jobs.DeviceTimedOut, job.Devices[1].State)
+}
+
+// racing is a manager that steps the job, using another runner, when the first batch is desired.
+type racing struct {
+	*stdmgr.ManagerImpl
+	other   *jobs.Runner
+	jobID   string
+	desired map[persistencemodel.ID]int
+	err     error
+}
+
+func (m *racing) Desire(ctx context.Context, operations ...managermodel.DesireOperation) []managermodel.DesireOperationResult {
+	for _, op := range operations {
+		m.desired[op.ID]++
+	}
+
+	if other := m.other; other != nil {
+		m.other = nil
+		_, m.err = other.Step(ctx, m.jobID)
+	}
+
+	return m.ManagerImpl.Desire(ctx, operations...)
+}
+
+func TestJobBatchIsClaimedBeforeApplied(t *testing.T) {
+	ctx := context.Background()
+	mgr, persistence := setup(t, 4)
+	mgr2 := &racing{ManagerImpl: mgr, jobID: "claim", desired: map[persistencemodel.ID]int{}}
+	runner := jobs.New(mgr2, persistence).Build()
+
+	mgr2.other = jobs.New(mgr2, persistence).Build()
+
+	_, err := runner.Create(ctx, newJob("claim", jobs.Rollout{BatchSize: 2}))
+	require.NoError(t, err)
+
+	_, err = runner.Step(ctx, "claim")
+	require.NoError(t, err)
+	require.NoError(t, mgr2.err)
+
+	// The other runner did apply the next batch, but never the claimed one
+	job, err := runner.Get(ctx, "claim")
+	require.NoError(t, err)
+	assert.Equal(t, 4, job.Counts()[jobs.DeviceApplied])
+	assert.Len(t, mgr2.desired, 4)
+
+	for id, n := range mgr2.desired {
+		assert.Equal(t, 1, n, id.String())
+	}
+}
+
+func TestJobProgressIsPersistedInParts(t *testing.T) {
+	ctx := context.Background()
+	mgr, persistence := setup(t, 0)
+	runner := jobs.New(mgr, persistence).Build()
+
+	for i := range jobs.PartSize*2 + 1 {
+		report(t, mgr, fmt.Sprintf("device-%04d", i), 18.0, false)
+	}
+
+	_, err := runner.Create(ctx, newJob("parts", jobs.Rollout{BatchSize: jobs.PartSize + 10}))
+	require.NoError(t, err)
+
+	job, err := runner.Step(ctx, "parts")
+	require.NoError(t, err)
+	assert.Equal(t, 3, job.Parts)
+	assert.Equal(t, jobs.PartSize+10, job.Counts()[jobs.DeviceApplied])
+
+	// The job itself holds no devices
+	res := persistence.Read(ctx, persistencemodel.ReadOptions{}, persistencemodel.ReadOperation{
+		ID: jobs.PersistenceID("parts"), Model: reflect.TypeOf(jobs.Job{}),
+	})
+
+	require.NoError(t, res[0].Error)
+	assert.Empty(t, res[0].Model.(jobs.Job).Devices)
+
+	for i := range 3 {
+		res = persistence.Read(ctx, persistencemodel.ReadOptions{}, persistencemodel.ReadOperation{
+			ID: jobs.PartPersistenceID("parts", i), Model: reflect.TypeOf(jobs.Part{}),
+		})
+
+		require.NoError(t, res[0].Error)
+		assert.LessOrEqual(t, len(res[0].Model.(jobs.Part).Devices), jobs.PartSize)
+	}
+
+	job, err = runner.Get(ctx, "parts")
+	require.NoError(t, err)
+	require.Len(t, job.Devices, jobs.PartSize*2+1)
+	assert.Equal(t, "device-1000", job.Devices[jobs.PartSize*2].ID.ID)
+	assert.Equal(t, jobs.DeviceApplied, job.Devices[jobs.PartSize+9].State)
+	assert.Equal(t, jobs.DevicePending, job.Devices[jobs.PartSize+10].State)
+}
+
+// counting counts the desired status reads.
+type counting struct {
+	*stdmgr.ManagerImpl
+	reads int
+}
+
+func (m *counting) ReadDesiredStatus(ctx context.Context, id persistencemodel.ID) (managermodel.DesiredStatuses, error) {
+	m.reads++
+	return m.ManagerImpl.ReadDesiredStatus(ctx, id)
+}
+
+func (m *counting) ReadDesiredStatuses(
+	ctx context.Context,
+	ids ...persistencemodel.ID,
+) (map[persistencemodel.ID]managermodel.DesiredStatuses, error) {
+	m.reads++
+	return m.ManagerImpl.ReadDesiredStatuses(ctx, ids...)
+}
+
+func TestJobUnchangedAndBatchedStatusReads(t *testing.T) {
+	ctx := context.Background()
+	mgr, persistence := setup(t, 0)
+	counter := &counting{ManagerImpl: mgr}
+	runner := jobs.New(counter, persistence).Build()
+	job := newJob("unchanged", jobs.Rollout{BatchSize: jobs.PartSize + 1})
+
+	for i := range jobs.PartSize + 1 {
+		report(t, mgr, fmt.Sprintf("device-%04d", i), 18.0, false)
+	}
+
+	// The template is already desired on the first device
+	res := mgr.Desire(ctx, job.Template.Operation(persistencemodel.ID{ID: "device-0000", Name: "homeHub"}))
+	require.NoError(t, res[0].Error)
+	require.True(t, res[0].Processed)
+
+	_, err := runner.Create(ctx, job)
+	require.NoError(t, err)
+
+	job, err = runner.Step(ctx, "unchanged")
+	require.NoError(t, err)
+	assert.Equal(t, jobs.DeviceUnchanged, job.Devices[0].State)
+	assert.Equal(t, jobs.PartSize, job.Counts()[jobs.DeviceApplied])
+
+	// One read per part with applied devices
+	for i := range jobs.PartSize + 1 {
+		report(t, mgr, fmt.Sprintf("device-%04d", i), 21.5, false)
+	}
+
+	counter.reads = 0
+
+	job, err = runner.Step(ctx, "unchanged")
+	require.NoError(t, err)
+	assert.Equal(t, 2, counter.reads)
+	assert.Equal(t, jobs.JobCompleted, job.Status)
+	assert.Equal(t, jobs.PartSize, job.Counts()[jobs.DeviceAcknowledged])
+	assert.Equal(t, 1, job.Counts()[jobs.DeviceUnchanged])
+}
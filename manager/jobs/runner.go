@@ -0,0 +1,467 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// Manager is the manager that a `Runner` applies the jobs through. It must have the desired status tracking
+// enabled since the acknowledgements are read from the desired status section.
+type Manager interface {
+	managermodel.Desireable
+	managermodel.Lister
+	managermodel.DesiredStatusReceiver
+}
+
+// Runner creates and steps jobs. All state is persisted after each operation and hence, a new `Runner` may
+// continue the job, e.g. after a restart.
+type Runner struct {
+	manager      Manager
+	persistence  persistencemodel.Persistence
+	typeRegistry model.TypeRegistry
+	now          func() time.Time
+}
+
+// DefaultBatchSize is used when `Rollout.BatchSize` is not set.
+const DefaultBatchSize = 100
+
+// Create selects all shadows using `Job.Selection` and persists the job as running. It fails with 409 if the
+// job already exists.
+func (r *Runner) Create(ctx context.Context, job Job) (Job, error) {
+	if job.ID == "" {
+		return Job{}, persistencemodel.Error400("job id is required")
+	}
+
+	if job.Template.Model == nil {
+		return Job{}, persistencemodel.Error400("job template model is required")
+	}
+
+	if _, _, err := r.readJob(ctx, job.ID); err == nil {
+		return Job{}, persistencemodel.Error409(fmt.Sprintf("job %s already exists", job.ID))
+	} else if !isNotFound(err) {
+		return Job{}, err
+	}
+
+	ids, err := r.selectShadows(ctx, job.Selection)
+
+	if err != nil {
+		return Job{}, err
+	}
+
+	canaries := 0
+
+	if job.Rollout.CanaryPercent > 0 {
+		canaries = (len(ids)*job.Rollout.CanaryPercent + 99) / 100
+	}
+
+	now := r.now().UTC().UnixNano()
+
+	job.Status = JobRunning
+	job.Reason = ""
+	job.LastBatchAt = 0
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	job.Devices = make([]DeviceProgress, len(ids))
+
+	for i, id := range ids {
+		job.Devices[i] = DeviceProgress{ID: id, State: DevicePending, Canary: i < canaries}
+	}
+
+	job.Parts = job.parts()
+
+	if len(ids) == 0 {
+		job.Status = JobCompleted
+	}
+
+	// The parts are written first, hence a persisted job always has all of its parts
+	if err := r.writeParts(ctx, job, nil, make([]int64, job.Parts)); err != nil {
+		return Job{}, err
+	}
+
+	if _, err := r.write(ctx, job, 0); err != nil {
+		return Job{}, err
+	}
+
+	return job, nil
+}
+
+// Get reads the job. If not found, a 404 error is returned.
+func (r *Runner) Get(ctx context.Context, jobID string) (Job, error) {
+	job, _, _, err := r.read(ctx, jobID)
+
+	return job, err
+}
+
+// Pause pauses a running job. A paused job is not progressed by `Step`.
+func (r *Runner) Pause(ctx context.Context, jobID string) (Job, error) {
+	return r.update(ctx, jobID, func(job *Job) (bool, error) {
+		if job.Status != JobRunning {
+			return false, persistencemodel.Error409(fmt.Sprintf("job %s is %s and cannot be paused", job.ID, job.Status))
+		}
+
+		job.Status = JobPaused
+
+		return true, nil
+	})
+}
+
+// Resume resumes a paused job.
+func (r *Runner) Resume(ctx context.Context, jobID string) (Job, error) {
+	return r.update(ctx, jobID, func(job *Job) (bool, error) {
+		if job.Status != JobPaused {
+			return false, persistencemodel.Error409(fmt.Sprintf("job %s is %s and cannot be resumed", job.ID, job.Status))
+		}
+
+		job.Status = JobRunning
+
+		return true, nil
+	})
+}
+
+// Abort aborts the job with the _reason_. Shadows that already got the template are not reverted.
+func (r *Runner) Abort(ctx context.Context, jobID, reason string) (Job, error) {
+	return r.update(ctx, jobID, func(job *Job) (bool, error) {
+		if job.Status.Final() {
+			return false, persistencemodel.Error409(fmt.Sprintf("job %s is %s and cannot be aborted", job.ID, job.Status))
+		}
+
+		job.Status = JobAborted
+		job.Reason = reason
+
+		return true, nil
+	})
+}
+
+// Step progresses a running job one step. It will:
+//
+//  1. Refresh the state of each applied shadow from its desired status.
+//  2. Abort the job if any of the `Rollout` thresholds is reached.
+//  3. Apply the template on the next batch of pending shadows, unless rate limited or waiting for the canaries.
+//  4. Complete the job when all shadows are done.
+//
+// The batch is claimed, by writing the job and the changed parts with their read versions, before the template is
+// applied. Hence, when the job is stepped by several `Runner`s, only one applies the batch and the others fail
+// with 409. A job that is not running is returned as is.
+func (r *Runner) Step(ctx context.Context, jobID string) (Job, error) {
+	job, version, partVersions, err := r.read(ctx, jobID)
+
+	if err != nil || job.Status != JobRunning {
+		return job, err
+	}
+
+	read := slices.Clone(job.Devices)
+	batch, changed, err := r.step(ctx, &job)
+
+	if err != nil || !changed {
+		return job, err
+	}
+
+	job.UpdatedAt = r.now().UTC().UnixNano()
+
+	if version, err = r.write(ctx, job, version); err != nil {
+		return job, err
+	}
+
+	if err := r.writeParts(ctx, job, read, partVersions); err != nil {
+		return job, err
+	}
+
+	if len(batch) == 0 {
+		return job, nil
+	}
+
+	claimed := slices.Clone(job.Devices)
+
+	r.apply(ctx, &job, batch)
+
+	if err := r.writeParts(ctx, job, claimed, partVersions); err != nil {
+		return job, err
+	}
+
+	if !job.done() {
+		return job, nil
+	}
+
+	job.Status = JobCompleted
+	_, err = r.write(ctx, job, version)
+
+	return job, err
+}
+
+// Run steps the job every _interval_ until it is completed, aborted or the _ctx_ is done. A paused job is
+// still polled so it continues when resumed.
+func (r *Runner) Run(ctx context.Context, jobID string, interval time.Duration) (Job, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		job, err := r.Step(ctx, jobID)
+
+		if err != nil || job.Status.Final() {
+			return job, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// update reads the job, invokes _f_ and writes the job back if _f_ did change it. The write fails with 409 if
+// the job was updated in between, e.g. by another `Runner`. The parts are read but never written.
+func (r *Runner) update(ctx context.Context, jobID string, f func(job *Job) (bool, error)) (Job, error) {
+	job, version, _, err := r.read(ctx, jobID)
+
+	if err != nil {
+		return Job{}, err
+	}
+
+	if changed, err := f(&job); err != nil || !changed {
+		return job, err
+	}
+
+	job.UpdatedAt = r.now().UTC().UnixNano()
+
+	_, err = r.write(ctx, job, version)
+
+	return job, err
+}
+
+// selectShadows lists all shadows that matches the _sel_. Each shadow is returned once, sorted by id.
+func (r *Runner) selectShadows(ctx context.Context, sel Selection) ([]persistencemodel.ID, error) {
+	seen := map[persistencemodel.ID]bool{}
+	opt := managermodel.ListOptions{ID: sel.ID, SearchExpr: sel.SearchExpr}
+
+	for {
+		res, err := r.manager.List(ctx, opt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range res.Items {
+			id := item.ID.ToID()
+
+			if id.ID == managermodel.JobsID ||
+				(sel.IDPrefix != "" && !strings.HasPrefix(id.ID, sel.IDPrefix)) ||
+				(sel.Name != "" && id.Name != sel.Name) {
+				continue
+			}
+
+			seen[id] = true
+		}
+
+		if res.Token == "" {
+			break
+		}
+
+		opt.Token = res.Token
+	}
+
+	ids := make([]persistencemodel.ID, 0, len(seen))
+
+	for id := range seen {
+		ids = append(ids, id)
+	}
+
+	slices.SortFunc(ids, func(a, b persistencemodel.ID) int {
+		return strings.Compare(a.String(), b.String())
+	})
+
+	return ids, nil
+}
+
+// read reads the job and all of its parts into `Job.Devices`. The versions of the parts are returned in part order.
+func (r *Runner) read(ctx context.Context, jobID string) (Job, int64, []int64, error) {
+	job, version, err := r.readJob(ctx, jobID)
+
+	if err != nil {
+		return Job{}, 0, nil, err
+	}
+
+	ops := make([]persistencemodel.ReadOperation, job.Parts)
+	index := make(map[string]int, job.Parts)
+
+	for i := range ops {
+		ops[i] = persistencemodel.ReadOperation{ID: PartPersistenceID(job.ID, i), Model: reflect.TypeOf(Part{})}
+		index[ops[i].ID.Name] = i
+	}
+
+	parts := make([]Part, job.Parts)
+	versions := make([]int64, job.Parts)
+
+	for _, res := range r.persistence.Read(ctx, persistencemodel.ReadOptions{}, ops...) {
+		if res.Error != nil {
+			return Job{}, 0, nil, res.Error
+		}
+
+		i, ok := index[res.ID.Name]
+
+		if !ok {
+			continue
+		}
+
+		switch m := res.Model.(type) {
+		case Part:
+			parts[i] = m
+		case *Part:
+			parts[i] = *m
+		default:
+			return Job{}, 0, nil, persistencemodel.Error500(fmt.Sprintf("job %s part %d has unexpected type %T", jobID, i, m))
+		}
+
+		versions[i] = res.Version
+	}
+
+	job.Devices = make([]DeviceProgress, 0, job.Parts*PartSize)
+
+	for i, part := range parts {
+		if versions[i] == 0 {
+			return Job{}, 0, nil, persistencemodel.Error500(fmt.Sprintf("job %s part %d is missing", jobID, i))
+		}
+
+		// Never modify the instance that the persistence may hold on to
+		job.Devices = append(job.Devices, part.Devices...)
+	}
+
+	return job, version, versions, nil
+}
+
+// readJob reads the job without its parts.
+func (r *Runner) readJob(ctx context.Context, jobID string) (Job, int64, error) {
+	res := r.persistence.Read(ctx, persistencemodel.ReadOptions{}, persistencemodel.ReadOperation{
+		ID:    PersistenceID(jobID),
+		Model: reflect.TypeOf(Job{}),
+	})
+
+	if len(res) == 0 {
+		return Job{}, 0, persistencemodel.Error404(fmt.Sprintf("job %s not found", jobID))
+	}
+
+	if res[0].Error != nil {
+		return Job{}, 0, res[0].Error
+	}
+
+	var job Job
+
+	switch m := res[0].Model.(type) {
+	case Job:
+		job = m
+	case *Job:
+		job = *m
+	default:
+		return Job{}, 0, persistencemodel.Error500(fmt.Sprintf("job %s has unexpected type %T", jobID, m))
+	}
+
+	job.Devices = nil
+
+	if err := r.restoreTemplate(&job.Template); err != nil {
+		return Job{}, 0, err
+	}
+
+	return job, res[0].Version, nil
+}
+
+// write writes the job, without the devices, and returns the new version.
+func (r *Runner) write(ctx context.Context, job Job, version int64) (int64, error) {
+	job.Devices = nil
+
+	res := r.persistence.Write(ctx, persistencemodel.WriteOptions{
+		Config: persistencemodel.WriteConfig{Separation: persistencemodel.SeparateModels},
+	}, persistencemodel.WriteOperation{
+		ClientID: job.Template.ClientID,
+		ID:       PersistenceID(job.ID),
+		Model:    job,
+		Version:  version,
+		Config:   persistencemodel.WriteOperationConfig{Separation: persistencemodel.SeparateModels},
+	})
+
+	if len(res) == 0 {
+		return 0, nil
+	}
+
+	return res[0].Version, res[0].Error
+}
+
+// writeParts writes, in part order, each part where the `Job.Devices` differs from _previous_ (all when nil). The
+// _versions_ are the read versions of the parts and are updated with the written versions. It stops at the first
+// failed write, e.g. a 409 when another `Runner` did write the part in between.
+func (r *Runner) writeParts(ctx context.Context, job Job, previous []DeviceProgress, versions []int64) error {
+	for i := range job.Parts {
+		devices := job.part(i)
+
+		if previous != nil && slices.Equal(devices, previous[i*PartSize:i*PartSize+len(devices)]) {
+			continue
+		}
+
+		res := r.persistence.Write(ctx, persistencemodel.WriteOptions{
+			Config: persistencemodel.WriteConfig{Separation: persistencemodel.SeparateModels},
+		}, persistencemodel.WriteOperation{
+			ClientID: job.Template.ClientID,
+			ID:       PartPersistenceID(job.ID, i),
+			Model:    Part{Devices: slices.Clone(devices)},
+			Version:  versions[i],
+			Config:   persistencemodel.WriteOperationConfig{Separation: persistencemodel.SeparateModels},
+		})
+
+		if len(res) == 0 {
+			continue
+		}
+
+		if res[0].Error != nil {
+			return res[0].Error
+		}
+
+		versions[i] = res[0].Version
+	}
+
+	return nil
+}
+
+// restoreTemplate converts the template model into the registered `Template.ModelType` when it was
+// deserialized into another type, e.g. a `map[string]any`.
+func (r *Runner) restoreTemplate(t *Template) error {
+	if r.typeRegistry == nil || t.ModelType == "" || t.Model == nil {
+		return nil
+	}
+
+	entry, ok := r.typeRegistry.Get(t.ModelType)
+
+	if !ok || reflect.TypeOf(t.Model) == entry.Model {
+		return nil
+	}
+
+	data, err := json.Marshal(t.Model)
+
+	if err != nil {
+		return persistencemodel.Error500(fmt.Sprintf("failed to restore template model %s: %s", t.ModelType, err))
+	}
+
+	v := reflect.New(entry.Model)
+
+	if err := json.Unmarshal(data, v.Interface()); err != nil {
+		return persistencemodel.Error500(fmt.Sprintf("failed to restore template model %s: %s", t.ModelType, err))
+	}
+
+	t.Model = v.Elem().Interface()
+
+	return nil
+}
+
+func isNotFound(err error) bool {
+	var pe persistencemodel.PersistenceError
+
+	return errors.As(err, &pe) && pe.Code == 404
+}
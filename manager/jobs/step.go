@@ -0,0 +1,226 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
+)
+
+// step progresses the _job_ and returns the claimed batch, i.e. the indices of the shadows that shall get the
+// template, and `true` if the job was changed. The claimed shadows are set as applied.
+func (r *Runner) step(ctx context.Context, job *Job) ([]int, bool, error) {
+	now := r.now().UTC().UnixNano()
+
+	changed, err := r.refresh(ctx, job, now)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if reason := job.threshold(); reason != "" {
+		job.Status = JobAborted
+		job.Reason = reason
+
+		return nil, true, nil
+	}
+
+	var batch []int
+
+	if job.Rollout.Interval <= 0 || job.LastBatchAt == 0 || now-job.LastBatchAt >= int64(job.Rollout.Interval) {
+		if batch = job.nextBatch(); len(batch) > 0 {
+			for _, idx := range batch {
+				d := &job.Devices[idx]
+				d.State, d.AppliedAt, d.UpdatedAt = DeviceApplied, now, now
+			}
+
+			job.LastBatchAt = now
+			changed = true
+		}
+	}
+
+	if job.done() {
+		job.Status = JobCompleted
+		changed = true
+	}
+
+	return batch, changed, nil
+}
+
+// done returns `true` if all devices are in a final state.
+func (j *Job) done() bool {
+	return !slices.ContainsFunc(j.Devices, func(d DeviceProgress) bool { return !d.State.Final() })
+}
+
+// refresh updates all applied shadows from their desired status. The statuses are read using one read per part,
+// only for the shadows that are applied. It returns `true` if any was changed.
+func (r *Runner) refresh(ctx context.Context, job *Job, now int64) (bool, error) {
+	var changed bool
+
+	paths, err := r.templatePaths(ctx, job.Template)
+
+	if err != nil {
+		return false, err
+	}
+
+	for start := 0; start < len(job.Devices); start += PartSize {
+		part := job.Devices[start:min(start+PartSize, len(job.Devices))]
+
+		var ids []persistencemodel.ID
+
+		for _, d := range part {
+			if d.State == DeviceApplied {
+				ids = append(ids, d.ID)
+			}
+		}
+
+		if len(ids) == 0 {
+			continue
+		}
+
+		statuses, err := r.manager.ReadDesiredStatuses(ctx, ids...)
+
+		if err != nil {
+			return changed, err
+		}
+
+		for i := range part {
+			d := &part[i]
+
+			if d.State != DeviceApplied {
+				continue
+			}
+
+			state, reason := evaluate(statuses[d.ID], paths)
+
+			if state == DeviceApplied && job.Rollout.AckTimeout > 0 && now-d.AppliedAt >= int64(job.Rollout.AckTimeout) {
+				state, reason = DeviceTimedOut, fmt.Sprintf("not acknowledged within %s", job.Rollout.AckTimeout)
+			}
+
+			if state != DeviceApplied {
+				d.State, d.Error, d.UpdatedAt = state, reason, now
+				changed = true
+			}
+		}
+	}
+
+	return changed, nil
+}
+
+// evaluate returns the state of a shadow based on the desired status of all template _paths_.
+func evaluate(statuses managermodel.DesiredStatuses, paths []string) (DeviceState, string) {
+	acknowledged := 0
+
+	for _, path := range paths {
+		s, ok := statuses.Entries[path]
+
+		if !ok {
+			continue
+		}
+
+		switch s.State {
+		case managermodel.DesiredStateRejected:
+			reason := "rejected"
+
+			if s.Rejection != nil {
+				reason = fmt.Sprintf("%s: %s", path, s.Rejection.Code)
+			}
+
+			return DeviceRejected, reason
+		case managermodel.DesiredStateExpired:
+			return DeviceTimedOut, fmt.Sprintf("%s: expired", path)
+		case managermodel.DesiredStateAcknowledged:
+			acknowledged++
+		}
+	}
+
+	if acknowledged == len(paths) {
+		return DeviceAcknowledged, ""
+	}
+
+	return DeviceApplied, ""
+}
+
+// templatePaths returns the paths of all values in the template, those are the keys in the desired status.
+func (r *Runner) templatePaths(ctx context.Context, t Template) ([]string, error) {
+	values, err := merge.Values(ctx, t.Model, pathutils.StyleDotted)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.Sorted(maps.Keys(values)), nil
+}
+
+// threshold returns a reason if any of the abort thresholds are reached.
+func (j *Job) threshold() string {
+	counts := j.Counts()
+
+	switch {
+	case j.Rollout.MaxRejected > 0 && counts[DeviceRejected] >= j.Rollout.MaxRejected:
+		return fmt.Sprintf("%d device(s) rejected", counts[DeviceRejected])
+	case j.Rollout.MaxTimedOut > 0 && counts[DeviceTimedOut] >= j.Rollout.MaxTimedOut:
+		return fmt.Sprintf("%d device(s) timed out", counts[DeviceTimedOut])
+	case j.Rollout.MaxFailed > 0 && counts[DeviceFailed] >= j.Rollout.MaxFailed:
+		return fmt.Sprintf("%d device(s) failed", counts[DeviceFailed])
+	}
+
+	return ""
+}
+
+// nextBatch returns the indices of the pending shadows to apply next. While any canary is not done, only
+// canaries are selected.
+func (j *Job) nextBatch() []int {
+	size := j.Rollout.BatchSize
+
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+
+	canary := slices.ContainsFunc(j.Devices, func(d DeviceProgress) bool { return d.Canary && !d.State.Final() })
+
+	var batch []int
+
+	for i, d := range j.Devices {
+		if len(batch) == size {
+			break
+		}
+
+		if d.State == DevicePending && (!canary || d.Canary) {
+			batch = append(batch, i)
+		}
+	}
+
+	return batch
+}
+
+// apply desires the template on the claimed shadows in the _batch_. Those that could not be desired are set
+// as failed and those where the template was already desired, i.e. nothing was written, as unchanged.
+func (r *Runner) apply(ctx context.Context, job *Job, batch []int) {
+	ops := make([]managermodel.DesireOperation, len(batch))
+
+	for i, idx := range batch {
+		ops[i] = job.Template.Operation(job.Devices[idx].ID)
+	}
+
+	res := r.manager.Desire(ctx, ops...)
+
+	for i, idx := range batch {
+		d := &job.Devices[idx]
+
+		switch {
+		case i >= len(res):
+			d.State, d.Error = DeviceFailed, persistencemodel.Error500("no result from desire").Error()
+		case res[i].Error != nil:
+			d.State, d.Error = DeviceFailed, res[i].Error.Error()
+		case !res[i].Processed:
+			// No desired status is written when not changed, hence it would never be acknowledged
+			d.State = DeviceUnchanged
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package jobs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/copyutils"
+)
+
+// JobStatus is the status of a `Job`.
+type JobStatus string
+
+const (
+	JobRunning   JobStatus = "running"
+	JobPaused    JobStatus = "paused"
+	JobAborted   JobStatus = "aborted"
+	JobCompleted JobStatus = "completed"
+)
+
+// Final returns `true` if the job will not progress any more.
+func (s JobStatus) Final() bool {
+	return s == JobAborted || s == JobCompleted
+}
+
+// DeviceState is the progress of a single shadow in a `Job`.
+type DeviceState string
+
+const (
+	// DevicePending is when the desired template has not yet been applied.
+	DevicePending DeviceState = "pending"
+	// DeviceApplied is when the desired template was applied and it waits for the device to acknowledge.
+	DeviceApplied DeviceState = "applied"
+	// DeviceAcknowledged is when the device did acknowledge all desired values.
+	DeviceAcknowledged DeviceState = "acknowledged"
+	// DeviceRejected is when the device did reject, or let expire, any of the desired values.
+	DeviceRejected DeviceState = "rejected"
+	// DeviceTimedOut is when the device did not acknowledge within `Rollout.AckTimeout`.
+	DeviceTimedOut DeviceState = "timed-out"
+	// DeviceFailed is when the desired template could not be applied.
+	DeviceFailed DeviceState = "failed"
+	// DeviceUnchanged is when the desired template was already desired and hence nothing was applied.
+	DeviceUnchanged DeviceState = "unchanged"
+)
+
+// Final returns `true` if the device will not progress any more.
+func (s DeviceState) Final() bool {
+	return s != DevicePending && s != DeviceApplied
+}
+
+// Selection selects the shadows of a job using `managermodel.Lister.List`.
+type Selection struct {
+	// ID is passed as `managermodel.ListOptions.ID` to only list the models under the id.
+	ID string `json:"id,omitempty"`
+	// IDPrefix when set, only ids with the prefix are selected.
+	IDPrefix string `json:"id_prefix,omitempty"`
+	// Name when set, only models with the name are selected.
+	Name string `json:"name,omitempty"`
+	// SearchExpr is passed as `managermodel.ListOptions.SearchExpr`. It may not be supported by the persistence.
+	SearchExpr string `json:"search_expr,omitempty"`
+}
+
+// Rollout controls how fast and how safe the desired template is applied.
+type Rollout struct {
+	// BatchSize is the max number of shadows that is applied in one step. Default is 100.
+	BatchSize int `json:"batch_size,omitempty"`
+	// Interval is the minimum time between two batches, i.e. the rate limit.
+	Interval time.Duration `json:"interval,omitempty"`
+	// CanaryPercent when set, only this percentage (rounded up) of the shadows are applied first. The rest is
+	// applied when all canaries has acknowledged, rejected or timed out without the job being aborted.
+	CanaryPercent int `json:"canary_percent,omitempty"`
+	// AckTimeout is the time a device has to acknowledge before it is `DeviceTimedOut`. If _zero_ it waits forever.
+	AckTimeout time.Duration `json:"ack_timeout,omitempty"`
+	// MaxRejected when set, aborts the job when this number of devices has rejected.
+	MaxRejected int `json:"max_rejected,omitempty"`
+	// MaxTimedOut when set, aborts the job when this number of devices has timed out.
+	MaxTimedOut int `json:"max_timed_out,omitempty"`
+	// MaxFailed when set, aborts the job when this number of devices could not be applied.
+	MaxFailed int `json:"max_failed,omitempty"`
+}
+
+// Template is the desire operation that is applied on each selected shadow. It is persisted with the job and
+// hence only the serializable parts of a `managermodel.DesireOperation` is kept.
+type Template struct {
+	// ClientID is a optional client ID used in the desire operations.
+	ClientID string `json:"client_id,omitempty"`
+	// ModelType is the registered name of the model. It is used to restore the `Model` when the job is read from
+	// a persistence that serializes the model. It is also passed as `managermodel.DesireOperation.ModelType`.
+	ModelType string `json:"model_type,omitempty"`
+	// Model is the desired model to merge into each shadow.
+	Model any `json:"model"`
+	// MergeMode is the merge mode to use. Default is `merge.ServerIsMaster`.
+	MergeMode merge.MergeMode `json:"merge_mode,omitempty"`
+	// Expiry is when set, the time the desired values may stay pending or delivered before expired.
+	Expiry time.Duration `json:"expiry,omitempty"`
+}
+
+// Operation creates the desire operation for the shadow _id_ with a copy of the `Model`.
+func (t Template) Operation(id persistencemodel.ID) managermodel.DesireOperation {
+	return managermodel.DesireOperation{
+		ClientID:  t.ClientID,
+		ID:        id,
+		ModelType: t.ModelType,
+		Model:     copyutils.DeepCopy(t.Model),
+		MergeMode: t.MergeMode,
+		Expiry:    t.Expiry,
+	}
+}
+
+// DeviceProgress is the progress of a single shadow.
+type DeviceProgress struct {
+	// ID is the id of the shadow.
+	ID persistencemodel.ID `json:"id"`
+	// State is the current state of the shadow in the job.
+	State DeviceState `json:"state"`
+	// Canary is set when the shadow is part of the canary.
+	Canary bool `json:"canary,omitempty"`
+	// AppliedAt is when the template was applied. It is a Unix64 bit _UTC_ nanosecond timestamp.
+	AppliedAt int64 `json:"applied_at,omitempty"`
+	// UpdatedAt is when the state was last changed. It is a Unix64 bit _UTC_ nanosecond timestamp.
+	UpdatedAt int64 `json:"updated_at,omitempty"`
+	// Error is set when the state is `DeviceFailed`, `DeviceRejected` or `DeviceTimedOut`.
+	Error string `json:"error,omitempty"`
+}
+
+// Job applies a desired template to all shadows selected.
+type Job struct {
+	// ID is the unique id of the job.
+	ID string `json:"id"`
+	// Template is applied on each selected shadow.
+	Template Template `json:"template"`
+	// Selection selects the shadows.
+	Selection Selection `json:"selection"`
+	// Rollout controls the rollout.
+	Rollout Rollout `json:"rollout"`
+	// Status is the status of the job.
+	Status JobStatus `json:"status"`
+	// Reason is why the job was aborted.
+	Reason string `json:"reason,omitempty"`
+	// Devices is the progress of each selected shadow. It is persisted apart from the job in `Parts` number of
+	// `Part`s to keep each persisted item small, regardless of the number of shadows.
+	Devices []DeviceProgress `json:"-"`
+	// Parts is the number of persisted `Part`s of the `Devices`.
+	Parts int `json:"parts"`
+	// LastBatchAt is when the last batch was applied. It is a Unix64 bit _UTC_ nanosecond timestamp.
+	LastBatchAt int64 `json:"last_batch_at,omitempty"`
+	// CreatedAt is when the job was created.
+	CreatedAt int64 `json:"created_at"`
+	// UpdatedAt is when the job was last updated.
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+// Counts returns the number of devices in each state.
+func (j *Job) Counts() map[DeviceState]int {
+	counts := map[DeviceState]int{}
+
+	for _, d := range j.Devices {
+		counts[d.State]++
+	}
+
+	return counts
+}
+
+// PartSize is the max number of devices in a `Part`. It keeps each persisted part well below the item size
+// limit of the persistence, e.g. 400 KB in DynamoDB.
+const PartSize = 500
+
+// Part is a slice of at most `PartSize` of the `Job.Devices`. The part with index _n_ holds the devices from
+// index _n_ * `PartSize`.
+type Part struct {
+	// Devices is the progress of the shadows in the part.
+	Devices []DeviceProgress `json:"devices"`
+}
+
+// parts returns the number of parts for the `Devices`.
+func (j *Job) parts() int {
+	return (len(j.Devices) + PartSize - 1) / PartSize
+}
+
+// part returns the devices in the part with _index_.
+func (j *Job) part(index int) []DeviceProgress {
+	return j.Devices[index*PartSize : min((index+1)*PartSize, len(j.Devices))]
+}
+
+// PersistenceID returns the id where the job with _jobID_ is persisted.
+func PersistenceID(jobID string) persistencemodel.PersistenceID {
+	return persistencemodel.PersistenceID{
+		ID:        managermodel.JobsID,
+		Name:      jobID + managermodel.JobNameSuffix,
+		ModelType: persistencemodel.ModelTypeDesired,
+	}
+}
+
+// PartPersistenceID returns the id where the part with _index_ of the job with _jobID_ is persisted.
+func PartPersistenceID(jobID string, index int) persistencemodel.PersistenceID {
+	return persistencemodel.PersistenceID{
+		ID:        managermodel.JobsID,
+		Name:      fmt.Sprintf("%s:%d%s", jobID, index, managermodel.JobPartNameSuffix),
+		ModelType: persistencemodel.ModelTypeDesired,
+	}
+}
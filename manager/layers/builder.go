@@ -0,0 +1,67 @@
+package layers
+
+import (
+	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+type builder struct {
+	l *Layers
+}
+
+// New creates a builder for `Layers` that writes the effective desired models using _manager_. The layers are
+// persisted in the _persistence_, it is typically the same persistence as the _manager_ uses.
+func New(manager Manager, persistence persistencemodel.Persistence) *builder {
+	return &builder{
+		l: &Layers{manager: manager, persistence: persistence},
+	}
+}
+
+func (b *builder) Build() *Layers {
+	clientID := b.l.clientID
+
+	if clientID == "" {
+		clientID = DefaultClientID
+	}
+
+	batchSize := b.l.batchSize
+
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	return &Layers{
+		manager:     b.l.manager,
+		persistence: b.l.persistence,
+		groups:      b.l.groups,
+		clientID:    clientID,
+		mergeMode:   b.l.mergeMode,
+		batchSize:   batchSize,
+	}
+}
+
+// WithGroups sets the resolver of the groups of a shadow. Without it, no group layers are applied.
+func (b *builder) WithGroups(groups GroupResolver) *builder {
+	b.l.groups = groups
+	return b
+}
+
+// WithClientID sets the client id that the layers and effective desired models are written with. Default is
+// `DefaultClientID`.
+func (b *builder) WithClientID(clientID string) *builder {
+	b.l.clientID = clientID
+	return b
+}
+
+// WithMergeMode sets how the effective desired model is merged into the desired model of the shadow. Default is
+// `merge.ServerIsMaster`.
+func (b *builder) WithMergeMode(mode merge.MergeMode) *builder {
+	b.l.mergeMode = mode
+	return b
+}
+
+// WithBatchSize sets the max number of shadows that is desired in one call. Default is `DefaultBatchSize`.
+func (b *builder) WithBatchSize(size int) *builder {
+	b.l.batchSize = size
+	return b
+}
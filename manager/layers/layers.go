@@ -0,0 +1,348 @@
+package layers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// Manager is the manager that the effective desired models are written through.
+type Manager interface {
+	managermodel.Desireable
+	managermodel.Lister
+	// ResolveType resolves the model type of a shadow, e.g. `stdmgr.ManagerImpl.ResolveType`.
+	ResolveType(name string, id ...persistencemodel.ID) (model.TypeEntry, bool)
+}
+
+// Layers keeps the fleet, group and device layers and writes the effective desired model of each shadow when any
+// of its layers changes.
+type Layers struct {
+	manager     Manager
+	persistence persistencemodel.Persistence
+	groups      GroupResolver
+	clientID    string
+	mergeMode   merge.MergeMode
+	batchSize   int
+}
+
+// DefaultClientID is the client id the effective desired models are written with when none is set.
+const DefaultClientID = "layers"
+
+// DefaultBatchSize is the max number of shadows that is desired in one `Manager.Desire` call.
+const DefaultBatchSize = 100
+
+// Set writes the _model_ of the _layer_ and then the effective desired model of all shadows that the layer
+// applies to. The layer model replaces any previous model of the layer.
+//
+// The error is only set when the layer could not be written, the result of each shadow is in the results.
+func (l *Layers) Set(ctx context.Context, layer Layer, model any) ([]managermodel.DesireOperationResult, error) {
+	if err := layer.validate(); err != nil {
+		return nil, err
+	}
+
+	if model == nil {
+		return nil, persistencemodel.Error400("layer model is required")
+	}
+
+	res := l.persistence.Write(ctx, persistencemodel.WriteOptions{
+		Config: persistencemodel.WriteConfig{Separation: persistencemodel.SeparateModels},
+	}, persistencemodel.WriteOperation{
+		ClientID: l.clientID,
+		ID:       layer.PersistenceID(),
+		Model:    model,
+		Config:   persistencemodel.WriteOperationConfig{Separation: persistencemodel.SeparateModels},
+	})
+
+	if len(res) > 0 && res[0].Error != nil {
+		return nil, res[0].Error
+	}
+
+	return l.applyLayer(ctx, layer)
+}
+
+// Get reads the model of the _layer_. If not found, a 404 error is returned.
+func (l *Layers) Get(ctx context.Context, layer Layer) (any, error) {
+	if err := layer.validate(); err != nil {
+		return nil, err
+	}
+
+	te, ok := l.manager.ResolveType("", persistencemodel.ID{Name: layer.Name})
+
+	if !ok {
+		return nil, persistencemodel.Error400(fmt.Sprintf("could not resolve model for name: %s", layer.Name))
+	}
+
+	res := l.persistence.Read(ctx, persistencemodel.ReadOptions{}, persistencemodel.ReadOperation{
+		ID:     layer.PersistenceID(),
+		Model:  te.Model,
+		Schema: te.Schema,
+	})
+
+	if len(res) == 0 {
+		return nil, persistencemodel.Error404(fmt.Sprintf("layer %s not found", layer.PersistenceID().Name))
+	}
+
+	return res[0].Model, res[0].Error
+}
+
+// Remove deletes the _layer_ and writes the effective desired model of all shadows that the layer applied to.
+//
+// NOTE: When the merge mode is `merge.ServerIsMaster`, values that only the removed layer did set are kept in the
+// desired models until acknowledged. Use `merge.ClientIsMaster` to remove them as well.
+func (l *Layers) Remove(ctx context.Context, layer Layer) ([]managermodel.DesireOperationResult, error) {
+	if err := layer.validate(); err != nil {
+		return nil, err
+	}
+
+	res := l.persistence.Delete(ctx, persistencemodel.WriteOptions{
+		Config: persistencemodel.WriteConfig{Separation: persistencemodel.SeparateModels},
+	}, persistencemodel.WriteOperation{
+		ID:     layer.PersistenceID(),
+		Config: persistencemodel.WriteOperationConfig{Separation: persistencemodel.SeparateModels},
+	})
+
+	if len(res) > 0 && res[0].Error != nil {
+		return nil, res[0].Error
+	}
+
+	return l.applyLayer(ctx, layer)
+}
+
+// Effective computes the effective desired model of the shadow _id_ by merging the fleet, group and device layers
+// in that order. A higher layer has precedence regardless of the timestamps of the values. If the shadow has no
+// layers, a 404 error is returned.
+func (l *Layers) Effective(ctx context.Context, id persistencemodel.ID) (any, error) {
+	groups, err := l.groupsOf(ctx, []persistencemodel.ID{id})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return l.effective(ctx, id, groups[id])
+}
+
+// effective computes the effective desired model of the shadow _id_ that is a member of the _groups_.
+func (l *Layers) effective(ctx context.Context, id persistencemodel.ID, groups []string) (any, error) {
+	te, ok := l.manager.ResolveType("", id)
+
+	if !ok {
+		return nil, persistencemodel.Error400(fmt.Sprintf("could not resolve model for id: %s", id))
+	}
+
+	layers := []Layer{Fleet(id.Name)}
+
+	for _, group := range groups {
+		layers = append(layers, Group(group, id.Name))
+	}
+
+	layers = append(layers, Device(id))
+	ops := make([]persistencemodel.ReadOperation, len(layers))
+
+	for i, layer := range layers {
+		ops[i] = persistencemodel.ReadOperation{ID: layer.PersistenceID(), Model: te.Model, Schema: te.Schema}
+	}
+
+	models := map[string]any{}
+
+	for _, rr := range l.persistence.Read(ctx, persistencemodel.ReadOptions{}, ops...) {
+		if rr.Error != nil {
+			if isNotFound(rr.Error) {
+				continue
+			}
+
+			return nil, rr.Error
+		}
+
+		models[rr.ID.Name] = rr.Model
+	}
+
+	var effective any
+
+	for _, layer := range layers {
+		m, ok := models[layer.PersistenceID().Name]
+
+		if !ok {
+			continue
+		}
+
+		if effective == nil {
+			effective = m
+			continue
+		}
+
+		var err error
+
+		if effective, err = merge.MergeAny(ctx, effective, m, merge.MergeOptions{
+			Mode:             merge.ServerIsMaster,
+			IgnoreTimestamps: true,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if effective == nil {
+		return nil, persistencemodel.Error404(fmt.Sprintf("%s has no layers", id))
+	}
+
+	return effective, nil
+}
+
+// Apply writes the effective desired model of each shadow in _ids_, e.g. when a new shadow is created or when its
+// groups has changed. A shadow without any layers gets a 404 error in its result.
+//
+// It will always return a result for each id, in the same order as _ids_.
+func (l *Layers) Apply(ctx context.Context, ids ...persistencemodel.ID) []managermodel.DesireOperationResult {
+	groups, err := l.groupsOf(ctx, ids)
+
+	if err != nil {
+		results := make([]managermodel.DesireOperationResult, len(ids))
+
+		for i, id := range ids {
+			results[i] = managermodel.DesireOperationResult{ID: id, Error: err}
+		}
+
+		return results
+	}
+
+	return l.apply(ctx, ids, groups)
+}
+
+// apply writes the effective desired model of each shadow in _ids_ where _groups_ are the groups of each shadow.
+func (l *Layers) apply(
+	ctx context.Context,
+	ids []persistencemodel.ID,
+	groups map[persistencemodel.ID][]string,
+) []managermodel.DesireOperationResult {
+	results := make([]managermodel.DesireOperationResult, len(ids))
+
+	for start := 0; start < len(ids); start += l.batchSize {
+		end := min(start+l.batchSize, len(ids))
+		ops := make([]managermodel.DesireOperation, 0, end-start)
+		indices := make([]int, 0, end-start)
+
+		for i := start; i < end; i++ {
+			effective, err := l.effective(ctx, ids[i], groups[ids[i]])
+
+			if err != nil {
+				results[i] = managermodel.DesireOperationResult{ID: ids[i], Error: err}
+				continue
+			}
+
+			ops = append(ops, managermodel.DesireOperation{
+				ClientID:         l.clientID,
+				ID:               ids[i],
+				Model:            effective,
+				MergeMode:        l.mergeMode,
+				IgnoreTimestamps: true,
+			})
+
+			indices = append(indices, i)
+		}
+
+		if len(ops) == 0 {
+			continue
+		}
+
+		for n, res := range l.manager.Desire(ctx, ops...) {
+			results[indices[n]] = res
+		}
+	}
+
+	return results
+}
+
+// applyLayer applies the effective desired model on all shadows that the _layer_ applies to. The groups of the
+// shadows are resolved once.
+func (l *Layers) applyLayer(ctx context.Context, layer Layer) ([]managermodel.DesireOperationResult, error) {
+	if layer.Level == LevelDevice {
+		return l.Apply(ctx, persistencemodel.ID{ID: layer.ID, Name: layer.Name}), nil
+	}
+
+	if layer.Level == LevelGroup && l.groups == nil {
+		return nil, nil
+	}
+
+	ids, err := l.shadows(ctx, layer.Name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := l.groupsOf(ctx, ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if layer.Level == LevelGroup {
+		ids = slices.DeleteFunc(ids, func(id persistencemodel.ID) bool {
+			return !slices.Contains(groups[id], layer.Group)
+		})
+	}
+
+	return l.apply(ctx, ids, groups), nil
+}
+
+// groupsOf resolves the groups of the shadows in _ids_. Without a `GroupResolver`, no shadow has groups.
+func (l *Layers) groupsOf(ctx context.Context, ids []persistencemodel.ID) (map[persistencemodel.ID][]string, error) {
+	if l.groups == nil || len(ids) == 0 {
+		return nil, nil
+	}
+
+	return l.groups(ctx, ids...)
+}
+
+// shadows lists all shadows, sorted by id, with the _name_.
+func (l *Layers) shadows(ctx context.Context, name string) ([]persistencemodel.ID, error) {
+	seen := map[persistencemodel.ID]bool{}
+	opt := managermodel.ListOptions{}
+
+	for {
+		res, err := l.manager.List(ctx, opt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range res.Items {
+			id := item.ID.ToID()
+
+			if id.Name != name || strings.HasPrefix(id.ID, "$") /*reserved*/ {
+				continue
+			}
+
+			seen[id] = true
+		}
+
+		if res.Token == "" {
+			break
+		}
+
+		opt.Token = res.Token
+	}
+
+	ids := make([]persistencemodel.ID, 0, len(seen))
+
+	for id := range seen {
+		ids = append(ids, id)
+	}
+
+	slices.SortFunc(ids, func(a, b persistencemodel.ID) int {
+		return strings.Compare(a.String(), b.String())
+	})
+
+	return ids, nil
+}
+
+func isNotFound(err error) bool {
+	var pe persistencemodel.PersistenceError
+
+	return errors.As(err, &pe) && pe.Code == 404
+}
@@ -0,0 +1,219 @@
+package layers_test
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/manager/layers"
+	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+	"github.com/mariotoffia/godeviceshadow/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Sensor struct {
+	Value     any
+	TimeStamp time.Time
+}
+
+func (sp *Sensor) GetTimestamp() time.Time {
+	return sp.TimeStamp
+}
+
+func (sp *Sensor) GetValue() any {
+	return sp.Value
+}
+
+type TestModel struct {
+	Sensors map[string]Sensor
+}
+
+func setpoints(now time.Time, values map[string]any) TestModel {
+	m := TestModel{Sensors: map[string]Sensor{}}
+
+	for k, v := range values {
+		m.Sensors[k] = Sensor{Value: v, TimeStamp: now}
+	}
+
+	return m
+}
+
+func setup(t *testing.T) (*stdmgr.ManagerImpl, *layers.Layers) {
+	persistence := mempersistence.New()
+	mgr := stdmgr.New().
+		WithPersistence(persistence).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	for _, id := range []string{"device-1", "device-2", "device-3"} {
+		res := mgr.Report(context.Background(), managermodel.ReportOperation{
+			ID:    persistencemodel.ID{ID: id, Name: "homeHub"},
+			Model: setpoints(time.Now().UTC(), map[string]any{"temp": 20.0}),
+		})
+
+		require.NoError(t, res[0].Error)
+	}
+
+	north := func(_ context.Context, ids ...persistencemodel.ID) (map[persistencemodel.ID][]string, error) {
+		groups := map[persistencemodel.ID][]string{}
+
+		for _, id := range ids {
+			if slices.Contains([]string{"device-1", "device-2"}, id.ID) {
+				groups[id] = []string{"north"}
+			}
+		}
+
+		return groups, nil
+	}
+
+	return mgr, layers.New(mgr, persistence).WithGroups(north).Build()
+}
+
+func desired(t *testing.T, mgr *stdmgr.ManagerImpl, id string) map[string]any {
+	res := mgr.Read(context.Background(), managermodel.ReadOperation{
+		ID: persistencemodel.PersistenceID{ID: id, Name: "homeHub", ModelType: persistencemodel.ModelTypeDesired},
+	})
+
+	require.NoError(t, res[0].Error)
+
+	values := map[string]any{}
+
+	for k, v := range res[0].Model.(TestModel).Sensors {
+		values[k] = v.Value
+	}
+
+	return values
+}
+
+func TestLayers(t *testing.T) {
+	ctx := context.Background()
+	mgr, l := setup(t)
+	t0 := time.Now().UTC()
+
+	res, err := l.Set(ctx, layers.Fleet("homeHub"), setpoints(t0, map[string]any{"sp": 20.0, "mode": "heat"}))
+
+	require.NoError(t, err)
+	require.Len(t, res, 3)
+
+	for _, r := range res {
+		require.NoError(t, r.Error)
+	}
+
+	_, err = l.Set(ctx, layers.Group("north", "homeHub"), setpoints(t0, map[string]any{"sp": 21.0}))
+	require.NoError(t, err)
+
+	// An older override still has precedence
+	res, err = l.Set(ctx, layers.Device(persistencemodel.ID{ID: "device-2", Name: "homeHub"}),
+		setpoints(t0.Add(-time.Hour), map[string]any{"sp": 22.0}))
+
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+
+	assert.Equal(t, map[string]any{"sp": 21.0, "mode": "heat"}, desired(t, mgr, "device-1"))
+	assert.Equal(t, map[string]any{"sp": 22.0, "mode": "heat"}, desired(t, mgr, "device-2"))
+	assert.Equal(t, map[string]any{"sp": 20.0, "mode": "heat"}, desired(t, mgr, "device-3"))
+
+	// Changing the fleet default is applied on all, except where overridden
+	t1 := t0.Add(time.Minute)
+
+	_, err = l.Set(ctx, layers.Fleet("homeHub"), setpoints(t1, map[string]any{"sp": 19.0, "mode": "cool"}))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{"sp": 21.0, "mode": "cool"}, desired(t, mgr, "device-1"))
+	assert.Equal(t, map[string]any{"sp": 22.0, "mode": "cool"}, desired(t, mgr, "device-2"))
+	assert.Equal(t, map[string]any{"sp": 19.0, "mode": "cool"}, desired(t, mgr, "device-3"))
+
+	effective, err := l.Effective(ctx, persistencemodel.ID{ID: "device-2", Name: "homeHub"})
+
+	require.NoError(t, err)
+	assert.Equal(t, 22.0, effective.(TestModel).Sensors["sp"].Value)
+
+	// Layers are not listed as shadows
+	list, err := mgr.List(ctx)
+
+	require.NoError(t, err)
+
+	for _, item := range list.Items {
+		assert.NotEqual(t, managermodel.LayersID, item.ID.ID)
+	}
+
+	layer, err := l.Get(ctx, layers.Group("north", "homeHub"))
+
+	require.NoError(t, err)
+	assert.Equal(t, 21.0, layer.(TestModel).Sensors["sp"].Value)
+
+	_, err = l.Remove(ctx, layers.Group("north", "homeHub"))
+	require.NoError(t, err)
+
+	_, err = l.Get(ctx, layers.Group("north", "homeHub"))
+	require.Error(t, err)
+
+	_, err = l.Effective(ctx, persistencemodel.ID{ID: "device-9", Name: "other"})
+	require.Error(t, err)
+}
+
+func TestLayersResolveGroupsOncePerSet(t *testing.T) {
+	ctx := context.Background()
+	persistence := mempersistence.New()
+	mgr := stdmgr.New().
+		WithPersistence(persistence).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	for _, id := range []string{"north-1", "north-2", "south-1"} {
+		res := mgr.Report(ctx, managermodel.ReportOperation{
+			ID:    persistencemodel.ID{ID: id, Name: "homeHub"},
+			Model: setpoints(time.Now().UTC(), map[string]any{"temp": 20.0}),
+		})
+
+		require.NoError(t, res[0].Error)
+	}
+
+	require.NoError(t, mgr.SaveGroup(ctx, managermodel.Group{Name: "north", IDPattern: "north-*"}))
+
+	calls := 0
+	l := layers.New(mgr, persistence).
+		WithGroups(func(ctx context.Context, ids ...persistencemodel.ID) (map[persistencemodel.ID][]string, error) {
+			calls++
+			return mgr.GroupsOfEach(ctx, ids...)
+		}).
+		Build()
+
+	t0 := time.Now().UTC()
+
+	res, err := l.Set(ctx, layers.Fleet("homeHub"), setpoints(t0, map[string]any{"sp": 20.0}))
+	require.NoError(t, err)
+	require.Len(t, res, 3)
+	assert.Equal(t, 1, calls)
+
+	res, err = l.Set(ctx, layers.Group("north", "homeHub"), setpoints(t0, map[string]any{"sp": 21.0}))
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	assert.Equal(t, 2, calls)
+
+	assert.Equal(t, map[string]any{"sp": 21.0}, desired(t, mgr, "north-1"))
+	assert.Equal(t, map[string]any{"sp": 21.0}, desired(t, mgr, "north-2"))
+	assert.Equal(t, map[string]any{"sp": 20.0}, desired(t, mgr, "south-1"))
+}
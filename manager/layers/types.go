@@ -0,0 +1,110 @@
+package layers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// Level is the level of a `Layer`. A higher level has precedence over the lower levels.
+type Level int
+
+const (
+	// LevelFleet is the default of all shadows with the same name.
+	LevelFleet Level = 1
+	// LevelGroup is the template of all shadows, with the same name, in a group.
+	LevelGroup Level = 2
+	// LevelDevice is the override of a single shadow.
+	LevelDevice Level = 3
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelFleet:
+		return "fleet"
+	case LevelGroup:
+		return "group"
+	case LevelDevice:
+		return "device"
+	}
+
+	return fmt.Sprintf("level: %d", int(l))
+}
+
+// Layer identifies a layer of the desired model of the shadows with the `Name`. Each layer is a, typically
+// partial, desired model of the same type as the shadow.
+type Layer struct {
+	// Level is the level of the layer.
+	Level Level
+	// Name is the name of the shadows the layer applies to, e.g. _homeHub_.
+	Name string
+	// Group is the group when `LevelGroup`.
+	Group string
+	// ID is the id of the shadow when `LevelDevice`.
+	ID string
+}
+
+// Fleet is the fleet default layer of all shadows with the _name_.
+func Fleet(name string) Layer {
+	return Layer{Level: LevelFleet, Name: name}
+}
+
+// Group is the template layer of all shadows with the _name_ in the _group_.
+func Group(group, name string) Layer {
+	return Layer{Level: LevelGroup, Name: name, Group: group}
+}
+
+// Device is the override layer of the shadow _id_.
+func Device(id persistencemodel.ID) Layer {
+	return Layer{Level: LevelDevice, Name: id.Name, ID: id.ID}
+}
+
+// PersistenceID returns the id where the layer is persisted. It is a desired model under `managermodel.LayersID`
+// where the name is _fleet:{name}_, _group:{group}:{name}_ or _device:{id}:{name}_.
+func (l Layer) PersistenceID() persistencemodel.PersistenceID {
+	var name string
+
+	switch l.Level {
+	case LevelGroup:
+		name = fmt.Sprintf("%s:%s:%s", l.Level, l.Group, l.Name)
+	case LevelDevice:
+		name = fmt.Sprintf("%s:%s:%s", l.Level, l.ID, l.Name)
+	default:
+		name = fmt.Sprintf("%s:%s", l.Level, l.Name)
+	}
+
+	return persistencemodel.PersistenceID{
+		ID:        managermodel.LayersID,
+		Name:      name,
+		ModelType: persistencemodel.ModelTypeDesired,
+	}
+}
+
+func (l Layer) validate() error {
+	if l.Name == "" {
+		return persistencemodel.Error400("layer name is required")
+	}
+
+	switch l.Level {
+	case LevelFleet:
+	case LevelGroup:
+		if l.Group == "" {
+			return persistencemodel.Error400("group is required for a group layer")
+		}
+	case LevelDevice:
+		if l.ID == "" {
+			return persistencemodel.Error400("id is required for a device layer")
+		}
+	default:
+		return persistencemodel.Error400(fmt.Sprintf("unknown layer %s", l.Level))
+	}
+
+	return nil
+}
+
+// GroupResolver returns the groups of each shadow in _ids_. The group layers are applied in the returned order,
+// i.e. a later group has precedence. It is invoked once per `Layers.Set`, `Layers.Remove` and `Layers.Apply` with all
+// shadows, e.g. `stdmgr.ManagerImpl.GroupsOfEach`.
+type GroupResolver func(ctx context.Context, ids ...persistencemodel.ID) (map[persistencemodel.ID][]string, error)
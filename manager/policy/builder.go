@@ -0,0 +1,83 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
+)
+
+type builder struct {
+	e     *Engine
+	rules []Rule
+}
+
+func New() *builder {
+	return &builder{
+		e: &Engine{roles: map[string][]string{}},
+	}
+}
+
+// Build compiles the rules into an `Engine`. It fails if any path glob is invalid.
+func (b *builder) Build() (*Engine, error) {
+	e := &Engine{
+		roles:        b.e.roles,
+		roleResolver: b.e.roleResolver,
+		enforcement:  b.e.enforcement,
+		style:        b.e.style,
+	}
+
+	for _, r := range b.rules {
+		cr := compiledRule{Rule: r}
+
+		for _, p := range r.Paths {
+			g, err := pathutils.CompileGlob(p, b.e.style)
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid path in rule for %s: %s: %w", r.Principal, p, err)
+			}
+
+			cr.globs = append(cr.globs, g)
+		}
+
+		e.rules = append(e.rules, cr)
+	}
+
+	return e, nil
+}
+
+// WithRules adds one or more rules.
+func (b *builder) WithRules(rules ...Rule) *builder {
+	b.rules = append(b.rules, rules...)
+	return b
+}
+
+// Allow adds a rule that allows the _principal_ to perform the _operations_ on the _paths_ (all if none).
+func (b *builder) Allow(principal string, operations []managermodel.InterceptKind, paths ...string) *builder {
+	return b.WithRules(Rule{Principal: principal, Operations: operations, Paths: paths})
+}
+
+// WithRoles assigns the _roles_ to the _clientID_.
+func (b *builder) WithRoles(clientID string, roles ...string) *builder {
+	b.e.roles[clientID] = append(b.e.roles[clientID], roles...)
+	return b
+}
+
+// WithRoleResolver sets a function that resolves additional roles of a client, e.g. from a token in the _ctx_.
+func (b *builder) WithRoleResolver(resolver func(ctx context.Context, clientID string) []string) *builder {
+	b.e.roleResolver = resolver
+	return b
+}
+
+// WithEnforcement sets what happens when an operation writes to paths that are not allowed. Default is `EnforceDeny`.
+func (b *builder) WithEnforcement(enforcement Enforcement) *builder {
+	b.e.enforcement = enforcement
+	return b
+}
+
+// WithPathStyle sets the style of the paths in the rules. Default is `pathutils.StyleDotted`.
+func (b *builder) WithPathStyle(style pathutils.Style) *builder {
+	b.e.style = style
+	return b
+}
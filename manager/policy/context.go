@@ -0,0 +1,20 @@
+package policy
+
+import "context"
+
+type clientIDKey struct{}
+
+// WithClientID returns a context with the _clientID_ of the authenticated caller. It is the only client id that
+// the `Engine` authorizes, a client id of the operation must be empty or the same.
+func WithClientID(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, clientIDKey{}, clientID)
+}
+
+// ClientIDFromContext returns the client id set by `WithClientID` or empty string.
+func ClientIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(clientIDKey{}).(string); ok {
+		return id
+	}
+
+	return ""
+}
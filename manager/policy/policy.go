@@ -0,0 +1,298 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
+)
+
+// RolePrefix is the prefix of a `Rule.Principal` that refers to a role instead of a client id e.g. _role:installer_.
+const RolePrefix = "role:"
+
+// AnyPrincipal is a `Rule.Principal` that matches all clients, even those without a client id.
+const AnyPrincipal = "*"
+
+// Enforcement is what happens when an operation writes to paths that the client is not allowed to.
+type Enforcement int
+
+const (
+	// EnforceDeny fails the operation with 403 (Forbidden). This is the default.
+	EnforceDeny Enforcement = 0
+	// EnforceStrip removes the values on the paths that are not allowed and applies the rest.
+	EnforceStrip Enforcement = 1
+)
+
+// Rule allows a principal to perform operations on a set of paths.
+type Rule struct {
+	// Principal is a client id, a role (prefixed with `RolePrefix`) or `AnyPrincipal`.
+	Principal string
+	// Operations are the allowed operations.
+	Operations []managermodel.InterceptKind
+	// Paths are globs (see `pathutils.Glob`) of the paths that are allowed to be written (report, desire)
+	// or read. A path is allowed when it, or any of its parents, matches. When empty, all paths are allowed.
+	Paths []string
+}
+
+type compiledRule struct {
+	Rule
+	globs []*pathutils.Glob
+}
+
+// Engine authorizes the `Manager` operations based on the client id in the context (see `WithClientID`). It is a
+// `managermodel.Interceptor` and is registered using `stdmgr.WithInterceptors`.
+//
+// Everything that is not explicitly allowed by a `Rule` is denied. The client id of an operation is never trusted,
+// when set it must be the same as the one in the context.
+type Engine struct {
+	rules        []compiledRule
+	roles        map[string][]string
+	roleResolver func(ctx context.Context, clientID string) []string
+	enforcement  Enforcement
+	style        pathutils.Style
+}
+
+// Before implements the `managermodel.Interceptor` interface.
+func (e *Engine) Before(ctx context.Context, call *managermodel.InterceptCall) error {
+	clientID := ClientIDFromContext(ctx)
+
+	if opClientID := operationClientID(call); opClientID != "" && opClientID != clientID {
+		return persistencemodel.Error403(
+			fmt.Sprintf("client id '%s' of the operation is not the client '%s' of the context", opClientID, clientID),
+		)
+	}
+
+	rules := e.matching(ctx, clientID, call.Kind)
+
+	if len(rules) == 0 {
+		return persistencemodel.Error403(fmt.Sprintf("client '%s' is not allowed to %s %s", clientID, call.Kind, call.ID))
+	}
+
+	switch call.Kind {
+	case managermodel.InterceptReport:
+		if err := e.authorizeDesired(clientID, rules, call.Report); err != nil {
+			return err
+		}
+
+		return e.authorizeModel(ctx, clientID, rules, call.Report.MergeMode, &call.Report.Model)
+	case managermodel.InterceptDesire:
+		return e.authorizeModel(ctx, clientID, rules, call.Desire.MergeMode, &call.Desire.Model)
+	case managermodel.InterceptRollback, managermodel.InterceptCopy:
+		// Those replaces the whole model
+		if !unrestricted(rules) {
+			return persistencemodel.Error403(fmt.Sprintf("client '%s' is not allowed to %s all paths of %s", clientID, call.Kind, call.ID))
+		}
+	}
+
+	return nil
+}
+
+// After implements the `managermodel.Interceptor` interface. It removes the paths, in the read models,
+// that the client is not allowed to read.
+func (e *Engine) After(ctx context.Context, call *managermodel.InterceptCall) {
+	if call.Kind != managermodel.InterceptRead || call.ReadResult == nil ||
+		call.ReadResult.Error != nil || call.ReadResult.Model == nil {
+		return
+	}
+
+	rules := e.matching(ctx, ClientIDFromContext(ctx), call.Kind)
+
+	if unrestricted(rules) {
+		return
+	}
+
+	filtered, err := merge.Filter(ctx, call.ReadResult.Model, func(path string) bool {
+		return e.allowed(rules, path)
+	}, e.style)
+
+	if err != nil {
+		call.ReadResult.Error = err
+		return
+	}
+
+	call.ReadResult.Model = filtered
+}
+
+// authorizeModel checks that the paths of the _m_ are allowed. A `merge.ClientIsMaster` merge removes all stored
+// paths that are not in _m_, hence it requires that all paths are allowed.
+func (e *Engine) authorizeModel(
+	ctx context.Context,
+	clientID string,
+	rules []compiledRule,
+	mode merge.MergeMode,
+	m *any,
+) error {
+	if unrestricted(rules) {
+		return nil
+	}
+
+	if mode == merge.ClientIsMaster {
+		return persistencemodel.Error403(
+			fmt.Sprintf("client '%s' is not allowed to replace all paths (client is master merge)", clientID),
+		)
+	}
+
+	if *m == nil {
+		return nil
+	}
+
+	values, err := merge.Values(ctx, *m, e.style)
+
+	if err != nil {
+		return err
+	}
+
+	var denied []string
+
+	for path := range values {
+		if !e.allowed(rules, path) {
+			denied = append(denied, path)
+		}
+	}
+
+	if len(denied) == 0 {
+		return nil
+	}
+
+	if e.enforcement == EnforceDeny {
+		sort.Strings(denied)
+
+		return persistencemodel.Error403(
+			fmt.Sprintf("client '%s' is not allowed to write: %s", clientID, strings.Join(denied, ", ")),
+		)
+	}
+
+	filtered, err := merge.Filter(ctx, *m, func(path string) bool { return e.allowed(rules, path) }, e.style)
+
+	if err != nil {
+		return err
+	}
+
+	*m = filtered
+
+	return nil
+}
+
+// authorizeDesired checks that the paths of the rejected desired values and the acknowledge policies of the report
+// _op_ are allowed, since those removes desired values. The `managermodel.ReportOperation.DefaultAckPolicy` applies
+// to all paths, hence it requires that all paths are allowed. Those are always denied, regardless of the enforcement.
+func (e *Engine) authorizeDesired(clientID string, rules []compiledRule, op *managermodel.ReportOperation) error {
+	if unrestricted(rules) {
+		return nil
+	}
+
+	if op.DefaultAckPolicy != merge.AckOnEqual {
+		return persistencemodel.Error403(
+			fmt.Sprintf("client '%s' is not allowed to set the default acknowledge policy (%s)", clientID, op.DefaultAckPolicy),
+		)
+	}
+
+	var denied []string
+
+	for _, rejected := range op.RejectedDesires {
+		if !e.allowed(rules, rejected.Path) {
+			denied = append(denied, rejected.Path)
+		}
+	}
+
+	for path := range op.AckPolicies {
+		if !e.allowed(rules, path) {
+			denied = append(denied, path)
+		}
+	}
+
+	if len(denied) == 0 {
+		return nil
+	}
+
+	sort.Strings(denied)
+
+	return persistencemodel.Error403(
+		fmt.Sprintf("client '%s' is not allowed to reject or acknowledge: %s", clientID, strings.Join(slices.Compact(denied), ", ")),
+	)
+}
+
+// operationClientID returns the client id of the operation, if any.
+func operationClientID(call *managermodel.InterceptCall) string {
+	switch {
+	case call.Report != nil:
+		return call.Report.ClientID
+	case call.Desire != nil:
+		return call.Desire.ClientID
+	case call.Copy != nil:
+		return call.Copy.ClientID
+	}
+
+	return ""
+}
+
+// matching returns all rules that allows the _clientID_ to perform _kind_.
+func (e *Engine) matching(ctx context.Context, clientID string, kind managermodel.InterceptKind) []compiledRule {
+	principals := []string{AnyPrincipal}
+
+	if clientID != "" {
+		principals = append(principals, clientID)
+
+		for _, role := range e.roles[clientID] {
+			principals = append(principals, RolePrefix+role)
+		}
+
+		if e.roleResolver != nil {
+			for _, role := range e.roleResolver(ctx, clientID) {
+				principals = append(principals, RolePrefix+role)
+			}
+		}
+	}
+
+	var rules []compiledRule
+
+	for _, r := range e.rules {
+		if slices.Contains(principals, r.Principal) && slices.Contains(r.Operations, kind) {
+			rules = append(rules, r)
+		}
+	}
+
+	return rules
+}
+
+// allowed returns `true` if any of the _rules_ allows the _path_ or any of its parents.
+func (e *Engine) allowed(rules []compiledRule, path string) bool {
+	parsed, err := pathutils.Parse(path, e.style)
+
+	if err != nil {
+		return false
+	}
+
+	for _, r := range rules {
+		if len(r.globs) == 0 {
+			return true
+		}
+
+		for _, g := range r.globs {
+			for n := len(parsed); n > 0; n-- {
+				if g.MatchPath(parsed[:n]) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// unrestricted returns `true` if any of the _rules_ allows all paths.
+func unrestricted(rules []compiledRule) bool {
+	for _, r := range rules {
+		if len(r.globs) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
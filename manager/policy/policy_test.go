@@ -0,0 +1,258 @@
+package policy_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/manager/policy"
+	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+	"github.com/mariotoffia/godeviceshadow/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Sensor struct {
+	Value     any
+	TimeStamp time.Time
+}
+
+func (sp *Sensor) GetTimestamp() time.Time { return sp.TimeStamp }
+func (sp *Sensor) GetValue() any           { return sp.Value }
+
+type Climate struct {
+	Sensors map[string]Sensor `json:"sensors"`
+}
+
+type Hub struct {
+	Firmware string  `json:"fw"`
+	Climate  Climate `json:"climate"`
+}
+
+var (
+	report = []managermodel.InterceptKind{managermodel.InterceptReport}
+	desire = []managermodel.InterceptKind{managermodel.InterceptDesire}
+	read   = []managermodel.InterceptKind{managermodel.InterceptRead}
+)
+
+func newManager(t *testing.T, enforcement policy.Enforcement) *stdmgr.ManagerImpl {
+	engine, err := policy.New().
+		Allow("device1", report, "climate.**").
+		Allow("role:installer", desire, "climate.sensors.indoor_temp_sp").
+		Allow("role:installer", read, "climate").
+		Allow("admin", []managermodel.InterceptKind{
+			managermodel.InterceptReport, managermodel.InterceptDesire, managermodel.InterceptRead,
+		}).
+		WithRoles("partner", "installer").
+		WithEnforcement(enforcement).
+		Build()
+
+	require.NoError(t, err)
+
+	return stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "hub", Model: reflect.TypeOf(Hub{})}, true
+				}),
+			),
+		).
+		WithInterceptors(engine).
+		Build()
+}
+
+func TestPolicyDeny(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	id := persistencemodel.ID{ID: "device1", Name: "hub"}
+	mgr := newManager(t, policy.EnforceDeny)
+
+	res := mgr.Report(policy.WithClientID(ctx, "device1"),
+		managermodel.ReportOperation{
+			ClientID: "device1",
+			ID:       id,
+			Model:    Hub{Climate: Climate{Sensors: map[string]Sensor{"temp": {Value: 21.0, TimeStamp: now}}}},
+		},
+		managermodel.ReportOperation{
+			ID:    id,
+			Model: Hub{Firmware: "1.2.3"},
+		},
+		managermodel.ReportOperation{
+			ClientID: "admin", // not the client of the context
+			ID:       id,
+			Model:    Hub{Climate: Climate{Sensors: map[string]Sensor{"temp": {Value: 22.0, TimeStamp: now}}}},
+		},
+	)
+
+	require.Len(t, res, 3)
+	require.NoError(t, res[0].Error)
+	assert.True(t, res[0].ReportedProcessed)
+
+	var pe persistencemodel.PersistenceError
+
+	require.ErrorAs(t, res[1].Error, &pe)
+	assert.Equal(t, 403, pe.Code)
+	assert.Contains(t, pe.Message, "fw")
+
+	require.ErrorAs(t, res[2].Error, &pe)
+	assert.Equal(t, 403, pe.Code)
+
+	// Unknown and anonymous clients
+	for _, c := range []context.Context{policy.WithClientID(ctx, "unknown"), ctx} {
+		res = mgr.Report(c, managermodel.ReportOperation{
+			ID:    id,
+			Model: Hub{Climate: Climate{Sensors: map[string]Sensor{"temp": {Value: 22.0, TimeStamp: now}}}},
+		})
+
+		require.ErrorAs(t, res[0].Error, &pe)
+		assert.Equal(t, 403, pe.Code)
+	}
+
+	// The installer may not report
+	res = mgr.Report(policy.WithClientID(ctx, "partner"), managermodel.ReportOperation{ID: id, Model: Hub{Firmware: "2.0.0"}})
+	require.ErrorAs(t, res[0].Error, &pe)
+	assert.Equal(t, 403, pe.Code)
+}
+
+func TestPolicyDenyClientIsMaster(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	id := persistencemodel.ID{ID: "device1", Name: "hub"}
+
+	for _, enforcement := range []policy.Enforcement{policy.EnforceDeny, policy.EnforceStrip} {
+		mgr := newManager(t, enforcement)
+
+		res := mgr.Desire(policy.WithClientID(ctx, "admin"), managermodel.DesireOperation{
+			ID:    id,
+			Model: Hub{Firmware: "1.2.3", Climate: Climate{Sensors: map[string]Sensor{"indoor_temp_sp": {Value: 21.0, TimeStamp: now}}}},
+		})
+
+		require.NoError(t, res[0].Error)
+
+		// An empty model would remove the firmware that the installer may not write
+		res = mgr.Desire(policy.WithClientID(ctx, "partner"), managermodel.DesireOperation{
+			ID:        id,
+			Model:     Hub{},
+			MergeMode: merge.ClientIsMaster,
+		})
+
+		var pe persistencemodel.PersistenceError
+
+		require.ErrorAs(t, res[0].Error, &pe)
+		assert.Equal(t, 403, pe.Code)
+
+		// The admin may write all paths
+		res = mgr.Desire(policy.WithClientID(ctx, "admin"), managermodel.DesireOperation{
+			ID:        id,
+			Model:     Hub{Firmware: "1.2.4"},
+			MergeMode: merge.ClientIsMaster,
+		})
+
+		require.NoError(t, res[0].Error)
+		assert.Equal(t, "1.2.4", res[0].Model.(Hub).Firmware)
+		assert.Empty(t, res[0].Model.(Hub).Climate.Sensors)
+	}
+}
+
+func TestPolicyStripAndReadFilter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	id := persistencemodel.ID{ID: "device1", Name: "hub"}
+	mgr := newManager(t, policy.EnforceStrip)
+
+	res := mgr.Report(policy.WithClientID(ctx, "admin"), managermodel.ReportOperation{
+		ClientID: "admin",
+		ID:       id,
+		Model:    Hub{Firmware: "1.2.3", Climate: Climate{Sensors: map[string]Sensor{"temp": {Value: 21.0, TimeStamp: now}}}},
+	})
+
+	require.NoError(t, res[0].Error)
+
+	model := Hub{Firmware: "9.9.9", Climate: Climate{Sensors: map[string]Sensor{
+		"indoor_temp_sp": {Value: 22.0, TimeStamp: now},
+		"outdoor":        {Value: 5.0, TimeStamp: now},
+	}}}
+
+	dres := mgr.Desire(policy.WithClientID(ctx, "partner"), managermodel.DesireOperation{ClientID: "partner", ID: id, Model: model})
+
+	require.Len(t, dres, 1)
+	require.NoError(t, dres[0].Error)
+	assert.True(t, dres[0].Processed)
+
+	desired := dres[0].Model.(Hub)
+	assert.Empty(t, desired.Firmware)
+	assert.Len(t, desired.Climate.Sensors, 1)
+	assert.Contains(t, desired.Climate.Sensors, "indoor_temp_sp")
+
+	// The callers model is not altered
+	assert.Len(t, model.Climate.Sensors, 2)
+
+	// The installer may only read climate
+	rr := mgr.Read(policy.WithClientID(ctx, "partner"), managermodel.ReadOperation{
+		ID: id.ToPersistenceID(persistencemodel.ModelTypeReported),
+	})
+
+	require.Len(t, rr, 1)
+	require.NoError(t, rr[0].Error)
+
+	reported := rr[0].Model.(Hub)
+	assert.Empty(t, reported.Firmware)
+	assert.Equal(t, 21.0, reported.Climate.Sensors["temp"].Value)
+}
+
+func TestPolicyDenyRejectAndAckOutsidePaths(t *testing.T) {
+	ctx := policy.WithClientID(context.Background(), "device1")
+	now := time.Now().UTC()
+	id := persistencemodel.ID{ID: "device1", Name: "hub"}
+	climate := Hub{Climate: Climate{Sensors: map[string]Sensor{"temp": {Value: 21.0, TimeStamp: now}}}}
+
+	for _, enforcement := range []policy.Enforcement{policy.EnforceDeny, policy.EnforceStrip} {
+		mgr := newManager(t, enforcement)
+
+		res := mgr.Report(ctx,
+			managermodel.ReportOperation{
+				ID:              id,
+				Model:           climate,
+				RejectedDesires: []model.RejectedDesire{{Path: "climate.sensors.temp", Code: "out-of-range"}},
+				AckPolicies:     map[string]merge.AckPolicy{"climate.**": merge.AckSticky},
+			},
+			managermodel.ReportOperation{
+				ID:              id,
+				Model:           climate,
+				RejectedDesires: []model.RejectedDesire{{Path: "fw", Code: "not-supported"}},
+			},
+			managermodel.ReportOperation{
+				ID:          id,
+				Model:       climate,
+				AckPolicies: map[string]merge.AckPolicy{"fw": merge.AckOnReportPresent},
+			},
+			managermodel.ReportOperation{
+				ID:               id,
+				Model:            climate,
+				DefaultAckPolicy: merge.AckOnReportPresent,
+			},
+		)
+
+		require.Len(t, res, 4)
+		require.NoError(t, res[0].Error)
+
+		for _, r := range res[1:] {
+			var pe persistencemodel.PersistenceError
+
+			require.ErrorAs(t, r.Error, &pe)
+			assert.Equal(t, 403, pe.Code)
+		}
+
+		assert.Contains(t, res[1].Error.Error(), "fw")
+		assert.Contains(t, res[2].Error.Error(), "fw")
+	}
+}
@@ -0,0 +1,101 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+)
+
+type builder struct {
+	e *Engine
+}
+
+// New creates a builder for a rule `Engine`. The _manager_ executes the `DesireAction`(s), if not known yet, use
+// `Engine.Attach` when the manager is built.
+func New(manager ...managermodel.Desireable) *builder {
+	e := &Engine{}
+
+	if len(manager) > 0 {
+		e.manager = manager[0]
+	}
+
+	return &builder{e: e}
+}
+
+// Build creates the `Engine`. It fails if a rule has no id or the ids are not unique.
+func (b *builder) Build() (*Engine, error) {
+	ids := map[string]bool{}
+
+	for _, r := range b.e.rules {
+		if r.ID == "" {
+			return nil, fmt.Errorf("rule id is required")
+		}
+
+		if ids[r.ID] {
+			return nil, fmt.Errorf("duplicate rule id: %s", r.ID)
+		}
+
+		ids[r.ID] = true
+	}
+
+	maxDepth := b.e.maxDepth
+
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+
+	now := b.e.now
+
+	if now == nil {
+		now = time.Now
+	}
+
+	name := b.e.name
+
+	if name == "" {
+		name = DefaultClientID
+	}
+
+	return &Engine{
+		manager:  b.e.manager,
+		rules:    b.e.rules,
+		maxDepth: maxDepth,
+		now:      now,
+		onFiring: b.e.onFiring,
+		name:     name,
+		fired:    map[string][]time.Time{},
+	}, nil
+}
+
+// WithRules adds one or more rules.
+func (b *builder) WithRules(rules ...Rule) *builder {
+	b.e.rules = append(b.e.rules, rules...)
+	return b
+}
+
+// WithMaxDepth sets the maximum cascade depth, i.e. how many rule actions that may lead to a report that is
+// evaluated. Default is `DefaultMaxDepth`.
+func (b *builder) WithMaxDepth(depth int) *builder {
+	b.e.maxDepth = depth
+	return b
+}
+
+// WithClock sets the function that returns the current time. It is used by the rate limits. Default is `time.Now`.
+func (b *builder) WithClock(now func() time.Time) *builder {
+	b.e.now = now
+	return b
+}
+
+// WithOnFiring sets a function that is invoked for each matching rule, e.g. to log `Firing.Error`.
+func (b *builder) WithOnFiring(f func(ctx context.Context, firing Firing)) *builder {
+	b.e.onFiring = f
+	return b
+}
+
+// WithName sets the name of the engine as a `notifiermodel.NotificationTarget`. Default is `DefaultClientID`.
+func (b *builder) WithName(name string) *builder {
+	b.e.name = name
+	return b
+}
@@ -0,0 +1,86 @@
+package rules
+
+import (
+	"reflect"
+
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/notifiermodel"
+	"github.com/mariotoffia/godeviceshadow/utils/numutils"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
+)
+
+// Value creates a selection that matches when any added, updated or unchanged value, in the reported merge, on a
+// path that matches the _path_ glob (see `pathutils.Glob`) satisfies _cond_. It requires the `changelogger` to be
+// a report logger of the manager.
+//
+// This is the same as _SELECT * FROM Notification WHERE log.Path == 'climate.indoor.t' AND log.Value > 30_ in
+// the _selectlang_ when `Value("climate.indoor.t", Above(30))`.
+func Value(path string, cond func(value any) bool) notifiermodel.Selection {
+	glob := pathutils.MustCompileGlob(path)
+
+	return notifiermodel.FuncSelection(func(op notifiermodel.NotifierOperation, value bool) (bool, []notifiermodel.SelectedValue) {
+		var selected []notifiermodel.SelectedValue
+
+		for _, mo := range []model.MergeOperation{model.MergeOperationAdd, model.MergeOperationUpdate, model.MergeOperationNotChanged} {
+			for _, mv := range op.MergeLogger.ManagedLog[mo] {
+				if mv.NewValue == nil || !glob.Match(mv.Path) || !cond(mv.NewValue.GetValue()) {
+					continue
+				}
+
+				if !value {
+					return true, nil
+				}
+
+				selected = append(selected, notifiermodel.SelectedValue{
+					Path:         mv.Path,
+					OldValue:     mv.OldValue,
+					NewValue:     mv.NewValue,
+					OldTimeStamp: mv.OldTimeStamp,
+					NewTimeStamp: mv.NewTimeStamp,
+				})
+			}
+
+			for _, pv := range op.MergeLogger.PlainLog[mo] {
+				if glob.Match(pv.Path) && cond(pv.NewValue) {
+					if !value {
+						return true, nil
+					}
+
+					selected = append(selected, notifiermodel.SelectedValue{Path: pv.Path})
+				}
+			}
+		}
+
+		return len(selected) > 0, selected
+	})
+}
+
+// Below returns a condition that holds when the value is a number less than _limit_.
+func Below(limit float64) func(value any) bool {
+	return func(value any) bool {
+		f, ok := numutils.ToFloat(value)
+		return ok && f < limit
+	}
+}
+
+// Above returns a condition that holds when the value is a number greater than _limit_.
+func Above(limit float64) func(value any) bool {
+	return func(value any) bool {
+		f, ok := numutils.ToFloat(value)
+		return ok && f > limit
+	}
+}
+
+// Equals returns a condition that holds when the value equals _v_. Numbers are compared as `float64`.
+func Equals(v any) func(value any) bool {
+	return func(value any) bool {
+		a, aok := numutils.ToFloat(value)
+		b, bok := numutils.ToFloat(v)
+
+		if aok && bok {
+			return a == b
+		}
+
+		return reflect.DeepEqual(value, v)
+	}
+}
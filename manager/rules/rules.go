@@ -0,0 +1,212 @@
+package rules
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/loggers/changelogger"
+	"github.com/mariotoffia/godeviceshadow/loggers/desirelogger"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/notifiermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// DefaultClientID is the client id of the `DesireAction` when none is set.
+const DefaultClientID = "rules"
+
+// DefaultMaxDepth is the default maximum cascade depth.
+const DefaultMaxDepth = 3
+
+// CustomRule is the `notifiermodel.NotifierOperation.Custom` property that holds the id of the rule when the
+// actions are executed.
+const CustomRule = "rule"
+
+// Engine evaluates the rules after each successful report.
+//
+// It is a `managermodel.Interceptor`, when registered on the manager it evaluates all reports of the manager. It
+// is also a `notifiermodel.NotificationTarget` to evaluate the report notifications of e.g. a stream processor in
+// a separate service.
+//
+// Actions may cause new reports, e.g. an in-process device that reports on a desire, and those are evaluated
+// as well. To protect from loops, the cascade depth is carried in the context (see `Depth`) and rules are not
+// executed on reports beyond the maximum depth.
+//
+// The depth is not persisted, it only protects within one process where the report is made with the context of
+// the action. A device that reports back over the network, or a report notification processed in another service,
+// arrives at depth zero. Use a `RateLimit` and conditions that no longer hold once the action is applied to
+// protect against those loops.
+type Engine struct {
+	manager  managermodel.Desireable
+	rules    []Rule
+	maxDepth int
+	now      func() time.Time
+	onFiring func(ctx context.Context, firing Firing)
+	name     string
+
+	mu    sync.Mutex
+	fired map[string][]time.Time
+}
+
+// Attach sets the _manager_ that executes the `DesireAction`(s). This is needed when the engine is registered as a
+// interceptor on the same manager, since the manager is built after the engine.
+func (e *Engine) Attach(manager managermodel.Desireable) *Engine {
+	e.manager = manager
+	return e
+}
+
+// Rules returns the rules of the engine.
+func (e *Engine) Rules() []Rule {
+	return e.rules
+}
+
+// Evaluate evaluates the report notification _op_ against all rules and executes the actions of those that match.
+func (e *Engine) Evaluate(ctx context.Context, op notifiermodel.NotifierOperation) []Firing {
+	if op.Operation != notifiermodel.OperationTypeReport {
+		return nil
+	}
+
+	var res []Firing
+
+	depth := Depth(ctx)
+	id := persistencemodel.ID{ID: op.ID.ID, Name: op.ID.Name}
+
+	for _, rule := range e.rules {
+		if rule.Where != nil {
+			if selected, _ := rule.Where.Select(op, false /*value*/); !selected {
+				continue
+			}
+		}
+
+		firing := Firing{Rule: rule.ID, ID: id, Depth: depth}
+
+		switch {
+		case depth >= e.maxDepth:
+			firing.Cascaded = true
+		case !e.allow(rule):
+			firing.Limited = true
+		default:
+			firing.Error = e.execute(withDepth(ctx, depth+1), rule, op)
+		}
+
+		if e.onFiring != nil {
+			e.onFiring(ctx, firing)
+		}
+
+		res = append(res, firing)
+	}
+
+	return res
+}
+
+// Before implements the `managermodel.Interceptor` interface.
+func (e *Engine) Before(ctx context.Context, call *managermodel.InterceptCall) error {
+	return nil
+}
+
+// After implements the `managermodel.Interceptor` interface. It evaluates the successful reports.
+func (e *Engine) After(ctx context.Context, call *managermodel.InterceptCall) {
+	if call.Kind != managermodel.InterceptReport || call.ReportResult == nil {
+		return
+	}
+
+	res := call.ReportResult
+
+	if res.Error != nil || res.Duplicate {
+		return
+	}
+
+	op := notifiermodel.NotifierOperation{
+		ID:        res.ID.ToPersistenceID(persistencemodel.ModelTypeReported),
+		Operation: notifiermodel.OperationTypeReport,
+		Reported:  res.ReportModel,
+		Desired:   res.DesiredModel,
+	}
+
+	if cl := changelogger.Find(res.MergeLoggers); cl != nil {
+		op.MergeLogger = *cl
+	}
+
+	for _, lg := range res.DesiredLoggers {
+		if dl, ok := lg.(*desirelogger.DesireLogger); ok {
+			op.DesireLogger = *dl
+			break
+		}
+	}
+
+	e.Evaluate(ctx, op)
+}
+
+// Name implements the `notifiermodel.NotificationTarget` interface.
+func (e *Engine) Name() string {
+	return e.name
+}
+
+// Notify implements the `notifiermodel.NotificationTarget` interface. It evaluates the report operations.
+func (e *Engine) Notify(
+	ctx context.Context,
+	tx *persistencemodel.TransactionImpl,
+	operations ...notifiermodel.NotifierOperation,
+) []notifiermodel.NotificationTargetResult {
+	res := make([]notifiermodel.NotificationTargetResult, 0, len(operations))
+
+	for _, op := range operations {
+		var err error
+
+		for _, firing := range e.Evaluate(ctx, op) {
+			if firing.Error != nil && err == nil {
+				err = firing.Error
+			}
+		}
+
+		res = append(res, notifiermodel.NotificationTargetResult{Target: e, Operation: op, Error: err})
+	}
+
+	return res
+}
+
+// execute executes the actions of the _rule_ until one fails.
+func (e *Engine) execute(ctx context.Context, rule Rule, op notifiermodel.NotifierOperation) error {
+	custom := make(map[string]any, len(op.Custom)+1)
+	maps.Copy(custom, op.Custom)
+	custom[CustomRule] = rule.ID
+
+	op.Custom = custom
+
+	for _, action := range rule.Actions {
+		if err := action.Execute(ctx, e.manager, op); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// allow records an execution of the _rule_ if it is within its `RateLimit`.
+func (e *Engine) allow(rule Rule) bool {
+	if rule.RateLimit.Max <= 0 {
+		return true
+	}
+
+	now := e.now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	fired := e.fired[rule.ID]
+
+	// Drop those outside of the window
+	for len(fired) > 0 && now.Sub(fired[0]) >= rule.RateLimit.Per {
+		fired = fired[1:]
+	}
+
+	if len(fired) >= rule.RateLimit.Max {
+		e.fired[rule.ID] = fired
+		return false
+	}
+
+	e.fired[rule.ID] = append(fired, now)
+
+	return true
+}
@@ -0,0 +1,192 @@
+package rules_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/loggers/changelogger"
+	"github.com/mariotoffia/godeviceshadow/manager/rules"
+	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/notifiermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/notify"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+	"github.com/mariotoffia/godeviceshadow/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Sensor struct {
+	Value     any
+	TimeStamp time.Time
+}
+
+func (sp *Sensor) GetTimestamp() time.Time {
+	return sp.TimeStamp
+}
+
+func (sp *Sensor) GetValue() any {
+	return sp.Value
+}
+
+type TestModel struct {
+	Sensors map[string]Sensor
+}
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newManager(engine *rules.Engine) *stdmgr.ManagerImpl {
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithReportLoggers(changelogger.New()).
+		WithInterceptors(engine).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	engine.Attach(mgr)
+
+	return mgr
+}
+
+func report(t *testing.T, ctx context.Context, mgr *stdmgr.ManagerImpl, id persistencemodel.ID, name string, value float64) {
+	res := mgr.Report(ctx, managermodel.ReportOperation{
+		ClientID: "device",
+		ID:       id,
+		Model:    TestModel{Sensors: map[string]Sensor{name: {Value: value, TimeStamp: time.Now().UTC()}}},
+	})
+
+	require.NoError(t, res[0].Error)
+}
+
+func TestRules(t *testing.T) {
+	ctx := context.Background()
+	clk := &clock{now: time.Now().UTC()}
+
+	var notified []notifiermodel.NotifierOperation
+
+	notifier := notify.NewBuilder().
+		TargetBuilder(notifiermodel.FuncTarget(
+			func(ctx context.Context, target notifiermodel.NotificationTarget, tx *persistencemodel.TransactionImpl, operations ...notifiermodel.NotifierOperation) []notifiermodel.NotificationTargetResult {
+				notified = append(notified, operations...)
+				return nil
+			}, "alerts")).
+		Build().
+		Build()
+
+	var firings []rules.Firing
+
+	engine, err := rules.New().
+		WithClock(clk.Now).
+		WithOnFiring(func(ctx context.Context, firing rules.Firing) { firings = append(firings, firing) }).
+		WithRules(rules.Rule{
+			ID:    "cold-outdoor",
+			Where: rules.Value("Sensors.outdoor", rules.Below(-10)),
+			Actions: []rules.Action{
+				rules.DesireAction{
+					Model: TestModel{Sensors: map[string]Sensor{"indoor_temp_sp": {Value: 22.0, TimeStamp: clk.now}}},
+				},
+				rules.NotifyAction{Notifier: notifier},
+			},
+			RateLimit: rules.RateLimit{Max: 1, Per: time.Hour},
+		}).
+		Build()
+
+	require.NoError(t, err)
+
+	mgr := newManager(engine)
+	id := persistencemodel.ID{ID: "hub-1", Name: "homeHub"}
+
+	report(t, ctx, mgr, id, "outdoor", -5)
+	assert.Empty(t, firings, "not cold enough")
+
+	report(t, ctx, mgr, id, "outdoor", -12)
+	require.Len(t, firings, 1)
+	assert.NoError(t, firings[0].Error)
+	assert.False(t, firings[0].Limited)
+
+	res := mgr.Read(ctx, managermodel.ReadOperation{ID: id.ToPersistenceID(persistencemodel.ModelTypeDesired)})
+
+	require.NoError(t, res[0].Error)
+	assert.Equal(t, 22.0, res[0].Model.(TestModel).Sensors["indoor_temp_sp"].Value)
+
+	require.Len(t, notified, 1)
+	assert.Equal(t, "cold-outdoor", notified[0].Custom[rules.CustomRule])
+	assert.Equal(t, id.ID, notified[0].ID.ID)
+
+	// Rate limited within the hour
+	report(t, ctx, mgr, id, "outdoor", -13)
+	require.Len(t, firings, 2)
+	assert.True(t, firings[1].Limited)
+	assert.Len(t, notified, 1)
+
+	clk.now = clk.now.Add(time.Hour)
+
+	report(t, ctx, mgr, id, "outdoor", -14)
+	require.Len(t, firings, 3)
+	assert.False(t, firings[2].Limited)
+	assert.Len(t, notified, 2)
+}
+
+func TestRulesCascade(t *testing.T) {
+	ctx := context.Background()
+
+	var firings []rules.Firing
+
+	engine, err := rules.New().
+		WithMaxDepth(2).
+		WithOnFiring(func(ctx context.Context, firing rules.Firing) { firings = append(firings, firing) }).
+		WithRules(rules.Rule{
+			ID: "echo",
+			Actions: []rules.Action{
+				// A device that reports back on each report, i.e. a loop
+				rules.ActionFunc(func(ctx context.Context, manager managermodel.Desireable, op notifiermodel.NotifierOperation) error {
+					manager.(managermodel.Reportable).Report(ctx, managermodel.ReportOperation{
+						ID:    persistencemodel.ID{ID: op.ID.ID, Name: op.ID.Name},
+						Model: TestModel{Sensors: map[string]Sensor{"echo": {Value: 1, TimeStamp: time.Now().UTC()}}},
+					})
+
+					return nil
+				}),
+			},
+		}).
+		Build()
+
+	require.NoError(t, err)
+
+	mgr := newManager(engine)
+
+	report(t, ctx, mgr, persistencemodel.ID{ID: "hub-1", Name: "homeHub"}, "outdoor", 1)
+
+	require.Len(t, firings, 3)
+	assert.Equal(t, 2, firings[0].Depth)
+	assert.True(t, firings[0].Cascaded)
+	assert.Equal(t, 1, firings[1].Depth)
+	assert.False(t, firings[1].Cascaded)
+	assert.Equal(t, 0, firings[2].Depth)
+}
+
+func TestRulesBuild(t *testing.T) {
+	_, err := rules.New().WithRules(rules.Rule{ID: "a"}, rules.Rule{ID: "a"}).Build()
+	assert.Error(t, err)
+
+	_, err = rules.New().WithRules(rules.Rule{}).Build()
+	assert.Error(t, err)
+}
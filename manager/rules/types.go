@@ -0,0 +1,137 @@
+package rules
+
+import (
+	"context"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/notifiermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/copyutils"
+)
+
+// Rule executes its actions when a report on a shadow matches the selection.
+type Rule struct {
+	// ID is the unique id of the rule.
+	ID string
+	// Where is the condition on the report notification e.g. a `selectlang.ToSelection` expression or a `Value`
+	// condition. When `nil`, all reports match.
+	Where notifiermodel.Selection
+	// Actions are executed, in order, when the rule matches.
+	Actions []Action
+	// RateLimit limits how often the rule is executed.
+	RateLimit RateLimit
+}
+
+// RateLimit allows at most `Max` executions of a rule within `Per`. When `Max` is zero, the rule is not limited.
+type RateLimit struct {
+	Max int
+	Per time.Duration
+}
+
+// Action is executed when a rule matches the _op_. The _ctx_ carries the cascade depth (see `Depth`) and shall be
+// passed to any manager operation.
+type Action interface {
+	Execute(ctx context.Context, manager managermodel.Desireable, op notifiermodel.NotifierOperation) error
+}
+
+// ActionFunc is a `Action` made of a function.
+type ActionFunc func(ctx context.Context, manager managermodel.Desireable, op notifiermodel.NotifierOperation) error
+
+func (f ActionFunc) Execute(ctx context.Context, manager managermodel.Desireable, op notifiermodel.NotifierOperation) error {
+	return f(ctx, manager, op)
+}
+
+// DesireAction desires a model on the shadow of the report or on another shadow. The model has precedence over
+// the current desired values, regardless of its timestamps.
+type DesireAction struct {
+	// Shadow is the shadow to desire. An empty `ID` or `Name` is taken from the reported shadow.
+	Shadow persistencemodel.ID
+	// ClientID is the client id of the desire operation. Default is `DefaultClientID`.
+	ClientID string
+	// ModelType is the registered name of the model. Otherwise it is resolved via the shadow.
+	ModelType string
+	// Model is the desired model, it is copied on each execution.
+	Model any
+	// MergeMode is the merge mode to use.
+	MergeMode merge.MergeMode
+}
+
+func (a DesireAction) Execute(ctx context.Context, manager managermodel.Desireable, op notifiermodel.NotifierOperation) error {
+	id := a.Shadow
+
+	if id.ID == "" {
+		id.ID = op.ID.ID
+	}
+
+	if id.Name == "" {
+		id.Name = op.ID.Name
+	}
+
+	clientID := a.ClientID
+
+	if clientID == "" {
+		clientID = DefaultClientID
+	}
+
+	res := manager.Desire(ctx, managermodel.DesireOperation{
+		ClientID:         clientID,
+		ID:               id,
+		ModelType:        a.ModelType,
+		Model:            copyutils.DeepCopy(a.Model),
+		MergeMode:        a.MergeMode,
+		IgnoreTimestamps: true,
+	})
+
+	return res[0].Error
+}
+
+// NotifyAction raises a notification of the report on the `Notifier`. The id of the rule is set in the custom
+// property `CustomRule`.
+type NotifyAction struct {
+	Notifier notifiermodel.Notifier
+}
+
+func (a NotifyAction) Execute(ctx context.Context, manager managermodel.Desireable, op notifiermodel.NotifierOperation) error {
+	for _, r := range a.Notifier.Process(ctx, nil, op) {
+		if r.Error != nil {
+			return r.Error
+		}
+	}
+
+	return nil
+}
+
+// Firing is the outcome of a matching rule.
+type Firing struct {
+	// Rule is the id of the rule.
+	Rule string
+	// ID is the shadow that was reported.
+	ID persistencemodel.ID
+	// Depth is the cascade depth of the report.
+	Depth int
+	// Limited is set when the rule was not executed due to its `RateLimit`.
+	Limited bool
+	// Cascaded is set when the rule was not executed since the maximum cascade depth was reached.
+	Cascaded bool
+	// Error is the error of the first failed action. The remaining actions are not executed.
+	Error error
+}
+
+type depthKey struct{}
+
+// Depth returns the cascade depth of the _ctx_, i.e. the number of rule actions that did lead to the current
+// operation. It is only carried in the context, an operation that is not made with the context of the action,
+// e.g. a device report over the network, is at depth zero.
+func Depth(ctx context.Context) int {
+	if d, ok := ctx.Value(depthKey{}).(int); ok {
+		return d
+	}
+
+	return 0
+}
+
+func withDepth(ctx context.Context, depth int) context.Context {
+	return context.WithValue(ctx, depthKey{}, depth)
+}
@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+)
+
+type builder struct {
+	s *Scheduler
+}
+
+// New creates a builder for a `Scheduler` that applies the schedules of the _manager_.
+func New(manager managermodel.Scheduler) *builder {
+	return &builder{
+		s: &Scheduler{manager: manager},
+	}
+}
+
+func (b *builder) Build() *Scheduler {
+	now := b.s.now
+
+	if now == nil {
+		now = time.Now
+	}
+
+	return &Scheduler{
+		manager: b.s.manager,
+		now:     now,
+		onRun:   b.s.onRun,
+	}
+}
+
+// WithClock sets the function that returns the current time. It decides which schedules are due. Default is
+// `time.Now`.
+func (b *builder) WithClock(now func() time.Time) *builder {
+	b.s.now = now
+	return b
+}
+
+// WithOnRun sets a function that is invoked for each applied schedule, e.g. to log `Schedule.LastError`.
+func (b *builder) WithOnRun(f func(schedule managermodel.Schedule)) *builder {
+	b.s.onRun = f
+	return b
+}
@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+)
+
+// Scheduler applies the due schedules of a `managermodel.Scheduler`, i.e. the desire operations that were
+// scheduled using `managermodel.DesireOperation.ActivateAt`. All state is persisted by the manager and hence, many
+// schedulers may run and a new `Scheduler` continues after a restart.
+type Scheduler struct {
+	manager managermodel.Scheduler
+	now     func() time.Time
+	onRun   func(schedule managermodel.Schedule)
+}
+
+// Step applies all schedules that are due now and returns them as updated by the run.
+func (s *Scheduler) Step(ctx context.Context) ([]managermodel.Schedule, error) {
+	applied, err := s.manager.ApplySchedules(ctx, s.now())
+
+	if s.onRun != nil {
+		for _, schedule := range applied {
+			s.onRun(schedule)
+		}
+	}
+
+	return applied, err
+}
+
+// Run calls `Step` every _interval_ until the _ctx_ is done. A failed step is retried on next interval.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, _ = s.Step(ctx)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
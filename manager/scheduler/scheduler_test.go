@@ -0,0 +1,317 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/manager/scheduler"
+	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+	"github.com/mariotoffia/godeviceshadow/types"
+	"github.com/mariotoffia/godeviceshadow/validate"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Sensor struct {
+	Value     any
+	TimeStamp time.Time
+}
+
+func (sp *Sensor) GetTimestamp() time.Time {
+	return sp.TimeStamp
+}
+
+func (sp *Sensor) GetValue() any {
+	return sp.Value
+}
+
+type TestModel struct {
+	Sensors map[string]Sensor
+}
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func setpoint(t *testing.T, mgr *stdmgr.ManagerImpl, id persistencemodel.ID) any {
+	res := mgr.Read(context.Background(), managermodel.ReadOperation{
+		ID: id.ToPersistenceID(persistencemodel.ModelTypeDesired),
+	})
+
+	require.NoError(t, res[0].Error)
+
+	return res[0].Model.(TestModel).Sensors["sp"].Value
+}
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithIdempotency(16, 0).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	clk := &clock{now: time.Now().UTC()}
+	s := scheduler.New(mgr).WithClock(clk.Now).Build()
+	id := persistencemodel.ID{ID: "device-1", Name: "homeHub"}
+
+	desire := func(sp float64, at time.Time, recurrence time.Duration) string {
+		res := mgr.Desire(ctx, managermodel.DesireOperation{
+			ClientID:   "operator",
+			ID:         id,
+			Model:      TestModel{Sensors: map[string]Sensor{"sp": {Value: sp, TimeStamp: at}}},
+			ActivateAt: at,
+			Recurrence: recurrence,
+		})
+
+		require.NoError(t, res[0].Error)
+		require.NotEmpty(t, res[0].ScheduleID)
+		assert.False(t, res[0].Processed, "only scheduled")
+
+		return res[0].ScheduleID
+	}
+
+	boost := desire(23, clk.now.Add(time.Hour), 0)
+	setback := desire(17, clk.now.Add(2*time.Hour), 24*time.Hour) // nightly
+
+	schedules, err := mgr.ListSchedules(ctx, id)
+
+	require.NoError(t, err)
+	require.Len(t, schedules, 2)
+	assert.Equal(t, boost, schedules[0].ID)
+	assert.Equal(t, setback, schedules[1].ID)
+
+	applied, err := s.Step(ctx)
+
+	require.NoError(t, err)
+	assert.Empty(t, applied, "nothing is due")
+
+	// The boost is applied and removed
+	clk.now = clk.now.Add(time.Hour)
+	applied, err = s.Step(ctx)
+
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.Empty(t, applied[0].LastError)
+	assert.Equal(t, 23.0, setpoint(t, mgr, id))
+
+	_, err = mgr.ReadSchedule(ctx, boost)
+	require.Error(t, err)
+
+	// The setback recurs, missed activations are skipped
+	clk.now = clk.now.Add(25 * time.Hour)
+	applied, err = s.Step(ctx)
+
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.Equal(t, 1, applied[0].Runs)
+	assert.Equal(t, 17.0, setpoint(t, mgr, id))
+
+	schedule, err := mgr.ReadSchedule(ctx, setback)
+
+	require.NoError(t, err)
+	assert.True(t, schedule.ActivateAt > clk.now.UnixNano())
+	assert.Equal(t, clk.now.Add(24*time.Hour).UnixNano(), schedule.ActivateAt)
+
+	// Schedules are not shadows
+	list, err := mgr.List(ctx)
+
+	require.NoError(t, err)
+
+	for _, item := range list.Items {
+		assert.NotEqual(t, managermodel.SchedulesID, item.ID.ID)
+	}
+
+	require.NoError(t, mgr.CancelSchedule(ctx, setback))
+	require.Error(t, mgr.CancelSchedule(ctx, setback))
+
+	schedules, err = mgr.ListSchedules(ctx)
+
+	require.NoError(t, err)
+	assert.Empty(t, schedules)
+}
+
+func TestSchedulerChecksBeforePersist(t *testing.T) {
+	ctx := context.Background()
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithLimits(validate.Limits{MaxMapEntries: 1}).
+		WithInterceptors(managermodel.InterceptorFuncs{
+			BeforeFunc: func(ctx context.Context, call *managermodel.InterceptCall) error {
+				if call.Desire != nil && call.Desire.ClientID == "intruder" {
+					return persistencemodel.Error403("denied")
+				}
+
+				return nil
+			},
+		}).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	at := time.Now().UTC().Add(time.Hour)
+	id := persistencemodel.ID{ID: "device-1", Name: "homeHub"}
+	one := TestModel{Sensors: map[string]Sensor{"sp": {Value: 21.0, TimeStamp: at}}}
+
+	res := mgr.Desire(ctx,
+		managermodel.DesireOperation{ClientID: "intruder", ID: id, Model: one, ActivateAt: at},
+		managermodel.DesireOperation{ID: id, ActivateAt: at, Model: TestModel{Sensors: map[string]Sensor{
+			"sp": {Value: 21.0, TimeStamp: at}, "boost": {Value: 23.0, TimeStamp: at},
+		}}},
+		managermodel.DesireOperation{ID: id, Model: one, ActivateAt: at, Preconditions: []model.Precondition{
+			{Path: "Sensors.sp.Value", Operator: model.PreconditionPresent},
+		}},
+		managermodel.DesireOperation{ID: id, Model: one, ActivateAt: at, ClientToken: "t-1"},
+		managermodel.DesireOperation{ID: persistencemodel.ID{ID: "device-1", Name: "homeHub:status"}, Model: one, ActivateAt: at},
+	)
+
+	require.Len(t, res, 5)
+
+	for i, code := range []int{403, 413, 400, 400, 400} {
+		var pe persistencemodel.PersistenceError
+
+		require.True(t, errors.As(res[i].Error, &pe), "operation %d", i)
+		assert.Equal(t, code, pe.Code, "operation %d", i)
+		assert.Empty(t, res[i].ScheduleID, "operation %d", i)
+	}
+
+	schedules, err := mgr.ListSchedules(ctx)
+
+	require.NoError(t, err)
+	assert.Empty(t, schedules)
+}
+
+func TestSchedulerClaimsBeforeDesire(t *testing.T) {
+	ctx := context.Background()
+	id := persistencemodel.ID{ID: "device-1", Name: "homeHub"}
+	now := time.Now().UTC()
+
+	var (
+		mgr     *stdmgr.ManagerImpl
+		desired int
+		nested  bool
+	)
+
+	mgr = stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithInterceptors(managermodel.InterceptorFuncs{
+			BeforeFunc: func(ctx context.Context, call *managermodel.InterceptCall) error {
+				if call.Kind != managermodel.InterceptDesire || !call.Desire.ActivateAt.IsZero() {
+					return nil
+				}
+
+				desired++
+
+				// Another scheduler on the same tick
+				if !nested {
+					nested = true
+
+					applied, err := mgr.ApplySchedules(ctx, now)
+					require.NoError(t, err)
+					assert.Empty(t, applied)
+				}
+
+				return nil
+			},
+		}).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	for _, recurrence := range []time.Duration{0, time.Hour} {
+		desired, nested = 0, false
+
+		res := mgr.Desire(ctx, managermodel.DesireOperation{
+			ID:         id,
+			Model:      TestModel{Sensors: map[string]Sensor{"sp": {Value: 21.0, TimeStamp: now}}},
+			ActivateAt: now,
+			Recurrence: recurrence,
+		})
+
+		require.NoError(t, res[0].Error)
+
+		applied, err := mgr.ApplySchedules(ctx, now)
+		require.NoError(t, err)
+		require.Len(t, applied, 1)
+		assert.Equal(t, 1, desired, "recurrence %s", recurrence)
+	}
+}
+
+func TestSchedulerRecurrence(t *testing.T) {
+	ctx := context.Background()
+	id := persistencemodel.ID{ID: "device-1", Name: "homeHub"}
+	now := time.Now().UTC()
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	sp := TestModel{Sensors: map[string]Sensor{"sp": {Value: 21.0, TimeStamp: now}}}
+
+	res := mgr.Desire(ctx,
+		managermodel.DesireOperation{ID: id, Model: sp, ActivateAt: now, Recurrence: time.Nanosecond},
+		managermodel.DesireOperation{ID: id, Model: sp, Recurrence: time.Hour},
+	)
+
+	for _, r := range res {
+		var pe persistencemodel.PersistenceError
+
+		require.True(t, errors.As(r.Error, &pe))
+		assert.Equal(t, 400, pe.Code)
+		assert.Empty(t, r.ScheduleID)
+		assert.False(t, r.Processed)
+	}
+
+	// Missed activations, long ago, are skipped at once
+	start := now.AddDate(-100, 0, 0)
+
+	res = mgr.Desire(ctx, managermodel.DesireOperation{ID: id, Model: sp, ActivateAt: start, Recurrence: managermodel.MinRecurrence})
+	require.NoError(t, res[0].Error)
+
+	applied, err := mgr.ApplySchedules(ctx, now)
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+
+	next := applied[0].ActivateAt
+	assert.Greater(t, next, now.UnixNano())
+	assert.LessOrEqual(t, next, now.Add(managermodel.MinRecurrence).UnixNano())
+	assert.Zero(t, (next-start.UnixNano())%int64(managermodel.MinRecurrence))
+}
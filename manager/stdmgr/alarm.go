@@ -0,0 +1,62 @@
+package stdmgr
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// ListAlarms implements the `managermodel.Alarmer` interface. The alarm section is maintained by e.g. the
+// `alarms.Engine`.
+func (mgr *ManagerImpl) ListAlarms(ctx context.Context, id persistencemodel.ID, states ...managermodel.AlarmState) ([]managermodel.Alarm, error) {
+	section, _, err := mgr.readAlarms(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return section.List(states...), nil
+}
+
+// AcknowledgeAlarm implements the `managermodel.Alarmer` interface. Since the manager has no notifier, no
+// transition is emitted, use `alarms.Engine.AcknowledgeAlarm` to have it emitted. If the section is concurrently
+// updated, a 409 error is returned.
+func (mgr *ManagerImpl) AcknowledgeAlarm(ctx context.Context, id persistencemodel.ID, key, clientID string) (managermodel.Alarm, error) {
+	section, version, err := mgr.readAlarms(ctx, id)
+
+	if err != nil {
+		return managermodel.Alarm{}, err
+	}
+
+	alarm, err := section.Acknowledge(key, clientID, time.Now().UTC().UnixNano())
+
+	if err != nil {
+		return managermodel.Alarm{}, err
+	}
+
+	if err := mgr.writeSection(ctx, clientID, managermodel.AlarmsID(id), section, version); err != nil {
+		return managermodel.Alarm{}, err
+	}
+
+	return alarm, nil
+}
+
+// readAlarms reads the alarm section and the version of it. If not found, an empty section with version zero is
+// returned.
+func (mgr *ManagerImpl) readAlarms(ctx context.Context, id persistencemodel.ID) (managermodel.Alarms, int64, error) {
+	section, version, err := readSection[managermodel.Alarms](ctx, mgr, managermodel.AlarmsID(id))
+
+	if err != nil {
+		return managermodel.Alarms{}, 0, err
+	}
+
+	// Copy since the persistence may return the stored instance
+	alarms := managermodel.Alarms{Entries: map[string]managermodel.Alarm{}, Pending: map[string]int64{}}
+	maps.Copy(alarms.Entries, section.Entries)
+	maps.Copy(alarms.Pending, section.Pending)
+
+	return alarms, version, nil
+}
@@ -1,8 +1,13 @@
 package stdmgr
 
 import (
+	"time"
+
 	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/observemodel"
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/validate"
 )
 
 type builder struct {
@@ -30,6 +35,13 @@ func (b *builder) Build() *ManagerImpl {
 		reportedMergeLoggers:   b.m.reportedMergeLoggers,
 		reportedDesiredLoggers: b.m.reportedDesiredLoggers,
 		desiredMergeLoggers:    b.m.desiredMergeLoggers,
+		desiredStatus:          b.m.desiredStatus,
+		interceptors:           b.m.interceptors,
+		validation:             b.m.validation,
+		limits:                 b.m.limits,
+		tokenWindow:            b.m.tokenWindow,
+		tokenMaxAge:            b.m.tokenMaxAge,
+		observer:               observemodel.OrNop(b.m.observer),
 	}
 }
 
@@ -85,3 +97,54 @@ func (b *builder) WithReportDesiredLoggers(desiredLoggers ...model.CreatableDesi
 	b.m.reportedDesiredLoggers = desiredLoggers
 	return b
 }
+
+// WithDesiredStatusTracking enables tracking of the lifecycle status (pending, delivered, acknowledged, expired
+// and rejected) of each desired value. The status is persisted in a separate section of the shadow and can be
+// queried using `ReadDesiredStatus` or read together with the desired model.
+func (b *builder) WithDesiredStatusTracking() *builder {
+	b.m.desiredStatus = true
+	return b
+}
+
+// WithValidation enables validation of the merged reported and desired models before they are written. It uses
+// the `validate.Tag` rules and the `model.Validator` interface. Violations fails the operation with a
+// `*validate.ValidationError` that unwraps into a 422 (Unprocessable Entity) `persistencemodel.PersistenceError`.
+func (b *builder) WithValidation() *builder {
+	b.m.validation = true
+	return b
+}
+
+// WithLimits sets the size limits that are checked before the reported and desired models are written.
+// Violations fails the operation with a `*validate.LimitError` that unwraps into a 413 (Content Too Large)
+// `persistencemodel.PersistenceError`. The `validate.Limits.MaxNamedShadows` is checked when a shadow is created.
+func (b *builder) WithLimits(limits validate.Limits) *builder {
+	b.m.limits = limits
+	return b
+}
+
+// WithIdempotency enables idempotent `Report` and `Desire` operations. An operation whose `ClientToken` matches
+// a recently applied token, of the same kind on the same shadow, returns the original result without merging
+// again. At most _size_ tokens (`managermodel.DefaultTokenWindow` when zero) no older than _maxAge_ (if set) are
+// kept per shadow.
+func (b *builder) WithIdempotency(size int, maxAge time.Duration) *builder {
+	if size <= 0 {
+		size = managermodel.DefaultTokenWindow
+	}
+
+	b.m.tokenWindow, b.m.tokenMaxAge = size, maxAge
+	return b
+}
+
+// WithObserver sets the observer that receives a span, the duration and the outcome of each operation, the merge
+// durations, conflicts and duplicates. The span is carried in the context passed on to the persistence.
+func (b *builder) WithObserver(observer observemodel.Observer) *builder {
+	b.m.observer = observer
+	return b
+}
+
+// WithInterceptors adds interceptors that wraps the `Report`, `Desire`, `Read`, `List` and `Delete` functions. The
+// `Interceptor.Before` are invoked in the order they are added and `Interceptor.After` in reverse order.
+func (b *builder) WithInterceptors(interceptors ...managermodel.Interceptor) *builder {
+	b.m.interceptors = append(b.m.interceptors, interceptors...)
+	return b
+}
@@ -0,0 +1,286 @@
+package stdmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// relocation is a single model, or section, to copy.
+type relocation struct {
+	op persistencemodel.CopyOperation
+	// model is the type to read when the persistence is not a `persistencemodel.Copier`.
+	model reflect.Type
+	// section is `true` when a shadow section, such as the desired status.
+	section bool
+}
+
+// Copy implements the `managermodel.Relocator` interface.
+func (mgr *ManagerImpl) Copy(ctx context.Context, op managermodel.CopyOperation) (res managermodel.CopyResult, err error) {
+	res = managermodel.CopyResult{From: op.From, To: op.To}
+
+	ctx, obs := mgr.observe(ctx, "copy")
+
+	defer func() {
+		obs.end(err)
+	}()
+
+	switch {
+	case op.From.ID == "" || op.From.Name == "" || op.To.ID == "" || op.To.Name == "":
+		return res, persistencemodel.Error400("both from and to id and name are required")
+	case op.From == op.To:
+		return res, persistencemodel.Error400(fmt.Sprintf("cannot copy %s onto itself", op.From))
+	case op.Move && op.ModelType != 0:
+		return res, persistencemodel.Error400("a move always moves both models, model type must be zero")
+	case mgr.separation == persistencemodel.CombinedModels && op.ModelType != 0:
+		return res, persistencemodel.Error400("combined models are copied together, model type must be zero")
+	}
+
+	for _, id := range []persistencemodel.ID{op.From, op.To} {
+		if err := checkReservedID(id); err != nil {
+			return res, err
+		}
+	}
+
+	te, ok := mgr.ResolveType("", op.From)
+
+	if !ok {
+		return res, persistencemodel.Error400(fmt.Sprintf("could not resolve model for id: %s", op.From))
+	}
+
+	if len(mgr.interceptors) > 0 {
+		call := &managermodel.InterceptCall{Kind: managermodel.InterceptCopy, ID: op.From, Type: te, Copy: &op}
+
+		defer func() {
+			call.CopyResult = &res
+			call.CopyError = err
+			mgr.interceptAfter(ctx, call)
+		}()
+
+		if err := mgr.interceptBefore(ctx, call); err != nil {
+			return res, err
+		}
+	}
+
+	// A rename do not add a named shadow
+	if !op.Move || op.From.ID != op.To.ID {
+		if err := mgr.checkNamedShadows(ctx, op.To); err != nil {
+			return res, err
+		}
+	}
+
+	relocations := mgr.relocations(op, te.Model)
+
+	var results []persistencemodel.WriteResult
+
+	if c, ok := mgr.persistence.(persistencemodel.Copier); ok {
+		ops := make([]persistencemodel.CopyOperation, len(relocations))
+
+		for i, r := range relocations {
+			ops[i] = r.op
+		}
+
+		res.Atomic = true
+		results = c.Copy(ctx, persistencemodel.WriteOptions{}, ops...)
+	} else {
+		results = mgr.copyEach(ctx, relocations)
+	}
+
+	for i, wr := range results {
+		if wr.Error != nil && !(relocations[i].op.Optional && isNotFound(wr.Error)) {
+			return res, wr.Error
+		}
+
+		if wr.Error == nil && !relocations[i].section {
+			res.Models = append(res.Models, wr)
+		}
+	}
+
+	if len(res.Models) == 0 {
+		return res, persistencemodel.Error404(fmt.Sprintf("shadow %s not found", op.From))
+	}
+
+	return res, nil
+}
+
+// Move implements the `managermodel.Relocator` interface.
+func (mgr *ManagerImpl) Move(ctx context.Context, from, to persistencemodel.ID, clientID string) (managermodel.CopyResult, error) {
+	return mgr.Copy(ctx, managermodel.CopyOperation{ClientID: clientID, From: from, To: to, Move: true})
+}
+
+// Rename implements the `managermodel.Relocator` interface.
+func (mgr *ManagerImpl) Rename(ctx context.Context, id persistencemodel.ID, name, clientID string) (managermodel.CopyResult, error) {
+	return mgr.Move(ctx, id, persistencemodel.ID{ID: id.ID, Name: name}, clientID)
+}
+
+// relocations returns the models, and when a move the sections, to copy. All, but a combined model, are
+// optional since a shadow may lack any of them.
+func (mgr *ManagerImpl) relocations(op managermodel.CopyOperation, model reflect.Type) []relocation {
+	var relocations []relocation
+
+	add := func(from, to persistencemodel.PersistenceID, model reflect.Type, optional, section bool) {
+		relocations = append(relocations, relocation{
+			op: persistencemodel.CopyOperation{
+				ClientID: op.ClientID,
+				From:     from,
+				To:       to,
+				Move:     op.Move,
+				Optional: optional,
+			},
+			model:   model,
+			section: section,
+		})
+	}
+
+	if mgr.separation == persistencemodel.CombinedModels {
+		add(op.From.ToPersistenceID(0), op.To.ToPersistenceID(0), model, false, false)
+	} else {
+		for _, mt := range []persistencemodel.ModelType{persistencemodel.ModelTypeReported, persistencemodel.ModelTypeDesired} {
+			if op.ModelType == 0 || op.ModelType == mt {
+				add(op.From.ToPersistenceID(mt), op.To.ToPersistenceID(mt), model, true, false)
+			}
+		}
+	}
+
+	if op.Move {
+		add(managermodel.DesiredStatusID(op.From), managermodel.DesiredStatusID(op.To),
+			reflect.TypeOf(managermodel.DesiredStatuses{}), true, true)
+		add(managermodel.RejectionsID(op.From), managermodel.RejectionsID(op.To),
+			reflect.TypeOf(managermodel.Rejections{}), true, true)
+		add(managermodel.ClientTokensID(op.From), managermodel.ClientTokensID(op.To),
+			reflect.TypeOf(managermodel.ClientTokens{}), true, true)
+		add(managermodel.AlarmsID(op.From), managermodel.AlarmsID(op.To),
+			reflect.TypeOf(managermodel.Alarms{}), true, true)
+	}
+
+	return relocations
+}
+
+// copyEach copies the _relocations_ one by one when the persistence is not a `persistencemodel.Copier`. If any
+// copy fails, the already copied models are deleted. The sources are deleted, when a move, after all are copied.
+func (mgr *ManagerImpl) copyEach(ctx context.Context, relocations []relocation) []persistencemodel.WriteResult {
+	results := make([]persistencemodel.WriteResult, len(relocations))
+	versions := make([]int64, len(relocations))
+	copied := make([]persistencemodel.WriteOperation, 0, len(relocations))
+
+	fail := func(err error) []persistencemodel.WriteResult {
+		if len(copied) > 0 {
+			mgr.persistence.Delete(ctx, persistencemodel.WriteOptions{}, copied...)
+		}
+
+		for i := range results {
+			results[i].Error = err
+		}
+
+		return results
+	}
+
+	for i, r := range relocations {
+		results[i].ID = r.op.To
+
+		read := mgr.persistence.Read(ctx, persistencemodel.ReadOptions{}, persistencemodel.ReadOperation{
+			ID:    r.op.From,
+			Model: r.model,
+		})
+
+		if len(read) == 0 || read[0].Error != nil {
+			var err error = persistencemodel.Error404(fmt.Sprintf("%s - Not found", r.op.From))
+
+			if len(read) > 0 {
+				err = read[0].Error
+			}
+
+			if r.op.Optional && isNotFound(err) {
+				results[i].Error = err
+				continue
+			}
+
+			return fail(err)
+		}
+
+		if target := mgr.persistence.Read(ctx, persistencemodel.ReadOptions{}, persistencemodel.ReadOperation{
+			ID:    r.op.To,
+			Model: r.model,
+		}); len(target) > 0 && !isNotFound(target[0].Error) {
+			if target[0].Error != nil {
+				return fail(target[0].Error)
+			}
+
+			return fail(persistencemodel.Error409(fmt.Sprintf("Conflict, %s already exists", r.op.To)))
+		}
+
+		sep := persistencemodel.SeparateModels
+
+		if r.op.From.ModelType == 0 {
+			sep = persistencemodel.CombinedModels
+		}
+
+		writes := make([]persistencemodel.WriteOperation, 0, len(read))
+
+		for _, rr := range read {
+			writes = append(writes, persistencemodel.WriteOperation{
+				ClientID:      r.op.ClientID,
+				ID:            r.op.To.ToID().ToPersistenceID(rr.ID.ModelType),
+				Model:         rr.Model,
+				SchemaVersion: rr.SchemaVersion, // copied as persisted, i.e. not migrated
+				Config:        persistencemodel.WriteOperationConfig{Separation: sep},
+			})
+		}
+
+		for _, wr := range mgr.persistence.Write(ctx, persistencemodel.WriteOptions{
+			Config: persistencemodel.WriteConfig{Separation: sep},
+		}, writes...) {
+			if wr.Error != nil {
+				return fail(wr.Error)
+			}
+
+			results[i].Version, results[i].TimeStamp = wr.Version, wr.TimeStamp
+		}
+
+		versions[i] = read[0].Version
+		copied = append(copied, persistencemodel.WriteOperation{ID: r.op.To})
+	}
+
+	var deletes []persistencemodel.WriteOperation
+
+	for i, r := range relocations {
+		if r.op.Move && results[i].Error == nil {
+			deletes = append(deletes, persistencemodel.WriteOperation{ID: r.op.From, Version: versions[i]})
+		}
+	}
+
+	if len(deletes) == 0 {
+		return results
+	}
+
+	// The copies are kept if the sources could not be deleted since those may already be partially deleted
+	for _, dr := range mgr.persistence.Delete(ctx, persistencemodel.WriteOptions{}, deletes...) {
+		if dr.Error != nil {
+			for i := range results {
+				if results[i].Error == nil {
+					results[i].Error = dr.Error
+				}
+			}
+
+			break
+		}
+	}
+
+	return results
+}
+
+func isNotFound(err error) bool {
+	var pe persistencemodel.PersistenceError
+
+	return errors.As(err, &pe) && pe.Code == 404
+}
+
+func isConflict(err error) bool {
+	var pe persistencemodel.PersistenceError
+
+	return errors.As(err, &pe) && pe.Code == 409
+}
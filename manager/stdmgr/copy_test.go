@@ -0,0 +1,122 @@
+package stdmgr_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+	"github.com/mariotoffia/godeviceshadow/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// plainPersistence hides the `persistencemodel.Copier` of the embedded persistence.
+type plainPersistence struct {
+	persistencemodel.Persistence
+}
+
+func newCopyManager(t *testing.T, persistence persistencemodel.Persistence) *stdmgr.ManagerImpl {
+	mgr := stdmgr.New().
+		WithPersistence(persistence).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithDesiredStatusTracking().
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	for _, id := range []string{"device-old", "device-other"} {
+		sensors := map[string]Sensor{"temp": {Value: 19.0, TimeStamp: time.Now().UTC()}}
+
+		rres := mgr.Report(context.Background(), managermodel.ReportOperation{
+			ID:    persistencemodel.ID{ID: id, Name: "homeHub"},
+			Model: TestModel{Sensors: sensors},
+		})
+
+		require.NoError(t, rres[0].Error)
+
+		dres := mgr.Desire(context.Background(), managermodel.DesireOperation{
+			ID:    persistencemodel.ID{ID: id, Name: "homeHub"},
+			Model: TestModel{Sensors: map[string]Sensor{"sp": {Value: 21.0, TimeStamp: time.Now().UTC()}}},
+		})
+
+		require.NoError(t, dres[0].Error)
+	}
+
+	return mgr
+}
+
+func TestMoveShadow(t *testing.T) {
+	for name, atomic := range map[string]bool{"copier": true, "model-by-model": false} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			var persistence persistencemodel.Persistence = mempersistence.New()
+
+			if !atomic {
+				persistence = plainPersistence{persistence}
+			}
+
+			mgr := newCopyManager(t, persistence)
+			from := persistencemodel.ID{ID: "device-old", Name: "homeHub"}
+			to := persistencemodel.ID{ID: "device-new", Name: "homeHub"}
+
+			// Fails on conflict and nothing is moved
+			_, err := mgr.Move(ctx, from, persistencemodel.ID{ID: "device-other", Name: "homeHub"}, "installer")
+			require.Error(t, err)
+
+			read := mgr.Read(ctx, managermodel.ReadOperation{ID: from.ToPersistenceID(persistencemodel.ModelTypeDesired)})
+			require.NoError(t, read[0].Error)
+
+			res, err := mgr.Move(ctx, from, to, "installer")
+			require.NoError(t, err)
+			assert.Equal(t, atomic, res.Atomic)
+			assert.Len(t, res.Models, 2)
+
+			read = mgr.Read(ctx, managermodel.ReadOperation{ID: from.ToPersistenceID(persistencemodel.ModelTypeDesired)})
+			assert.Error(t, read[0].Error)
+
+			read = mgr.Read(ctx, managermodel.ReadOperation{ID: to.ToPersistenceID(persistencemodel.ModelTypeDesired)})
+			require.NoError(t, read[0].Error)
+			assert.Equal(t, 21.0, read[0].Model.(TestModel).Sensors["sp"].Value)
+
+			read = mgr.Read(ctx, managermodel.ReadOperation{ID: to.ToPersistenceID(persistencemodel.ModelTypeReported)})
+			require.NoError(t, read[0].Error)
+			assert.Equal(t, 19.0, read[0].Model.(TestModel).Sensors["temp"].Value)
+
+			// Desired status is moved as well
+			statuses, err := mgr.ReadDesiredStatus(ctx, to)
+			require.NoError(t, err)
+			assert.Equal(t, managermodel.DesiredStatePending, statuses.Entries["Sensors.sp"].State)
+
+			// Rename under the same id
+			_, err = mgr.Rename(ctx, to, "livingRoom", "installer")
+			require.NoError(t, err)
+
+			// Copy only the desired
+			res, err = mgr.Copy(ctx, managermodel.CopyOperation{
+				From:      persistencemodel.ID{ID: "device-new", Name: "livingRoom"},
+				To:        persistencemodel.ID{ID: "device-spare", Name: "livingRoom"},
+				ModelType: persistencemodel.ModelTypeDesired,
+			})
+
+			require.NoError(t, err)
+			require.Len(t, res.Models, 1)
+			assert.Equal(t, persistencemodel.ModelTypeDesired, res.Models[0].ID.ModelType)
+
+			_, err = mgr.Copy(ctx, managermodel.CopyOperation{From: from, To: to})
+			require.Error(t, err)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package stdmgr
 
 import (
 	"context"
+	"slices"
 
 	"github.com/mariotoffia/godeviceshadow/model/managermodel"
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
@@ -12,29 +13,140 @@ import (
 //
 // If model type is _zero_ in the operation it will delete both reported and desired model in one go since it signals a combined storage.
 // If separate storage the model type *must* be provided.
-func (mgr *ManagerImpl) Delete(ctx context.Context, operations ...managermodel.DeleteOperation) []managermodel.DeleteOperationOperationResult {
+//
+// When the shadow has no model left, its sections, e.g. the rejections and client tokens, are deleted as well.
+// Otherwise a new shadow with the same id would inherit them.
+func (mgr *ManagerImpl) Delete(ctx context.Context, operations ...managermodel.DeleteOperation) (result []managermodel.DeleteOperationOperationResult) {
 	if len(operations) == 0 {
 		return nil
 	}
 
-	result := make([]managermodel.DeleteOperationOperationResult, 0, len(operations))
+	ctx, obs := mgr.observe(ctx, "delete")
+
+	defer func() {
+		obs.end(resultErrors(result, func(r managermodel.DeleteOperationOperationResult) error { return r.Error })...)
+	}()
+
+	result = make([]managermodel.DeleteOperationOperationResult, len(operations))
 	deletes := make([]persistencemodel.WriteOperation, 0, len(operations))
+	// indices are the index of the operation for each delete
+	indices := make([]int, 0, len(operations))
+	calls := make([]*managermodel.InterceptCall, len(operations))
+
+	if len(mgr.interceptors) > 0 {
+		operations = slices.Clone(operations) // interceptors may alter the operations
+	}
+
+	for i := range operations {
+		op := &operations[i]
+		result[i] = managermodel.DeleteOperationOperationResult{ID: op.ID}
+
+		if len(mgr.interceptors) > 0 {
+			calls[i] = &managermodel.InterceptCall{Kind: managermodel.InterceptDelete, ID: op.ID.ToID(), Delete: op}
+
+			if err := mgr.interceptBefore(ctx, calls[i]); err != nil {
+				result[i].Error = err
+				continue
+			}
+		}
 
-	for _, op := range operations {
 		deletes = append(deletes, persistencemodel.WriteOperation{
 			ID:      op.ID,
 			Version: op.Version,
 		})
+
+		indices = append(indices, i)
 	}
 
-	results := mgr.persistence.Delete(ctx, persistencemodel.WriteOptions{}, deletes...)
+	if len(deletes) > 0 {
+		results := mgr.persistence.Delete(ctx, persistencemodel.WriteOptions{}, deletes...)
 
-	for _, res := range results {
-		result = append(result, managermodel.DeleteOperationOperationResult{
-			ID:    res.ID,
-			Error: res.Error,
-		})
+		for n, res := range results {
+			if n < len(indices) {
+				result[indices[n]] = managermodel.DeleteOperationOperationResult{
+					ID:    res.ID,
+					Error: res.Error,
+				}
+			}
+		}
+	}
+
+	mgr.deleteSections(ctx, result)
+
+	for i, call := range calls {
+		if call != nil {
+			call.DeleteResult = &result[i]
+			mgr.interceptAfter(ctx, call)
+		}
 	}
 
 	return result
 }
+
+// deleteSections deletes the sections of each shadow, in the _result_, that was deleted and has no model left.
+// A failure is set as `SectionError` since the shadow itself is deleted.
+func (mgr *ManagerImpl) deleteSections(ctx context.Context, result []managermodel.DeleteOperationOperationResult) {
+	for i, r := range result {
+		if r.Error != nil {
+			continue
+		}
+
+		id := r.ID.ToID()
+
+		if r.ID.ModelType != 0 {
+			exists, err := mgr.shadowExists(ctx, id)
+
+			if err != nil {
+				result[i].SectionError = err
+				continue
+			}
+
+			if exists {
+				continue
+			}
+		}
+
+		ids := sectionIDs(id)
+		deletes := make([]persistencemodel.WriteOperation, 0, len(ids))
+
+		for _, sid := range ids {
+			deletes = append(deletes, persistencemodel.WriteOperation{
+				ID:     sid,
+				Config: persistencemodel.WriteOperationConfig{Separation: persistencemodel.SeparateModels},
+			})
+		}
+
+		for _, dr := range mgr.persistence.Delete(ctx, persistencemodel.WriteOptions{
+			Config: persistencemodel.WriteConfig{Separation: persistencemodel.SeparateModels},
+		}, deletes...) {
+			if dr.Error != nil && !isNotFound(dr.Error) && result[i].SectionError == nil {
+				result[i].SectionError = dr.Error
+			}
+		}
+	}
+}
+
+// shadowExists returns `true` if the shadow _id_ has a reported or desired model.
+func (mgr *ManagerImpl) shadowExists(ctx context.Context, id persistencemodel.ID) (bool, error) {
+	opt := persistencemodel.ListOptions{ID: id.ID}
+
+	for {
+		res, err := mgr.persistence.List(ctx, opt)
+
+		if err != nil {
+			return false, err
+		}
+
+		for _, item := range res.Items {
+			if item.ID.ID == id.ID && item.ID.Name == id.Name {
+				return true, nil
+			}
+		}
+
+		if res.Token == "" {
+			return false, nil
+		}
+
+		opt.Token = res.Token
+	}
+}
@@ -1,24 +1,55 @@
 package stdmgr
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
-	"maps"
 	"reflect"
+	"slices"
+	"time"
 
 	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model"
 	"github.com/mariotoffia/godeviceshadow/model/managermodel"
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
 )
 
-func (mgr *ManagerImpl) Desire(ctx context.Context, operations ...managermodel.DesireOperation) []managermodel.DesireOperationResult {
+// Desire will merge one or more desired models into the desired models in persistence.
+//
+// Operations on the same shadow are folded, in input order, onto a single read of the shadow and persisted using a
+// single write. Each operation still gets its own loggers, statistics and model in its result.
+//
+// TIP: It will *always* return a slice of `managermodel.DesireOperationResult` with the same length and order as the input `operations`.
+func (mgr *ManagerImpl) Desire(ctx context.Context, operations ...managermodel.DesireOperation) (all []managermodel.DesireOperationResult) {
 	if len(operations) == 0 {
 		return nil
 	}
 
-	res := make(map[string]*managermodel.DesireOperationResult)
+	ctx, obs := mgr.observe(ctx, "desire")
+
+	defer func() {
+		obs.end(resultErrors(all, func(r managermodel.DesireOperationResult) error { return r.Error })...)
+	}()
+
+	operations = slices.Clone(operations) // interceptors may alter the operations
+
+	var res []*managermodel.DesireOperationResult
+
+	if mgr.tokenWindow > 0 {
+		res = mgr.desireDuplicates(ctx, operations)
+	} else {
+		res = make([]*managermodel.DesireOperationResult, len(operations))
+	}
+
+	mgr.desireSchedule(ctx, operations, res)
+
+	types := make([]model.TypeEntry, len(operations))
+	calls := make([]*managermodel.InterceptCall, len(operations))
+	changes := make([]*desiredChangeCollector, len(operations))
 	readOps := make([]persistencemodel.ReadOperation, 0, len(operations))
+	// shadows maps the `persistencemodel.ID.String()` to the indices, in input order, of all operations on that shadow.
+	shadows := make(map[string][]int, len(operations))
 
 	readOperation := func(id persistencemodel.PersistenceID) *persistencemodel.ReadOperation {
 		for _, op := range readOps {
@@ -30,22 +61,26 @@ func (mgr *ManagerImpl) Desire(ctx context.Context, operations ...managermodel.D
 		return nil
 	}
 
-	desiredOperation := func(id persistencemodel.PersistenceID) *managermodel.DesireOperation {
-		for _, op := range operations {
-			if op.ID.ID == id.ID && op.ID.Name == id.Name {
-				return &op
+	setShadowError := func(key string, err error) {
+		for _, idx := range shadows[key] {
+			if res[idx] == nil {
+				res[idx] = &managermodel.DesireOperationResult{ID: operations[idx].ID, Error: err}
 			}
 		}
-
-		return nil
 	}
 
 	// Create read operations
-	for _, op := range operations {
+	for i, op := range operations {
 		te, ok := mgr.ResolveType(op.ModelType, op.ID)
 
+		if res[i] != nil {
+			// Duplicate, already applied, or scheduled
+			types[i] = te
+			continue
+		}
+
 		if !ok {
-			res[op.ID.String()] = &managermodel.DesireOperationResult{
+			res[i] = &managermodel.DesireOperationResult{
 				ID:    op.ID,
 				Error: fmt.Errorf("could not resolve model for id: %s", op.ID),
 			}
@@ -53,22 +88,68 @@ func (mgr *ManagerImpl) Desire(ctx context.Context, operations ...managermodel.D
 			continue
 		}
 
+		types[i] = te
+
+		if len(mgr.interceptors) > 0 {
+			// Before the read, since the interceptor may alter the operation
+			call := &managermodel.InterceptCall{
+				Kind:   managermodel.InterceptDesire,
+				ID:     op.ID,
+				Type:   te,
+				Desire: &operations[i],
+			}
+
+			calls[i] = call
+
+			if err := mgr.interceptBefore(ctx, call); err != nil {
+				res[i] = &managermodel.DesireOperationResult{ID: op.ID, Error: err}
+				continue
+			}
+
+			op = operations[i]
+		}
+
+		if err := checkReservedID(op.ID); err != nil {
+			res[i] = &managermodel.DesireOperationResult{ID: op.ID, Error: err}
+			continue
+		}
+
+		key := op.ID.String()
 		sep := mgr.separation
 
 		if op.Separation > 0 {
 			sep = op.Separation
 		}
 
+		if indices, ok := shadows[key]; ok {
+			// Already read -> fold onto the first operation on the same shadow, it is written the same way as read
+			if first := operations[indices[0]].Separation; cmp.Or(first, mgr.separation) != sep {
+				res[i] = &managermodel.DesireOperationResult{
+					ID:    op.ID,
+					Error: persistencemodel.Error400(fmt.Sprintf("separation differs from a previous operation on %s", op.ID)),
+				}
+
+				continue
+			}
+
+			shadows[key] = append(indices, i)
+			continue
+		}
+
+		shadows[key] = []int{i}
+
 		// We always read the last version
 		if sep == persistencemodel.CombinedModels {
 			readOps = append(readOps, persistencemodel.ReadOperation{
-				ID:    persistencemodel.PersistenceID{ID: op.ID.ID, Name: op.ID.Name, ModelType: 0 /*combined*/},
-				Model: te.Model,
+				ID:     persistencemodel.PersistenceID{ID: op.ID.ID, Name: op.ID.Name, ModelType: 0 /*combined*/},
+				Model:  te.Model,
+				Schema: te.Schema,
 			})
 		} else /*separate*/ {
 			readOps = append(readOps, persistencemodel.ReadOperation{
-				ID:    persistencemodel.PersistenceID{ID: op.ID.ID, Name: op.ID.Name, ModelType: persistencemodel.ModelTypeDesired},
-				Model: te.Model,
+				ID:     persistencemodel.PersistenceID{ID: op.ID.ID, Name: op.ID.Name, ModelType: persistencemodel.ModelTypeDesired},
+				Model:  te.Model,
+				Schema: te.Schema,
 			})
 		}
 	}
@@ -102,7 +183,6 @@ func (mgr *ManagerImpl) Desire(ctx context.Context, operations ...managermodel.D
 						id:       rr.ID.ToID(),
 						reported: &reported,
 						desired:  &desired,
-						dop:      desiredOperation(desired.ID),
 					}
 
 					continue
@@ -110,10 +190,7 @@ func (mgr *ManagerImpl) Desire(ctx context.Context, operations ...managermodel.D
 			}
 
 			if rr.Error != nil {
-				res[rr.ID.String()] = &managermodel.DesireOperationResult{
-					ID: rr.ID.ToID(), Error: rr.Error,
-				}
-
+				setShadowError(rr.ID.StringWithoutModelType(), rr.Error)
 				continue
 			}
 		}
@@ -132,7 +209,6 @@ func (mgr *ManagerImpl) Desire(ctx context.Context, operations ...managermodel.D
 				id:       rr.ID.ToID(),
 				reported: reported,
 				desired:  desired,
-				dop:      desiredOperation(rr.ID),
 			}
 		} else {
 			if reported != nil {
@@ -145,62 +221,141 @@ func (mgr *ManagerImpl) Desire(ctx context.Context, operations ...managermodel.D
 		}
 	}
 
-	// Remove all that has no desired operation
-	keys := maps.Keys(grouped)
-	for k := range keys {
-		grp := grouped[k]
+	// Fold the operations, in read order, onto each shadow
+	ordered := make([]*groupedPersistenceResult, 0, len(grouped))
 
-		if grp.dop == nil {
-			delete(grouped, k)
+	for _, rop := range readOps {
+		key := rop.ID.StringWithoutModelType()
+		grp, ok := grouped[key]
+
+		if !ok || grp.desired == nil || len(shadows[key]) == 0 {
+			continue
 		}
-	}
 
-	for _, rr := range grouped {
-		// Create merge loggers
-		ml := mgr.createMergeLoggers(false /*report*/, rr.dop.MergeLoggers)
+		indices := shadows[key]
+		grp.schemaVersion = types[indices[0]].Schema.Version
+
+		ordered = append(ordered, grp)
 
-		// Merge the model
-		mergeMode := merge.ServerIsMaster
+		current := grp.desired.Model
 
-		if rr.dop.MergeMode > 0 {
-			mergeMode = rr.dop.MergeMode
+		// The preconditions are checked against the stored model, a new shadow has no values
+		var stored any
+
+		if grp.desired.Version > 0 {
+			stored = current
 		}
 
-		newDesired, err := merge.MergeAny(context.Background(), rr.desired.Model, rr.dop.Model, merge.MergeOptions{
-			Mode:    mergeMode,
-			Loggers: ml,
-		})
+		for _, idx := range indices {
+			op := &operations[idx]
 
-		if err != nil {
-			res[rr.dop.ID.String()] = &managermodel.DesireOperationResult{
-				ID:    rr.dop.ID,
-				Error: err,
+			if call := calls[idx]; call != nil {
+				call.Desired = current
+
+				if grp.reported != nil {
+					call.Reported = grp.reported.Model
+				}
 			}
 
-			continue
-		}
+			if op.Version > 0 && op.Version != grp.desired.Version {
+				res[idx] = &managermodel.DesireOperationResult{
+					ID: op.ID,
+					Error: persistencemodel.Error409(
+						fmt.Sprintf("version mismatch, expected: %d, got: %d", op.Version, grp.desired.Version),
+					),
+				}
 
-		dl, _ := FindMergeDirtyLogger(ml)
+				continue
+			}
 
-		res[rr.dop.ID.String()] = &managermodel.DesireOperationResult{
-			ID:           rr.dop.ID,
-			Model:        newDesired,
-			MergeLoggers: ml,
-		}
+			if err := checkPreconditions(ctx, stored, op.Preconditions); err != nil {
+				res[idx] = &managermodel.DesireOperationResult{ID: op.ID, Error: err}
+				continue
+			}
+
+			// Create merge loggers
+			ml := mgr.createMergeLoggers(false /*report*/, op.MergeLoggers)
+
+			// Merge the model
+			mergeMode := merge.ServerIsMaster
+
+			if op.MergeMode > 0 {
+				mergeMode = op.MergeMode
+			}
 
-		if dl.Dirty {
-			rr.queueDesired = newDesired
+			var stats merge.Stats
+
+			changes[idx] = &desiredChangeCollector{}
+
+			start := time.Now()
+
+			newDesired, err := merge.MergeAny(ctx, current, op.Model, merge.MergeOptions{
+				Mode:             mergeMode,
+				IgnoreTimestamps: op.IgnoreTimestamps,
+				Loggers:          append(merge.MergeLoggers{changes[idx]}, ml...),
+				Stats:            &stats,
+			})
+
+			mgr.observeMerge(ctx, managermodel.InterceptDesire, start)
+
+			if err != nil {
+				// Discard the changes of the operation
+				changes[idx] = nil
+				res[idx] = &managermodel.DesireOperationResult{
+					ID:    op.ID,
+					Error: err,
+				}
+
+				continue
+			}
+
+			dl, _ := FindMergeDirtyLogger(ml)
+
+			res[idx] = &managermodel.DesireOperationResult{
+				ID:           op.ID,
+				Model:        newDesired,
+				MergeLoggers: ml,
+				Stats:        stats,
+			}
+
+			current, stored = newDesired, newDesired
+
+			if !dl.Dirty {
+				changes[idx] = nil
+				continue
+			}
+
+			// The last successful operation owns the write (client id and separation)
+			grp.dop, grp.queueDesired = op, current
 
 			// If combined persistence reported has to be written
-			if rr.reported != nil {
-				rr.queueReported = rr.reported.Model
+			if grp.reported != nil {
+				grp.queueReported = grp.reported.Model
 			}
 		}
 	}
 
-	writes := make([]persistencemodel.WriteOperation, 0, len(grouped)*2)
+	if mgr.checks() {
+		for _, grp := range ordered {
+			if grp.queueDesired == nil {
+				continue
+			}
+
+			if err := mgr.checkModels(ctx, grp.id, nil, grp.queueDesired, grp.created()); err != nil {
+				grp.queueDesired, grp.queueReported = nil, nil
 
-	for _, grp := range grouped {
+				for _, idx := range shadows[grp.id.String()] {
+					if res[idx] != nil && res[idx].Error == nil && changes[idx] != nil {
+						res[idx].Error = err
+					}
+				}
+			}
+		}
+	}
+
+	writes := make([]persistencemodel.WriteOperation, 0, len(ordered)*2)
+
+	for _, grp := range ordered {
 		if grp.queueDesired == nil {
 			continue
 		}
@@ -212,20 +367,22 @@ func (mgr *ManagerImpl) Desire(ctx context.Context, operations ...managermodel.D
 		}
 
 		writes = append(writes, persistencemodel.WriteOperation{
-			ID:       persistencemodel.PersistenceID{ID: grp.id.ID, Name: grp.id.Name, ModelType: persistencemodel.ModelTypeDesired},
-			Model:    grp.queueDesired,
-			ClientID: grp.dop.ClientID,
-			Version:  grp.desired.Version,
-			Config:   persistencemodel.WriteOperationConfig{Separation: sep},
+			ID:            persistencemodel.PersistenceID{ID: grp.id.ID, Name: grp.id.Name, ModelType: persistencemodel.ModelTypeDesired},
+			Model:         grp.queueDesired,
+			ClientID:      grp.dop.ClientID,
+			Version:       grp.desired.Version,
+			SchemaVersion: grp.schemaVersion,
+			Config:        persistencemodel.WriteOperationConfig{Separation: sep},
 		})
 
 		if sep == persistencemodel.CombinedModels && grp.queueReported != nil {
 			writes = append(writes, persistencemodel.WriteOperation{
-				ID:       persistencemodel.PersistenceID{ID: grp.id.ID, Name: grp.id.Name, ModelType: persistencemodel.ModelTypeReported},
-				Model:    grp.queueReported,
-				ClientID: grp.dop.ClientID,
-				Version:  grp.reported.Version,
-				Config:   persistencemodel.WriteOperationConfig{Separation: sep},
+				ID:            persistencemodel.PersistenceID{ID: grp.id.ID, Name: grp.id.Name, ModelType: persistencemodel.ModelTypeReported},
+				Model:         grp.queueReported,
+				ClientID:      grp.dop.ClientID,
+				Version:       grp.reported.Version,
+				SchemaVersion: grp.schemaVersion,
+				Config:        persistencemodel.WriteOperationConfig{Separation: sep},
 			})
 		}
 	}
@@ -233,27 +390,116 @@ func (mgr *ManagerImpl) Desire(ctx context.Context, operations ...managermodel.D
 	writeResults := mgr.persistence.Write(ctx, persistencemodel.WriteOptions{}, writes...)
 
 	for _, wr := range writeResults {
-		if wr.Error != nil {
-			res[wr.ID.String()] = &managermodel.DesireOperationResult{
-				ID:        wr.ID.ToID(),
-				Error:     wr.Error,
-				Version:   wr.Version,
-				TimeStamp: wr.TimeStamp,
+		for _, idx := range shadows[wr.ID.StringWithoutModelType()] {
+			// Only the operations with changes did contribute to the write
+			if res[idx] == nil || res[idx].Error != nil || changes[idx] == nil {
+				continue
 			}
 
-			continue
+			if wr.Error != nil {
+				res[idx].Error = wr.Error
+				res[idx].Version = wr.Version
+				res[idx].TimeStamp = wr.TimeStamp
+
+				continue
+			}
+
+			res[idx].Processed = true
+
+			if wr.ID.ModelType == persistencemodel.ModelTypeDesired {
+				res[idx].Version = wr.Version
+				res[idx].TimeStamp = wr.TimeStamp
+			}
+		}
+	}
+
+	if mgr.watches.active() {
+		mgr.desirePublish(writes, writeResults, shadows, res)
+	}
+
+	// The desired model is already persisted (and published), a failed status must not fail the operation
+	if mgr.desiredStatus {
+		for i, dop := range res {
+			if dop != nil && dop.Processed && dop.Error == nil && !dop.Duplicate {
+				if err := mgr.desireUpdateStatus(ctx, &operations[i], changes[i]); err != nil {
+					dop.SectionError = err
+				}
+			}
 		}
+	}
+
+	if mgr.tokenWindow > 0 {
+		mgr.desireRecordTokens(ctx, operations, shadows, res)
+	}
 
-		if dop, ok := res[wr.ID.StringWithoutModelType()]; ok {
-			dop.Processed = true
+	all = make([]managermodel.DesireOperationResult, len(operations))
+
+	for i, v := range res {
+		if v == nil {
+			all[i] = managermodel.DesireOperationResult{ID: operations[i].ID}
+			continue
 		}
+
+		all[i] = *v
 	}
 
-	all := make([]managermodel.DesireOperationResult, 0, len(res))
+	if len(mgr.interceptors) > 0 {
+		for i := range all {
+			call := calls[i]
+
+			if call == nil {
+				// Never reached the merge
+				call = &managermodel.InterceptCall{
+					Kind:   managermodel.InterceptDesire,
+					ID:     operations[i].ID,
+					Type:   types[i],
+					Desire: &operations[i],
+				}
+			}
 
-	for _, v := range res {
-		all = append(all, *v)
+			call.DesireResult = &all[i]
+			mgr.interceptAfter(ctx, call)
+		}
 	}
 
 	return all
 }
+
+// desirePublish emits a `managermodel.WatchEvent` for each successful write of a desired model.
+func (mgr *ManagerImpl) desirePublish(
+	writes []persistencemodel.WriteOperation,
+	writeResults []persistencemodel.WriteResult,
+	shadows map[string][]int,
+	res []*managermodel.DesireOperationResult,
+) {
+	events := make([]managermodel.WatchEvent, 0, len(writeResults))
+
+	for _, wr := range writeResults {
+		if wr.Error != nil || wr.ID.ModelType != persistencemodel.ModelTypeDesired {
+			continue
+		}
+
+		event := managermodel.WatchEvent{
+			Kind:      managermodel.InterceptDesire,
+			ID:        wr.ID,
+			Version:   wr.Version,
+			TimeStamp: wr.TimeStamp,
+		}
+
+		for _, w := range writes {
+			if w.ID.Equal(wr.ID) {
+				event.Model, event.ClientID = w.Model, w.ClientID
+			}
+		}
+
+		for _, idx := range shadows[wr.ID.StringWithoutModelType()] {
+			if res[idx] != nil && res[idx].Processed {
+				event.MergeLoggers = append(event.MergeLoggers, res[idx].MergeLoggers...)
+			}
+		}
+
+		events = append(events, event)
+	}
+
+	mgr.watches.publish(events...)
+}
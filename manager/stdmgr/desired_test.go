@@ -2,14 +2,17 @@ package stdmgr_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/mariotoffia/godeviceshadow/loggers/changelogger"
 	"github.com/mariotoffia/godeviceshadow/loggers/desirelogger"
 	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/merge"
 	"github.com/mariotoffia/godeviceshadow/model"
 	"github.com/mariotoffia/godeviceshadow/model/managermodel"
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
@@ -286,6 +289,140 @@ func TestDesiredAcknowledge(t *testing.T) {
 // states. All is done as separate models.
 //
 // This takes around 13μs on my machine.
+func TestDesireBatchPreservesOrderAndFolds(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	var mgr managermodel.Manager = stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	first := persistencemodel.ID{ID: "device1", Name: "homeHub"}
+	second := persistencemodel.ID{ID: "device2", Name: "homeHub"}
+
+	res := mgr.Desire(ctx,
+		managermodel.DesireOperation{
+			ClientID: "myClient",
+			ID:       first,
+			Model:    TestModel{Sensors: map[string]Sensor{"temp": {Value: 22.0, TimeStamp: now}}},
+		},
+		managermodel.DesireOperation{
+			ClientID: "myClient",
+			ID:       second,
+			Model:    TestModel{Sensors: map[string]Sensor{"temp": {Value: 19.0, TimeStamp: now}}},
+		},
+		managermodel.DesireOperation{
+			ClientID: "myClient",
+			ID:       first,
+			Model:    TestModel{Sensors: map[string]Sensor{"temp": {Value: 21.0, TimeStamp: now.Add(time.Second)}}},
+		},
+	)
+
+	require.Len(t, res, 3)
+	assert.Equal(t, first, res[0].ID)
+	assert.Equal(t, second, res[1].ID)
+	assert.Equal(t, first, res[2].ID)
+
+	for _, r := range res {
+		require.NoError(t, r.Error)
+		assert.True(t, r.Processed)
+	}
+
+	assert.Equal(t, 22.0, res[0].Model.(TestModel).Sensors["temp"].Value)
+	assert.Equal(t, 21.0, res[2].Model.(TestModel).Sensors["temp"].Value)
+
+	// The last operation wins and both are written once
+	rr := mgr.Read(ctx, managermodel.ReadOperation{ID: first.ToPersistenceID(persistencemodel.ModelTypeDesired)})
+	require.Len(t, rr, 1)
+	require.NoError(t, rr[0].Error)
+	assert.Equal(t, int64(1), rr[0].Version)
+	assert.Equal(t, 21.0, rr[0].Model.(TestModel).Sensors["temp"].Value)
+}
+
+func TestDesirePreconditionsAndVersion(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	id := persistencemodel.ID{ID: "device1", Name: "homeHub"}
+
+	var mgr managermodel.Manager = stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	// Only create when absent
+	res := mgr.Desire(ctx, managermodel.DesireOperation{
+		ID:    id,
+		Model: TestModel{Sensors: map[string]Sensor{"temp": {Value: 22.0, TimeStamp: now}}},
+		Preconditions: []model.Precondition{
+			{Path: "Sensors.temp", Operator: model.PreconditionAbsent},
+		},
+	})
+
+	require.Len(t, res, 1)
+	require.NoError(t, res[0].Error)
+	require.True(t, res[0].Processed)
+
+	// Two operators: the second one did see 21.0 and fails
+	res = mgr.Desire(ctx,
+		managermodel.DesireOperation{
+			ID:    id,
+			Model: TestModel{Sensors: map[string]Sensor{"temp": {Value: 23.0, TimeStamp: now.Add(time.Second)}}},
+			Preconditions: []model.Precondition{
+				{Path: "Sensors.temp", Operator: model.PreconditionEquals, Value: 22.0},
+			},
+		},
+		managermodel.DesireOperation{
+			ID:    id,
+			Model: TestModel{Sensors: map[string]Sensor{"temp": {Value: 19.0, TimeStamp: now.Add(2 * time.Second)}}},
+			Preconditions: []model.Precondition{
+				{Path: "Sensors.temp", Operator: model.PreconditionEquals, Value: 21.0},
+			},
+		},
+	)
+
+	require.Len(t, res, 2)
+	require.NoError(t, res[0].Error)
+	assert.True(t, res[0].Processed)
+
+	var pe persistencemodel.PersistenceError
+
+	require.ErrorAs(t, res[1].Error, &pe)
+	assert.Equal(t, 412, pe.Code)
+	assert.False(t, res[1].Processed)
+
+	// Stale version
+	res = mgr.Desire(ctx, managermodel.DesireOperation{
+		ID:      id,
+		Version: 1,
+		Model:   TestModel{Sensors: map[string]Sensor{"temp": {Value: 18.0, TimeStamp: now.Add(3 * time.Second)}}},
+	})
+
+	require.Len(t, res, 1)
+	require.ErrorAs(t, res[0].Error, &pe)
+	assert.Equal(t, 409, pe.Code)
+
+	rr := mgr.Read(ctx, managermodel.ReadOperation{ID: id.ToPersistenceID(persistencemodel.ModelTypeDesired)})
+	require.Len(t, rr, 1)
+	require.NoError(t, rr[0].Error)
+	assert.Equal(t, int64(2), rr[0].Version)
+	assert.Equal(t, 23.0, rr[0].Model.(TestModel).Sensors["temp"].Value)
+}
+
 func BenchmarkDesireReportThatAcknowledgesAndReadAgain(t *testing.B) {
 	ctx := context.Background()
 	now := time.Now()
@@ -372,3 +509,373 @@ func BenchmarkDesireReportThatAcknowledgesAndReadAgain(t *testing.B) {
 		assert.Equal(t, 23.4, reported.Sensors["temp"].Value)
 	}
 }
+
+func TestDesireCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	res := mgr.Desire(ctx, managermodel.DesireOperation{
+		Model: TestModel{TimeZone: tz, Sensors: map[string]Sensor{"temp": {Value: 23.4, TimeStamp: time.Now()}}},
+		ID:    persistencemodel.ID{ID: "device123", Name: "homeHub"},
+	})
+
+	require.Len(t, res, 1)
+	assert.ErrorIs(t, res[0].Error, context.Canceled)
+	assert.False(t, res[0].Processed)
+}
+
+func TestDesiredAndReportStats(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.CombinedModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	resDesire := mgr.Desire(ctx, managermodel.DesireOperation{
+		Model: TestModel{
+			TimeZone: tz,
+			Sensors:  map[string]Sensor{"temp": {Value: 23.4, TimeStamp: now}},
+		},
+		ID: persistencemodel.ID{ID: "device123", Name: "homeHub"},
+	})
+
+	require.Len(t, resDesire, 1)
+	require.NoError(t, resDesire[0].Error)
+	assert.Equal(t, 1, resDesire[0].Stats.Added)
+	assert.True(t, resDesire[0].Stats.Changed())
+
+	resReport := mgr.Report(ctx, managermodel.ReportOperation{
+		Model: TestModel{
+			TimeZone: tz,
+			Sensors: map[string]Sensor{
+				"temp":     {Value: 23.4, TimeStamp: now},
+				"humidity": {Value: 40.0, TimeStamp: now},
+			},
+		},
+		ID: persistencemodel.ID{ID: "device123", Name: "homeHub"},
+	})
+
+	require.Len(t, resReport, 1)
+	require.NoError(t, resReport[0].Error)
+	assert.Equal(t, 2, resReport[0].Stats.Added)
+	assert.Equal(t, 1, resReport[0].Stats.Acknowledged)
+}
+
+func TestReportRejectDesired(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.CombinedModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	id := persistencemodel.ID{ID: "device123", Name: "homeHub"}
+
+	resDesire := mgr.Desire(ctx, managermodel.DesireOperation{
+		Model: TestModel{
+			Sensors: map[string]Sensor{
+				"temp":  {Value: 35.0, TimeStamp: now},
+				"light": {Value: "on", TimeStamp: now},
+			},
+		},
+		ID: id,
+	})
+
+	require.Len(t, resDesire, 1)
+	require.NoError(t, resDesire[0].Error)
+
+	resReport := mgr.Report(ctx, managermodel.ReportOperation{
+		ClientID:       "myClient",
+		DesiredLoggers: []model.CreatableDesiredLogger{desirelogger.New()},
+		Model:          TestModel{TimeZone: tz},
+		ID:             id,
+		RejectedDesires: []model.RejectedDesire{
+			{Path: "Sensors.temp", Code: "out-of-range", Reason: "max 28 degrees"},
+		},
+	})
+
+	require.Len(t, resReport, 1)
+	require.NoError(t, resReport[0].Error)
+	assert.True(t, resReport[0].DesiredProcessed)
+	assert.Equal(t, 1, resReport[0].Stats.Rejected)
+	require.Len(t, resReport[0].Rejected, 1)
+	assert.Equal(t, "myClient", resReport[0].Rejected[0].ClientID)
+
+	var dl *desirelogger.DesireLogger
+
+	for _, lg := range resReport[0].DesiredLoggers {
+		if d, ok := lg.(*desirelogger.DesireLogger); ok {
+			dl = d
+		}
+	}
+
+	require.NotNil(t, dl)
+	assert.Contains(t, dl.Rejected(), "Sensors.temp")
+
+	// Rejected value is removed from desired while the rest is kept
+	desired := resReport[0].DesiredModel.(TestModel)
+	assert.NotContains(t, desired.Sensors, "temp")
+	assert.Contains(t, desired.Sensors, "light")
+
+	// Rejection section is persisted
+	rejections, err := mgr.ReadRejections(ctx, id)
+	require.NoError(t, err)
+	require.Contains(t, rejections.Entries, "Sensors.temp")
+	assert.Equal(t, "out-of-range", rejections.Entries["Sensors.temp"].Code)
+	assert.Equal(t, 35.0, rejections.Entries["Sensors.temp"].Value)
+
+	// The rejection section is not listed as a model
+	list, err := mgr.List(ctx)
+	require.NoError(t, err)
+
+	for _, item := range list.Items {
+		assert.Equal(t, "homeHub", item.ID.Name)
+	}
+}
+
+func TestReportStickyAckPolicy(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	id := persistencemodel.ID{ID: "device123", Name: "homeHub"}
+	sensors := map[string]Sensor{
+		"fw":   {Value: "stable", TimeStamp: now},
+		"temp": {Value: 23.4, TimeStamp: now},
+	}
+
+	resDesire := mgr.Desire(ctx, managermodel.DesireOperation{Model: TestModel{Sensors: sensors}, ID: id})
+	require.Len(t, resDesire, 1)
+	require.NoError(t, resDesire[0].Error)
+
+	resReport := mgr.Report(ctx, managermodel.ReportOperation{
+		Model:       TestModel{Sensors: sensors},
+		ID:          id,
+		AckPolicies: map[string]merge.AckPolicy{"Sensors.fw": merge.AckSticky},
+	})
+
+	require.Len(t, resReport, 1)
+	require.NoError(t, resReport[0].Error)
+	assert.Equal(t, 1, resReport[0].Stats.Acknowledged)
+
+	desired := resReport[0].DesiredModel.(TestModel)
+	assert.Contains(t, desired.Sensors, "fw")
+	assert.NotContains(t, desired.Sensors, "temp")
+}
+
+// failingSections fails all writes of the shadow sections.
+type failingSections struct {
+	persistencemodel.Persistence
+}
+
+func (p *failingSections) Write(
+	ctx context.Context,
+	opt persistencemodel.WriteOptions,
+	operations ...persistencemodel.WriteOperation,
+) []persistencemodel.WriteResult {
+	for _, op := range operations {
+		if strings.Contains(op.ID.Name, ":") {
+			res := make([]persistencemodel.WriteResult, len(operations))
+
+			for i, o := range operations {
+				res[i] = persistencemodel.WriteResult{ID: o.ID, Error: persistencemodel.Error500("section write failed")}
+			}
+
+			return res
+		}
+	}
+
+	return p.Persistence.Write(ctx, opt, operations...)
+}
+
+func TestReportRejectSectionFailureKeepsResult(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	mgr := stdmgr.New().
+		WithPersistence(&failingSections{Persistence: mempersistence.New()}).
+		WithSeparation(persistencemodel.CombinedModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	id := persistencemodel.ID{ID: "device123", Name: "homeHub"}
+
+	resDesire := mgr.Desire(ctx, managermodel.DesireOperation{
+		Model: TestModel{Sensors: map[string]Sensor{"temp": {Value: 35.0, TimeStamp: now}}},
+		ID:    id,
+	})
+
+	require.NoError(t, resDesire[0].Error)
+
+	resReport := mgr.Report(ctx, managermodel.ReportOperation{
+		Model:           TestModel{TimeZone: tz},
+		ID:              id,
+		RejectedDesires: []model.RejectedDesire{{Path: "Sensors.temp", Code: "out-of-range"}},
+	})
+
+	// The models are persisted, only the section failed
+	require.NoError(t, resReport[0].Error)
+	assert.True(t, resReport[0].DesiredProcessed)
+	assert.Error(t, resReport[0].SectionError)
+	assert.Empty(t, resReport[0].Rejected)
+}
+
+func TestDeleteRemovesSections(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithIdempotency(16, 0).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	id := persistencemodel.ID{ID: "device123", Name: "homeHub"}
+
+	require.NoError(t, mgr.Desire(ctx, managermodel.DesireOperation{
+		Model: TestModel{Sensors: map[string]Sensor{"temp": {Value: 35.0, TimeStamp: now}}},
+		ID:    id,
+	})[0].Error)
+
+	report := func() managermodel.ReportOperationResult {
+		return mgr.Report(ctx, managermodel.ReportOperation{
+			ClientToken:     "token-1",
+			Model:           TestModel{TimeZone: tz},
+			ID:              id,
+			RejectedDesires: []model.RejectedDesire{{Path: "Sensors.temp", Code: "out-of-range"}},
+		})[0]
+	}
+
+	require.NoError(t, report().Error)
+
+	rejections, err := mgr.ReadRejections(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, rejections.Entries, 1)
+
+	// The sections are kept while the shadow has a model
+	res := mgr.Delete(ctx, managermodel.DeleteOperation{ID: id.ToPersistenceID(persistencemodel.ModelTypeDesired)})
+	require.NoError(t, res[0].Error)
+
+	rejections, err = mgr.ReadRejections(ctx, id)
+	require.NoError(t, err)
+	assert.Len(t, rejections.Entries, 1)
+
+	res = mgr.Delete(ctx, managermodel.DeleteOperation{ID: id.ToPersistenceID(persistencemodel.ModelTypeReported)})
+	require.NoError(t, res[0].Error)
+	require.NoError(t, res[0].SectionError)
+
+	rejections, err = mgr.ReadRejections(ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, rejections.Entries)
+
+	// A new shadow with the same id does not inherit the client tokens
+	assert.False(t, report().Duplicate)
+}
+
+// recording records the write operations.
+type recording struct {
+	*mempersistence.Persistence
+	writes []persistencemodel.WriteOperation
+}
+
+func (p *recording) Write(
+	ctx context.Context,
+	opt persistencemodel.WriteOptions,
+	operations ...persistencemodel.WriteOperation,
+) []persistencemodel.WriteResult {
+	p.writes = append(p.writes, operations...)
+
+	return p.Persistence.Write(ctx, opt, operations...)
+}
+
+func TestDesireFoldWritesWithLastSuccessfulOperation(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	persistence := &recording{Persistence: mempersistence.New()}
+	mgr := stdmgr.New().
+		WithPersistence(persistence).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	id := persistencemodel.ID{ID: "device1", Name: "homeHub"}
+	sp := TestModel{Sensors: map[string]Sensor{"sp": {Value: 21.0, TimeStamp: now}}}
+
+	res := mgr.Desire(ctx,
+		managermodel.DesireOperation{ClientID: "operator", ID: id, Model: sp},
+		managermodel.DesireOperation{ClientID: "stale", ID: id, Model: sp, Version: 99},
+		managermodel.DesireOperation{ClientID: "combined", ID: id, Model: sp, Separation: persistencemodel.CombinedModels},
+	)
+
+	require.Len(t, res, 3)
+	require.NoError(t, res[0].Error)
+
+	var pe persistencemodel.PersistenceError
+
+	require.True(t, errors.As(res[1].Error, &pe))
+	assert.Equal(t, 409, pe.Code)
+	require.True(t, errors.As(res[2].Error, &pe))
+	assert.Equal(t, 400, pe.Code)
+
+	require.Len(t, persistence.writes, 1)
+	assert.Equal(t, "operator", persistence.writes[0].ClientID)
+	assert.Equal(t, persistencemodel.SeparateModels, persistence.writes[0].Config.Separation)
+}
@@ -0,0 +1,365 @@
+package stdmgr
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/copyutils"
+)
+
+// SaveGroup implements the `managermodel.Grouper` interface.
+func (mgr *ManagerImpl) SaveGroup(ctx context.Context, group managermodel.Group) error {
+	if err := group.Validate(); err != nil {
+		return err
+	}
+
+	return mgr.writeSection(ctx, "", managermodel.GroupID(group.Name), group, 0 /*latest*/)
+}
+
+// ReadGroup implements the `managermodel.Grouper` interface.
+func (mgr *ManagerImpl) ReadGroup(ctx context.Context, name string) (managermodel.Group, error) {
+	group, version, err := readSection[managermodel.Group](ctx, mgr, managermodel.GroupID(name))
+
+	if err != nil {
+		return managermodel.Group{}, err
+	}
+
+	if version == 0 {
+		return managermodel.Group{}, persistencemodel.Error404(fmt.Sprintf("group %s not found", name))
+	}
+
+	// Copy since the persistence may return the stored instance
+	group.Members = slices.Clone(group.Members)
+	group.Conditions = slices.Clone(group.Conditions)
+
+	return group, nil
+}
+
+// DeleteGroup implements the `managermodel.Grouper` interface.
+func (mgr *ManagerImpl) DeleteGroup(ctx context.Context, name string) error {
+	res := mgr.persistence.Delete(ctx, persistencemodel.WriteOptions{
+		Config: persistencemodel.WriteConfig{Separation: persistencemodel.SeparateModels},
+	}, persistencemodel.WriteOperation{
+		ID:     managermodel.GroupID(name),
+		Config: persistencemodel.WriteOperationConfig{Separation: persistencemodel.SeparateModels},
+	})
+
+	if len(res) > 0 {
+		return res[0].Error
+	}
+
+	return nil
+}
+
+// ListGroups implements the `managermodel.Grouper` interface.
+func (mgr *ManagerImpl) ListGroups(ctx context.Context) ([]managermodel.Group, error) {
+	var names []string
+
+	opt := persistencemodel.ListOptions{ID: managermodel.GroupsID}
+
+	for {
+		res, err := mgr.persistence.List(ctx, opt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range res.Items {
+			if name, ok := strings.CutSuffix(item.ID.Name, managermodel.GroupNameSuffix); ok && item.ID.ID == managermodel.GroupsID {
+				names = append(names, name)
+			}
+		}
+
+		if res.Token == "" {
+			break
+		}
+
+		opt.Token = res.Token
+	}
+
+	slices.Sort(names)
+
+	groups := make([]managermodel.Group, 0, len(names))
+
+	for _, name := range slices.Compact(names) {
+		group, err := mgr.ReadGroup(ctx, name)
+
+		if err != nil {
+			if isNotFound(err) {
+				continue // deleted in between
+			}
+
+			return nil, err
+		}
+
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// ReadGroupMembers implements the `managermodel.Grouper` interface.
+func (mgr *ManagerImpl) ReadGroupMembers(ctx context.Context, name string) ([]persistencemodel.ID, error) {
+	group, err := mgr.ReadGroup(ctx, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	members := map[persistencemodel.ID]bool{}
+
+	for _, id := range group.Members {
+		members[id] = true
+	}
+
+	if group.Dynamic() {
+		ids, err := mgr.listShadows(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+
+		matches, err := mgr.groupMatches(ctx, group, ids)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range matches {
+			members[id] = true
+		}
+	}
+
+	return sortedIDs(members), nil
+}
+
+// GroupsOf implements the `managermodel.Grouper` interface.
+func (mgr *ManagerImpl) GroupsOf(ctx context.Context, id persistencemodel.ID) ([]string, error) {
+	groups, err := mgr.GroupsOfEach(ctx, id)
+
+	return groups[id], err
+}
+
+// GroupsOfEach implements the `managermodel.Grouper` interface. It may be used as a `layers.GroupResolver`.
+func (mgr *ManagerImpl) GroupsOfEach(ctx context.Context, ids ...persistencemodel.ID) (map[persistencemodel.ID][]string, error) {
+	groups, err := mgr.ListGroups(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[persistencemodel.ID][]string, len(ids))
+
+	for _, group := range groups {
+		var matches []persistencemodel.ID
+
+		if group.Dynamic() {
+			if matches, err = mgr.groupMatches(ctx, group, ids); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, id := range ids {
+			if slices.Contains(group.Members, id) || slices.Contains(matches, id) {
+				names[id] = append(names[id], group.Name)
+			}
+		}
+	}
+
+	return names, nil
+}
+
+// ListGroup implements the `managermodel.Grouper` interface. The static members are listed by their id, only a
+// dynamic group lists all shadows.
+func (mgr *ManagerImpl) ListGroup(ctx context.Context, name string) ([]persistencemodel.ListResult, error) {
+	group, err := mgr.ReadGroup(ctx, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	members := map[persistencemodel.ID]bool{}
+
+	for _, id := range group.Members {
+		members[id] = true
+	}
+
+	var (
+		results []persistencemodel.ListResult
+		opts    []managermodel.ListOptions
+	)
+
+	if group.Dynamic() {
+		opts = []managermodel.ListOptions{{}}
+	} else {
+		for _, id := range sortedIDs(members) {
+			if !slices.ContainsFunc(opts, func(opt managermodel.ListOptions) bool { return opt.ID == id.ID }) {
+				opts = append(opts, managermodel.ListOptions{ID: id.ID})
+			}
+		}
+	}
+
+	for _, opt := range opts {
+		for {
+			res, err := mgr.list(ctx, opt)
+
+			if err != nil {
+				return nil, err
+			}
+
+			results = append(results, res.Items...)
+
+			if res.Token == "" {
+				break
+			}
+
+			opt.Token = res.Token
+		}
+	}
+
+	if group.Dynamic() {
+		seen := map[persistencemodel.ID]bool{}
+
+		for _, item := range results {
+			seen[item.ID.ToID()] = true
+		}
+
+		matches, err := mgr.groupMatches(ctx, group, sortedIDs(seen))
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range matches {
+			members[id] = true
+		}
+	}
+
+	return slices.DeleteFunc(results, func(item persistencemodel.ListResult) bool {
+		return !members[item.ID.ToID()]
+	}), nil
+}
+
+// DesireGroup implements the `managermodel.Grouper` interface.
+func (mgr *ManagerImpl) DesireGroup(
+	ctx context.Context,
+	name string,
+	operation managermodel.DesireOperation,
+) ([]managermodel.DesireOperationResult, error) {
+	members, err := mgr.ReadGroupMembers(ctx, name)
+
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+
+	operations := make([]managermodel.DesireOperation, len(members))
+
+	for i, id := range members {
+		operations[i] = operation
+		operations[i].ID = id
+		operations[i].Model = copyutils.DeepCopy(operation.Model)
+	}
+
+	return mgr.Desire(ctx, operations...), nil
+}
+
+// groupMatches returns the _ids_ that are dynamic members of the _group_, in the same order.
+func (mgr *ManagerImpl) groupMatches(
+	ctx context.Context,
+	group managermodel.Group,
+	ids []persistencemodel.ID,
+) ([]persistencemodel.ID, error) {
+	candidates := make([]persistencemodel.ID, 0, len(ids))
+
+	for _, id := range ids {
+		if group.ShadowName != "" && id.Name != group.ShadowName {
+			continue
+		}
+
+		if group.IDPattern != "" {
+			if ok, _ := path.Match(group.IDPattern, id.ID); !ok {
+				continue
+			}
+		}
+
+		candidates = append(candidates, id)
+	}
+
+	if len(group.Conditions) == 0 || len(candidates) == 0 {
+		return candidates, nil
+	}
+
+	ops := make([]managermodel.ReadOperation, len(candidates))
+
+	for i, id := range candidates {
+		ops[i] = managermodel.ReadOperation{ID: id.ToPersistenceID(persistencemodel.ModelTypeReported)}
+	}
+
+	reported := map[persistencemodel.ID]any{}
+
+	for _, rr := range mgr.Read(ctx, ops...) {
+		if rr.Error != nil {
+			if isNotFound(rr.Error) {
+				continue // never reported
+			}
+
+			return nil, rr.Error
+		}
+
+		reported[rr.ID.ToID()] = rr.Model
+	}
+
+	return slices.DeleteFunc(candidates, func(id persistencemodel.ID) bool {
+		m, ok := reported[id]
+
+		return !ok || checkPreconditions(ctx, m, group.Conditions) != nil
+	}), nil
+}
+
+// listShadows lists the ids of all shadows.
+func (mgr *ManagerImpl) listShadows(ctx context.Context) ([]persistencemodel.ID, error) {
+	seen := map[persistencemodel.ID]bool{}
+
+	var opt managermodel.ListOptions
+
+	for {
+		res, err := mgr.list(ctx, opt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range res.Items {
+			seen[item.ID.ToID()] = true
+		}
+
+		if res.Token == "" {
+			break
+		}
+
+		opt.Token = res.Token
+	}
+
+	return sortedIDs(seen), nil
+}
+
+func sortedIDs(set map[persistencemodel.ID]bool) []persistencemodel.ID {
+	ids := make([]persistencemodel.ID, 0, len(set))
+
+	for id := range set {
+		ids = append(ids, id)
+	}
+
+	slices.SortFunc(ids, compareIDs)
+
+	return ids
+}
+
+func compareIDs(a, b persistencemodel.ID) int {
+	return strings.Compare(a.String(), b.String())
+}
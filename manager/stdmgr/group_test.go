@@ -0,0 +1,185 @@
+package stdmgr_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+	"github.com/mariotoffia/godeviceshadow/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroups(t *testing.T) {
+	ctx := context.Background()
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: name, Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	for id, zone := range map[string]string{"site-1-hub": "Europe/Stockholm", "site-1-gw": "UTC", "site-2-hub": "UTC"} {
+		res := mgr.Report(ctx, managermodel.ReportOperation{
+			ID: persistencemodel.ID{ID: id, Name: "homeHub"},
+			Model: TestModel{
+				TimeZone: zone,
+				Sensors:  map[string]Sensor{"temp": {Value: 20.0, TimeStamp: time.Now().UTC()}},
+			},
+		})
+
+		require.NoError(t, res[0].Error)
+	}
+
+	hub := func(id string) persistencemodel.ID {
+		return persistencemodel.ID{ID: id, Name: "homeHub"}
+	}
+
+	require.NoError(t, mgr.SaveGroup(ctx, managermodel.Group{Name: "site-1", IDPattern: "site-1-*"}))
+	require.NoError(t, mgr.SaveGroup(ctx, managermodel.Group{
+		Name:       "utc",
+		ShadowName: "homeHub",
+		Conditions: []model.Precondition{{Path: "TimeZone", Operator: model.PreconditionEquals, Value: "UTC"}},
+	}))
+	require.NoError(t, mgr.SaveGroup(ctx, managermodel.Group{Name: "vip", Members: []persistencemodel.ID{hub("site-2-hub")}}))
+	require.Error(t, mgr.SaveGroup(ctx, managermodel.Group{Name: "bad", IDPattern: "site-["}))
+
+	members, err := mgr.ReadGroupMembers(ctx, "site-1")
+	require.NoError(t, err)
+	assert.Equal(t, []persistencemodel.ID{hub("site-1-gw"), hub("site-1-hub")}, members)
+
+	members, err = mgr.ReadGroupMembers(ctx, "utc")
+	require.NoError(t, err)
+	assert.Equal(t, []persistencemodel.ID{hub("site-1-gw"), hub("site-2-hub")}, members)
+
+	groups, err := mgr.ListGroups(ctx)
+	require.NoError(t, err)
+	require.Len(t, groups, 3)
+	assert.Equal(t, "site-1", groups[0].Name)
+
+	names, err := mgr.GroupsOf(ctx, hub("site-2-hub"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"utc", "vip"}, names)
+
+	each, err := mgr.GroupsOfEach(ctx, hub("site-1-hub"), hub("site-1-gw"), hub("site-2-hub"))
+	require.NoError(t, err)
+	assert.Equal(t, map[persistencemodel.ID][]string{
+		hub("site-1-hub"): {"site-1"},
+		hub("site-1-gw"):  {"site-1", "utc"},
+		hub("site-2-hub"): {"utc", "vip"},
+	}, each)
+
+	items, err := mgr.ListGroup(ctx, "vip")
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, hub("site-2-hub"), items[0].ID.ToID())
+
+	res, err := mgr.DesireGroup(ctx, "site-1", managermodel.DesireOperation{
+		ClientID: "operator",
+		Model:    TestModel{Sensors: map[string]Sensor{"sp": {Value: 21.0, TimeStamp: time.Now().UTC()}}},
+	})
+
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	assert.Equal(t, hub("site-1-gw"), res[0].ID)
+	assert.True(t, res[0].Processed)
+	assert.True(t, res[1].Processed)
+
+	// Groups are not shadows
+	list, err := mgr.List(ctx)
+	require.NoError(t, err)
+
+	for _, item := range list.Items {
+		assert.NotEqual(t, managermodel.GroupsID, item.ID.ID)
+	}
+
+	require.NoError(t, mgr.DeleteGroup(ctx, "vip"))
+
+	_, err = mgr.ReadGroup(ctx, "vip")
+	require.Error(t, err)
+
+	_, err = mgr.ReadGroupMembers(ctx, "vip")
+	require.Error(t, err)
+}
+
+// listing records the options of each list.
+type listing struct {
+	*mempersistence.Persistence
+	options []persistencemodel.ListOptions
+}
+
+func (p *listing) List(ctx context.Context, opt persistencemodel.ListOptions) (persistencemodel.ListResults, error) {
+	p.options = append(p.options, opt)
+
+	return p.Persistence.List(ctx, opt)
+}
+
+func TestListGroupStaticAndShadowName(t *testing.T) {
+	ctx := context.Background()
+	persistence := &listing{Persistence: mempersistence.New()}
+	mgr := stdmgr.New().
+		WithPersistence(persistence).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: name, Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	for _, id := range []persistencemodel.ID{
+		{ID: "device-1", Name: "homeHub"}, {ID: "device-1", Name: "garage"}, {ID: "device-2", Name: "homeHub"},
+	} {
+		res := mgr.Report(ctx, managermodel.ReportOperation{
+			ID:    id,
+			Model: TestModel{Sensors: map[string]Sensor{"temp": {Value: 20.0, TimeStamp: time.Now().UTC()}}},
+		})
+
+		require.NoError(t, res[0].Error)
+	}
+
+	require.NoError(t, mgr.SaveGroup(ctx, managermodel.Group{
+		Name: "vip", Members: []persistencemodel.ID{{ID: "device-1", Name: "garage"}},
+	}))
+
+	hubs := managermodel.Group{Name: "hubs", ShadowName: "homeHub"}
+
+	require.True(t, hubs.Dynamic())
+	require.NoError(t, mgr.SaveGroup(ctx, hubs))
+
+	// A static group only lists the ids of its members
+	persistence.options = nil
+
+	items, err := mgr.ListGroup(ctx, "vip")
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, persistencemodel.ID{ID: "device-1", Name: "garage"}, items[0].ID.ToID())
+
+	require.NotEmpty(t, persistence.options)
+
+	for _, opt := range persistence.options {
+		assert.Equal(t, "device-1", opt.ID)
+	}
+
+	members, err := mgr.ReadGroupMembers(ctx, "hubs")
+	require.NoError(t, err)
+	assert.Equal(t, []persistencemodel.ID{{ID: "device-1", Name: "homeHub"}, {ID: "device-2", Name: "homeHub"}}, members)
+
+	items, err = mgr.ListGroup(ctx, "hubs")
+	require.NoError(t, err)
+	assert.Len(t, items, 2)
+}
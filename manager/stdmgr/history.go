@@ -0,0 +1,35 @@
+package stdmgr
+
+import (
+	"context"
+
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// History implements the `managermodel.HistoryReceiver` interface. When the models are combined, the history
+// of the combined model is returned.
+func (mgr *ManagerImpl) History(ctx context.Context, id persistencemodel.PersistenceID) ([]persistencemodel.ListResult, error) {
+	hr, ok := mgr.persistence.(persistencemodel.HistoryReader)
+
+	if !ok {
+		return nil, persistencemodel.Error400("persistence do not retain history")
+	}
+
+	if id.ModelType == 0 {
+		return nil, persistencemodel.Error400("combined model type is not supported, specify Separation instead")
+	}
+
+	pid := id
+
+	if mgr.separation == persistencemodel.CombinedModels {
+		pid.ModelType = 0
+	}
+
+	res, err := hr.History(ctx, pid)
+
+	for i := range res {
+		res[i].ID = id
+	}
+
+	return res, err
+}
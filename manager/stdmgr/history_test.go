@@ -0,0 +1,65 @@
+package stdmgr_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+	"github.com/mariotoffia/godeviceshadow/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadDesiredAsOf(t *testing.T) {
+	ctx := context.Background()
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New(mempersistence.PersistenceOpts{
+			History: persistencemodel.Retention{MaxVersions: 10},
+		})).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	id := persistencemodel.ID{ID: "device123", Name: "homeHub"}
+	timestamps := make([]int64, 0, 3)
+
+	for _, sp := range []float64{20.0, 21.0, 22.0} {
+		res := mgr.Desire(ctx, managermodel.DesireOperation{
+			ID:    id,
+			Model: TestModel{Sensors: map[string]Sensor{"sp": {Value: sp, TimeStamp: time.Now().UTC()}}},
+		})
+
+		require.Len(t, res, 1)
+		require.NoError(t, res[0].Error)
+
+		timestamps = append(timestamps, res[0].TimeStamp)
+		time.Sleep(time.Millisecond)
+	}
+
+	history, err := mgr.History(ctx, id.ToPersistenceID(persistencemodel.ModelTypeDesired))
+	require.NoError(t, err)
+	assert.Len(t, history, 3)
+
+	res := mgr.Read(ctx, managermodel.ReadOperation{
+		ID:   id.ToPersistenceID(persistencemodel.ModelTypeDesired),
+		AsOf: timestamps[1],
+	})
+
+	require.Len(t, res, 1)
+	require.NoError(t, res[0].Error)
+	assert.Equal(t, history[1].Version, res[0].Version)
+	assert.Equal(t, 21.0, res[0].Model.(TestModel).Sensors["sp"].Value)
+}
@@ -0,0 +1,263 @@
+package stdmgr
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/observemodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// duplicates returns, for each operation that has a token, the applied token if it was recently applied on the
+// shadow. A token that is repeated, on the same shadow in the same call, is a duplicate of the first. The _ids_ and
+// _tokens_ are per operation.
+func (mgr *ManagerImpl) duplicates(
+	ctx context.Context,
+	kind managermodel.InterceptKind,
+	ids []persistencemodel.ID,
+	tokens []string,
+) ([]*managermodel.AppliedToken, []error) {
+	applied := make([]*managermodel.AppliedToken, len(ids))
+	errs := make([]error, len(ids))
+	readOps := make([]persistencemodel.ReadOperation, 0, len(ids))
+	read := map[string]bool{}
+
+	for i, id := range ids {
+		if tokens[i] != "" && !read[id.String()] {
+			read[id.String()] = true
+			readOps = append(readOps, persistencemodel.ReadOperation{
+				ID:    managermodel.ClientTokensID(id),
+				Model: reflect.TypeOf(managermodel.ClientTokens{}),
+			})
+		}
+	}
+
+	if len(readOps) == 0 {
+		return applied, errs
+	}
+
+	sections := map[string]managermodel.ClientTokens{}
+	failed := map[string]error{}
+
+	for _, rr := range mgr.persistence.Read(ctx, persistencemodel.ReadOptions{}, readOps...) {
+		if rr.Error != nil {
+			if !isNotFound(rr.Error) {
+				failed[rr.ID.StringWithoutModelType()] = rr.Error
+			}
+
+			continue
+		}
+
+		switch m := rr.Model.(type) {
+		case managermodel.ClientTokens:
+			sections[rr.ID.StringWithoutModelType()] = m
+		case *managermodel.ClientTokens:
+			sections[rr.ID.StringWithoutModelType()] = *m
+		}
+	}
+
+	seen := map[string]bool{}
+	now := time.Now().UTC().UnixNano()
+
+	for i, id := range ids {
+		if tokens[i] == "" {
+			continue
+		}
+
+		key := managermodel.ClientTokensID(id).StringWithoutModelType()
+
+		if err, ok := failed[key]; ok {
+			errs[i] = err
+			continue
+		}
+
+		if at, ok := sections[key].Find(kind, tokens[i]); ok && (mgr.tokenMaxAge <= 0 || now-at.AppliedAt <= int64(mgr.tokenMaxAge)) {
+			applied[i] = &at
+			continue
+		}
+
+		if seen[key+"/"+tokens[i]] {
+			applied[i] = &managermodel.AppliedToken{Token: tokens[i], Kind: kind}
+			continue
+		}
+
+		seen[key+"/"+tokens[i]] = true
+	}
+
+	return applied, errs
+}
+
+// recordTokens adds the _applied_ tokens to the client token section of the shadow _id_ and drops those that are
+// outside of the window.
+func (mgr *ManagerImpl) recordTokens(ctx context.Context, clientID string, id persistencemodel.ID, applied []managermodel.AppliedToken) error {
+	section, version, err := readSection[managermodel.ClientTokens](ctx, mgr, managermodel.ClientTokensID(id))
+
+	if err != nil {
+		return err
+	}
+
+	// Never modify the instance that the persistence may hold on to
+	tokens := managermodel.ClientTokens{Entries: append(append([]managermodel.AppliedToken(nil), section.Entries...), applied...)}
+	tokens.Trim(mgr.tokenWindow, mgr.tokenMaxAge, time.Now().UTC().UnixNano())
+
+	return mgr.writeSection(ctx, clientID, managermodel.ClientTokensID(id), tokens, version)
+}
+
+// reportDuplicates sets the result of all operations in the _batch_ that are duplicates.
+func (mgr *ManagerImpl) reportDuplicates(ctx context.Context, batch *reportBatch) {
+	ids := make([]persistencemodel.ID, len(batch.operations))
+	tokens := make([]string, len(batch.operations))
+
+	for i, op := range batch.operations {
+		ids[i], tokens[i] = op.ID, op.ClientToken
+	}
+
+	applied, errs := mgr.duplicates(ctx, managermodel.InterceptReport, ids, tokens)
+
+	for i, at := range applied {
+		switch {
+		case errs[i] != nil:
+			batch.results[i] = &managermodel.ReportOperationResult{ID: ids[i], Error: errs[i]}
+		case at != nil:
+			mgr.observer.Count(ctx, observemodel.ManagerDuplicates, 1, observemodel.Attr(observemodel.AttrOperation, "report"))
+			batch.results[i] = &managermodel.ReportOperationResult{
+				ID:                ids[i],
+				Duplicate:         true,
+				ReportedProcessed: at.ReportedProcessed,
+				DesiredProcessed:  at.DesiredProcessed,
+			}
+		}
+	}
+}
+
+// reportRecordTokens records the tokens of all successful operations in the _batch_, one write per shadow.
+func (mgr *ManagerImpl) reportRecordTokens(ctx context.Context, batch *reportBatch) {
+	now := time.Now().UTC().UnixNano()
+
+	for _, indices := range batch.shadows {
+		var (
+			applied  []managermodel.AppliedToken
+			recorded []int
+		)
+
+		for _, idx := range indices {
+			r, op := batch.results[idx], &batch.operations[idx]
+
+			if op.ClientToken == "" || r == nil || r.Error != nil || r.Duplicate {
+				continue
+			}
+
+			applied = append(applied, managermodel.AppliedToken{
+				Token:             op.ClientToken,
+				Kind:              managermodel.InterceptReport,
+				ReportedProcessed: r.ReportedProcessed,
+				DesiredProcessed:  r.DesiredProcessed,
+				AppliedAt:         now,
+			})
+
+			recorded = append(recorded, idx)
+		}
+
+		if len(applied) == 0 {
+			continue
+		}
+
+		last := &batch.operations[recorded[len(recorded)-1]]
+
+		// The operations are applied, failing them would make the client re-try and apply them again
+		if err := mgr.recordTokens(ctx, last.ClientID, last.ID, applied); err != nil {
+			for _, idx := range recorded {
+				if batch.results[idx].SectionError == nil {
+					batch.results[idx].SectionError = err
+				}
+			}
+		}
+	}
+}
+
+// desireDuplicates returns the results of all _operations_ that are duplicates, the others are `nil`.
+func (mgr *ManagerImpl) desireDuplicates(
+	ctx context.Context,
+	operations []managermodel.DesireOperation,
+) []*managermodel.DesireOperationResult {
+	ids := make([]persistencemodel.ID, len(operations))
+	tokens := make([]string, len(operations))
+
+	for i, op := range operations {
+		ids[i], tokens[i] = op.ID, op.ClientToken
+	}
+
+	applied, errs := mgr.duplicates(ctx, managermodel.InterceptDesire, ids, tokens)
+	res := make([]*managermodel.DesireOperationResult, len(operations))
+
+	for i, at := range applied {
+		switch {
+		case errs[i] != nil:
+			res[i] = &managermodel.DesireOperationResult{ID: ids[i], Error: errs[i]}
+		case at != nil:
+			mgr.observer.Count(ctx, observemodel.ManagerDuplicates, 1, observemodel.Attr(observemodel.AttrOperation, "desire"))
+			res[i] = &managermodel.DesireOperationResult{
+				ID:        ids[i],
+				Duplicate: true,
+				Processed: at.DesiredProcessed,
+				Version:   at.Version,
+				TimeStamp: at.TimeStamp,
+			}
+		}
+	}
+
+	return res
+}
+
+// desireRecordTokens records the tokens of all successful operations, one write per shadow.
+func (mgr *ManagerImpl) desireRecordTokens(
+	ctx context.Context,
+	operations []managermodel.DesireOperation,
+	shadows map[string][]int,
+	res []*managermodel.DesireOperationResult,
+) {
+	now := time.Now().UTC().UnixNano()
+
+	for _, indices := range shadows {
+		var (
+			applied  []managermodel.AppliedToken
+			recorded []int
+		)
+
+		for _, idx := range indices {
+			r, op := res[idx], &operations[idx]
+
+			if op.ClientToken == "" || r == nil || r.Error != nil || r.Duplicate {
+				continue
+			}
+
+			applied = append(applied, managermodel.AppliedToken{
+				Token:            op.ClientToken,
+				Kind:             managermodel.InterceptDesire,
+				DesiredProcessed: r.Processed,
+				Version:          r.Version,
+				TimeStamp:        r.TimeStamp,
+				AppliedAt:        now,
+			})
+
+			recorded = append(recorded, idx)
+		}
+
+		if len(applied) == 0 {
+			continue
+		}
+
+		last := &operations[recorded[len(recorded)-1]]
+
+		// The operations are applied, failing them would make the client re-try and apply them again
+		if err := mgr.recordTokens(ctx, last.ClientID, last.ID, applied); err != nil {
+			for _, idx := range recorded {
+				if res[idx].SectionError == nil {
+					res[idx].SectionError = err
+				}
+			}
+		}
+	}
+}
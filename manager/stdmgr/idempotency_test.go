@@ -0,0 +1,144 @@
+package stdmgr_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+	"github.com/mariotoffia/godeviceshadow/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotency(t *testing.T) {
+	ctx := context.Background()
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithIdempotency(2, 0).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: name, Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	id := persistencemodel.ID{ID: "device123", Name: "homeHub"}
+	now := time.Now().UTC()
+	report := func(token string, value float64) managermodel.ReportOperation {
+		return managermodel.ReportOperation{
+			ClientID:    "device",
+			ClientToken: token,
+			ID:          id,
+			Model:       TestModel{Sensors: map[string]Sensor{"temp": {Value: value, TimeStamp: now}}},
+		}
+	}
+
+	res := mgr.Report(ctx, report("t1", 1))
+
+	require.NoError(t, res[0].Error)
+	assert.False(t, res[0].Duplicate)
+	assert.True(t, res[0].ReportedProcessed)
+
+	// Same token, even with another value, is not merged again
+	now = now.Add(time.Second)
+	res = mgr.Report(ctx, report("t1", 2), report("t2", 3), report("t2", 4))
+
+	require.NoError(t, res[0].Error)
+	assert.True(t, res[0].Duplicate)
+	assert.True(t, res[0].ReportedProcessed)
+	assert.Nil(t, res[0].MergeLoggers)
+
+	require.NoError(t, res[1].Error)
+	assert.False(t, res[1].Duplicate)
+	assert.True(t, res[2].Duplicate)
+
+	read := mgr.Read(ctx, managermodel.ReadOperation{ID: id.ToPersistenceID(persistencemodel.ModelTypeReported)})
+
+	require.NoError(t, read[0].Error)
+	assert.Equal(t, 3.0, read[0].Model.(TestModel).Sensors["temp"].Value)
+
+	// Desire tokens are separate from the report tokens
+	desire := managermodel.DesireOperation{
+		ClientID:    "backend",
+		ClientToken: "t1",
+		ID:          id,
+		Model:       TestModel{Sensors: map[string]Sensor{"temp": {Value: 10, TimeStamp: now}}},
+	}
+
+	dres := mgr.Desire(ctx, desire)
+
+	require.NoError(t, dres[0].Error)
+	assert.False(t, dres[0].Duplicate)
+	assert.True(t, dres[0].Processed)
+
+	again := mgr.Desire(ctx, desire)
+
+	require.NoError(t, again[0].Error)
+	assert.True(t, again[0].Duplicate)
+	assert.Equal(t, dres[0].Version, again[0].Version)
+	assert.Equal(t, dres[0].TimeStamp, again[0].TimeStamp)
+
+	// The window only keeps the two newest tokens, t1 (report) is outside
+	res = mgr.Report(ctx, report("t1", 5))
+
+	require.NoError(t, res[0].Error)
+	assert.False(t, res[0].Duplicate)
+
+	// The section is not listed as a shadow
+	list, err := mgr.List(ctx, managermodel.ListOptions{ID: id.ID})
+
+	require.NoError(t, err)
+
+	for _, item := range list.Items {
+		assert.Equal(t, id.Name, item.ID.Name)
+	}
+}
+
+func TestIdempotencyTokenFailureKeepsResult(t *testing.T) {
+	ctx := context.Background()
+	mgr := stdmgr.New().
+		WithPersistence(&failingSections{Persistence: mempersistence.New()}).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithIdempotency(16, 0).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: name, Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	id := persistencemodel.ID{ID: "device123", Name: "homeHub"}
+
+	res := mgr.Report(ctx, managermodel.ReportOperation{
+		ClientToken: "t1",
+		ID:          id,
+		Model:       TestModel{Sensors: map[string]Sensor{"temp": {Value: 1.0, TimeStamp: time.Now().UTC()}}},
+	})
+
+	// Applied, hence the client must not re-try even if the token was not recorded
+	require.NoError(t, res[0].Error)
+	assert.True(t, res[0].ReportedProcessed)
+	assert.Error(t, res[0].SectionError)
+
+	dres := mgr.Desire(ctx, managermodel.DesireOperation{
+		ClientToken: "t2",
+		ID:          id,
+		Model:       TestModel{Sensors: map[string]Sensor{"temp": {Value: 2.0, TimeStamp: time.Now().UTC()}}},
+	})
+
+	require.NoError(t, dres[0].Error)
+	assert.True(t, dres[0].Processed)
+	assert.Error(t, dres[0].SectionError)
+}
@@ -0,0 +1,25 @@
+package stdmgr
+
+import (
+	"context"
+
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+)
+
+// interceptBefore invokes the `Interceptor.Before` in registration order and stops at the first error.
+func (mgr *ManagerImpl) interceptBefore(ctx context.Context, call *managermodel.InterceptCall) error {
+	for _, ic := range mgr.interceptors {
+		if err := ic.Before(ctx, call); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// interceptAfter invokes the `Interceptor.After` in reverse registration order.
+func (mgr *ManagerImpl) interceptAfter(ctx context.Context, call *managermodel.InterceptCall) {
+	for i := len(mgr.interceptors) - 1; i >= 0; i-- {
+		mgr.interceptors[i].After(ctx, call)
+	}
+}
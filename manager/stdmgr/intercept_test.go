@@ -0,0 +1,304 @@
+package stdmgr_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+	"github.com/mariotoffia/godeviceshadow/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errForbidden = errors.New("forbidden")
+
+func TestInterceptorAuthorizeAndAudit(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	id := persistencemodel.ID{ID: "device1", Name: "homeHub"}
+
+	var audit []string
+
+	authorize := managermodel.InterceptorFuncs{
+		BeforeFunc: func(ctx context.Context, call *managermodel.InterceptCall) error {
+			audit = append(audit, "before:auth:"+call.Kind.String())
+
+			if call.Kind == managermodel.InterceptDesire && call.Desire.ClientID == "intruder" {
+				return errForbidden
+			}
+
+			if call.Kind == managermodel.InterceptDelete {
+				return errForbidden
+			}
+
+			return nil
+		},
+	}
+
+	enrich := managermodel.InterceptorFuncs{
+		BeforeFunc: func(ctx context.Context, call *managermodel.InterceptCall) error {
+			if call.Kind == managermodel.InterceptReport && call.Report.ClientID == "" {
+				call.Report.ClientID = "enriched"
+			}
+
+			return nil
+		},
+		AfterFunc: func(ctx context.Context, call *managermodel.InterceptCall) {
+			switch call.Kind {
+			case managermodel.InterceptReport:
+				assert.Equal(t, "homeHub", call.Type.Name)
+				assert.NotNil(t, call.Reported)
+				audit = append(audit, "after:"+call.Report.ClientID)
+			case managermodel.InterceptDesire:
+				audit = append(audit, "after:desire:"+call.Desire.ClientID)
+
+				if call.DesireResult.Error != nil {
+					audit = append(audit, "error:"+call.DesireResult.Error.Error())
+				}
+			case managermodel.InterceptDelete:
+				assert.ErrorIs(t, call.DeleteResult.Error, errForbidden)
+				audit = append(audit, "after:delete")
+			}
+		},
+	}
+
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.CombinedModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		WithInterceptors(authorize, enrich).
+		Build()
+
+	res := mgr.Report(ctx, managermodel.ReportOperation{
+		ID:    id,
+		Model: TestModel{TimeZone: tz, Sensors: map[string]Sensor{"temp": {Value: 23.4, TimeStamp: now}}},
+	})
+
+	require.Len(t, res, 1)
+	require.NoError(t, res[0].Error)
+
+	dres := mgr.Desire(ctx,
+		managermodel.DesireOperation{
+			ClientID: "operator",
+			ID:       id,
+			Model:    TestModel{Sensors: map[string]Sensor{"temp": {Value: 22.0, TimeStamp: now}}},
+		},
+		managermodel.DesireOperation{
+			ClientID: "intruder",
+			ID:       id,
+			Model:    TestModel{Sensors: map[string]Sensor{"temp": {Value: 30.0, TimeStamp: now.Add(time.Second)}}},
+		},
+	)
+
+	require.Len(t, dres, 2)
+	require.NoError(t, dres[0].Error)
+	assert.True(t, dres[0].Processed)
+	assert.ErrorIs(t, dres[1].Error, errForbidden)
+	assert.False(t, dres[1].Processed)
+
+	del := mgr.Delete(ctx, managermodel.DeleteOperation{ID: id.ToPersistenceID(0)})
+	require.Len(t, del, 1)
+	assert.ErrorIs(t, del[0].Error, errForbidden)
+
+	assert.Equal(t, []string{
+		"before:auth:report",
+		"after:enriched",
+		"before:auth:desire",
+		"before:auth:desire",
+		"after:desire:operator",
+		"after:desire:intruder",
+		"error:forbidden",
+		"before:auth:delete",
+		"after:delete",
+	}, audit)
+
+	// Not deleted and the intruder did not overwrite the desired value
+	rr := mgr.Read(ctx, managermodel.ReadOperation{ID: id.ToPersistenceID(persistencemodel.ModelTypeDesired)})
+	desired := desiredReadResult(t, rr)
+	assert.Equal(t, 22.0, desired.Model.(TestModel).Sensors["temp"].Value)
+}
+
+func TestInterceptorReadAndList(t *testing.T) {
+	ctx := context.Background()
+	id := persistencemodel.ID{ID: "device1", Name: "homeHub"}
+
+	var reads []persistencemodel.PersistenceID
+
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		WithInterceptors(managermodel.InterceptorFuncs{
+			BeforeFunc: func(ctx context.Context, call *managermodel.InterceptCall) error {
+				if call.Kind == managermodel.InterceptList && call.List.ID == "" {
+					return errForbidden
+				}
+
+				return nil
+			},
+			AfterFunc: func(ctx context.Context, call *managermodel.InterceptCall) {
+				if call.Kind == managermodel.InterceptRead {
+					require.NotNil(t, call.Read)
+					reads = append(reads, call.ReadResult.ID)
+				}
+			},
+		}).
+		Build()
+
+	res := mgr.Report(ctx, managermodel.ReportOperation{ID: id, Model: TestModel{TimeZone: tz}})
+	require.Len(t, res, 1)
+	require.NoError(t, res[0].Error)
+
+	rr := mgr.Read(ctx, managermodel.ReadOperation{ID: id.ToPersistenceID(persistencemodel.ModelTypeReported)})
+	require.Len(t, rr, 1)
+	require.NoError(t, rr[0].Error)
+	assert.Equal(t, []persistencemodel.PersistenceID{id.ToPersistenceID(persistencemodel.ModelTypeReported)}, reads)
+
+	_, err := mgr.List(ctx)
+	assert.ErrorIs(t, err, errForbidden)
+
+	list, err := mgr.List(ctx, managermodel.ListOptions{ID: "device1"})
+	require.NoError(t, err)
+	assert.Len(t, list.Items, 1)
+}
+
+func TestInterceptorReportAltersIDBeforeRead(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	id := persistencemodel.ID{ID: "device1", Name: "homeHub"}
+	tenant := persistencemodel.ID{ID: "tenant1-device1", Name: "homeHub"}
+
+	var stored []any
+
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		WithInterceptors(managermodel.InterceptorFuncs{
+			BeforeFunc: func(ctx context.Context, call *managermodel.InterceptCall) error {
+				if call.Kind == managermodel.InterceptReport {
+					assert.Nil(t, call.Reported, "not yet read")
+					call.Report.ID = tenant
+				}
+
+				return nil
+			},
+			AfterFunc: func(ctx context.Context, call *managermodel.InterceptCall) {
+				if call.Kind == managermodel.InterceptReport {
+					stored = append(stored, call.Reported)
+				}
+			},
+		}).
+		Build()
+
+	for i := range 2 {
+		res := mgr.Report(ctx, managermodel.ReportOperation{
+			ID:    id,
+			Model: TestModel{TimeZone: tz, Sensors: map[string]Sensor{"temp": {Value: 23.4 + float64(i), TimeStamp: now.Add(time.Duration(i) * time.Second)}}},
+		})
+
+		require.Len(t, res, 1)
+		require.NoError(t, res[0].Error)
+		assert.Equal(t, tenant, res[0].ID)
+	}
+
+	// The second report was merged onto the stored model of the altered id
+	require.Len(t, stored, 2)
+	assert.Equal(t, 23.4, stored[1].(TestModel).Sensors["temp"].Value)
+
+	rr := mgr.Read(ctx, managermodel.ReadOperation{ID: tenant.ToPersistenceID(persistencemodel.ModelTypeReported)})
+	require.Len(t, rr, 1)
+	require.NoError(t, rr[0].Error)
+	assert.Equal(t, int64(2), rr[0].Version)
+
+	rr = mgr.Read(ctx, managermodel.ReadOperation{ID: id.ToPersistenceID(persistencemodel.ModelTypeReported)})
+	require.Len(t, rr, 1)
+	assert.Error(t, rr[0].Error)
+}
+
+func TestInterceptorDesireAltersIDBeforeRead(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	id := persistencemodel.ID{ID: "device1", Name: "homeHub"}
+	tenant := persistencemodel.ID{ID: "tenant1-device1", Name: "homeHub"}
+
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		WithInterceptors(managermodel.InterceptorFuncs{
+			BeforeFunc: func(ctx context.Context, call *managermodel.InterceptCall) error {
+				if call.Kind == managermodel.InterceptDesire {
+					assert.Nil(t, call.Desired, "not yet read")
+
+					if call.Desire.ID == id {
+						call.Desire.ID = tenant
+					} else {
+						call.Desire.ID = persistencemodel.ID{ID: managermodel.GroupsID, Name: "homeHub"}
+					}
+				}
+
+				return nil
+			},
+		}).
+		Build()
+
+	res := mgr.Desire(ctx,
+		managermodel.DesireOperation{ID: id, Model: TestModel{Sensors: map[string]Sensor{"sp": {Value: 21.0, TimeStamp: now}}}},
+		managermodel.DesireOperation{
+			ID:    persistencemodel.ID{ID: "device2", Name: "homeHub"},
+			Model: TestModel{Sensors: map[string]Sensor{"sp": {Value: 21.0, TimeStamp: now}}},
+		},
+	)
+
+	require.Len(t, res, 2)
+	require.NoError(t, res[0].Error)
+	assert.Equal(t, tenant, res[0].ID)
+	assert.True(t, res[0].Processed)
+
+	// The altered id is checked as well
+	var pe persistencemodel.PersistenceError
+
+	require.True(t, errors.As(res[1].Error, &pe))
+	assert.Equal(t, 400, pe.Code)
+
+	rr := mgr.Read(ctx, managermodel.ReadOperation{ID: tenant.ToPersistenceID(persistencemodel.ModelTypeDesired)})
+	require.Len(t, rr, 1)
+	require.NoError(t, rr[0].Error)
+
+	rr = mgr.Read(ctx, managermodel.ReadOperation{ID: id.ToPersistenceID(persistencemodel.ModelTypeDesired)})
+	require.Len(t, rr, 1)
+	require.Error(t, rr[0].Error)
+}
@@ -0,0 +1,141 @@
+package stdmgr_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+	"github.com/mariotoffia/godeviceshadow/types"
+	"github.com/mariotoffia/godeviceshadow/validate"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimits(t *testing.T) {
+	ctx := context.Background()
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithLimits(validate.Limits{MaxMapEntries: 3, MaxNamedShadows: 2}).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: name, Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	sensors := func(n int) map[string]Sensor {
+		m := map[string]Sensor{}
+
+		for i := range n {
+			m[fmt.Sprintf("s%d", i)] = Sensor{Value: float64(i), TimeStamp: time.Now().UTC()}
+		}
+
+		return m
+	}
+
+	res := mgr.Report(ctx,
+		managermodel.ReportOperation{ID: persistencemodel.ID{ID: "device123", Name: "homeHub"}, Model: TestModel{Sensors: sensors(3)}},
+		managermodel.ReportOperation{ID: persistencemodel.ID{ID: "device123", Name: "garage"}, Model: TestModel{Sensors: sensors(4)}},
+	)
+
+	require.Len(t, res, 2)
+	require.NoError(t, res[0].Error)
+	require.Error(t, res[1].Error)
+
+	var le *validate.LimitError
+
+	require.True(t, errors.As(res[1].Error, &le))
+	assert.Equal(t, "Sensors", le.Path)
+
+	var pe persistencemodel.PersistenceError
+
+	require.True(t, errors.As(res[1].Error, &pe))
+	assert.Equal(t, 413, pe.Code)
+
+	// Second named shadow is allowed, but not a third
+	dres := mgr.Desire(ctx, managermodel.DesireOperation{
+		ID: persistencemodel.ID{ID: "device123", Name: "garage"}, Model: TestModel{Sensors: sensors(1)},
+	})
+
+	require.NoError(t, dres[0].Error)
+
+	res = mgr.Report(ctx, managermodel.ReportOperation{
+		ID: persistencemodel.ID{ID: "device123", Name: "attic"}, Model: TestModel{Sensors: sensors(1)},
+	})
+
+	require.True(t, errors.As(res[0].Error, &le))
+	assert.Equal(t, "MaxNamedShadows", le.Limit)
+
+	// Existing named shadows may still be updated
+	res = mgr.Report(ctx, managermodel.ReportOperation{
+		ID: persistencemodel.ID{ID: "device123", Name: "garage"}, Model: TestModel{Sensors: sensors(2)},
+	})
+
+	require.NoError(t, res[0].Error)
+}
+
+func TestReservedIDs(t *testing.T) {
+	ctx := context.Background()
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: name, Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	m := TestModel{Sensors: map[string]Sensor{"s": {Value: 1.0, TimeStamp: time.Now().UTC()}}}
+	reserved := []persistencemodel.ID{
+		{ID: managermodel.SchedulesID, Name: "homeHub"},
+		{ID: managermodel.JobsID, Name: "homeHub"},
+		{ID: managermodel.LayersID, Name: "homeHub"},
+		{ID: managermodel.GroupsID, Name: "homeHub"},
+		{ID: "device123", Name: "homeHub" + managermodel.DesiredStatusNameSuffix},
+		{ID: "device123", Name: "homeHub" + managermodel.RejectionsNameSuffix},
+		{ID: "device123", Name: "homeHub" + managermodel.ClientTokensNameSuffix},
+		{ID: "device123", Name: "homeHub" + managermodel.AlarmsNameSuffix},
+		{ID: "device123", Name: "boost" + managermodel.ScheduleNameSuffix},
+		{ID: "device123", Name: "rollout" + managermodel.JobNameSuffix},
+		{ID: "device123", Name: "floor1" + managermodel.GroupNameSuffix},
+	}
+
+	var pe persistencemodel.PersistenceError
+
+	for _, id := range reserved {
+		res := mgr.Report(ctx, managermodel.ReportOperation{ID: id, Model: m})
+
+		require.True(t, errors.As(res[0].Error, &pe), "report %s", id)
+		assert.Equal(t, 400, pe.Code)
+
+		dres := mgr.Desire(ctx, managermodel.DesireOperation{ID: id, Model: m})
+
+		require.True(t, errors.As(dres[0].Error, &pe), "desire %s", id)
+		assert.Equal(t, 400, pe.Code)
+
+		_, err := mgr.Copy(ctx, managermodel.CopyOperation{From: persistencemodel.ID{ID: "device123", Name: "homeHub"}, To: id})
+
+		require.True(t, errors.As(err, &pe), "copy %s", id)
+		assert.Equal(t, 400, pe.Code)
+	}
+
+	list, err := mgr.List(ctx)
+
+	require.NoError(t, err)
+	assert.Empty(t, list.Items)
+}
@@ -2,6 +2,8 @@ package stdmgr
 
 import (
 	"context"
+	"slices"
+	"strings"
 
 	"github.com/mariotoffia/godeviceshadow/model/managermodel"
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
@@ -9,7 +11,38 @@ import (
 
 // List will list the models. If no id or search expression is provided all models will be listed. It may be
 // paged, thus check the `managermodel.ListResults.Token` to see if there's more to fetch.
-func (mgr *ManagerImpl) List(ctx context.Context, options ...managermodel.ListOptions) (managermodel.ListResults, error) {
+func (mgr *ManagerImpl) List(ctx context.Context, options ...managermodel.ListOptions) (results managermodel.ListResults, err error) {
+	ctx, obs := mgr.observe(ctx, "list")
+
+	defer func() {
+		obs.end(err)
+	}()
+
+	if len(mgr.interceptors) == 0 {
+		return mgr.list(ctx, options...)
+	}
+
+	var opts managermodel.ListOptions
+
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	call := &managermodel.InterceptCall{Kind: managermodel.InterceptList, List: &opts}
+
+	if err := mgr.interceptBefore(ctx, call); err != nil {
+		call.ListError = err
+	} else {
+		results, call.ListError = mgr.list(ctx, opts)
+	}
+
+	call.ListResult = &results
+	mgr.interceptAfter(ctx, call)
+
+	return results, call.ListError
+}
+
+func (mgr *ManagerImpl) list(ctx context.Context, options ...managermodel.ListOptions) (managermodel.ListResults, error) {
 	var opt persistencemodel.ListOptions
 
 	if len(options) > 0 {
@@ -23,8 +56,21 @@ func (mgr *ManagerImpl) List(ctx context.Context, options ...managermodel.ListOp
 	if results, err := mgr.persistence.List(ctx, opt); err != nil {
 		return managermodel.ListResults{}, err
 	} else {
+		// The rejection, desired status, client token and alarm sections are part of a shadow and not models by themselves, neither
+		// are the persisted jobs, layers, groups and schedules.
+		items := slices.DeleteFunc(results.Items, func(item persistencemodel.ListResult) bool {
+			return strings.HasSuffix(item.ID.Name, managermodel.RejectionsNameSuffix) ||
+				strings.HasSuffix(item.ID.Name, managermodel.DesiredStatusNameSuffix) ||
+				strings.HasSuffix(item.ID.Name, managermodel.ClientTokensNameSuffix) ||
+				strings.HasSuffix(item.ID.Name, managermodel.AlarmsNameSuffix) ||
+				item.ID.ID == managermodel.JobsID ||
+				item.ID.ID == managermodel.LayersID ||
+				item.ID.ID == managermodel.GroupsID ||
+				item.ID.ID == managermodel.SchedulesID
+		})
+
 		return managermodel.ListResults{
-			Items: results.Items,
+			Items: items,
 			Token: results.Token,
 		}, nil
 	}
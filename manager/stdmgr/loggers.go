@@ -31,6 +31,11 @@ func (dal *DesiredAckLogger) Acknowledge(ctx context.Context, path string, value
 	dal.Dirty = true
 }
 
+// Reject is called when a desired value has been rejected (`model.DesiredRejectLogger` interface).
+func (dal *DesiredAckLogger) Reject(ctx context.Context, path string, value model.ValueAndTimestamp, rejection model.RejectedDesire) {
+	dal.Dirty = true
+}
+
 // New implements the `model.CreatableMergeLogger`
 func (mdl *MergeDirtyLogger) New() model.MergeLogger {
 	return &MergeDirtyLogger{}
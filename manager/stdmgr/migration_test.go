@@ -0,0 +1,152 @@
+package stdmgr_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+	"github.com/mariotoffia/godeviceshadow/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// legacyModel is the `TestModel` before the _Zone_ was renamed to _TimeZone_.
+type legacyModel struct {
+	Zone    string
+	Sensors map[string]Sensor
+}
+
+func TestMigrateOnRead(t *testing.T) {
+	ctx := context.Background()
+	persistence := mempersistence.New()
+	id := persistencemodel.ID{ID: "device123", Name: "homeHub"}
+
+	// Persisted with schema version zero
+	res := persistence.Write(ctx, persistencemodel.WriteOptions{}, persistencemodel.WriteOperation{
+		ClientID: "device",
+		ID:       id.ToPersistenceID(persistencemodel.ModelTypeReported),
+		Model:    legacyModel{Zone: "Europe/Stockholm", Sensors: map[string]Sensor{}},
+		Config:   persistencemodel.WriteOperationConfig{Separation: persistencemodel.SeparateModels},
+	})
+
+	require.NoError(t, res[0].Error)
+
+	schema := model.Schema{
+		Version: 2,
+		Migrations: map[int]model.MigrateFunc{
+			0: func(data map[string]any) (map[string]any, error) {
+				data["TimeZone"] = data["Zone"]
+				delete(data, "Zone")
+
+				return data, nil
+			},
+		},
+	}
+
+	mgr := stdmgr.New().
+		WithPersistence(persistence).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: name, Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			).RegisterSchema(TestModel{}, schema),
+		).
+		Build()
+
+	read := mgr.Read(ctx, managermodel.ReadOperation{ID: id.ToPersistenceID(persistencemodel.ModelTypeReported)})
+
+	require.NoError(t, read[0].Error)
+	assert.Equal(t, "Europe/Stockholm", read[0].Model.(TestModel).TimeZone)
+	assert.Equal(t, int64(2), read[0].Version, "written back")
+
+	// Written back with the current schema version
+	rr := persistence.Read(ctx, persistencemodel.ReadOptions{}, persistencemodel.ReadOperation{
+		ID:    id.ToPersistenceID(persistencemodel.ModelTypeReported),
+		Model: reflect.TypeOf(TestModel{}),
+	})
+
+	require.NoError(t, rr[0].Error)
+	assert.Equal(t, 2, rr[0].SchemaVersion)
+	assert.Equal(t, "Europe/Stockholm", rr[0].Model.(TestModel).TimeZone)
+
+	// A report on a current model does not migrate again
+	rep := mgr.Report(ctx, managermodel.ReportOperation{
+		ClientID: "device",
+		ID:       id,
+		Model:    TestModel{Sensors: map[string]Sensor{"temp": {Value: 1.0, TimeStamp: time.Now().UTC()}}},
+	})
+
+	require.NoError(t, rep[0].Error)
+
+	read = mgr.Read(ctx, managermodel.ReadOperation{ID: id.ToPersistenceID(persistencemodel.ModelTypeReported)})
+
+	require.NoError(t, read[0].Error)
+	assert.Equal(t, "Europe/Stockholm", read[0].Model.(TestModel).TimeZone)
+	assert.Equal(t, 1.0, read[0].Model.(TestModel).Sensors["temp"].Value)
+}
+
+func TestMigrateOnReport(t *testing.T) {
+	ctx := context.Background()
+	persistence := mempersistence.New()
+	id := persistencemodel.ID{ID: "device123", Name: "homeHub"}
+
+	res := persistence.Write(ctx, persistencemodel.WriteOptions{}, persistencemodel.WriteOperation{
+		ClientID: "device",
+		ID:       id.ToPersistenceID(persistencemodel.ModelTypeReported),
+		Model:    legacyModel{Zone: "UTC"},
+		Config:   persistencemodel.WriteOperationConfig{Separation: persistencemodel.SeparateModels},
+	})
+
+	require.NoError(t, res[0].Error)
+
+	mgr := stdmgr.New().
+		WithPersistence(persistence).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{
+						Name:  name,
+						Model: reflect.TypeOf(TestModel{}),
+						Schema: model.Schema{
+							Version: 1,
+							Migrations: map[int]model.MigrateFunc{
+								0: func(data map[string]any) (map[string]any, error) {
+									data["TimeZone"] = data["Zone"]
+									return data, nil
+								},
+							},
+						},
+					}, true
+				}),
+			),
+		).
+		Build()
+
+	rep := mgr.Report(ctx, managermodel.ReportOperation{
+		ClientID: "device",
+		ID:       id,
+		Model:    TestModel{Sensors: map[string]Sensor{"temp": {Value: 1.0, TimeStamp: time.Now().UTC()}}},
+	})
+
+	require.NoError(t, rep[0].Error)
+
+	rr := persistence.Read(ctx, persistencemodel.ReadOptions{}, persistencemodel.ReadOperation{
+		ID:    id.ToPersistenceID(persistencemodel.ModelTypeReported),
+		Model: reflect.TypeOf(TestModel{}),
+	})
+
+	require.NoError(t, rr[0].Error)
+	assert.Equal(t, 1, rr[0].SchemaVersion)
+	assert.Equal(t, "UTC", rr[0].Model.(TestModel).TimeZone)
+	assert.Equal(t, 1.0, rr[0].Model.(TestModel).Sensors["temp"].Value)
+}
@@ -0,0 +1,85 @@
+package stdmgr
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/observemodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// observation is a started manager operation.
+type observation struct {
+	ctx       context.Context
+	observer  observemodel.Observer
+	span      observemodel.Span
+	operation string
+	start     time.Time
+}
+
+// observe starts a span for the manager _operation_. The returned context carries the span and shall be passed
+// on to the persistence.
+func (mgr *ManagerImpl) observe(ctx context.Context, operation string) (context.Context, *observation) {
+	ctx, span := mgr.observer.Start(ctx, observemodel.SpanManager+operation,
+		observemodel.Attr(observemodel.AttrOperation, operation),
+	)
+
+	return ctx, &observation{ctx: ctx, observer: mgr.observer, span: span, operation: operation, start: time.Now()}
+}
+
+// end records the duration and the outcome, and conflicts, of each operation and ends the span. The span fails with
+// the first error.
+func (o *observation) end(errs ...error) {
+	var first error
+
+	op := observemodel.Attr(observemodel.AttrOperation, o.operation)
+
+	for _, err := range errs {
+		o.observer.Count(o.ctx, observemodel.ManagerOperations, 1, op,
+			observemodel.Attr(observemodel.AttrOutcome, observemodel.Outcome(err)),
+		)
+
+		if err == nil {
+			continue
+		}
+
+		if first == nil {
+			first = err
+		}
+
+		var pe persistencemodel.PersistenceError
+
+		if errors.As(err, &pe) && pe.Code == 409 {
+			o.observer.Count(o.ctx, observemodel.ManagerConflicts, 1, op)
+		}
+	}
+
+	observemodel.Since(o.ctx, o.observer, observemodel.ManagerOperationDuration, o.start, op)
+	o.span.End(first)
+}
+
+// observeMerge records the duration of a merge that started at _start_.
+func (mgr *ManagerImpl) observeMerge(ctx context.Context, kind managermodel.InterceptKind, start time.Time) {
+	operation := "report"
+
+	if kind == managermodel.InterceptDesire {
+		operation = "desire"
+	}
+
+	observemodel.Since(ctx, mgr.observer, observemodel.ManagerMergeDuration, start,
+		observemodel.Attr(observemodel.AttrOperation, operation),
+	)
+}
+
+// resultErrors returns the error of each result.
+func resultErrors[T any](results []T, err func(T) error) []error {
+	errs := make([]error, len(results))
+
+	for i, r := range results {
+		errs[i] = err(r)
+	}
+
+	return errs
+}
@@ -0,0 +1,97 @@
+package stdmgr_test
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/observemodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+	"github.com/mariotoffia/godeviceshadow/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a `observemodel.Observer` that records the span names, counters and the number of histogram values.
+type recorder struct {
+	mu       sync.Mutex
+	spans    []string
+	counters map[string]int64
+	records  map[string]int
+}
+
+func (r *recorder) Start(ctx context.Context, name string, _ ...observemodel.Attribute) (context.Context, observemodel.Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.spans = append(r.spans, name)
+
+	return observemodel.Nop{}.Start(ctx, name)
+}
+
+func (r *recorder) Count(_ context.Context, name string, delta int64, attrs ...observemodel.Attribute) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, a := range attrs {
+		name += "/" + a.Value
+	}
+
+	r.counters[name] += delta
+}
+
+func (r *recorder) Record(_ context.Context, name string, _ float64, _ ...observemodel.Attribute) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records[name]++
+}
+
+func TestObserver(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{counters: map[string]int64{}, records: map[string]int{}}
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New(mempersistence.PersistenceOpts{Observer: rec})).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithObserver(rec).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: name, Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	id := persistencemodel.ID{ID: "device123", Name: "homeHub"}
+	desire := managermodel.DesireOperation{
+		ID:    id,
+		Model: TestModel{Sensors: map[string]Sensor{"temp": {Value: 21, TimeStamp: time.Now().UTC()}}},
+	}
+
+	res := mgr.Desire(ctx, desire)
+	require.NoError(t, res[0].Error)
+
+	// Stale version -> conflict
+	desire.Version = res[0].Version + 10
+	res = mgr.Desire(ctx, desire)
+	require.Error(t, res[0].Error)
+
+	assert.Equal(t, []string{
+		"manager.desire", "persistence.read", "persistence.write",
+		"manager.desire", "persistence.read", "persistence.write",
+	}, rec.spans)
+	assert.Equal(t, int64(1), rec.counters["manager.operations/desire/ok"])
+	assert.Equal(t, int64(1), rec.counters["manager.operations/desire/error"])
+	assert.Equal(t, int64(1), rec.counters["manager.conflicts/desire"])
+	assert.Equal(t, 2, rec.records[observemodel.ManagerOperationDuration])
+	assert.Equal(t, 1, rec.records[observemodel.ManagerMergeDuration])
+	assert.Equal(t, 4, rec.records[observemodel.PersistenceOperationDuration])
+}
@@ -0,0 +1,44 @@
+package stdmgr
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
+)
+
+// checkPreconditions checks the _preconditions_ against the _current_ model. It returns a 412 (Precondition Failed)
+// on the first precondition that does not hold.
+func checkPreconditions(ctx context.Context, current any, preconditions []model.Precondition) error {
+	if len(preconditions) == 0 {
+		return nil
+	}
+
+	for _, p := range preconditions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var (
+			value   any
+			present bool
+		)
+
+		if current != nil {
+			var err error
+
+			if value, present, err = merge.Lookup(current, p.Path, pathutils.StyleDotted); err != nil {
+				return err
+			}
+		}
+
+		if !p.Holds(value, present) {
+			return persistencemodel.Error412(fmt.Sprintf("precondition failed: %s", p))
+		}
+	}
+
+	return nil
+}
@@ -3,26 +3,39 @@ package stdmgr
 import (
 	"context"
 	"fmt"
+	"slices"
 
+	"github.com/mariotoffia/godeviceshadow/model"
 	"github.com/mariotoffia/godeviceshadow/model/managermodel"
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
 )
 
 // Query implements the read function in `managermodel.Receiver` interface.
-func (mgr *ManagerImpl) Read(ctx context.Context, operations ...managermodel.ReadOperation) []managermodel.ReadOperationResult {
+func (mgr *ManagerImpl) Read(ctx context.Context, operations ...managermodel.ReadOperation) (result []managermodel.ReadOperationResult) {
 	if len(operations) == 0 {
 		return nil
 	}
 
+	ctx, obs := mgr.observe(ctx, "read")
+
+	defer func() {
+		obs.end(resultErrors(result, func(r managermodel.ReadOperationResult) error { return r.Error })...)
+	}()
+
 	type readOperation struct {
 		operation  *persistencemodel.ReadOperation
 		separation persistencemodel.ModelSeparation
 	}
 
+	if len(mgr.interceptors) > 0 {
+		operations = slices.Clone(operations) // interceptors may alter the operations
+	}
+
 	readOperations := make(map[string]*readOperation, len(operations))
-	result := make([]managermodel.ReadOperationResult, 0, len(operations))
+	result = make([]managermodel.ReadOperationResult, 0, len(operations))
+	types := make([]model.TypeEntry, len(operations))
 
-	for _, op := range operations {
+	for i, op := range operations {
 		if op.ID.ModelType == 0 {
 			result = append(result, managermodel.ReadOperationResult{
 				ID:      op.ID,
@@ -45,6 +58,22 @@ func (mgr *ManagerImpl) Read(ctx context.Context, operations ...managermodel.Rea
 			continue
 		}
 
+		types[i] = te
+
+		if len(mgr.interceptors) > 0 {
+			if err := mgr.interceptBefore(ctx, &managermodel.InterceptCall{
+				Kind: managermodel.InterceptRead,
+				ID:   op.ID.ToID(),
+				Type: te,
+				Read: &operations[i],
+			}); err != nil {
+				result = append(result, managermodel.ReadOperationResult{ID: op.ID, Version: op.Version, Error: err})
+				continue
+			}
+
+			op = operations[i]
+		}
+
 		sep := mgr.separation
 
 		if op.Separation > 0 {
@@ -56,7 +85,9 @@ func (mgr *ManagerImpl) Read(ctx context.Context, operations ...managermodel.Rea
 			operation: &persistencemodel.ReadOperation{
 				ID:      op.ID,
 				Version: op.Version,
+				AsOf:    op.AsOf,
 				Model:   te.Model,
+				Schema:  te.Schema,
 			},
 		}
 	}
@@ -82,6 +113,7 @@ func (mgr *ManagerImpl) Read(ctx context.Context, operations ...managermodel.Rea
 	}
 
 	readResult := mgr.persistence.Read(ctx, persistencemodel.ReadOptions{}, readOps...)
+	mgr.readWriteBack(ctx, readOps, readResult)
 
 	toOperation := func(id persistencemodel.PersistenceID, mt persistencemodel.ModelType) *managermodel.ReadOperation {
 		for _, op := range operations {
@@ -128,5 +160,122 @@ func (mgr *ManagerImpl) Read(ctx context.Context, operations ...managermodel.Rea
 		}
 	}
 
+	if mgr.desiredStatus {
+		mgr.readAttachDesiredStatus(ctx, operations, result)
+	}
+
+	if len(mgr.interceptors) > 0 {
+		mgr.readInterceptAfter(ctx, operations, types, result)
+	}
+
 	return result
 }
+
+// readWriteBack writes the current models in _results_ that were migrated from an older schema version. When the
+// write is successful, the version and timestamp of the _results_ are updated. Failures, e.g. a concurrent write,
+// are ignored since the model is migrated again on next read.
+func (mgr *ManagerImpl) readWriteBack(
+	ctx context.Context,
+	readOps []persistencemodel.ReadOperation,
+	results []persistencemodel.ReadResult,
+) {
+	var writes []persistencemodel.WriteOperation
+
+	for _, op := range readOps {
+		if op.Version > 0 || op.AsOf > 0 {
+			continue // historical models are never written
+		}
+
+		sep := persistencemodel.SeparateModels
+
+		if op.ID.ModelType == 0 {
+			sep = persistencemodel.CombinedModels
+		}
+
+		for _, rr := range results {
+			if rr.Error != nil || rr.Model == nil || rr.ID.ID != op.ID.ID || rr.ID.Name != op.ID.Name ||
+				(sep == persistencemodel.SeparateModels && rr.ID.ModelType != op.ID.ModelType) ||
+				!op.Schema.Outdated(rr.SchemaVersion) {
+				continue
+			}
+
+			writes = append(writes, persistencemodel.WriteOperation{
+				ClientID:      managermodel.MigrationClientID,
+				ID:            rr.ID,
+				Model:         rr.Model,
+				Version:       rr.Version,
+				SchemaVersion: op.Schema.Version,
+				Config:        persistencemodel.WriteOperationConfig{Separation: sep},
+			})
+		}
+	}
+
+	if len(writes) == 0 {
+		return
+	}
+
+	for _, wr := range mgr.persistence.Write(ctx, persistencemodel.WriteOptions{}, writes...) {
+		if wr.Error != nil {
+			continue
+		}
+
+		for i, rr := range results {
+			if rr.ID.Equal(wr.ID) {
+				results[i].Version, results[i].TimeStamp = wr.Version, wr.TimeStamp
+			}
+		}
+	}
+}
+
+// readInterceptAfter invokes the `Interceptor.After` for each result together with the operation it belongs to.
+func (mgr *ManagerImpl) readInterceptAfter(
+	ctx context.Context,
+	operations []managermodel.ReadOperation,
+	types []model.TypeEntry,
+	result []managermodel.ReadOperationResult,
+) {
+	for i, rr := range result {
+		call := &managermodel.InterceptCall{Kind: managermodel.InterceptRead, ID: rr.ID.ToID(), ReadResult: &result[i]}
+
+		for n, op := range operations {
+			if op.ID.Equal(rr.ID) || (call.Read == nil && op.ID.ID == rr.ID.ID && op.ID.Name == rr.ID.Name) {
+				call.Read, call.Type = &operations[n], types[n]
+			}
+		}
+
+		mgr.interceptAfter(ctx, call)
+	}
+}
+
+// readAttachDesiredStatus sets the desired status on all successfully read desired models. If the operation
+// is `MarkDelivered`, all pending values are transitioned into delivered.
+func (mgr *ManagerImpl) readAttachDesiredStatus(
+	ctx context.Context,
+	operations []managermodel.ReadOperation,
+	result []managermodel.ReadOperationResult,
+) {
+	for i, rr := range result {
+		if rr.Error != nil || rr.ID.ModelType != persistencemodel.ModelTypeDesired {
+			continue
+		}
+
+		var markDelivered, historical bool
+
+		for _, op := range operations {
+			if op.ID.Equal(rr.ID) {
+				markDelivered = markDelivered || op.MarkDelivered
+				historical = historical || op.AsOf > 0 || op.Version > 0
+			}
+		}
+
+		if historical {
+			continue // the status is only for the current desired model
+		} else if markDelivered {
+			result[i].DesiredStatus, result[i].Error = mgr.readMarkDelivered(ctx, rr.ID.ToID())
+		} else if statuses, err := mgr.ReadDesiredStatus(ctx, rr.ID.ToID()); err != nil {
+			result[i].Error = err
+		} else {
+			result[i].DesiredStatus = statuses.Entries
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package stdmgr
+
+import (
+	"context"
+	"maps"
+
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// ReadRejections implements the `managermodel.RejectionReceiver` interface.
+func (mgr *ManagerImpl) ReadRejections(ctx context.Context, id persistencemodel.ID) (managermodel.Rejections, error) {
+	rejections, _, err := mgr.readRejections(ctx, id)
+
+	return rejections, err
+}
+
+// readRejections reads the rejection section and the version of it. If not found, an empty section with
+// version zero is returned.
+func (mgr *ManagerImpl) readRejections(ctx context.Context, id persistencemodel.ID) (managermodel.Rejections, int64, error) {
+	section, version, err := readSection[managermodel.Rejections](ctx, mgr, managermodel.RejectionsID(id))
+
+	if err != nil {
+		return managermodel.Rejections{}, 0, err
+	}
+
+	// Copy since the persistence may return the stored instance
+	rejections := managermodel.Rejections{Entries: map[string]managermodel.Rejection{}}
+	maps.Copy(rejections.Entries, section.Entries)
+
+	return rejections, version, nil
+}
+
+// reportWriteSections will upsert all rejections into the rejection section and, when enabled, the desired
+// status section for each operation where the desired model was successfully persisted.
+func (mgr *ManagerImpl) reportWriteSections(ctx context.Context, batch *reportBatch) {
+	for i, r := range batch.results {
+		dc := batch.folds[i].desires
+
+		if dc == nil || r == nil || r.Error != nil || !r.DesiredProcessed {
+			continue
+		}
+
+		op := &batch.operations[i]
+
+		// The models are already persisted, a failed section must not fail the operation
+		if len(dc.rejected) > 0 {
+			if err := mgr.writeRejections(ctx, op.ClientID, op.ID, dc.rejected); err != nil {
+				r.SectionError = err
+			} else {
+				r.Rejected = dc.rejected
+			}
+		}
+
+		if mgr.desiredStatus {
+			if err := mgr.reportUpdateStatus(ctx, op, dc); err != nil && r.SectionError == nil {
+				r.SectionError = err
+			}
+		}
+	}
+}
+
+// writeRejections upserts the _rejected_ into the rejection section.
+func (mgr *ManagerImpl) writeRejections(ctx context.Context, clientID string, id persistencemodel.ID, rejected []managermodel.Rejection) error {
+	rejections, version, err := mgr.readRejections(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	for _, rj := range rejected {
+		rejections.Entries[rj.Path] = rj
+	}
+
+	return mgr.writeSection(ctx, clientID, managermodel.RejectionsID(id), rejections, version)
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"slices"
+	"time"
 
 	"github.com/mariotoffia/godeviceshadow/merge"
 	"github.com/mariotoffia/godeviceshadow/model"
@@ -16,186 +18,388 @@ import (
 // (from persistence) and if any desired (from persistence) values are matched, it will acknowledge them. Then it
 // will update both reported and desired (if any changes).
 //
+// Operations on the same shadow are folded, in input order, onto a single read of the shadow and persisted using a
+// single write. Each operation still gets its own loggers, statistics and models in its result.
+//
 // If another process / go routine is updating the same id, it may fail, and return an error. If e.g. 409 (Conflict)
 // the caller may safely re-try the operation.
 //
-// TIP: It will *always* return a slice of `  managermodel.ReportOperationResult` with the same length as the input `operations`.
+// TIP: It will *always* return a slice of `managermodel.ReportOperationResult` with the same length and order as the input `operations`.
 //
 // This implements the `managermodel.Reportable` interface.
-func (mgr *ManagerImpl) Report(ctx context.Context, operations ...managermodel.ReportOperation) []managermodel.ReportOperationResult {
+func (mgr *ManagerImpl) Report(ctx context.Context, operations ...managermodel.ReportOperation) (res []managermodel.ReportOperationResult) {
 	if len(operations) == 0 {
 		return nil
 	}
 
-	toResults := func(results map[string]*managermodel.ReportOperationResult) []managermodel.ReportOperationResult {
-		res := make([]managermodel.ReportOperationResult, 0, len(operations))
+	ctx, obs := mgr.observe(ctx, "report")
 
-		for op := range results {
-			res = append(res, *results[op])
-		}
+	defer func() {
+		obs.end(resultErrors(res, func(r managermodel.ReportOperationResult) error { return r.Error })...)
+	}()
 
-		return res
-	}
+	batch := newReportBatch(operations)
 
-	results := make(map[string]*managermodel.ReportOperationResult, len(operations))
+	if mgr.tokenWindow > 0 {
+		mgr.reportDuplicates(ctx, batch)
+	}
 
 	// Prepare for read
-	readOps := mgr.reportPrepareForRead(operations, results)
+	readOps := mgr.reportPrepareForRead(ctx, batch)
 
 	if len(readOps) == 0 {
-		return toResults(results)
+		return mgr.reportResults(ctx, batch)
 	}
 
 	// Read the models
-	readResults := mgr.reportReadFromPersistence(ctx, readOps, results, true /*create*/)
+	readResults := mgr.reportReadFromPersistence(ctx, readOps, batch, true /*create*/)
 
 	if len(readResults) == 0 {
-		return toResults(results)
+		return mgr.reportResults(ctx, batch)
 	}
 
 	// Merge the models
-	readResults = mgr.reportMergeModels(ctx, readResults, operations, results)
+	readResults = mgr.reportMergeModels(ctx, readResults, batch)
+
+	if mgr.checks() {
+		mgr.reportCheck(ctx, readResults, batch)
+	}
 
 	// Now we may have queueDesired|Reported models to persist.
 	writes := reportCreateWrites(readResults)
 
 	if len(writes) == 0 {
-		return toResults(results)
+		return mgr.reportResults(ctx, batch)
 	}
 
 	// Write
-	mgr.reportWriteBack(ctx, writes, results)
+	mgr.reportWriteBack(ctx, writes, batch)
+
+	// Rejections and desired status are persisted in their own sections when the desired model was successfully written
+	mgr.reportWriteSections(ctx, batch)
 
-	return toResults(results)
+	return mgr.reportResults(ctx, batch)
 }
 
-func (mgr *ManagerImpl) reportMergeModels(
-	ctx context.Context,
-	readResults []groupedPersistenceResult,
-	operations []managermodel.ReportOperation,
-	results map[string]*managermodel.ReportOperationResult,
-) []groupedPersistenceResult {
-	//
-	getOperation := func(id, name string) *managermodel.ReportOperation {
-		for _, op := range operations {
-			if op.ID.ID == id && op.ID.Name == name {
-				return &op
+// reportBatch keeps track of the operations and their results, in input order, in a `Report` call.
+type reportBatch struct {
+	operations []managermodel.ReportOperation
+	results    []*managermodel.ReportOperationResult
+	folds      []reportFold
+	// types are the resolved types of the operations.
+	types []model.TypeEntry
+	// calls are the intercepted operations (if any interceptors).
+	calls []*managermodel.InterceptCall
+	// shadows maps the `persistencemodel.ID.String()` to the indices, in input order, of all operations on that shadow.
+	shadows map[string][]int
+}
+
+// reportFold is the outcome of folding a single operation onto the shadow.
+type reportFold struct {
+	// queueReported is set when the operation needs the reported model to be persisted.
+	queueReported bool
+	// queueDesired is set when the operation needs the desired model to be persisted.
+	queueDesired bool
+	// desires are the acknowledged and rejected desired values.
+	desires *desireCollector
+}
+
+func newReportBatch(operations []managermodel.ReportOperation) *reportBatch {
+	return &reportBatch{
+		operations: slices.Clone(operations), // interceptors may alter the operations
+		results:    make([]*managermodel.ReportOperationResult, len(operations)),
+		folds:      make([]reportFold, len(operations)),
+		types:      make([]model.TypeEntry, len(operations)),
+		calls:      make([]*managermodel.InterceptCall, len(operations)),
+		shadows:    make(map[string][]int, len(operations)),
+	}
+}
+
+// setShadowError sets the _err_ on all operations on the shadow _key_ that do not already have an error.
+func (b *reportBatch) setShadowError(key string, err error) {
+	for _, idx := range b.shadows[key] {
+		if b.results[idx] == nil {
+			b.results[idx] = &managermodel.ReportOperationResult{ID: b.operations[idx].ID}
+		}
+
+		if b.results[idx].Error == nil {
+			b.results[idx].Error = err
+		}
+	}
+}
+
+func (b *reportBatch) toResults() []managermodel.ReportOperationResult {
+	res := make([]managermodel.ReportOperationResult, len(b.operations))
+
+	for i, r := range b.results {
+		if r == nil {
+			res[i] = managermodel.ReportOperationResult{ID: b.operations[i].ID}
+			continue
+		}
+
+		res[i] = *r
+	}
+
+	return res
+}
+
+// reportResults returns the results, in input order, after the `Interceptor.After` has been invoked on each.
+func (mgr *ManagerImpl) reportResults(ctx context.Context, batch *reportBatch) []managermodel.ReportOperationResult {
+	if mgr.tokenWindow > 0 {
+		mgr.reportRecordTokens(ctx, batch)
+	}
+
+	res := batch.toResults()
+
+	if len(mgr.interceptors) == 0 {
+		return res
+	}
+
+	for i := range res {
+		call := batch.calls[i]
+
+		if call == nil {
+			// Never reached the merge
+			call = &managermodel.InterceptCall{
+				Kind:   managermodel.InterceptReport,
+				ID:     batch.operations[i].ID,
+				Type:   batch.types[i],
+				Report: &batch.operations[i],
 			}
 		}
 
-		return nil
+		call.ReportResult = &res[i]
+		mgr.interceptAfter(ctx, call)
 	}
 
+	return res
+}
+
+func (mgr *ManagerImpl) reportMergeModels(
+	ctx context.Context,
+	readResults []groupedPersistenceResult,
+	batch *reportBatch,
+) []groupedPersistenceResult {
 	for i, rdr := range readResults {
-		op := getOperation(rdr.id.ID, rdr.id.Name)
+		indices := batch.shadows[rdr.id.String()]
+
+		if len(indices) == 0 {
+			continue
+		}
+
+		first := &batch.operations[indices[0]]
 		sep := mgr.separation
 
-		if op.Separation > 0 {
-			sep = op.Separation
+		if first.Separation > 0 {
+			sep = first.Separation
 		}
 
-		readResults[i].op = op
+		// The last operation owns the write (client id)
+		readResults[i].op = &batch.operations[indices[len(indices)-1]]
+		readResults[i].schemaVersion = batch.types[indices[0]].Schema.Version
 
-		// Merge the Reported models
 		var (
-			reported any // Merge into this to use below: desired
-			err      error
+			reported, desired any
+			version           int64
+			queueReported     bool
+			queueDesired      bool
 		)
 
+		if rdr.desired != nil {
+			desired, version = rdr.desired.Model, rdr.desired.Version
+		}
+
+		// The version of an operation refers to the reported model
 		if rdr.reported != nil {
-			ml := mgr.createMergeLoggers(true /*report*/, op.MergeLoggers)
+			reported, version = rdr.reported.Model, rdr.reported.Version
+		}
 
-			mergeMode := merge.ServerIsMaster
+		// The preconditions are checked against the stored model, a new shadow has no values
+		var stored any
 
-			if op.MergeMode > 0 {
-				mergeMode = op.MergeMode
-			}
+		if version > 0 {
+			stored = reported
+		}
 
-			reported, err = merge.MergeAny(context.Background(), rdr.reported.Model, op.Model, merge.MergeOptions{
-				Mode:    mergeMode,
-				Loggers: ml,
-			})
+		// Fold each operation, in order, onto the shadow
+		for _, idx := range indices {
+			op := &batch.operations[idx]
 
-			if err != nil {
-				results[rdr.id.String()] = &managermodel.ReportOperationResult{
-					ID:    rdr.id,
-					Error: err,
+			if call := batch.calls[idx]; call != nil {
+				call.Reported, call.Desired = reported, desired
+			}
+
+			if op.Version > 0 && op.Version != version {
+				batch.results[idx] = &managermodel.ReportOperationResult{
+					ID: op.ID,
+					Error: persistencemodel.Error409(
+						fmt.Sprintf("version mismatch, expected: %d, got: %d", op.Version, version),
+					),
 				}
 
 				continue
 			}
 
-			dl, _ := FindMergeDirtyLogger(ml)
+			if err := checkPreconditions(ctx, stored, op.Preconditions); err != nil {
+				batch.results[idx] = &managermodel.ReportOperationResult{ID: op.ID, Error: err}
+				continue
+			}
 
-			if !dl.Dirty {
-				// Nothing to do (no changes)
-				results[rdr.id.String()] = &managermodel.ReportOperationResult{
-					ID:           rdr.id,
-					MergeLoggers: ml,
-				}
+			res, fold, newReported, newDesired := mgr.reportFoldOperation(ctx, op, sep, reported, desired)
+
+			batch.results[idx] = res
+			batch.folds[idx] = fold
 
+			if res.Error != nil {
+				// Discard the changes of the operation
 				continue
 			}
 
-			// need persist -> queue
+			reported, desired, stored = newReported, newDesired, newReported
+			queueReported = queueReported || fold.queueReported
+			queueDesired = queueDesired || fold.queueDesired
+		}
+
+		// Write back persisted models that were migrated from an older schema version
+		if rdr.reported != nil && rdr.reported.Version > 0 && rdr.reported.SchemaVersion < readResults[i].schemaVersion {
+			queueReported = true
+		}
+
+		if rdr.desired != nil && rdr.desired.Version > 0 && rdr.desired.SchemaVersion < readResults[i].schemaVersion {
+			queueDesired = true
+		}
+
+		// need persist -> queue the folded models
+		if queueReported {
 			readResults[i].queueReported = reported
+		}
 
-			results[rdr.id.String()] = &managermodel.ReportOperationResult{
-				ID:           rdr.id,
-				MergeLoggers: ml,
-				ReportModel:  reported,
-			}
+		if queueDesired {
+			readResults[i].queueDesired = desired
 		}
+	}
 
-		if rdr.desired != nil && reported != nil {
-			dl := mgr.createDesiredLoggers(op.DesiredLoggers)
-			modelDesired, err := merge.DesiredAny(ctx, reported, rdr.desired.Model, merge.DesiredOptions{
-				Loggers: dl,
-			})
+	return readResults
+}
 
-			if err != nil {
-				// Skip this
-				results[rdr.id.String()] = &managermodel.ReportOperationResult{
-					ID:    rdr.id,
-					Error: err,
-				}
+// reportFoldOperation merges the _op_ onto the _reported_ and _desired_ models and returns the result of the
+// operation along with the new models. If the result has an error, the returned models shall be discarded.
+func (mgr *ManagerImpl) reportFoldOperation(
+	ctx context.Context,
+	op *managermodel.ReportOperation,
+	sep persistencemodel.ModelSeparation,
+	reported, desired any,
+) (*managermodel.ReportOperationResult, reportFold, any, any) {
+	var (
+		fold        reportFold
+		newReported any // Merge into this to use below: desired
+		stats       merge.Stats
+		err         error
+	)
+
+	res := &managermodel.ReportOperationResult{ID: op.ID}
+
+	// Merge the Reported models
+	if reported != nil {
+		ml := mgr.createMergeLoggers(true /*report*/, op.MergeLoggers)
+
+		mergeMode := merge.ServerIsMaster
+
+		if op.MergeMode > 0 {
+			mergeMode = op.MergeMode
+		}
 
-				// Do not persist
-				readResults[i].queueReported = nil
+		start := time.Now()
 
-				continue
-			}
+		newReported, err = merge.MergeAny(ctx, reported, op.Model, merge.MergeOptions{
+			Mode:    mergeMode,
+			Loggers: ml,
+			Stats:   &stats,
+		})
 
-			dla, _ := FindDesiredAckLogger(dl)
+		mgr.observeMerge(ctx, managermodel.InterceptReport, start)
 
-			if r, ok := results[rdr.id.String()]; ok {
-				r.DesiredLoggers = dl
-				r.DesiredModel = modelDesired
-			} else {
-				results[rdr.id.String()] = &managermodel.ReportOperationResult{
-					ID:             rdr.id,
-					DesiredLoggers: dl,
-					DesiredModel:   modelDesired,
-				}
-			}
+		if err != nil {
+			return &managermodel.ReportOperationResult{ID: op.ID, Error: err}, reportFold{}, reported, desired
+		}
 
-			// Always needed when combined independent on dirty
-			if dla.Dirty || sep == persistencemodel.CombinedModels {
-				// need persist -> queue
-				readResults[i].queueDesired = modelDesired
+		res.MergeLoggers = ml
+		res.Stats = stats
 
-				// Make sure reported is persisted as well when combined models
-				if sep == persistencemodel.CombinedModels && readResults[i].queueReported == nil {
-					readResults[i].queueReported = reported
-				}
-			}
+		dl, _ := FindMergeDirtyLogger(ml)
+
+		if !dl.Dirty && len(op.RejectedDesires) == 0 {
+			// Nothing to do (no changes)
+			return res, fold, reported, desired
+		}
+
+		if dl.Dirty {
+			// need persist -> queue
+			fold.queueReported = true
+			res.ReportModel = newReported
 		}
 	}
 
-	return readResults
+	if desired == nil || newReported == nil {
+		return res, fold, newReported, desired
+	}
+
+	var desiredStats merge.Stats
+
+	dc := &desireCollector{clientID: op.ClientID}
+	dl := mgr.createDesiredLoggers(op.DesiredLoggers)
+	modelDesired, err := merge.DesiredAny(ctx, newReported, desired, merge.DesiredOptions{
+		Loggers:          append(merge.DesiredLoggers{dc}, dl...),
+		Stats:            &desiredStats,
+		DefaultAckPolicy: op.DefaultAckPolicy,
+		AckPolicies:      op.AckPolicies,
+	})
+
+	if err != nil {
+		// Do not persist
+		return &managermodel.ReportOperationResult{ID: op.ID, Error: err}, reportFold{}, reported, desired
+	}
+
+	if len(op.RejectedDesires) > 0 {
+		var rejectStats merge.Stats
+
+		modelDesired, err = merge.RejectAny(ctx, modelDesired, op.RejectedDesires, merge.DesiredOptions{
+			Loggers: append(merge.DesiredLoggers{dc}, dl...),
+			Stats:   &rejectStats,
+		})
+
+		if err != nil {
+			return &managermodel.ReportOperationResult{ID: op.ID, Error: err}, reportFold{}, reported, desired
+		}
+
+		desiredStats = desiredStats.Combine(rejectStats)
+	}
+
+	fold.desires = dc
+
+	dla, _ := FindDesiredAckLogger(dl)
+
+	res.DesiredLoggers = dl
+	res.DesiredModel = modelDesired
+	res.Stats.Acknowledged = desiredStats.Acknowledged
+	res.Stats.Rejected = desiredStats.Rejected
+	res.Stats.Elapsed += desiredStats.Elapsed
+
+	// Always needed when combined independent on dirty
+	if dla.Dirty || sep == persistencemodel.CombinedModels {
+		// need persist -> queue
+		fold.queueDesired = true
+
+		// Make sure reported is persisted as well when combined models
+		if sep == persistencemodel.CombinedModels {
+			fold.queueReported = true
+		}
+	}
+
+	return res, fold, newReported, modelDesired
 }
 
-func (mgr *ManagerImpl) reportWriteBack(ctx context.Context, writes []persistencemodel.WriteOperation, results map[string]*managermodel.ReportOperationResult) {
+func (mgr *ManagerImpl) reportWriteBack(ctx context.Context, writes []persistencemodel.WriteOperation, batch *reportBatch) {
 	result := mgr.persistence.Write(ctx, persistencemodel.WriteOptions{
 		Config: persistencemodel.WriteConfig{
 			Separation: mgr.separation,
@@ -203,39 +407,72 @@ func (mgr *ManagerImpl) reportWriteBack(ctx context.Context, writes []persistenc
 	}, writes...)
 
 	for _, wr := range result {
-		if wr.Error != nil {
-			if r, ok := results[wr.ID.StringWithoutModelType()]; ok {
+		for _, idx := range batch.shadows[wr.ID.StringWithoutModelType()] {
+			r, fold := batch.results[idx], batch.folds[idx]
+
+			if r == nil || r.Error != nil || (!fold.queueReported && !fold.queueDesired) {
+				// Did not contribute to the write
+				continue
+			}
+
+			if wr.Error != nil {
 				r.Error = wr.Error
-			} else {
-				results[wr.ID.StringWithoutModelType()] = &managermodel.ReportOperationResult{
-					ID:    wr.ID.ToID(),
-					Error: wr.Error,
-				}
+				continue
+			}
+
+			if wr.ID.ModelType == persistencemodel.ModelTypeReported && fold.queueReported {
+				r.ReportedProcessed = true
+			} else if wr.ID.ModelType == persistencemodel.ModelTypeDesired && fold.queueDesired {
+				r.DesiredProcessed = true
 			}
+		}
+	}
+
+	if mgr.watches.active() {
+		mgr.reportPublish(writes, result, batch)
+	}
+}
+
+// reportPublish emits a `managermodel.WatchEvent` for each successful write.
+func (mgr *ManagerImpl) reportPublish(writes []persistencemodel.WriteOperation, result []persistencemodel.WriteResult, batch *reportBatch) {
+	events := make([]managermodel.WatchEvent, 0, len(result))
 
+	for _, wr := range result {
+		if wr.Error != nil {
 			continue
 		}
 
-		if wr.ID.ModelType == persistencemodel.ModelTypeReported {
-			if r, ok := results[wr.ID.StringWithoutModelType()]; ok {
-				r.ReportedProcessed = true
-			} else {
-				results[wr.ID.StringWithoutModelType()] = &managermodel.ReportOperationResult{
-					ID:                wr.ID.ToID(),
-					ReportedProcessed: true,
-				}
+		event := managermodel.WatchEvent{
+			Kind:      managermodel.InterceptReport,
+			ID:        wr.ID,
+			Version:   wr.Version,
+			TimeStamp: wr.TimeStamp,
+		}
+
+		for _, w := range writes {
+			if w.ID.Equal(wr.ID) {
+				event.Model, event.ClientID = w.Model, w.ClientID
 			}
-		} else if wr.ID.ModelType == persistencemodel.ModelTypeDesired {
-			if r, ok := results[wr.ID.StringWithoutModelType()]; ok {
-				r.DesiredProcessed = true
-			} else {
-				results[wr.ID.StringWithoutModelType()] = &managermodel.ReportOperationResult{
-					ID:               wr.ID.ToID(),
-					DesiredProcessed: true,
-				}
+		}
+
+		for _, idx := range batch.shadows[wr.ID.StringWithoutModelType()] {
+			r, fold := batch.results[idx], batch.folds[idx]
+
+			if r == nil || r.Error != nil {
+				continue
+			}
+
+			if wr.ID.ModelType == persistencemodel.ModelTypeReported && fold.queueReported {
+				event.MergeLoggers = append(event.MergeLoggers, r.MergeLoggers...)
+			} else if wr.ID.ModelType == persistencemodel.ModelTypeDesired && fold.queueDesired {
+				event.DesiredLoggers = append(event.DesiredLoggers, r.DesiredLoggers...)
 			}
 		}
+
+		events = append(events, event)
 	}
+
+	mgr.watches.publish(events...)
 }
 
 // createDesiredLoggers will create logger instance from _loggers_ (if any), if none where submitted, it will use the `Manager.desiredLoggers`.
@@ -263,7 +500,7 @@ func (mgr *ManagerImpl) createDesiredLoggers(loggers []model.CreatableDesiredLog
 func (mgr *ManagerImpl) reportReadFromPersistence(
 	ctx context.Context,
 	readOps []persistencemodel.ReadOperation,
-	results map[string]*managermodel.ReportOperationResult,
+	batch *reportBatch,
 	create bool,
 ) []groupedPersistenceResult {
 	readResults := mgr.persistence.Read(ctx, persistencemodel.ReadOptions{}, readOps...)
@@ -285,7 +522,7 @@ func (mgr *ManagerImpl) reportReadFromPersistence(
 
 			if errors.As(rdr.Error, &pe); create && pe.Code == 404 /*not found*/ {
 				// Special case -> create new empty model
-				if op := findOp(rdr.ID); op != nil && op.Version == 0 /*only when version is 0*/ {
+				if op := findOp(rdr.ID); op != nil {
 					rdr.Model = reflect.New(op.Model).Elem().Interface()
 					rdr.Error = nil
 
@@ -309,11 +546,7 @@ func (mgr *ManagerImpl) reportReadFromPersistence(
 			}
 
 			if rdr.Model == nil {
-				results[rdr.ID.StringWithoutModelType()] = &managermodel.ReportOperationResult{
-					ID:    rdr.ID.ToID(),
-					Error: rdr.Error,
-				}
-
+				batch.setShadowError(rdr.ID.StringWithoutModelType(), rdr.Error)
 				continue
 			}
 		}
@@ -340,24 +573,34 @@ func (mgr *ManagerImpl) reportReadFromPersistence(
 		}
 	}
 
+	// Keep the order of the read operations to get a deterministic write order
 	r := make([]groupedPersistenceResult, 0, len(res))
 
-	for _, v := range res {
-		r = append(r, *v)
+	for _, op := range readOps {
+		if v, ok := res[op.ID.StringWithoutModelType()]; ok {
+			r = append(r, *v)
+			delete(res, op.ID.StringWithoutModelType())
+		}
 	}
 
 	return r
 }
 
-func (mgr *ManagerImpl) reportPrepareForRead(operations []managermodel.ReportOperation, results map[string]*managermodel.ReportOperationResult) []persistencemodel.ReadOperation {
+func (mgr *ManagerImpl) reportPrepareForRead(ctx context.Context, batch *reportBatch) []persistencemodel.ReadOperation {
 	// Prepare for read
-	readOps := make([]persistencemodel.ReadOperation, 0, len(operations))
+	readOps := make([]persistencemodel.ReadOperation, 0, len(batch.operations))
 
-	for _, op := range operations {
+	for i, op := range batch.operations {
 		te, ok := mgr.ResolveType(op.ModelType, op.ID)
 
+		if batch.results[i] != nil {
+			// Duplicate, already applied
+			batch.types[i] = te
+			continue
+		}
+
 		if !ok {
-			results[op.ID.String()] = &managermodel.ReportOperationResult{
+			batch.results[i] = &managermodel.ReportOperationResult{
 				ID:    op.ID,
 				Error: fmt.Errorf("unable to resolve type: %s", op.ModelType),
 			}
@@ -366,7 +609,7 @@ func (mgr *ManagerImpl) reportPrepareForRead(operations []managermodel.ReportOpe
 		}
 
 		if op.Model == nil {
-			results[op.ID.String()] = &managermodel.ReportOperationResult{
+			batch.results[i] = &managermodel.ReportOperationResult{
 				ID:    op.ID,
 				Error: fmt.Errorf("model is nil (use delete to remove model)"),
 			}
@@ -374,6 +617,42 @@ func (mgr *ManagerImpl) reportPrepareForRead(operations []managermodel.ReportOpe
 			continue
 		}
 
+		batch.types[i] = te
+
+		if len(mgr.interceptors) > 0 {
+			// Before the read, since the interceptor may alter the operation
+			call := &managermodel.InterceptCall{
+				Kind:   managermodel.InterceptReport,
+				ID:     op.ID,
+				Type:   te,
+				Report: &batch.operations[i],
+			}
+
+			batch.calls[i] = call
+
+			if err := mgr.interceptBefore(ctx, call); err != nil {
+				batch.results[i] = &managermodel.ReportOperationResult{ID: op.ID, Error: err}
+				continue
+			}
+
+			op = batch.operations[i]
+		}
+
+		if err := checkReservedID(op.ID); err != nil {
+			batch.results[i] = &managermodel.ReportOperationResult{ID: op.ID, Error: err}
+			continue
+		}
+
+		key := op.ID.String()
+
+		if indices, ok := batch.shadows[key]; ok {
+			// Already read -> fold onto the first operation on the same shadow
+			batch.shadows[key] = append(indices, i)
+			continue
+		}
+
+		batch.shadows[key] = []int{i}
+
 		sep := op.Separation
 
 		if op.Separation == 0 {
@@ -383,21 +662,21 @@ func (mgr *ManagerImpl) reportPrepareForRead(operations []managermodel.ReportOpe
 		if sep == persistencemodel.SeparateModels {
 			readOps = append(readOps,
 				persistencemodel.ReadOperation{
-					ID:      persistencemodel.PersistenceID{ID: op.ID.ID, Name: op.ID.Name, ModelType: persistencemodel.ModelTypeReported},
-					Version: op.Version,
-					Model:   te.Model,
+					ID:     persistencemodel.PersistenceID{ID: op.ID.ID, Name: op.ID.Name, ModelType: persistencemodel.ModelTypeReported},
+					Model:  te.Model,
+					Schema: te.Schema,
 				},
 				persistencemodel.ReadOperation{
-					ID:      persistencemodel.PersistenceID{ID: op.ID.ID, Name: op.ID.Name, ModelType: persistencemodel.ModelTypeDesired},
-					Version: op.Version,
-					Model:   te.Model,
+					ID:     persistencemodel.PersistenceID{ID: op.ID.ID, Name: op.ID.Name, ModelType: persistencemodel.ModelTypeDesired},
+					Model:  te.Model,
+					Schema: te.Schema,
 				},
 			)
 		} else {
 			readOps = append(readOps, persistencemodel.ReadOperation{
-				ID:      persistencemodel.PersistenceID{ID: op.ID.ID, Name: op.ID.Name, ModelType: 0 /*combined*/},
-				Version: op.Version,
-				Model:   te.Model,
+				ID:     persistencemodel.PersistenceID{ID: op.ID.ID, Name: op.ID.Name, ModelType: 0 /*combined*/},
+				Model:  te.Model,
+				Schema: te.Schema,
 			})
 		}
 	}
@@ -411,10 +690,11 @@ func reportCreateWrites(readResults []groupedPersistenceResult) []persistencemod
 	for _, rdr := range readResults {
 		if rdr.queueReported != nil {
 			writes = append(writes, persistencemodel.WriteOperation{
-				ClientID: rdr.op.ClientID,
-				ID:       rdr.reported.ID,
-				Model:    rdr.queueReported,
-				Version:  rdr.reported.Version,
+				ClientID:      rdr.op.ClientID,
+				ID:            rdr.reported.ID,
+				Model:         rdr.queueReported,
+				Version:       rdr.reported.Version,
+				SchemaVersion: rdr.schemaVersion,
 				Config: persistencemodel.WriteOperationConfig{
 					Separation: rdr.op.Separation,
 				},
@@ -423,10 +703,11 @@ func reportCreateWrites(readResults []groupedPersistenceResult) []persistencemod
 
 		if rdr.queueDesired != nil {
 			writes = append(writes, persistencemodel.WriteOperation{
-				ClientID: rdr.op.ClientID,
-				ID:       rdr.desired.ID,
-				Model:    rdr.queueDesired,
-				Version:  rdr.desired.Version,
+				ClientID:      rdr.op.ClientID,
+				ID:            rdr.desired.ID,
+				Model:         rdr.queueDesired,
+				Version:       rdr.desired.Version,
+				SchemaVersion: rdr.schemaVersion,
 				Config: persistencemodel.WriteOperationConfig{
 					Separation: rdr.op.Separation,
 				},
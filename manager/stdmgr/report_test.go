@@ -14,6 +14,7 @@ import (
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
 	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
 	"github.com/mariotoffia/godeviceshadow/types"
+	"github.com/mariotoffia/godeviceshadow/validate"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -222,10 +223,367 @@ func TestReportUpdateReportNotChanged(t *testing.T) {
 	assert.Len(t, chl.ManagedLog[model.MergeOperationNotChanged], 1)
 }
 
+type ctxKey string
+
+// ctxCaptureLogger records the context value of `ctxKey("request")` that the merge logger is invoked with.
+type ctxCaptureLogger struct {
+	Values []any
+}
+
+func (cl *ctxCaptureLogger) New() model.MergeLogger {
+	return &ctxCaptureLogger{}
+}
+
+func (cl *ctxCaptureLogger) Managed(
+	ctx context.Context, path string, operation model.MergeOperation,
+	oldValue, newValue model.ValueAndTimestamp, oldTimeStamp, newTimeStamp time.Time,
+) {
+	cl.Values = append(cl.Values, ctx.Value(ctxKey("request")))
+}
+
+func (cl *ctxCaptureLogger) Plain(ctx context.Context, path string, operation model.MergeOperation, oldValue, newValue any) {
+	cl.Values = append(cl.Values, ctx.Value(ctxKey("request")))
+}
+
+func TestReportPropagatesContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey("request"), "req-1")
+
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.CombinedModels).
+		WithReportLoggers(&ctxCaptureLogger{}).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	res := mgr.Report(ctx, managermodel.ReportOperation{
+		Model: TestModel{TimeZone: tz, Sensors: map[string]Sensor{"temp": {Value: 23.4, TimeStamp: time.Now()}}},
+		ID:    persistencemodel.ID{ID: "device123", Name: "homeHub"},
+	})
+
+	require.Len(t, res, 1)
+	require.NoError(t, res[0].Error)
+
+	cl, ok := res[0].MergeLoggers[0].(*ctxCaptureLogger)
+	require.True(t, ok)
+	require.NotEmpty(t, cl.Values)
+
+	for _, v := range cl.Values {
+		assert.Equal(t, "req-1", v)
+	}
+}
+
+func TestReportCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.CombinedModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	res := mgr.Report(ctx, managermodel.ReportOperation{
+		Model: TestModel{TimeZone: tz, Sensors: map[string]Sensor{"temp": {Value: 23.4, TimeStamp: time.Now()}}},
+		ID:    persistencemodel.ID{ID: "device123", Name: "homeHub"},
+	})
+
+	require.Len(t, res, 1)
+	assert.ErrorIs(t, res[0].Error, context.Canceled)
+	assert.False(t, res[0].ReportedProcessed)
+
+	// Nothing shall have been persisted
+	rr := mgr.Read(context.Background(), managermodel.ReadOperation{
+		ID: persistencemodel.PersistenceID{ID: "device123", Name: "homeHub", ModelType: persistencemodel.ModelTypeReported},
+	})
+
+	require.Len(t, rr, 1)
+	assert.Error(t, rr[0].Error)
+}
+
 // BenchmarkNewReportAndUpdateReport benchmarks the creation of a new report and then
 // update the report with a new value.
 //
 // On my machine it takes about 14μs to perform this benchmark.
+func TestReportBatchPreservesOrderAndFolds(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithReportLoggers(changelogger.New()).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	first := persistencemodel.ID{ID: "device1", Name: "homeHub"}
+	second := persistencemodel.ID{ID: "device2", Name: "homeHub"}
+
+	res := mgr.Report(ctx,
+		managermodel.ReportOperation{
+			ClientID: "myClient",
+			ID:       first,
+			Model:    TestModel{TimeZone: tz, Sensors: map[string]Sensor{"temp": {Value: 23.4, TimeStamp: now}}},
+		},
+		managermodel.ReportOperation{
+			ClientID: "myClient",
+			ID:       second,
+			Model:    TestModel{TimeZone: tz, Sensors: map[string]Sensor{"temp": {Value: 18.2, TimeStamp: now}}},
+		},
+		managermodel.ReportOperation{
+			ClientID: "myClient",
+			ID:       first,
+			Model:    TestModel{TimeZone: tz, Sensors: map[string]Sensor{"humidity": {Value: 45.0, TimeStamp: now}}},
+		},
+	)
+
+	require.Len(t, res, 3)
+	assert.Equal(t, first, res[0].ID)
+	assert.Equal(t, second, res[1].ID)
+	assert.Equal(t, first, res[2].ID)
+
+	for _, r := range res {
+		require.NoError(t, r.Error)
+		assert.True(t, r.ReportedProcessed)
+	}
+
+	// Each operation has its own loggers
+	chl := changelogger.Find(res[2].MergeLoggers)
+	require.NotNil(t, chl)
+
+	added := chl.ManagedLog[model.MergeOperationAdd]
+	require.Len(t, added, 1)
+	assert.Equal(t, "Sensors.humidity", added[0].Path)
+
+	// Both operations are folded into a single write
+	rr := mgr.Read(ctx, managermodel.ReadOperation{ID: first.ToPersistenceID(persistencemodel.ModelTypeReported)})
+	require.Len(t, rr, 1)
+	require.NoError(t, rr[0].Error)
+	assert.Equal(t, int64(1), rr[0].Version)
+
+	reported := rr[0].Model.(TestModel)
+	assert.Len(t, reported.Sensors, 2)
+}
+
+func TestReportBatchFailsOnlyStaleVersion(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	id := persistencemodel.ID{ID: "device1", Name: "homeHub"}
+
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	res := mgr.Report(ctx, managermodel.ReportOperation{
+		ID:    id,
+		Model: TestModel{TimeZone: tz, Sensors: map[string]Sensor{"temp": {Value: 23.4, TimeStamp: now}}},
+	})
+
+	require.Len(t, res, 1)
+	require.NoError(t, res[0].Error)
+
+	// The first operation has a stale version, the latest version is read and only that operation fails
+	res = mgr.Report(ctx,
+		managermodel.ReportOperation{
+			ID:      id,
+			Version: 7,
+			Model:   TestModel{TimeZone: tz, Sensors: map[string]Sensor{"temp": {Value: 24.1, TimeStamp: now.Add(time.Second)}}},
+		},
+		managermodel.ReportOperation{
+			ID:    id,
+			Model: TestModel{TimeZone: tz, Sensors: map[string]Sensor{"humidity": {Value: 45.0, TimeStamp: now}}},
+		},
+		managermodel.ReportOperation{
+			ID:      id,
+			Version: 1,
+			Model:   TestModel{TimeZone: tz, Sensors: map[string]Sensor{"co2": {Value: 410.0, TimeStamp: now}}},
+		},
+	)
+
+	require.Len(t, res, 3)
+
+	var pe persistencemodel.PersistenceError
+
+	require.ErrorAs(t, res[0].Error, &pe)
+	assert.Equal(t, 409, pe.Code)
+	require.NoError(t, res[1].Error)
+	require.NoError(t, res[2].Error)
+
+	rr := mgr.Read(ctx, managermodel.ReadOperation{ID: id.ToPersistenceID(persistencemodel.ModelTypeReported)})
+	require.Len(t, rr, 1)
+	require.NoError(t, rr[0].Error)
+	assert.Equal(t, int64(2), rr[0].Version)
+
+	reported := rr[0].Model.(TestModel)
+	assert.Len(t, reported.Sensors, 3)
+	assert.Equal(t, 23.4, reported.Sensors["temp"].Value)
+}
+func TestReportPreconditionFailed(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	id := persistencemodel.ID{ID: "device1", Name: "homeHub"}
+
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.CombinedModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	res := mgr.Report(ctx, managermodel.ReportOperation{
+		ID:    id,
+		Model: TestModel{TimeZone: tz, Sensors: map[string]Sensor{"temp": {Value: 23.4, TimeStamp: now}}},
+		Preconditions: []model.Precondition{
+			{Path: "TimeZone", Operator: model.PreconditionPresent},
+		},
+	})
+
+	require.Len(t, res, 1)
+
+	var pe persistencemodel.PersistenceError
+
+	require.ErrorAs(t, res[0].Error, &pe)
+	assert.Equal(t, 412, pe.Code)
+	assert.Contains(t, pe.Message, "TimeZone present")
+	assert.False(t, res[0].ReportedProcessed)
+}
+
+func TestReportPreconditionZeroValue(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	id := persistencemodel.ID{ID: "device1", Name: "homeHub"}
+
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	res := mgr.Report(ctx, managermodel.ReportOperation{
+		ID:    id,
+		Model: TestModel{Sensors: map[string]Sensor{"temp": {Value: 0.0, TimeStamp: now}}},
+	})
+
+	require.Len(t, res, 1)
+	require.NoError(t, res[0].Error)
+
+	// The stored zero values are present
+	res = mgr.Report(ctx,
+		managermodel.ReportOperation{
+			ID:    id,
+			Model: TestModel{TimeZone: tz, Sensors: map[string]Sensor{"temp": {Value: 1.0, TimeStamp: now.Add(time.Second)}}},
+			Preconditions: []model.Precondition{
+				{Path: "TimeZone", Operator: model.PreconditionEquals, Value: ""},
+				{Path: "Sensors.temp", Operator: model.PreconditionEquals, Value: 0.0},
+			},
+		},
+		managermodel.ReportOperation{
+			ID:    id,
+			Model: TestModel{Sensors: map[string]Sensor{"humidity": {Value: 45.0, TimeStamp: now}}},
+			Preconditions: []model.Precondition{
+				{Path: "Sensors.temp", Operator: model.PreconditionAbsent},
+			},
+		},
+	)
+
+	require.Len(t, res, 2)
+	require.NoError(t, res[0].Error)
+
+	var pe persistencemodel.PersistenceError
+
+	require.ErrorAs(t, res[1].Error, &pe)
+	assert.Equal(t, 412, pe.Code)
+}
+
+type ValidatedModel struct {
+	TimeZone string            `validate:"required"`
+	Sensors  map[string]Sensor `validate:"min=-40,max=85"`
+}
+
+func TestReportValidation(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	id := persistencemodel.ID{ID: "device1", Name: "homeHub"}
+
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.CombinedModels).
+		WithValidation().
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(ValidatedModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	res := mgr.Report(ctx, managermodel.ReportOperation{
+		ID:    id,
+		Model: ValidatedModel{TimeZone: tz, Sensors: map[string]Sensor{"temp": {Value: 120.0, TimeStamp: now}}},
+	})
+
+	require.Len(t, res, 1)
+
+	var pe persistencemodel.PersistenceError
+
+	require.ErrorAs(t, res[0].Error, &pe)
+	assert.Equal(t, 422, pe.Code)
+	assert.Equal(t, []validate.Violation{{Path: "Sensors.temp", Message: "must be at most 85"}}, validate.Violations(res[0].Error))
+	assert.False(t, res[0].ReportedProcessed)
+
+	// Nothing was stored
+	rr := mgr.Read(ctx, managermodel.ReadOperation{ID: id.ToPersistenceID(persistencemodel.ModelTypeReported)})
+	require.NotEmpty(t, rr)
+	require.ErrorAs(t, rr[0].Error, &pe)
+	assert.Equal(t, 404, pe.Code)
+
+	res = mgr.Report(ctx, managermodel.ReportOperation{
+		ID:    id,
+		Model: ValidatedModel{TimeZone: tz, Sensors: map[string]Sensor{"temp": {Value: 21.0, TimeStamp: now}}},
+	})
+
+	require.Len(t, res, 1)
+	require.NoError(t, res[0].Error)
+	assert.True(t, res[0].ReportedProcessed)
+}
+
 func BenchmarkNewReportAndUpdateReport(t *testing.B) {
 	ctx := context.Background()
 	now := time.Now()
@@ -0,0 +1,216 @@
+package stdmgr
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// Rollback implements the `managermodel.Rollbacker` interface. The restored model is written, as a new version, using
+// `managermodel.RollbackClientID` as client id. Hence, the version that was restored is recorded as client token in
+// the history (see `managermodel.RestoredFrom`).
+//
+// When the models are combined, the other model is written as is.
+func (mgr *ManagerImpl) Rollback(
+	ctx context.Context,
+	id persistencemodel.ID,
+	modelType persistencemodel.ModelType,
+	toVersion int64,
+) (res managermodel.RollbackResult, err error) {
+	res = managermodel.RollbackResult{ID: id.ToPersistenceID(modelType), RestoredFrom: toVersion}
+
+	ctx, obs := mgr.observe(ctx, "rollback")
+
+	defer func() {
+		obs.end(err)
+	}()
+
+	if modelType == 0 {
+		return res, persistencemodel.Error400("combined model type is not supported, specify Separation instead")
+	}
+
+	if toVersion <= 0 {
+		return res, persistencemodel.Error400("version to rollback to is required")
+	}
+
+	te, ok := mgr.ResolveType("", id)
+
+	if !ok {
+		return res, fmt.Errorf("could not resolve model for id: %s", id)
+	}
+
+	pid := id.ToPersistenceID(modelType)
+
+	if mgr.separation == persistencemodel.CombinedModels {
+		pid.ModelType = 0
+	}
+
+	current, err := mgr.readRollback(ctx, pid, te, 0)
+
+	if err != nil {
+		return res, err
+	}
+
+	restored, err := mgr.readRollback(ctx, pid, te, toVersion)
+
+	if err != nil {
+		return res, err
+	}
+
+	if len(mgr.interceptors) > 0 {
+		call := &managermodel.InterceptCall{
+			Kind:     managermodel.InterceptRollback,
+			ID:       id,
+			Type:     te,
+			Reported: current[persistencemodel.ModelTypeReported].Model,
+			Desired:  current[persistencemodel.ModelTypeDesired].Model,
+			Rollback: &managermodel.RollbackOperation{ID: id, ModelType: modelType, ToVersion: toVersion},
+		}
+
+		defer func() {
+			call.RollbackResult = &res
+			call.RollbackError = err
+			mgr.interceptAfter(ctx, call)
+		}()
+
+		if err := mgr.interceptBefore(ctx, call); err != nil {
+			return res, err
+		}
+	}
+
+	from, ok := current[modelType]
+
+	if !ok {
+		return res, persistencemodel.Error404(fmt.Sprintf("model %s not found", res.ID))
+	}
+
+	to, ok := restored[modelType]
+
+	if !ok {
+		return res, persistencemodel.Error404(fmt.Sprintf("model %s version %d not found", res.ID, toVersion))
+	}
+
+	res.Model = to.Model
+	res.Version, res.TimeStamp = from.Version, from.TimeStamp
+
+	if from.Version == toVersion {
+		return res, nil
+	}
+
+	changes := &desiredChangeCollector{}
+	ml := mgr.createMergeLoggers(modelType == persistencemodel.ModelTypeReported, nil)
+
+	if err := merge.Diff(ctx, from.Model, to.Model, merge.MergeOptions{
+		Loggers: append(merge.MergeLoggers{changes}, ml...),
+		Stats:   &res.Stats,
+	}); err != nil {
+		return res, err
+	}
+
+	res.MergeLoggers = ml
+
+	if dl, _ := FindMergeDirtyLogger(ml); !dl.Dirty {
+		return res, nil
+	}
+
+	if mgr.checks() {
+		reported, desired := any(nil), to.Model
+
+		if modelType == persistencemodel.ModelTypeReported {
+			reported, desired = to.Model, nil
+		}
+
+		if err := mgr.checkModels(ctx, id, reported, desired, false); err != nil {
+			return res, err
+		}
+	}
+
+	clientID := managermodel.RollbackClientID(toVersion)
+	writes := []persistencemodel.WriteOperation{{
+		ID:            id.ToPersistenceID(modelType),
+		Model:         to.Model,
+		ClientID:      clientID,
+		Version:       from.Version,
+		SchemaVersion: te.Schema.Version,
+		Config:        persistencemodel.WriteOperationConfig{Separation: mgr.separation},
+	}}
+
+	// Combined storage writes both models
+	for mt, other := range current {
+		if mt != modelType && mgr.separation == persistencemodel.CombinedModels {
+			writes = append(writes, persistencemodel.WriteOperation{
+				ID:            id.ToPersistenceID(mt),
+				Model:         other.Model,
+				ClientID:      clientID,
+				Version:       other.Version,
+				SchemaVersion: te.Schema.Version,
+				Config:        persistencemodel.WriteOperationConfig{Separation: mgr.separation},
+			})
+		}
+	}
+
+	writeResults := mgr.persistence.Write(ctx, persistencemodel.WriteOptions{}, writes...)
+
+	for _, wr := range writeResults {
+		if wr.Error != nil {
+			return res, wr.Error
+		}
+
+		if wr.ID.ModelType == modelType {
+			res.Version, res.TimeStamp, res.Processed = wr.Version, wr.TimeStamp, true
+		}
+	}
+
+	if mgr.watches.active() {
+		kind := managermodel.InterceptDesire
+
+		if modelType == persistencemodel.ModelTypeReported {
+			kind = managermodel.InterceptReport
+		}
+
+		mgr.watches.publish(managermodel.WatchEvent{
+			Kind:         kind,
+			ID:           res.ID,
+			ClientID:     clientID,
+			Version:      res.Version,
+			TimeStamp:    res.TimeStamp,
+			Model:        res.Model,
+			MergeLoggers: ml,
+		})
+	}
+
+	if mgr.desiredStatus && modelType == persistencemodel.ModelTypeDesired {
+		err = mgr.desireUpdateStatus(ctx, &managermodel.DesireOperation{ID: id, ClientID: clientID}, changes)
+	}
+
+	return res, err
+}
+
+// readRollback reads the _version_ of the model(s) in _pid_. If _version_ is _zero_, the current is read.
+func (mgr *ManagerImpl) readRollback(
+	ctx context.Context,
+	pid persistencemodel.PersistenceID,
+	te model.TypeEntry,
+	version int64,
+) (map[persistencemodel.ModelType]persistencemodel.ReadResult, error) {
+	models := map[persistencemodel.ModelType]persistencemodel.ReadResult{}
+
+	for _, rr := range mgr.persistence.Read(ctx, persistencemodel.ReadOptions{}, persistencemodel.ReadOperation{
+		ID:      pid,
+		Model:   te.Model,
+		Version: version,
+		Schema:  te.Schema,
+	}) {
+		if rr.Error != nil {
+			return nil, rr.Error
+		}
+
+		models[rr.ID.ModelType] = rr
+	}
+
+	return models, nil
+}
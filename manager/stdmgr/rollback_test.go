@@ -0,0 +1,91 @@
+package stdmgr_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/loggers/changelogger"
+	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+	"github.com/mariotoffia/godeviceshadow/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollbackDesired(t *testing.T) {
+	ctx := context.Background()
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New(mempersistence.PersistenceOpts{
+			History: persistencemodel.Retention{MaxVersions: 10},
+		})).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithDesiredMergeLoggers(changelogger.New()).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	id := persistencemodel.ID{ID: "device123", Name: "homeHub"}
+
+	for _, sp := range []float64{20.0, 21.0, 22.0} {
+		res := mgr.Desire(ctx, managermodel.DesireOperation{
+			ID:    id,
+			Model: TestModel{Sensors: map[string]Sensor{"sp": {Value: sp, TimeStamp: time.Now().UTC()}}},
+		})
+
+		require.Len(t, res, 1)
+		require.NoError(t, res[0].Error)
+	}
+
+	watch := mgr.Watch(ctx, persistencemodel.ID{}, managermodel.WatchFilter{})
+
+	res, err := mgr.Rollback(ctx, id, persistencemodel.ModelTypeDesired, 1)
+	require.NoError(t, err)
+	assert.True(t, res.Processed)
+	assert.Equal(t, int64(4), res.Version)
+	assert.Equal(t, int64(1), res.RestoredFrom)
+	assert.Equal(t, 20.0, res.Model.(TestModel).Sensors["sp"].Value)
+	assert.Equal(t, 1, res.Stats.Updated)
+
+	chl := changelogger.Find(res.MergeLoggers)
+	require.NotNil(t, chl)
+
+	sns, err := chl.ManagedFromPath(`Sensors\.sp`, model.MergeOperationUpdate)
+	require.NoError(t, err)
+
+	sensors := sns.All()
+	require.Len(t, sensors, 1)
+	assert.Equal(t, 22.0, sensors[0].OldValue.GetValue())
+	assert.Equal(t, 20.0, sensors[0].NewValue.GetValue())
+
+	event := <-watch
+	assert.Equal(t, managermodel.InterceptDesire, event.Kind)
+	assert.Equal(t, int64(4), event.Version)
+
+	history, err := mgr.History(ctx, id.ToPersistenceID(persistencemodel.ModelTypeDesired))
+	require.NoError(t, err)
+	require.Len(t, history, 4)
+
+	from, ok := managermodel.RestoredFrom(history[0].ClientToken)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), from)
+
+	// Already at the version
+	res, err = mgr.Rollback(ctx, id, persistencemodel.ModelTypeDesired, 4)
+	require.NoError(t, err)
+	assert.False(t, res.Processed)
+	assert.Equal(t, int64(4), res.Version)
+
+	_, err = mgr.Rollback(ctx, id, persistencemodel.ModelTypeDesired, 42)
+	require.Error(t, err)
+}
@@ -0,0 +1,328 @@
+package stdmgr
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/copyutils"
+)
+
+// ListSchedules implements the `managermodel.Scheduler` interface.
+func (mgr *ManagerImpl) ListSchedules(ctx context.Context, shadows ...persistencemodel.ID) ([]managermodel.Schedule, error) {
+	var ids []string
+
+	opt := persistencemodel.ListOptions{ID: managermodel.SchedulesID}
+
+	for {
+		res, err := mgr.persistence.List(ctx, opt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range res.Items {
+			if id, ok := strings.CutSuffix(item.ID.Name, managermodel.ScheduleNameSuffix); ok && item.ID.ID == managermodel.SchedulesID {
+				ids = append(ids, id)
+			}
+		}
+
+		if res.Token == "" {
+			break
+		}
+
+		opt.Token = res.Token
+	}
+
+	slices.Sort(ids)
+
+	schedules := make([]managermodel.Schedule, 0, len(ids))
+
+	for _, id := range slices.Compact(ids) {
+		schedule, _, err := mgr.readSchedule(ctx, id)
+
+		if err != nil {
+			if isNotFound(err) {
+				continue // applied or cancelled in between
+			}
+
+			return nil, err
+		}
+
+		if len(shadows) == 0 || slices.Contains(shadows, schedule.Shadow) {
+			schedules = append(schedules, schedule)
+		}
+	}
+
+	slices.SortStableFunc(schedules, func(a, b managermodel.Schedule) int {
+		return cmp.Compare(a.ActivateAt, b.ActivateAt)
+	})
+
+	return schedules, nil
+}
+
+// ReadSchedule implements the `managermodel.Scheduler` interface.
+func (mgr *ManagerImpl) ReadSchedule(ctx context.Context, scheduleID string) (managermodel.Schedule, error) {
+	schedule, _, err := mgr.readSchedule(ctx, scheduleID)
+
+	return schedule, err
+}
+
+// CancelSchedule implements the `managermodel.Scheduler` interface.
+func (mgr *ManagerImpl) CancelSchedule(ctx context.Context, scheduleID string) error {
+	if _, _, err := mgr.readSchedule(ctx, scheduleID); err != nil {
+		return err
+	}
+
+	return mgr.deleteSchedule(ctx, scheduleID, 0)
+}
+
+// ApplySchedules implements the `managermodel.Scheduler` interface. Each schedule is claimed, by writing or deleting
+// it with its read version, before it is desired. Hence, a schedule that was updated in between, e.g. by another
+// scheduler, is left as is and not desired twice.
+func (mgr *ManagerImpl) ApplySchedules(ctx context.Context, now time.Time) ([]managermodel.Schedule, error) {
+	schedules, err := mgr.ListSchedules(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []managermodel.Schedule
+
+	for _, s := range schedules {
+		if !s.Due(now) {
+			break // ordered by activation
+		}
+
+		// Re-read to get the version
+		schedule, version, err := mgr.readSchedule(ctx, s.ID)
+
+		if err != nil {
+			if isNotFound(err) {
+				continue
+			}
+
+			return applied, err
+		}
+
+		op := schedule.Operation()
+
+		schedule.Runs++
+		schedule.LastRunAt = now.UTC().UnixNano()
+		schedule.LastError = ""
+
+		if schedule.Recurrence <= 0 {
+			err = mgr.deleteSchedule(ctx, schedule.ID, version)
+		} else {
+			schedule.Next(now)
+			err = mgr.writeSection(ctx, schedule.ClientID, managermodel.ScheduleID(schedule.ID), schedule, version)
+		}
+
+		if err != nil {
+			if isNotFound(err) || isConflict(err) {
+				continue // claimed by another scheduler
+			}
+
+			return applied, err
+		}
+
+		if res := mgr.Desire(ctx, op); res[0].Error != nil {
+			schedule.LastError = res[0].Error.Error()
+
+			// Best effort, the run is already claimed and a removed schedule has nowhere to keep the error
+			if current, version, err := mgr.readSchedule(ctx, schedule.ID); err == nil && current.Runs == schedule.Runs {
+				current.LastError = schedule.LastError
+				_ = mgr.writeSection(ctx, current.ClientID, managermodel.ScheduleID(current.ID), current, version)
+			}
+		}
+
+		applied = append(applied, schedule)
+	}
+
+	return applied, nil
+}
+
+// desireSchedule persists a `managermodel.Schedule` for each operation with a `ActivateAt` that has no result yet.
+// Those get a result with the `ScheduleID`. The interceptors, validation and limits are applied before the schedule
+// is persisted, as when the operation is desired right away.
+func (mgr *ManagerImpl) desireSchedule(
+	ctx context.Context,
+	operations []managermodel.DesireOperation,
+	res []*managermodel.DesireOperationResult,
+) {
+	now := time.Now().UTC().UnixNano()
+
+	for i := range operations {
+		op := &operations[i]
+
+		if res[i] != nil {
+			continue
+		}
+
+		if op.ActivateAt.IsZero() {
+			if op.Recurrence != 0 {
+				res[i] = &managermodel.DesireOperationResult{ID: op.ID, Error: persistencemodel.Error400("recurrence requires activate at")}
+			}
+
+			continue
+		}
+
+		te, ok := mgr.ResolveType(op.ModelType, op.ID)
+
+		switch {
+		case !ok:
+			res[i] = &managermodel.DesireOperationResult{
+				ID:    op.ID,
+				Error: persistencemodel.Error400(fmt.Sprintf("could not resolve model for id: %s", op.ID)),
+			}
+
+			continue
+		case op.Model == nil:
+			res[i] = &managermodel.DesireOperationResult{ID: op.ID, Error: persistencemodel.Error400("model is required")}
+			continue
+		case op.Recurrence != 0 && op.Recurrence < managermodel.MinRecurrence:
+			res[i] = &managermodel.DesireOperationResult{
+				ID:    op.ID,
+				Error: persistencemodel.Error400(fmt.Sprintf("recurrence must be at least %s", managermodel.MinRecurrence)),
+			}
+
+			continue
+		case len(op.Preconditions) > 0:
+			// The stored model is only known when applied
+			res[i] = &managermodel.DesireOperationResult{
+				ID:    op.ID,
+				Error: persistencemodel.Error400("preconditions are not supported on a scheduled operation"),
+			}
+
+			continue
+		case op.ClientToken != "":
+			// Each run gets its own client token (see `managermodel.Schedule.Operation`)
+			res[i] = &managermodel.DesireOperationResult{
+				ID:    op.ID,
+				Error: persistencemodel.Error400("client token is not supported on a scheduled operation"),
+			}
+
+			continue
+		}
+
+		if len(mgr.interceptors) > 0 {
+			call := &managermodel.InterceptCall{
+				Kind:   managermodel.InterceptDesire,
+				ID:     op.ID,
+				Type:   te,
+				Desire: op,
+			}
+
+			if err := mgr.interceptBefore(ctx, call); err != nil {
+				res[i] = &managermodel.DesireOperationResult{ID: op.ID, Error: err}
+				continue
+			}
+		}
+
+		if err := checkReservedID(op.ID); err != nil {
+			res[i] = &managermodel.DesireOperationResult{ID: op.ID, Error: err}
+			continue
+		}
+
+		if mgr.checks() {
+			// The named shadow limit is checked when applied, the shadow may be created in between
+			if err := mgr.checkModels(ctx, op.ID, nil, op.Model, false /*create*/); err != nil {
+				res[i] = &managermodel.DesireOperationResult{ID: op.ID, Error: err}
+				continue
+			}
+		}
+
+		schedule := managermodel.Schedule{
+			ID:         scheduleID(),
+			Shadow:     op.ID,
+			ClientID:   op.ClientID,
+			ModelType:  te.Name,
+			Model:      copyutils.DeepCopy(op.Model),
+			MergeMode:  op.MergeMode,
+			Expiry:     op.Expiry,
+			ActivateAt: op.ActivateAt.UTC().UnixNano(),
+			Recurrence: op.Recurrence,
+			CreatedAt:  now,
+		}
+
+		res[i] = &managermodel.DesireOperationResult{ID: op.ID, ScheduleID: schedule.ID}
+
+		if err := mgr.writeSection(ctx, op.ClientID, managermodel.ScheduleID(schedule.ID), schedule, 0); err != nil {
+			res[i].Error = err
+		}
+	}
+}
+
+// readSchedule reads the schedule and the version of it. The model is restored into the `Schedule.ModelType` when
+// the persistence did serialize it.
+func (mgr *ManagerImpl) readSchedule(ctx context.Context, scheduleID string) (managermodel.Schedule, int64, error) {
+	schedule, version, err := readSection[managermodel.Schedule](ctx, mgr, managermodel.ScheduleID(scheduleID))
+
+	if err != nil {
+		return managermodel.Schedule{}, 0, err
+	}
+
+	if version == 0 {
+		return managermodel.Schedule{}, 0, persistencemodel.Error404(fmt.Sprintf("schedule %s not found", scheduleID))
+	}
+
+	te, ok := mgr.ResolveType(schedule.ModelType, schedule.Shadow)
+
+	if !ok || schedule.Model == nil || reflect.TypeOf(schedule.Model) == te.Model {
+		return schedule, version, nil
+	}
+
+	data, err := json.Marshal(schedule.Model)
+
+	if err != nil {
+		return managermodel.Schedule{}, 0, persistencemodel.Error500(fmt.Sprintf("failed to restore schedule model: %s", err))
+	}
+
+	v := reflect.New(te.Model)
+
+	if err := json.Unmarshal(data, v.Interface()); err != nil {
+		return managermodel.Schedule{}, 0, persistencemodel.Error500(fmt.Sprintf("failed to restore schedule model: %s", err))
+	}
+
+	schedule.Model = v.Elem().Interface()
+
+	return schedule, version, nil
+}
+
+// deleteSchedule deletes the schedule. If _version_ is greater than zero, it fails with 409 when the schedule was
+// updated in between.
+func (mgr *ManagerImpl) deleteSchedule(ctx context.Context, scheduleID string, version int64) error {
+	res := mgr.persistence.Delete(ctx, persistencemodel.WriteOptions{
+		Config: persistencemodel.WriteConfig{Separation: persistencemodel.SeparateModels},
+	}, persistencemodel.WriteOperation{
+		ID:      managermodel.ScheduleID(scheduleID),
+		Version: version,
+		Config:  persistencemodel.WriteOperationConfig{Separation: persistencemodel.SeparateModels},
+	})
+
+	if len(res) > 0 {
+		return res[0].Error
+	}
+
+	return nil
+}
+
+// scheduleID generates a random schedule id.
+func scheduleID() string {
+	data := make([]byte, 8)
+
+	if _, err := rand.Read(data); err != nil {
+		panic(fmt.Sprintf("failed to read crypto random: %v", err))
+	}
+
+	return hex.EncodeToString(data)
+}
@@ -0,0 +1,79 @@
+package stdmgr
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// sectionIDs returns the persistence ids of all sections of the shadow _id_.
+func sectionIDs(id persistencemodel.ID) []persistencemodel.PersistenceID {
+	return []persistencemodel.PersistenceID{
+		managermodel.RejectionsID(id),
+		managermodel.DesiredStatusID(id),
+		managermodel.ClientTokensID(id),
+		managermodel.AlarmsID(id),
+	}
+}
+
+// readSection reads a shadow section, such as the rejections, that is persisted as a separate desired model.
+// If not found, the zero value and version zero is returned.
+func readSection[T any](ctx context.Context, mgr *ManagerImpl, id persistencemodel.PersistenceID) (T, int64, error) {
+	var section T
+
+	res := mgr.persistence.Read(ctx, persistencemodel.ReadOptions{}, persistencemodel.ReadOperation{
+		ID:    id,
+		Model: reflect.TypeOf(section),
+	})
+
+	if len(res) == 0 {
+		return section, 0, nil
+	}
+
+	if res[0].Error != nil {
+		var pe persistencemodel.PersistenceError
+
+		if errors.As(res[0].Error, &pe) && pe.Code == 404 {
+			return section, 0, nil
+		}
+
+		return section, 0, res[0].Error
+	}
+
+	switch m := res[0].Model.(type) {
+	case T:
+		section = m
+	case *T:
+		section = *m
+	}
+
+	return section, res[0].Version, nil
+}
+
+// writeSection upserts a shadow section. The _version_ is the one returned by `readSection`.
+func (mgr *ManagerImpl) writeSection(
+	ctx context.Context,
+	clientID string,
+	id persistencemodel.PersistenceID,
+	section any,
+	version int64,
+) error {
+	res := mgr.persistence.Write(ctx, persistencemodel.WriteOptions{
+		Config: persistencemodel.WriteConfig{Separation: persistencemodel.SeparateModels},
+	}, persistencemodel.WriteOperation{
+		ClientID: clientID,
+		ID:       id,
+		Model:    section,
+		Version:  version,
+		Config:   persistencemodel.WriteOperationConfig{Separation: persistencemodel.SeparateModels},
+	})
+
+	if len(res) > 0 {
+		return res[0].Error
+	}
+
+	return nil
+}
@@ -0,0 +1,257 @@
+package stdmgr
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// desireCollector collects all acknowledged and rejected desired values in a `Report` operation.
+type desireCollector struct {
+	clientID     string
+	acknowledged map[string]any
+	rejected     []managermodel.Rejection
+}
+
+func (dc *desireCollector) Acknowledge(ctx context.Context, path string, value model.ValueAndTimestamp) {
+	if dc.acknowledged == nil {
+		dc.acknowledged = map[string]any{}
+	}
+
+	dc.acknowledged[path] = value.GetValue()
+}
+
+func (dc *desireCollector) Reject(ctx context.Context, path string, value model.ValueAndTimestamp, rejection model.RejectedDesire) {
+	dc.rejected = append(dc.rejected, managermodel.Rejection{
+		RejectedDesire: rejection,
+		Value:          value.GetValue(),
+		ClientID:       dc.clientID,
+		TimeStamp:      time.Now().UTC().UnixNano(),
+	})
+}
+
+// desiredChangeCollector collects the added, updated and removed desired values in a `Desire` operation.
+type desiredChangeCollector struct {
+	changed map[string]any
+	removed []string
+}
+
+func (dcc *desiredChangeCollector) Managed(
+	ctx context.Context,
+	path string,
+	operation model.MergeOperation,
+	oldValue, newValue model.ValueAndTimestamp,
+	oldTimeStamp, newTimeStamp time.Time,
+) {
+	switch operation {
+	case model.MergeOperationAdd, model.MergeOperationUpdate:
+		if dcc.changed == nil {
+			dcc.changed = map[string]any{}
+		}
+
+		dcc.changed[path] = newValue.GetValue()
+	case model.MergeOperationRemove:
+		dcc.removed = append(dcc.removed, path)
+	}
+}
+
+func (dcc *desiredChangeCollector) Plain(ctx context.Context, path string, operation model.MergeOperation, oldValue, newValue any) {
+}
+
+// ReadDesiredStatus implements the `managermodel.DesiredStatusReceiver` interface. Entries that has passed their
+// expiry are returned as expired.
+func (mgr *ManagerImpl) ReadDesiredStatus(ctx context.Context, id persistencemodel.ID) (managermodel.DesiredStatuses, error) {
+	statuses, _, err := mgr.readDesiredStatus(ctx, id)
+
+	if err != nil {
+		return statuses, err
+	}
+
+	statuses.Expire(time.Now().UTC().UnixNano())
+
+	return statuses, nil
+}
+
+// ReadDesiredStatuses implements the `managermodel.DesiredStatusReceiver` interface. Entries that has passed their
+// expiry are returned as expired.
+func (mgr *ManagerImpl) ReadDesiredStatuses(
+	ctx context.Context,
+	ids ...persistencemodel.ID,
+) (map[persistencemodel.ID]managermodel.DesiredStatuses, error) {
+	now := time.Now().UTC().UnixNano()
+	ops := make([]persistencemodel.ReadOperation, len(ids))
+	all := make(map[persistencemodel.ID]managermodel.DesiredStatuses, len(ids))
+	shadows := make(map[string]persistencemodel.ID, len(ids))
+
+	for i, id := range ids {
+		ops[i] = persistencemodel.ReadOperation{
+			ID:    managermodel.DesiredStatusID(id),
+			Model: reflect.TypeOf(managermodel.DesiredStatuses{}),
+		}
+
+		shadows[ops[i].ID.StringWithoutModelType()] = id
+		all[id] = managermodel.DesiredStatuses{Entries: map[string]managermodel.DesiredStatus{}}
+	}
+
+	if len(ops) == 0 {
+		return all, nil
+	}
+
+	for _, rr := range mgr.persistence.Read(ctx, persistencemodel.ReadOptions{}, ops...) {
+		if rr.Error != nil {
+			if isNotFound(rr.Error) {
+				continue
+			}
+
+			return nil, rr.Error
+		}
+
+		var section managermodel.DesiredStatuses
+
+		switch m := rr.Model.(type) {
+		case managermodel.DesiredStatuses:
+			section = m
+		case *managermodel.DesiredStatuses:
+			section = *m
+		}
+
+		id, ok := shadows[rr.ID.StringWithoutModelType()]
+
+		if !ok {
+			continue
+		}
+
+		// Copy since the persistence may return the stored instance
+		statuses := all[id]
+
+		for path, s := range section.Entries {
+			s.Transitions = append([]managermodel.DesiredTransition(nil), s.Transitions...)
+			statuses.Entries[path] = s
+		}
+
+		statuses.Expire(now)
+	}
+
+	return all, nil
+}
+
+func (mgr *ManagerImpl) readDesiredStatus(ctx context.Context, id persistencemodel.ID) (managermodel.DesiredStatuses, int64, error) {
+	section, version, err := readSection[managermodel.DesiredStatuses](ctx, mgr, managermodel.DesiredStatusID(id))
+
+	if err != nil {
+		return managermodel.DesiredStatuses{}, 0, err
+	}
+
+	// Copy since the persistence may return the stored instance
+	statuses := managermodel.DesiredStatuses{Entries: make(map[string]managermodel.DesiredStatus, len(section.Entries))}
+
+	for path, s := range section.Entries {
+		s.Transitions = append([]managermodel.DesiredTransition(nil), s.Transitions...)
+		statuses.Entries[path] = s
+	}
+
+	return statuses, version, nil
+}
+
+// updateDesiredStatus reads, updates (using _update_) and writes the desired status section. If _update_ returns
+// `false` and nothing has expired, nothing is written.
+func (mgr *ManagerImpl) updateDesiredStatus(
+	ctx context.Context,
+	clientID string,
+	id persistencemodel.ID,
+	update func(statuses managermodel.DesiredStatuses, now int64) bool,
+) (managermodel.DesiredStatuses, error) {
+	statuses, version, err := mgr.readDesiredStatus(ctx, id)
+
+	if err != nil {
+		return statuses, err
+	}
+
+	now := time.Now().UTC().UnixNano()
+	expired := statuses.Expire(now)
+
+	if !update(statuses, now) && !expired {
+		return statuses, nil
+	}
+
+	return statuses, mgr.writeSection(ctx, clientID, managermodel.DesiredStatusID(id), statuses, version)
+}
+
+// desireUpdateStatus sets all added and updated values as pending and the removed as expired.
+func (mgr *ManagerImpl) desireUpdateStatus(ctx context.Context, op *managermodel.DesireOperation, dcc *desiredChangeCollector) error {
+	_, err := mgr.updateDesiredStatus(ctx, op.ClientID, op.ID, func(statuses managermodel.DesiredStatuses, now int64) bool {
+		for path, value := range dcc.changed {
+			s := managermodel.DesiredStatus{Value: value, ClientID: op.ClientID}
+
+			if op.Expiry > 0 {
+				s.ExpiresAt = now + op.Expiry.Nanoseconds()
+			}
+
+			s.Transition(managermodel.DesiredStatePending, now)
+			statuses.Entries[path] = s
+		}
+
+		for _, path := range dcc.removed {
+			if s, ok := statuses.Entries[path]; ok && !s.State.Final() {
+				s.ClientID = op.ClientID
+				s.Transition(managermodel.DesiredStateExpired, now)
+				statuses.Entries[path] = s
+			}
+		}
+
+		return len(dcc.changed) > 0 || len(dcc.removed) > 0
+	})
+
+	return err
+}
+
+// reportUpdateStatus transitions all acknowledged and rejected values.
+func (mgr *ManagerImpl) reportUpdateStatus(ctx context.Context, op *managermodel.ReportOperation, dc *desireCollector) error {
+	_, err := mgr.updateDesiredStatus(ctx, op.ClientID, op.ID, func(statuses managermodel.DesiredStatuses, now int64) bool {
+		for path, value := range dc.acknowledged {
+			s := statuses.Entries[path]
+
+			s.Value = value
+			s.ClientID = op.ClientID
+			s.Transition(managermodel.DesiredStateAcknowledged, now)
+			statuses.Entries[path] = s
+		}
+
+		for _, rj := range dc.rejected {
+			s := statuses.Entries[rj.Path]
+
+			s.ClientID = op.ClientID
+			s.Rejection = &rj.RejectedDesire
+			s.Transition(managermodel.DesiredStateRejected, now)
+			statuses.Entries[rj.Path] = s
+		}
+
+		return len(dc.acknowledged) > 0 || len(dc.rejected) > 0
+	})
+
+	return err
+}
+
+// readMarkDelivered transitions all pending values into delivered and returns the resulting statuses.
+func (mgr *ManagerImpl) readMarkDelivered(ctx context.Context, id persistencemodel.ID) (map[string]managermodel.DesiredStatus, error) {
+	statuses, err := mgr.updateDesiredStatus(ctx, "" /*clientID*/, id, func(statuses managermodel.DesiredStatuses, now int64) bool {
+		var delivered bool
+
+		for path, s := range statuses.Entries {
+			if s.State == managermodel.DesiredStatePending {
+				s.Transition(managermodel.DesiredStateDelivered, now)
+				statuses.Entries[path] = s
+				delivered = true
+			}
+		}
+
+		return delivered
+	})
+
+	return maps.Clone(statuses.Entries), err
+}
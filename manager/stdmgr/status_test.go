@@ -0,0 +1,264 @@
+package stdmgr_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+	"github.com/mariotoffia/godeviceshadow/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStatusManager() *stdmgr.ManagerImpl {
+	return stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.CombinedModels).
+		WithDesiredStatusTracking().
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+}
+
+// desiredReadResult returns the desired result since combined models returns both reported and desired.
+func desiredReadResult(t *testing.T, results []managermodel.ReadOperationResult) managermodel.ReadOperationResult {
+	for _, rr := range results {
+		if rr.ID.ModelType == persistencemodel.ModelTypeDesired {
+			require.NoError(t, rr.Error)
+			return rr
+		}
+	}
+
+	require.Fail(t, "no desired model in read result")
+
+	return managermodel.ReadOperationResult{}
+}
+
+func TestDesiredStatusLifecycle(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	mgr := newStatusManager()
+	id := persistencemodel.ID{ID: "device123", Name: "homeHub"}
+
+	resDesire := mgr.Desire(ctx, managermodel.DesireOperation{
+		ClientID: "operator",
+		Model: TestModel{
+			Sensors: map[string]Sensor{
+				"temp":  {Value: 23.4, TimeStamp: now},
+				"light": {Value: "on", TimeStamp: now},
+				"fan":   {Value: "high", TimeStamp: now},
+			},
+		},
+		ID: id,
+	})
+
+	require.Len(t, resDesire, 1)
+	require.NoError(t, resDesire[0].Error)
+
+	statuses, err := mgr.ReadDesiredStatus(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, statuses.Entries, 3)
+	assert.Len(t, statuses.WithState(managermodel.DesiredStatePending), 3)
+	assert.Equal(t, "operator", statuses.Entries["Sensors.temp"].ClientID)
+
+	// Device fetches the desired model
+	rr := mgr.Read(ctx, managermodel.ReadOperation{
+		ID:            id.ToPersistenceID(persistencemodel.ModelTypeDesired),
+		MarkDelivered: true,
+	})
+
+	desired := desiredReadResult(t, rr)
+	require.Len(t, desired.DesiredStatus, 3)
+	assert.Equal(t, managermodel.DesiredStateDelivered, desired.DesiredStatus["Sensors.temp"].State)
+
+	// Device acknowledges temp and rejects light
+	resReport := mgr.Report(ctx, managermodel.ReportOperation{
+		ClientID: "device",
+		Model: TestModel{
+			Sensors: map[string]Sensor{"temp": {Value: 23.4, TimeStamp: now}},
+		},
+		ID:              id,
+		RejectedDesires: []model.RejectedDesire{{Path: "Sensors.light", Code: "not-supported"}},
+	})
+
+	require.Len(t, resReport, 1)
+	require.NoError(t, resReport[0].Error)
+
+	statuses, err = mgr.ReadDesiredStatus(ctx, id)
+	require.NoError(t, err)
+
+	temp := statuses.Entries["Sensors.temp"]
+	assert.Equal(t, managermodel.DesiredStateAcknowledged, temp.State)
+	assert.Equal(t, "device", temp.ClientID)
+	require.Len(t, temp.Transitions, 3)
+
+	for i, state := range []managermodel.DesiredState{
+		managermodel.DesiredStatePending, managermodel.DesiredStateDelivered, managermodel.DesiredStateAcknowledged,
+	} {
+		assert.Equal(t, state, temp.Transitions[i].State)
+		assert.Positive(t, temp.Transitions[i].TimeStamp)
+	}
+
+	pendingAt, ok := temp.TimeStamp(managermodel.DesiredStatePending)
+	require.True(t, ok)
+	ackAt, ok := temp.TimeStamp(managermodel.DesiredStateAcknowledged)
+	require.True(t, ok)
+	assert.LessOrEqual(t, pendingAt, ackAt)
+
+	light := statuses.Entries["Sensors.light"]
+	assert.Equal(t, managermodel.DesiredStateRejected, light.State)
+	require.NotNil(t, light.Rejection)
+	assert.Equal(t, "not-supported", light.Rejection.Code)
+
+	assert.Equal(t, managermodel.DesiredStateDelivered, statuses.Entries["Sensors.fan"].State)
+
+	// Removing the fan from desired before acknowledged -> expired
+	resDesire = mgr.Desire(ctx, managermodel.DesireOperation{
+		Model:     TestModel{Sensors: map[string]Sensor{}},
+		MergeMode: merge.ClientIsMaster,
+		ID:        id,
+	})
+
+	require.Len(t, resDesire, 1)
+	require.NoError(t, resDesire[0].Error)
+
+	statuses, err = mgr.ReadDesiredStatus(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, managermodel.DesiredStateExpired, statuses.Entries["Sensors.fan"].State)
+	assert.Equal(t, managermodel.DesiredStateAcknowledged, statuses.Entries["Sensors.temp"].State)
+}
+
+func TestDesiredStatusNotDeliveredOnVersionRead(t *testing.T) {
+	ctx := context.Background()
+	mgr := newStatusManager()
+	id := persistencemodel.ID{ID: "device123", Name: "homeHub"}
+
+	resDesire := mgr.Desire(ctx, managermodel.DesireOperation{
+		ClientID: "operator",
+		Model:    TestModel{Sensors: map[string]Sensor{"temp": {Value: 23.4, TimeStamp: time.Now()}}},
+		ID:       id,
+	})
+
+	require.Len(t, resDesire, 1)
+	require.NoError(t, resDesire[0].Error)
+
+	// A specific version is a historical read, it is neither delivered nor carries the status
+	rr := mgr.Read(ctx, managermodel.ReadOperation{
+		ID:            id.ToPersistenceID(persistencemodel.ModelTypeDesired),
+		Version:       resDesire[0].Version,
+		MarkDelivered: true,
+	})
+
+	desired := desiredReadResult(t, rr)
+	assert.Empty(t, desired.DesiredStatus)
+
+	statuses, err := mgr.ReadDesiredStatus(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, managermodel.DesiredStatePending, statuses.Entries["Sensors.temp"].State)
+}
+func TestDesiredStatusExpiry(t *testing.T) {
+	ctx := context.Background()
+	mgr := newStatusManager()
+	id := persistencemodel.ID{ID: "device123", Name: "homeHub"}
+
+	resDesire := mgr.Desire(ctx, managermodel.DesireOperation{
+		Model:  TestModel{Sensors: map[string]Sensor{"temp": {Value: 23.4, TimeStamp: time.Now()}}},
+		ID:     id,
+		Expiry: time.Millisecond,
+	})
+
+	require.Len(t, resDesire, 1)
+	require.NoError(t, resDesire[0].Error)
+
+	time.Sleep(5 * time.Millisecond)
+
+	statuses, err := mgr.ReadDesiredStatus(ctx, id)
+	require.NoError(t, err)
+
+	temp := statuses.Entries["Sensors.temp"]
+	assert.Equal(t, managermodel.DesiredStateExpired, temp.State)
+
+	expiredAt, ok := temp.TimeStamp(managermodel.DesiredStateExpired)
+	require.True(t, ok)
+	assert.Equal(t, temp.ExpiresAt, expiredAt)
+}
+
+func TestDesiredStatusDisabled(t *testing.T) {
+	ctx := context.Background()
+
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	id := persistencemodel.ID{ID: "device123", Name: "homeHub"}
+
+	mgr.Desire(ctx, managermodel.DesireOperation{
+		Model: TestModel{Sensors: map[string]Sensor{"temp": {Value: 23.4, TimeStamp: time.Now()}}},
+		ID:    id,
+	})
+
+	statuses, err := mgr.ReadDesiredStatus(ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, statuses.Entries)
+
+	rr := mgr.Read(ctx, managermodel.ReadOperation{ID: id.ToPersistenceID(persistencemodel.ModelTypeDesired)})
+	assert.Nil(t, desiredReadResult(t, rr).DesiredStatus)
+}
+
+func TestDesiredStatusFailureKeepsResult(t *testing.T) {
+	ctx := context.Background()
+
+	mgr := stdmgr.New().
+		WithPersistence(&failingSections{Persistence: mempersistence.New()}).
+		WithSeparation(persistencemodel.CombinedModels).
+		WithDesiredStatusTracking().
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	id := persistencemodel.ID{ID: "device123", Name: "homeHub"}
+
+	res := mgr.Desire(ctx, managermodel.DesireOperation{
+		ID:    id,
+		Model: TestModel{Sensors: map[string]Sensor{"temp": {Value: 22.0, TimeStamp: time.Now().UTC()}}},
+	})
+
+	// The desired model is persisted, only the status failed
+	require.NoError(t, res[0].Error)
+	assert.True(t, res[0].Processed)
+	assert.Error(t, res[0].SectionError)
+
+	report := mgr.Report(ctx, managermodel.ReportOperation{
+		ID:    id,
+		Model: TestModel{Sensors: map[string]Sensor{"temp": {Value: 22.0, TimeStamp: time.Now().UTC()}}},
+	})
+
+	require.NoError(t, report[0].Error)
+	assert.True(t, report[0].DesiredProcessed, "acknowledged")
+	assert.Error(t, report[0].SectionError)
+}
@@ -1,9 +1,13 @@
 package stdmgr
 
 import (
+	"time"
+
 	"github.com/mariotoffia/godeviceshadow/model"
 	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/observemodel"
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/validate"
 )
 
 // ManagerImpl is a implementation of a "standard" manager that can be used to manage models.
@@ -27,6 +31,22 @@ type ManagerImpl struct {
 	reportedDesiredLoggers []model.CreatableDesiredLogger
 	// separation is the default separation.
 	separation persistencemodel.ModelSeparation
+	// desiredStatus is set when the lifecycle status of desired values shall be tracked.
+	desiredStatus bool
+	// validation is set when the models shall be validated before they are written.
+	validation bool
+	// limits are the size limits that are checked before the models are written.
+	limits validate.Limits
+	// tokenWindow is the number of client tokens kept per shadow. Zero disables idempotency.
+	tokenWindow int
+	// tokenMaxAge is the max age of the client tokens, zero keeps them until dropped by the window.
+	tokenMaxAge time.Duration
+	// observer receives the spans and metrics of the operations.
+	observer observemodel.Observer
+	// interceptors wraps the `Manager` functions.
+	interceptors []managermodel.Interceptor
+	// watches are the in process watchers of change events.
+	watches watches
 }
 
 type groupedPersistenceResult struct {
@@ -35,6 +55,15 @@ type groupedPersistenceResult struct {
 	desired       *persistencemodel.ReadResult
 	queueReported any
 	queueDesired  any
-	op            *managermodel.ReportOperation
-	dop           *managermodel.DesireOperation
+	// op is the last report operation on the shadow.
+	op *managermodel.ReportOperation
+	// schemaVersion is the current schema version of the model type, written together with the queued models.
+	schemaVersion int
+	// dop is the last successful desire operation on the shadow, it is set when `queueDesired` is set.
+	dop *managermodel.DesireOperation
+}
+
+// created returns `true` if the shadow does not yet exist in the persistence.
+func (grp *groupedPersistenceResult) created() bool {
+	return (grp.reported == nil || grp.reported.Version == 0) && (grp.desired == nil || grp.desired.Version == 0)
 }
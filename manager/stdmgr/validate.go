@@ -0,0 +1,166 @@
+package stdmgr
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
+	"github.com/mariotoffia/godeviceshadow/validate"
+)
+
+// checks returns `true` if the models shall be checked, using `checkModels`, before they are written.
+func (mgr *ManagerImpl) checks() bool {
+	return mgr.validation || mgr.limits.Enabled()
+}
+
+// checkModels validates, when enabled, and checks the limits of the _reported_ and the _desired_ model (if not
+// nil). When _create_, the write creates the shadow _id_ and hence `validate.Limits.MaxNamedShadows` is checked.
+func (mgr *ManagerImpl) checkModels(ctx context.Context, id persistencemodel.ID, reported, desired any, create bool) error {
+	if mgr.validation {
+		if err := validateModels(ctx, reported, desired); err != nil {
+			return err
+		}
+	}
+
+	if !mgr.limits.Enabled() {
+		return nil
+	}
+
+	for _, m := range []any{reported, desired} {
+		if m == nil {
+			continue
+		}
+
+		if err := validate.CheckLimits(ctx, m, mgr.limits, pathutils.StyleDotted); err != nil {
+			return err
+		}
+	}
+
+	if create {
+		return mgr.checkNamedShadows(ctx, id)
+	}
+
+	return nil
+}
+
+// reservedIDs are the shadow ids where the manager persists the schedules, jobs, layers and groups.
+var reservedIDs = []string{
+	managermodel.SchedulesID,
+	managermodel.JobsID,
+	managermodel.LayersID,
+	managermodel.GroupsID,
+}
+
+// reservedSuffixes are the name suffixes of the shadow sections and of the persisted schedules, jobs and groups.
+var reservedSuffixes = []string{
+	managermodel.DesiredStatusNameSuffix,
+	managermodel.RejectionsNameSuffix,
+	managermodel.ClientTokensNameSuffix,
+	managermodel.AlarmsNameSuffix,
+	managermodel.ScheduleNameSuffix,
+	managermodel.JobNameSuffix,
+	managermodel.GroupNameSuffix,
+}
+
+// checkReservedID returns a 400 error when the _id_ is reserved by the manager, e.g. `managermodel.SchedulesID` or a
+// name that ends with `managermodel.RejectionsNameSuffix`. Those must not be written as a shadow.
+func checkReservedID(id persistencemodel.ID) error {
+	if slices.Contains(reservedIDs, id.ID) {
+		return persistencemodel.Error400(fmt.Sprintf("shadow id %s is reserved", id.ID))
+	}
+
+	for _, suffix := range reservedSuffixes {
+		if strings.HasSuffix(id.Name, suffix) {
+			return persistencemodel.Error400(fmt.Sprintf("shadow name %s has the reserved suffix %s", id.Name, suffix))
+		}
+	}
+
+	return nil
+}
+
+// checkNamedShadows checks that yet another named shadow may be created under the `persistencemodel.ID.ID`.
+func (mgr *ManagerImpl) checkNamedShadows(ctx context.Context, id persistencemodel.ID) error {
+	if mgr.limits.MaxNamedShadows <= 0 {
+		return nil
+	}
+
+	names := map[string]bool{}
+	opt := managermodel.ListOptions{ID: id.ID}
+
+	for {
+		res, err := mgr.list(ctx, opt)
+
+		if err != nil {
+			return err
+		}
+
+		for _, item := range res.Items {
+			if item.ID.ID == id.ID && item.ID.Name != id.Name && !strings.Contains(item.ID.Name, ":") {
+				names[item.ID.Name] = true
+			}
+		}
+
+		if res.Token == "" {
+			break
+		}
+
+		opt.Token = res.Token
+	}
+
+	if len(names) >= mgr.limits.MaxNamedShadows {
+		return &validate.LimitError{
+			Path:  id.ID,
+			Limit: "MaxNamedShadows",
+			Value: len(names) + 1,
+			Max:   mgr.limits.MaxNamedShadows,
+		}
+	}
+
+	return nil
+}
+
+// validateModels validates the _reported_ and the _desired_ model (if not nil). The desired model is
+// validated as a partial model since it only holds the desired values.
+func validateModels(ctx context.Context, reported, desired any) error {
+	if reported != nil {
+		if err := validate.Validate(ctx, reported, validate.Options{}); err != nil {
+			return err
+		}
+	}
+
+	if desired != nil {
+		return validate.Validate(ctx, desired, validate.Options{Partial: true})
+	}
+
+	return nil
+}
+
+// reportCheck checks the queued models of each shadow. When invalid, nothing is written for the shadow and the
+// error is set on all operations that contributed to it.
+func (mgr *ManagerImpl) reportCheck(ctx context.Context, readResults []groupedPersistenceResult, batch *reportBatch) {
+	for i, rdr := range readResults {
+		if rdr.queueReported == nil && rdr.queueDesired == nil {
+			continue
+		}
+
+		err := mgr.checkModels(ctx, rdr.id, rdr.queueReported, rdr.queueDesired, rdr.created())
+
+		if err == nil {
+			continue
+		}
+
+		readResults[i].queueReported, readResults[i].queueDesired = nil, nil
+
+		for _, idx := range batch.shadows[rdr.id.String()] {
+			r, fold := batch.results[idx], batch.folds[idx]
+
+			if r != nil && r.Error == nil && (fold.queueReported || fold.queueDesired) {
+				r.Error = err
+			}
+		}
+	}
+}
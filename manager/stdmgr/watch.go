@@ -0,0 +1,152 @@
+package stdmgr
+
+import (
+	"context"
+	"path"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// Watch implements the `managermodel.Watcher` interface.
+func (mgr *ManagerImpl) Watch(ctx context.Context, id persistencemodel.ID, filter managermodel.WatchFilter) <-chan managermodel.WatchEvent {
+	size := filter.BufferSize
+
+	if size <= 0 {
+		size = managermodel.DefaultWatchBufferSize
+	}
+
+	w := &watcher{ctx: ctx, id: id, filter: filter, ch: make(chan managermodel.WatchEvent, size)}
+
+	mgr.watches.add(w)
+
+	go func() {
+		<-ctx.Done()
+		mgr.watches.remove(w)
+	}()
+
+	return w.ch
+}
+
+type watcher struct {
+	ctx     context.Context
+	id      persistencemodel.ID
+	filter  managermodel.WatchFilter
+	ch      chan managermodel.WatchEvent
+	dropped atomic.Int64
+
+	// mu guards the channel against being closed while sending.
+	mu     sync.RWMutex
+	closed bool
+}
+
+func (w *watcher) match(id persistencemodel.PersistenceID) bool {
+	if w.filter.ModelType != 0 && w.filter.ModelType != id.ModelType {
+		return false
+	}
+
+	return matchWildcard(w.id.ID, id.ID) && matchWildcard(w.id.Name, id.Name)
+}
+
+func (w *watcher) send(event managermodel.WatchEvent) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return
+	}
+
+	event.Dropped = w.dropped.Swap(0)
+
+	if w.filter.Policy == managermodel.WatchBlock {
+		select {
+		case w.ch <- event:
+		case <-w.ctx.Done():
+		}
+
+		return
+	}
+
+	select {
+	case w.ch <- event:
+	default:
+		w.dropped.Add(event.Dropped + 1)
+	}
+}
+
+// close closes the channel. It waits for an ongoing send, a blocking send returns when the context is done.
+func (w *watcher) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	close(w.ch)
+}
+
+// matchWildcard returns `true` if _pattern_ is empty or matches _s_.
+func matchWildcard(pattern, s string) bool {
+	if pattern == "" || pattern == s {
+		return true
+	}
+
+	ok, err := path.Match(pattern, s)
+
+	return err == nil && ok
+}
+
+// watches keeps track of all active watchers.
+type watches struct {
+	mu       sync.RWMutex
+	watchers map[*watcher]struct{}
+}
+
+func (ws *watches) add(w *watcher) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.watchers == nil {
+		ws.watchers = map[*watcher]struct{}{}
+	}
+
+	ws.watchers[w] = struct{}{}
+}
+
+// remove removes the _w_ and closes its channel.
+func (ws *watches) remove(w *watcher) {
+	ws.mu.Lock()
+	delete(ws.watchers, w)
+	ws.mu.Unlock()
+
+	w.close()
+}
+
+// active returns `true` if there is at least one watcher.
+func (ws *watches) active() bool {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	return len(ws.watchers) > 0
+}
+
+// publish sends the _events_ to the matching watchers. The watchers are snapshotted such that a blocking watcher
+// do not hold the lock, i.e. watchers may be added and removed while sending.
+func (ws *watches) publish(events ...managermodel.WatchEvent) {
+	ws.mu.RLock()
+	watchers := make([]*watcher, 0, len(ws.watchers))
+
+	for w := range ws.watchers {
+		watchers = append(watchers, w)
+	}
+
+	ws.mu.RUnlock()
+
+	for _, event := range events {
+		for _, w := range watchers {
+			if w.match(event.ID) {
+				w.send(event)
+			}
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package stdmgr_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/loggers/changelogger"
+	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+	"github.com/mariotoffia/godeviceshadow/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWatchManager() *stdmgr.ManagerImpl {
+	return stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithReportLoggers(changelogger.New()).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(TestModel{})}, true
+				}),
+			),
+		).
+		Build()
+}
+
+func reportTemp(t *testing.T, mgr *stdmgr.ManagerImpl, id string, temp float64) {
+	res := mgr.Report(context.Background(), managermodel.ReportOperation{
+		ClientID: "myClient",
+		ID:       persistencemodel.ID{ID: id, Name: "homeHub"},
+		Model:    TestModel{TimeZone: tz, Sensors: map[string]Sensor{"temp": {Value: temp, TimeStamp: time.Now().UTC()}}},
+	})
+
+	require.Len(t, res, 1)
+	require.NoError(t, res[0].Error)
+}
+
+func TestWatchReportAndDesire(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	mgr := newWatchManager()
+
+	events := mgr.Watch(ctx, persistencemodel.ID{ID: "device-*"}, managermodel.WatchFilter{})
+
+	reportTemp(t, mgr, "hub-1", 20.0) // not watched
+	reportTemp(t, mgr, "device-1", 21.0)
+
+	ev := <-events
+	assert.Equal(t, managermodel.InterceptReport, ev.Kind)
+	assert.Equal(t, persistencemodel.PersistenceID{ID: "device-1", Name: "homeHub", ModelType: persistencemodel.ModelTypeReported}, ev.ID)
+	assert.Equal(t, int64(1), ev.Version)
+	assert.Equal(t, "myClient", ev.ClientID)
+	assert.Equal(t, 21.0, ev.Model.(TestModel).Sensors["temp"].Value)
+
+	chl := changelogger.Find(ev.MergeLoggers)
+	require.NotNil(t, chl)
+	assert.Len(t, chl.ManagedLog[model.MergeOperationAdd], 1)
+
+	res := mgr.Desire(ctx, managermodel.DesireOperation{
+		ClientID: "operator",
+		ID:       persistencemodel.ID{ID: "device-1", Name: "homeHub"},
+		Model:    TestModel{Sensors: map[string]Sensor{"temp": {Value: 22.0, TimeStamp: time.Now().UTC()}}},
+	})
+
+	require.NoError(t, res[0].Error)
+
+	ev = <-events
+	assert.Equal(t, managermodel.InterceptDesire, ev.Kind)
+	assert.Equal(t, persistencemodel.ModelTypeDesired, ev.ID.ModelType)
+	assert.Equal(t, "operator", ev.ClientID)
+
+	cancel()
+
+	// Channel is closed when the context is done
+	for range events {
+	}
+}
+
+func TestWatchDropSlowConsumer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgr := newWatchManager()
+
+	events := mgr.Watch(ctx, persistencemodel.ID{ID: "device-1", Name: "homeHub"}, managermodel.WatchFilter{
+		ModelType:  persistencemodel.ModelTypeReported,
+		BufferSize: 1,
+	})
+
+	reportTemp(t, mgr, "device-1", 21.0)
+	reportTemp(t, mgr, "device-1", 22.0) // dropped
+	reportTemp(t, mgr, "device-1", 23.0) // dropped
+
+	ev := <-events
+	assert.Equal(t, int64(1), ev.Version)
+	assert.Equal(t, int64(0), ev.Dropped)
+
+	reportTemp(t, mgr, "device-1", 24.0)
+
+	ev = <-events
+	assert.Equal(t, int64(4), ev.Version)
+	assert.Equal(t, int64(2), ev.Dropped)
+}
+
+func TestWatchBlockSlowConsumer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgr := newWatchManager()
+
+	events := mgr.Watch(ctx, persistencemodel.ID{ID: "device-1"}, managermodel.WatchFilter{
+		BufferSize: 1,
+		Policy:     managermodel.WatchBlock,
+	})
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 3; i++ {
+			res := mgr.Report(ctx, managermodel.ReportOperation{
+				ID:    persistencemodel.ID{ID: "device-1", Name: "homeHub"},
+				Model: TestModel{TimeZone: tz, Sensors: map[string]Sensor{"temp": {Value: 20.0 + float64(i), TimeStamp: time.Now().UTC()}}},
+			})
+
+			assert.NoError(t, res[0].Error)
+		}
+	}()
+
+	for i := int64(1); i <= 3; i++ {
+		ev := <-events
+		assert.Equal(t, i, ev.Version)
+		assert.Equal(t, int64(0), ev.Dropped)
+	}
+
+	<-done
+}
+
+func TestWatchBlockDoNotStallOtherWatchers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgr := newWatchManager()
+
+	// Never consumed
+	blockCtx, blockCancel := context.WithCancel(ctx)
+
+	mgr.Watch(blockCtx, persistencemodel.ID{ID: "device-1"}, managermodel.WatchFilter{
+		BufferSize: 1,
+		Policy:     managermodel.WatchBlock,
+	})
+
+	reportTemp(t, mgr, "device-1", 20.0) // fills the buffer
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		reportTemp(t, mgr, "device-1", 21.0) // blocks
+	}()
+
+	// Watchers can still be added and removed while blocked
+	otherCtx, otherCancel := context.WithCancel(ctx)
+	events := mgr.Watch(otherCtx, persistencemodel.ID{ID: "device-2"}, managermodel.WatchFilter{})
+
+	reportTemp(t, mgr, "device-2", 22.0)
+
+	ev := <-events
+	assert.Equal(t, "device-2", ev.ID.ID)
+
+	otherCancel()
+
+	for range events {
+		// drain until closed
+	}
+
+	select {
+	case <-done:
+		require.Fail(t, "report shall block until the watch is cancelled")
+	default:
+	}
+
+	blockCancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "report still blocked after the watch was cancelled")
+	}
+}
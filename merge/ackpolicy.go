@@ -0,0 +1,177 @@
+package merge
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
+	"github.com/mariotoffia/godeviceshadow/utils/vtsutils"
+)
+
+// AckPolicyTag is the struct tag that sets the `AckPolicy` on a field and all values beneath it, e.g.
+// `ack:"sticky"`.
+const AckPolicyTag = "ack"
+
+// AckPolicy decides when a desired value is acknowledged, and hence removed, by a reported value.
+type AckPolicy int
+
+const (
+	// AckOnEqual acknowledges the desired value when the reported value is equal to it. This is the default.
+	AckOnEqual AckPolicy = 0
+	// AckOnNewerReport acknowledges the desired value when equal or when the reported value is newer than the desired.
+	AckOnNewerReport AckPolicy = 1
+	// AckSticky never acknowledges the desired value. It is kept as a permanent target, e.g. a firmware channel.
+	AckSticky AckPolicy = 2
+	// AckOnReportPresent acknowledges the desired value as soon as the path is reported, regardless of the value.
+	AckOnReportPresent AckPolicy = 3
+)
+
+func (p AckPolicy) String() string {
+	switch p {
+	case AckOnEqual:
+		return "ack-on-equal"
+	case AckOnNewerReport:
+		return "ack-on-newer-report"
+	case AckSticky:
+		return "sticky"
+	case AckOnReportPresent:
+		return "ack-on-report-present"
+	}
+
+	return fmt.Sprintf("ack policy id: %d", int(p))
+}
+
+// ParseAckPolicy parses the `AckPolicy.String` representation of a policy.
+func ParseAckPolicy(s string) (AckPolicy, error) {
+	switch s {
+	case "ack-on-equal", "":
+		return AckOnEqual, nil
+	case "ack-on-newer-report":
+		return AckOnNewerReport, nil
+	case "sticky":
+		return AckSticky, nil
+	case "ack-on-report-present":
+		return AckOnReportPresent, nil
+	}
+
+	return AckOnEqual, fmt.Errorf("unknown ack policy: %s", s)
+}
+
+// acknowledges returns `true` if the _reported_ acknowledges the _desired_ value using the policy.
+func (p AckPolicy) acknowledges(reported, desired model.ValueAndTimestamp) bool {
+	switch p {
+	case AckSticky:
+		return false
+	case AckOnReportPresent:
+		return true
+	case AckOnNewerReport:
+		return vtsutils.Equals(reported, desired) || reported.GetTimestamp().After(desired.GetTimestamp())
+	}
+
+	return vtsutils.Equals(reported, desired)
+}
+
+type ackRule struct {
+	pattern string
+	glob    *pathutils.Glob
+	policy  AckPolicy
+}
+
+// ackRules are the compiled `DesiredOptions.AckPolicies`. Exact paths are matched first and then the globs, the
+// longest pattern first.
+type ackRules struct {
+	exact map[string]AckPolicy
+	globs []ackRule
+}
+
+func compileAckRules(policies map[string]AckPolicy, style pathutils.Style) (*ackRules, error) {
+	if len(policies) == 0 {
+		return nil, nil
+	}
+
+	rules := &ackRules{exact: map[string]AckPolicy{}}
+
+	for pattern, policy := range policies {
+		if !strings.ContainsAny(pattern, "*?") {
+			rules.exact[pattern] = policy
+			continue
+		}
+
+		g, err := pathutils.CompileGlob(pattern, style)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid ack policy path: %s: %w", pattern, err)
+		}
+
+		rules.globs = append(rules.globs, ackRule{pattern: pattern, glob: g, policy: policy})
+	}
+
+	sort.Slice(rules.globs, func(i, j int) bool {
+		if len(rules.globs[i].pattern) != len(rules.globs[j].pattern) {
+			return len(rules.globs[i].pattern) > len(rules.globs[j].pattern)
+		}
+
+		return rules.globs[i].pattern < rules.globs[j].pattern
+	})
+
+	return rules, nil
+}
+
+func (r *ackRules) lookup(path string) (AckPolicy, bool) {
+	if r == nil {
+		return AckOnEqual, false
+	}
+
+	if p, ok := r.exact[path]; ok {
+		return p, true
+	}
+
+	for _, rule := range r.globs {
+		if rule.glob.Match(path) {
+			return rule.policy, true
+		}
+	}
+
+	return AckOnEqual, false
+}
+
+// ackPolicy resolves the policy for the current path. Path policies have precedence over struct tags
+// which in turn have precedence over the `DesiredOptions.DefaultAckPolicy`.
+func (obj DesiredObject) ackPolicy() AckPolicy {
+	if p, ok := obj.walk.ackRules.lookup(obj.CurrentPath); ok {
+		return p
+	}
+
+	if obj.tagPolicy != nil {
+		return *obj.tagPolicy
+	}
+
+	return obj.DefaultAckPolicy
+}
+
+// withTagPolicy returns the _obj_ with the policy from the `AckPolicyTag` on _field_ (if any).
+func (obj DesiredObject) withTagPolicy(field reflect.StructField) DesiredObject {
+	tag, ok := field.Tag.Lookup(AckPolicyTag)
+
+	if !ok {
+		return obj
+	}
+
+	p, err := ParseAckPolicy(tag)
+
+	if err != nil {
+		// Invalid tag is a programming error -> abort the walk
+		if obj.walk.err == nil {
+			obj.walk.err = fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		return obj
+	}
+
+	obj.tagPolicy = &p
+
+	return obj
+}
@@ -5,8 +5,9 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
-	"github.com/mariotoffia/godeviceshadow/utils/vtsutils"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
 )
 
 // DesiredOptions holds configuration for the desired state processing.
@@ -22,12 +23,30 @@ type DesiredOptions struct {
 	// This is useful for batch processing where you want to collect all errors rather than
 	// stopping at the first one.
 	ContinueOnError bool
+
+	// PathStyle is how the paths, passed to the loggers and in errors, are rendered. Default is `pathutils.StyleDotted`.
+	PathStyle pathutils.Style
+
+	// Stats is optional and when set, it will be filled with the number of acknowledged and visited values.
+	Stats *Stats
+
+	// DefaultAckPolicy is the policy used when neither a path in `AckPolicies` nor a struct tag (`AckPolicyTag`)
+	// selects one. Default is `AckOnEqual`.
+	DefaultAckPolicy AckPolicy
+
+	// AckPolicies are policies per path. The key is either a exact path or a glob (see `pathutils.Glob`) rendered
+	// in `PathStyle`, e.g. _firmware.**_. Those have precedence over the struct tags.
+	AckPolicies map[string]AckPolicy
 }
 
 type DesiredObject struct {
 	DesiredOptions
 	CurrentPath string
 	Errors      DesiredErrors
+	// walk is shared among all steps in the walk to e.g. check for context cancellation.
+	walk *walkState
+	// tagPolicy is the closest `AckPolicyTag` policy (if any).
+	tagPolicy *AckPolicy
 }
 
 // Desired is a special merge where a reported model is analyzed if it matches the desired model.
@@ -37,6 +56,7 @@ type DesiredObject struct {
 // It works by comparing values that implement the `model.ValueAndTimestamp` interface:
 //   - When values in both models are equal (via `vtsutils.Equals`), the value is removed from the desired model
 //   - Non-matching values remain in the desired model
+//   - The `AckPolicy`, per path or by the `ack` struct tag, may change when a value is acknowledged (e.g. `AckSticky`)
 //   - Loggers are notified of acknowledged values via `NotifyAcknowledge`
 //
 // For complex data structures:
@@ -76,6 +96,9 @@ type DesiredObject struct {
 //	// - Temperature: nil (removed because it matched)
 //	// - Humidity: MyValueTS{Value: 45.0, ...} (kept because it didn't match)
 //	// - FanSpeed: MyValueTS{Value: "high", ...} (kept because it wasn't in reported)
+//
+// The _ctx_ is checked for cancellation and deadline periodically while walking the models. If it
+// is done, the walk is aborted and `ctx.Err()` is returned.
 func Desired[T any](ctx context.Context, reportedModel, desiredModel T, opts DesiredOptions) (T, error) {
 	//
 	mergedVal, err := DesiredAny(ctx, reportedModel, desiredModel, opts)
@@ -124,15 +147,37 @@ func DesiredAny(ctx context.Context, reportedModel, desiredModel any, opts Desir
 		return nil, fmt.Errorf("reported and desired model must be of the same kind: %s != %s", reportedVal.Kind(), desiredVal.Kind())
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rules, err := compileAckRules(opts.AckPolicies, opts.PathStyle)
+
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+
 	// Create desired object with error tracking
 	desiredObj := DesiredObject{
 		DesiredOptions: opts,
 		Errors:         make(DesiredErrors, 0),
+		walk:           &walkState{ackRules: rules},
 	}
 
 	// Process the desired model recursively
 	result := desiredRecursive(ctx, reportedVal, desiredVal, desiredObj)
 
+	if opts.Stats != nil {
+		desiredObj.walk.stats.Elapsed = time.Since(start)
+		*opts.Stats = desiredObj.walk.stats
+	}
+
+	if desiredObj.walk.err != nil {
+		return nil, desiredObj.walk.err
+	}
+
 	// Check if we have any errors
 	if len(desiredObj.Errors) > 0 && !opts.ContinueOnError {
 		return nil, desiredObj.Errors
@@ -156,6 +201,11 @@ func desiredRecursive(ctx context.Context, reportedVal, desiredVal reflect.Value
 		return reflect.Value{}
 	}
 
+	// Cancelled -> keep the desired value as is (the error is returned by DesiredAny)
+	if obj.walk.checkContext(ctx) != nil {
+		return desiredVal
+	}
+
 	// Handle nil pointers and interfaces
 	if canBeNil(reportedVal) && reportedVal.IsNil() {
 		return desiredVal // Keep desired value unchanged if reported is nil
@@ -194,7 +244,9 @@ func desiredRecursive(ctx context.Context, reportedVal, desiredVal reflect.Value
 	// If both implement ValueAndTimestamp, check for equality
 	if rvt, ok := unwrapValueAndTimestamp(reportedVal); ok {
 		if dvt, ok := unwrapValueAndTimestamp(desiredVal); ok {
-			if vtsutils.Equals(rvt, dvt) {
+			if obj.ackPolicy().acknowledges(rvt, dvt) {
+				obj.walk.stats.Acknowledged++
+
 				// Safely notify about the acknowledgment
 				if obj.Loggers != nil {
 					obj.Loggers.NotifyAcknowledge(ctx, obj.CurrentPath, rvt)
@@ -240,9 +292,10 @@ func desiredRecursive(ctx context.Context, reportedVal, desiredVal reflect.Value
 				continue // No tag -> skip
 			}
 
-			obj.CurrentPath = concatPath(basePath, tag)
+			fieldObj := obj.withTagPolicy(field)
+			fieldObj.CurrentPath = pathutils.Append(obj.PathStyle, basePath, pathutils.Field(tag))
 
-			if r := desiredRecursive(ctx, reportedVal.Field(i), desiredVal.Field(i), obj); r.IsValid() {
+			if r := desiredRecursive(ctx, reportedVal.Field(i), desiredVal.Field(i), fieldObj); r.IsValid() {
 				desiredVal.Field(i).Set(r)
 			}
 		}
@@ -258,7 +311,7 @@ func desiredRecursive(ctx context.Context, reportedVal, desiredVal reflect.Value
 				continue
 			}
 
-			obj.CurrentPath = concatPath(basePath, formatKey(key))
+			obj.CurrentPath = pathutils.Append(obj.PathStyle, basePath, pathutils.Key(formatKey(key)))
 
 			// Get map values safely
 			reportedMapVal := reportedVal.MapIndex(key)
@@ -306,7 +359,7 @@ func desiredRecursive(ctx context.Context, reportedVal, desiredVal reflect.Value
 		}
 
 		for i := 0; i < minLen; i++ {
-			obj.CurrentPath = fmt.Sprintf("%s.%d", basePath, i)
+			obj.CurrentPath = pathutils.Append(obj.PathStyle, basePath, pathutils.Index(i))
 
 			reportedItem := reportedVal.Index(i)
 			desiredItem := desiredVal.Index(i)
@@ -374,7 +427,7 @@ func desiredSliceById(ctx context.Context, reportedVal, desiredVal reflect.Value
 		// Find matching element in reported slice
 		if reportedIdx, exists := reportedMap[desiredId]; exists {
 			reportedElem := reportedVal.Index(reportedIdx)
-			obj.CurrentPath = fmt.Sprintf("%s.%s", basePath, desiredId)
+			obj.CurrentPath = pathutils.Append(obj.PathStyle, basePath, pathutils.Key(desiredId))
 
 			// Process the elements recursively
 			if r := desiredRecursive(ctx, reportedElem, desiredElem, obj); r.IsValid() {
@@ -0,0 +1,122 @@
+package merge_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ackPolicyHolder struct {
+	Firmware model.ValueAndTimestamp            `json:"fw" ack:"sticky"`
+	Mode     model.ValueAndTimestamp            `json:"mode"`
+	Sensors  map[string]model.ValueAndTimestamp `json:"sensors"`
+}
+
+type invalidAckTagHolder struct {
+	Mode model.ValueAndTimestamp `json:"mode" ack:"whenever"`
+}
+
+func TestAckPolicyParse(t *testing.T) {
+	for _, p := range []merge.AckPolicy{merge.AckOnEqual, merge.AckOnNewerReport, merge.AckSticky, merge.AckOnReportPresent} {
+		parsed, err := merge.ParseAckPolicy(p.String())
+
+		require.NoError(t, err)
+		assert.Equal(t, p, parsed)
+	}
+
+	_, err := merge.ParseAckPolicy("whenever")
+	assert.Error(t, err)
+}
+
+func TestAckPolicies(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Minute)
+
+	newModels := func() (ackPolicyHolder, ackPolicyHolder) {
+		reported := ackPolicyHolder{
+			Firmware: MockValueAndTimestamp{Value: "stable", Timestamp: later},
+			Mode:     MockValueAndTimestamp{Value: "comfort", Timestamp: later},
+			Sensors: map[string]model.ValueAndTimestamp{
+				"temp": MockValueAndTimestamp{Value: 22.0, Timestamp: now.Add(-time.Minute)},
+				"hum":  MockValueAndTimestamp{Value: 40.0, Timestamp: later},
+			},
+		}
+
+		desired := ackPolicyHolder{
+			Firmware: MockValueAndTimestamp{Value: "stable", Timestamp: now},
+			Mode:     MockValueAndTimestamp{Value: "eco", Timestamp: now},
+			Sensors: map[string]model.ValueAndTimestamp{
+				"temp": MockValueAndTimestamp{Value: 21.0, Timestamp: now},
+				"hum":  MockValueAndTimestamp{Value: 45.0, Timestamp: now},
+			},
+		}
+
+		return reported, desired
+	}
+
+	t.Run("Default_AckOnEqual_And_Sticky_Tag", func(t *testing.T) {
+		reported, desired := newModels()
+		logger := &MockLogger{}
+
+		result, err := merge.Desired(context.Background(), reported, desired, merge.DesiredOptions{
+			Loggers: merge.DesiredLoggers{logger},
+		})
+
+		require.NoError(t, err)
+		assert.NotNil(t, result.Firmware, "sticky shall never be acknowledged")
+		assert.NotNil(t, result.Mode)
+		assert.Len(t, result.Sensors, 2)
+		assert.Empty(t, logger.AcknowledgedPaths)
+	})
+
+	t.Run("AckOnNewerReport", func(t *testing.T) {
+		reported, desired := newModels()
+
+		result, err := merge.Desired(context.Background(), reported, desired, merge.DesiredOptions{
+			DefaultAckPolicy: merge.AckOnNewerReport,
+		})
+
+		require.NoError(t, err)
+		assert.NotNil(t, result.Firmware)
+		assert.Nil(t, result.Mode)
+		assert.Contains(t, result.Sensors, "temp") // reported is older
+		assert.NotContains(t, result.Sensors, "hum")
+	})
+
+	t.Run("Path_Overrides_Tag_And_Glob", func(t *testing.T) {
+		reported, desired := newModels()
+		logger := &MockLogger{}
+
+		result, err := merge.Desired(context.Background(), reported, desired, merge.DesiredOptions{
+			Loggers: merge.DesiredLoggers{logger},
+			AckPolicies: map[string]merge.AckPolicy{
+				"fw":          merge.AckOnEqual,
+				"sensors.*":   merge.AckOnReportPresent,
+				"sensors.hum": merge.AckSticky,
+			},
+		})
+
+		require.NoError(t, err)
+		assert.Nil(t, result.Firmware)
+		assert.NotNil(t, result.Mode)
+		assert.NotContains(t, result.Sensors, "temp")
+		assert.Contains(t, result.Sensors, "hum") // exact path wins over glob
+		assert.ElementsMatch(t, []string{"fw", "sensors.temp"}, logger.AcknowledgedPaths)
+	})
+
+	t.Run("Invalid_Tag", func(t *testing.T) {
+		_, err := merge.Desired(context.Background(),
+			invalidAckTagHolder{Mode: MockValueAndTimestamp{Value: "eco", Timestamp: now}},
+			invalidAckTagHolder{Mode: MockValueAndTimestamp{Value: "eco", Timestamp: now}},
+			merge.DesiredOptions{},
+		)
+
+		assert.ErrorContains(t, err, "unknown ack policy")
+	})
+}
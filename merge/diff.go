@@ -0,0 +1,115 @@
+package merge
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model"
+)
+
+// Diff notifies the `MergeOptions.Loggers` with the difference between _oldModel_ and _newModel_ without regard
+// to the timestamps. A path that is only in _newModel_ is added, only in _oldModel_ is removed, and with another
+// value is updated. Managed values are compared using `GetValue`. The paths are notified in sorted order.
+//
+// This is used when a model is replaced with another, e.g. when rolled back to a previous version, where a `Merge`
+// would keep the newer values. Only `MergeOptions.Loggers`, `MergeOptions.PathStyle` and `MergeOptions.Stats` are
+// used.
+func Diff(ctx context.Context, oldModel, newModel any, opts MergeOptions) (err error) {
+	start := time.Now()
+
+	if err := opts.Loggers.NotifyPrepare(ctx); err != nil {
+		return err
+	}
+
+	var stats Stats
+
+	defer func() {
+		if opts.Stats != nil {
+			stats.Elapsed = time.Since(start)
+			*opts.Stats = stats
+		}
+
+		if err2 := opts.Loggers.NotifyPost(ctx, err); err2 != nil && err == nil {
+			err = err2
+		}
+	}()
+
+	oldValues, err := Values(ctx, oldModel, opts.PathStyle)
+
+	if err != nil {
+		return err
+	}
+
+	newValues, err := Values(ctx, newModel, opts.PathStyle)
+
+	if err != nil {
+		return err
+	}
+
+	paths := slices.Collect(maps.Keys(oldValues))
+
+	for path := range newValues {
+		if _, ok := oldValues[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+
+	slices.Sort(paths)
+
+	for _, path := range paths {
+		oldValue, inOld := oldValues[path]
+		newValue, inNew := newValues[path]
+
+		var op model.MergeOperation
+
+		switch {
+		case !inOld:
+			op = model.MergeOperationAdd
+			stats.Added++
+		case !inNew:
+			op = model.MergeOperationRemove
+			stats.Removed++
+		case reflect.DeepEqual(plainValue(oldValue), plainValue(newValue)):
+			op = model.MergeOperationNotChanged
+			stats.NotChanged++
+		default:
+			op = model.MergeOperationUpdate
+			stats.Updated++
+		}
+
+		stats.Visited++
+
+		oldManaged, oldOk := oldValue.(model.ValueAndTimestamp)
+		newManaged, newOk := newValue.(model.ValueAndTimestamp)
+
+		if (oldOk || !inOld) && (newOk || !inNew) {
+			var oldTS, newTS time.Time
+
+			if oldOk {
+				oldTS = oldManaged.GetTimestamp()
+			}
+
+			if newOk {
+				newTS = newManaged.GetTimestamp()
+			}
+
+			opts.Loggers.NotifyManaged(ctx, path, op, oldManaged, newManaged, oldTS, newTS)
+		} else {
+			opts.Loggers.NotifyPlain(ctx, path, op, oldValue, newValue)
+		}
+	}
+
+	return nil
+}
+
+// plainValue returns the value of a managed value or the _v_ as is.
+func plainValue(v any) any {
+	if vt, ok := v.(model.ValueAndTimestamp); ok {
+		return vt.GetValue()
+	}
+
+	return v
+}
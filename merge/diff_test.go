@@ -0,0 +1,69 @@
+package merge_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/loggers/changelogger"
+	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffIgnoresTimestamps(t *testing.T) {
+	now := time.Now().UTC()
+	older := now.Add(-time.Hour)
+
+	oldModel := Device{Name: "a", Circuits: []Circuit{{ID: 1, Sensors: []Sensor{
+		{ID: 1, TimeStamp: now, Value: 20.0},
+		{ID: 2, TimeStamp: now, Value: 21.0},
+	}}}}
+
+	newModel := Device{Name: "b", Circuits: []Circuit{{ID: 1, Sensors: []Sensor{
+		{ID: 1, TimeStamp: older, Value: 19.0},
+	}}}}
+
+	cl := changelogger.New()
+
+	var stats merge.Stats
+
+	err := merge.Diff(context.Background(), oldModel, newModel, merge.MergeOptions{
+		Loggers: merge.MergeLoggers{cl},
+		Stats:   &stats,
+	})
+
+	require.NoError(t, err)
+
+	updated, err := cl.ManagedFromPath(circuits0Sensors0, model.MergeOperationUpdate)
+	require.NoError(t, err)
+	require.Len(t, updated.All(), 1)
+	assert.Equal(t, 19.0, updated.All()[0].NewValue.GetValue())
+
+	removed, err := cl.ManagedFromPath(circuits0Sensors1, model.MergeOperationRemove)
+	require.NoError(t, err)
+	assert.Len(t, removed.All(), 1)
+
+	name, err := cl.PlainFromPath("Name", model.MergeOperationUpdate)
+	require.NoError(t, err)
+	assert.Len(t, name.All(), 1)
+
+	assert.Equal(t, 2, stats.Updated)
+	assert.Equal(t, 1, stats.Removed)
+
+	// Same values with other timestamps are not changed
+	cl = changelogger.New()
+	newModel.Name = "a"
+	newModel.Circuits[0].Sensors[0].Value = 20.0
+	oldModel.Circuits[0].Sensors = oldModel.Circuits[0].Sensors[:1]
+
+	err = merge.Diff(context.Background(), oldModel, newModel, merge.MergeOptions{
+		Loggers: merge.MergeLoggers{cl},
+		Stats:   &stats,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.Updated+stats.Added+stats.Removed)
+}
@@ -0,0 +1,146 @@
+package merge
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
+)
+
+// Filter returns a copy of _m_ where only the leaf values, for which _keep_ returns `true`, are kept. All other
+// values are set to their zero value. Maps and slices are new instances, hence _m_ is never altered.
+//
+// The paths passed to _keep_ are rendered using _style_, same as the loggers and `Values`.
+func Filter(ctx context.Context, m any, keep func(path string) bool, style pathutils.Style) (any, error) {
+	if err := ctx.Err(); err != nil {
+		return m, err
+	}
+
+	val := reflect.ValueOf(m)
+
+	if !val.IsValid() {
+		return m, nil
+	}
+
+	obj := MergeObject{MergeOptions: MergeOptions{PathStyle: style}, walk: &walkState{}}
+	filtered := filterRecursive(ctx, val, keep, obj)
+
+	if obj.walk.err != nil {
+		return m, obj.walk.err
+	}
+
+	return filtered.Interface(), nil
+}
+
+func filterRecursive(ctx context.Context, val reflect.Value, keep func(path string) bool, obj MergeObject) reflect.Value {
+	zero := reflect.Zero(val.Type())
+
+	if obj.walk.checkContext(ctx) != nil {
+		return zero
+	}
+
+	if _, ok := unwrapValueAndTimestamp(val); ok {
+		// Managed values are leafs
+		if keep(obj.CurrentPath) {
+			return val
+		}
+
+		return zero
+	}
+
+	basePath := obj.CurrentPath
+
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if val.IsNil() {
+			return zero
+		}
+
+		inner := filterRecursive(ctx, val.Elem(), keep, obj)
+
+		if inner.IsZero() {
+			return zero
+		}
+
+		if val.Kind() == reflect.Interface {
+			out := reflect.New(val.Type()).Elem()
+			out.Set(inner)
+
+			return out
+		}
+
+		out := reflect.New(val.Type().Elem())
+		out.Elem().Set(inner)
+
+		return out
+	case reflect.Struct:
+		out := reflect.New(val.Type()).Elem()
+		out.Set(val) // unexported fields are kept as is
+
+		for i := 0; i < val.NumField(); i++ {
+			field := val.Type().Field(i)
+
+			if field.PkgPath != "" {
+				continue // Unexported field -> skip
+			}
+
+			tag := getJSONTag(field)
+
+			if tag == "" {
+				out.Field(i).Set(reflect.Zero(field.Type))
+				continue
+			}
+
+			obj.CurrentPath = pathutils.Append(obj.PathStyle, basePath, pathutils.Field(tag))
+			out.Field(i).Set(filterRecursive(ctx, val.Field(i), keep, obj))
+		}
+
+		return out
+	case reflect.Map:
+		if val.IsNil() {
+			return zero
+		}
+
+		out := reflect.MakeMapWithSize(val.Type(), val.Len())
+
+		for _, key := range val.MapKeys() {
+			obj.CurrentPath = pathutils.Append(obj.PathStyle, basePath, pathutils.Key(formatKey(key)))
+
+			if v := filterRecursive(ctx, val.MapIndex(key), keep, obj); !v.IsZero() {
+				out.SetMapIndex(key, v)
+			}
+		}
+
+		if out.Len() == 0 {
+			return zero
+		}
+
+		return out
+	case reflect.Slice, reflect.Array:
+		var out reflect.Value
+
+		if val.Kind() == reflect.Slice {
+			if val.IsNil() {
+				return zero
+			}
+
+			out = reflect.MakeSlice(val.Type(), val.Len(), val.Len())
+		} else {
+			out = reflect.New(val.Type()).Elem()
+		}
+
+		// Removed elements are zeroed to keep the positions
+		for i := 0; i < val.Len(); i++ {
+			obj.CurrentPath = pathutils.Append(obj.PathStyle, basePath, pathutils.Index(i))
+			out.Index(i).Set(filterRecursive(ctx, val.Index(i), keep, obj))
+		}
+
+		return out
+	}
+
+	if keep(obj.CurrentPath) {
+		return val
+	}
+
+	return zero
+}
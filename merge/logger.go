@@ -19,6 +19,14 @@ func (dl DesiredLoggers) NotifyAcknowledge(ctx context.Context, path string, val
 	}
 }
 
+func (dl DesiredLoggers) NotifyReject(ctx context.Context, path string, value model.ValueAndTimestamp, rejection model.RejectedDesire) {
+	for _, l := range dl {
+		if r, ok := l.(model.DesiredRejectLogger); ok {
+			r.Reject(ctx, path, value, rejection)
+		}
+	}
+}
+
 func (ml MergeLoggers) NotifyPrepare(ctx context.Context) error {
 	for _, l := range ml {
 		if p, ok := l.(model.MergeLoggerPrepare); ok {
@@ -40,7 +40,7 @@ func (m *MockMergeLoggerWithPreparePost) Post(ctx context.Context, err error) er
 func TestLoggerNotifyPrepare(t *testing.T) {
 	// Test with no error
 	mockLogger := &MockMergeLoggerWithPreparePost{}
-	mockLogger.On("Prepare").Return(nil).Once()
+	mockLogger.On("Prepare", mock.Anything).Return(nil).Once()
 
 	loggers := merge.MergeLoggers{mockLogger}
 	err := loggers.NotifyPrepare(context.Background())
@@ -51,7 +51,7 @@ func TestLoggerNotifyPrepare(t *testing.T) {
 	mockLogger = &MockMergeLoggerWithPreparePost{
 		PrepareError: errors.New("prepare error"),
 	}
-	mockLogger.On("Prepare").Return(mockLogger.PrepareError).Once()
+	mockLogger.On("Prepare", mock.Anything).Return(mockLogger.PrepareError).Once()
 
 	loggers = merge.MergeLoggers{mockLogger}
 	err = loggers.NotifyPrepare(context.Background())
@@ -61,12 +61,12 @@ func TestLoggerNotifyPrepare(t *testing.T) {
 
 	// Test with multiple loggers, one with error
 	mockLogger1 := &MockMergeLoggerWithPreparePost{}
-	mockLogger1.On("Prepare").Return(nil).Once()
+	mockLogger1.On("Prepare", mock.Anything).Return(nil).Once()
 
 	mockLogger2 := &MockMergeLoggerWithPreparePost{
 		PrepareError: errors.New("prepare error from logger 2"),
 	}
-	mockLogger2.On("Prepare").Return(mockLogger2.PrepareError).Once()
+	mockLogger2.On("Prepare", mock.Anything).Return(mockLogger2.PrepareError).Once()
 
 	loggers = merge.MergeLoggers{mockLogger1, mockLogger2}
 	err = loggers.NotifyPrepare(context.Background())
@@ -81,7 +81,7 @@ func TestLoggerNotifyPost(t *testing.T) {
 
 	// Test with no error
 	mockLogger := &MockMergeLoggerWithPreparePost{}
-	mockLogger.On("Post", inputErr).Return(nil).Once()
+	mockLogger.On("Post", mock.Anything, inputErr).Return(nil).Once()
 
 	loggers := merge.MergeLoggers{mockLogger}
 	err := loggers.NotifyPost(context.Background(), inputErr)
@@ -92,7 +92,7 @@ func TestLoggerNotifyPost(t *testing.T) {
 	mockLogger = &MockMergeLoggerWithPreparePost{
 		PostError: errors.New("post error"),
 	}
-	mockLogger.On("Post", inputErr).Return(mockLogger.PostError).Once()
+	mockLogger.On("Post", mock.Anything, inputErr).Return(mockLogger.PostError).Once()
 
 	loggers = merge.MergeLoggers{mockLogger}
 	err = loggers.NotifyPost(context.Background(), inputErr)
@@ -102,12 +102,12 @@ func TestLoggerNotifyPost(t *testing.T) {
 
 	// Test with multiple loggers, one with error
 	mockLogger1 := &MockMergeLoggerWithPreparePost{}
-	mockLogger1.On("Post", inputErr).Return(nil).Once()
+	mockLogger1.On("Post", mock.Anything, inputErr).Return(nil).Once()
 
 	mockLogger2 := &MockMergeLoggerWithPreparePost{
 		PostError: errors.New("post error from logger 2"),
 	}
-	mockLogger2.On("Post", inputErr).Return(mockLogger2.PostError).Once()
+	mockLogger2.On("Post", mock.Anything, inputErr).Return(mockLogger2.PostError).Once()
 
 	loggers = merge.MergeLoggers{mockLogger1, mockLogger2}
 	err = loggers.NotifyPost(context.Background(), inputErr)
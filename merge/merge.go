@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
 )
 
 // MergeMode indicates how merging is done regarding deletions.
@@ -34,13 +36,23 @@ type MergeOptions struct {
 	// with the same ID (requires elements to implement IdValueAndTimestamp). When `false` or
 	// when elements don't implement IdValueAndTimestamp, slices are merged by position.
 	MergeSlicesByID bool
+	// IgnoreTimestamps when set to `true`, a `ValueAndTimestamp` in newModel replaces the one in oldModel when
+	// the value or timestamp differs, regardless of which is newer. It is used when newModel has precedence, e.g.
+	// when layering models.
+	IgnoreTimestamps bool
 	// Loggers will be notified on add, updated, remove, not-changed operations while merging.
 	Loggers MergeLoggers
+	// PathStyle is how the paths, passed to the loggers, are rendered. Default is `pathutils.StyleDotted`.
+	PathStyle pathutils.Style
+	// Stats is optional and when set, it will be filled with a summary of the merge.
+	Stats *Stats
 }
 
 type MergeObject struct {
 	MergeOptions
 	CurrentPath string
+	// walk is shared among all steps in the merge to e.g. check for context cancellation.
+	walk *walkState
 }
 
 // Merge merges newModel into oldModel following the specified rules:
@@ -52,6 +64,7 @@ type MergeObject struct {
 //     - If Mode=ClientIsMaster and field missing in newModel, remove from merged result.
 //     - If Mode=ServerIsMaster and field missing in newModel, keep from oldModel.
 //     - If timestamps are equal => no update (keep old).
+//     - If IgnoreTimestamps, newModel wins when the value or timestamp differs.
 //
 //  3. For slices/arrays with elements implementing IdValueAndTimestamp and MergeSlicesByID=true:
 //     - Elements are matched by ID instead of by position.
@@ -63,6 +76,9 @@ type MergeObject struct {
 //     - Overwrite from newModel if present.
 //     - If absent in newModel: remove if ClientIsMaster, keep if ServerIsMaster.
 //
+// The _ctx_ is checked for cancellation and deadline before the merge and periodically while
+// walking the models. If it is done, the merge is aborted and `ctx.Err()` is returned.
+//
 // Returns the merged model. Neither _oldModel_ nor _newModel_ is modified.
 func Merge[T any](ctx context.Context, oldModel, newModel T, opts MergeOptions) (T, error) {
 
@@ -86,11 +102,26 @@ func MergeAny(ctx context.Context, oldModel, newModel any, opts MergeOptions) (a
 		return oldModel, fmt.Errorf("oldModel: '%T' and newModel: '%T' must be of the same type", oldModel, newModel)
 	}
 
+	if err := ctx.Err(); err != nil {
+		return oldModel, err
+	}
+
 	if err := opts.Loggers.NotifyPrepare(ctx); err != nil {
 		return oldModel, err
 	}
 
-	mergedVal, err := mergeRecursive(ctx, oldVal, newVal, MergeObject{MergeOptions: opts})
+	start := time.Now()
+	walk := &walkState{}
+	mergedVal, err := mergeRecursive(ctx, oldVal, newVal, MergeObject{MergeOptions: opts, walk: walk})
+
+	if err == nil && walk.err != nil {
+		err = walk.err // cancelled while notifying
+	}
+
+	if opts.Stats != nil {
+		walk.stats.Elapsed = time.Since(start)
+		*opts.Stats = walk.stats
+	}
 
 	if err2 := opts.Loggers.NotifyPost(ctx, err); err2 != nil {
 		return oldModel, err2
@@ -109,6 +140,10 @@ func mergeRecursive(ctx context.Context, base, override reflect.Value, obj Merge
 		return reflect.Value{}, fmt.Errorf("both base: '%T' and override: '%T' must be valid", base.Interface(), override.Interface())
 	}
 
+	if err := obj.walk.checkContext(ctx); err != nil {
+		return reflect.Value{}, err
+	}
+
 	// Check for Merger interface before unwrapping
 	if merger, ok := base.Interface().(model.Merger); ok {
 		result, err := merger.Merge(override.Interface(), model.MergeMode(obj.Mode))
@@ -148,12 +183,13 @@ func mergeRecursive(ctx context.Context, base, override reflect.Value, obj Merge
 		newTS := overrideValTS.GetTimestamp()
 
 		switch {
-		case newTS.After(oldTS):
-			obj.Loggers.NotifyManaged(ctx, obj.CurrentPath, model.MergeOperationUpdate, baseValTS, overrideValTS, oldTS, newTS)
+		case newTS.After(oldTS), obj.IgnoreTimestamps && (!newTS.Equal(oldTS) ||
+			!reflect.DeepEqual(baseValTS.GetValue(), overrideValTS.GetValue())):
+			obj.notifyManaged(ctx, model.MergeOperationUpdate, baseValTS, overrideValTS, oldTS, newTS)
 
 			return override, nil // override newer -> replace
 		default:
-			obj.Loggers.NotifyManaged(ctx, obj.CurrentPath, model.MergeOperationNotChanged, baseValTS, overrideValTS, oldTS, newTS)
+			obj.notifyManaged(ctx, model.MergeOperationNotChanged, baseValTS, overrideValTS, oldTS, newTS)
 
 			return base, nil // override less or equal -> no update -> keep old
 		}
@@ -170,7 +206,7 @@ func mergeRecursive(ctx context.Context, base, override reflect.Value, obj Merge
 	default:
 		if obj.Mode == ServerIsMaster {
 			if isEmptyValue(overrideVal) {
-				obj.Loggers.NotifyPlain(ctx, obj.CurrentPath, model.MergeOperationNotChanged, baseVal.Interface(), overrideVal.Interface())
+				obj.notifyPlain(ctx, model.MergeOperationNotChanged, baseVal.Interface(), overrideVal.Interface())
 
 				return base, nil
 			}
@@ -184,11 +220,11 @@ func mergeRecursive(ctx context.Context, base, override reflect.Value, obj Merge
 		ov := overrideVal.Interface()
 
 		if bv != ov {
-			obj.Loggers.NotifyPlain(ctx, obj.CurrentPath, model.MergeOperationUpdate, bv, ov)
+			obj.notifyPlain(ctx, model.MergeOperationUpdate, bv, ov)
 
 			return override, nil // not equal -> override
 		} else {
-			obj.Loggers.NotifyPlain(ctx, obj.CurrentPath, model.MergeOperationNotChanged, bv, ov)
+			obj.notifyPlain(ctx, model.MergeOperationNotChanged, bv, ov)
 
 			return base, nil // equal -> keep old
 		}
@@ -209,14 +245,14 @@ func mergeSlice(ctx context.Context, baseVal, overrideVal reflect.Value, opts Me
 		switch opts.Mode {
 		case ClientIsMaster:
 			for i := 0; i < baseVal.Len(); i++ {
-				opts.CurrentPath = fmt.Sprintf("%s.%d", basePath, i)
+				opts.CurrentPath = pathutils.Append(opts.PathStyle, basePath, pathutils.Index(i))
 
 				notifyRecursive(ctx, baseVal.Index(i), model.MergeOperationRemove, opts)
 			}
 			return overrideVal, nil
 		case ServerIsMaster:
 			for i := 0; i < baseVal.Len(); i++ {
-				opts.CurrentPath = fmt.Sprintf("%s.%d", basePath, i)
+				opts.CurrentPath = pathutils.Append(opts.PathStyle, basePath, pathutils.Index(i))
 
 				notifyRecursive(ctx, baseVal.Index(i), model.MergeOperationNotChanged, opts)
 			}
@@ -256,7 +292,7 @@ func mergeStruct(ctx context.Context, baseVal, overrideVal reflect.Value, opts M
 			continue
 		}
 
-		opts.CurrentPath = concatPath(basePath, getJSONTag(fieldType))
+		opts.CurrentPath = pathutils.Append(opts.PathStyle, basePath, pathutils.Field(getJSONTag(fieldType)))
 
 		if fieldValue.Kind() == reflect.Ptr {
 			// Handle pointer fields
@@ -303,7 +339,7 @@ func mergeMap(ctx context.Context, baseVal, overrideVal reflect.Value, opts Merg
 		switch opts.Mode {
 		case ClientIsMaster:
 			for _, key := range baseVal.MapKeys() {
-				opts.CurrentPath = concatPath(basePath, formatKey(key))
+				opts.CurrentPath = pathutils.Append(opts.PathStyle, basePath, pathutils.Key(formatKey(key)))
 
 				notifyRecursive(ctx, baseVal.MapIndex(key), model.MergeOperationRemove, opts)
 			}
@@ -311,7 +347,7 @@ func mergeMap(ctx context.Context, baseVal, overrideVal reflect.Value, opts Merg
 			return overrideVal, nil
 		case ServerIsMaster:
 			for _, key := range baseVal.MapKeys() {
-				opts.CurrentPath = concatPath(basePath, formatKey(key))
+				opts.CurrentPath = pathutils.Append(opts.PathStyle, basePath, pathutils.Key(formatKey(key)))
 
 				notifyRecursive(ctx, baseVal.MapIndex(key), model.MergeOperationNotChanged, opts)
 			}
@@ -333,7 +369,7 @@ func mergeMap(ctx context.Context, baseVal, overrideVal reflect.Value, opts Merg
 		overrideVal := overrideVal.MapIndex(key)
 		baseValForKey := baseVal.MapIndex(key)
 
-		opts.CurrentPath = concatPath(basePath, formatKey(key))
+		opts.CurrentPath = pathutils.Append(opts.PathStyle, basePath, pathutils.Key(formatKey(key)))
 
 		if !baseValForKey.IsValid() {
 			result.SetMapIndex(key, overrideVal) // add
@@ -358,7 +394,7 @@ func mergeMap(ctx context.Context, baseVal, overrideVal reflect.Value, opts Merg
 
 	// keys in base (but not in override)
 	for k, v := range baseKeys {
-		opts.CurrentPath = concatPath(basePath, k)
+		opts.CurrentPath = pathutils.Append(opts.PathStyle, basePath, pathutils.Key(k))
 
 		if opts.Mode == ServerIsMaster {
 			result.SetMapIndex(v, baseVal.MapIndex(v)) // keep
@@ -457,13 +493,6 @@ func isEmptyValue(v reflect.Value) bool {
 	return false
 }
 
-func concatPath(path, name string) string {
-	if path == "" {
-		return name
-	}
-	return path + "." + name
-}
-
 // getJSONTag get the tag (name part only) from a struct field.
 //
 // If no _JSON_ field tag is present, the field name is returned.
@@ -508,7 +537,7 @@ func mergeSliceByPosition(ctx context.Context, baseVal, overrideVal reflect.Valu
 		baseElem := baseVal.Index(i)
 		ovElem := overrideVal.Index(i)
 
-		opts.CurrentPath = fmt.Sprintf("%s.%d", basePath, i)
+		opts.CurrentPath = pathutils.Append(opts.PathStyle, basePath, pathutils.Index(i))
 		mergedElem, err := mergeRecursive(ctx, baseElem, ovElem, opts)
 
 		if err != nil {
@@ -521,7 +550,7 @@ func mergeSliceByPosition(ctx context.Context, baseVal, overrideVal reflect.Valu
 	// new slice is longer -> add extra elements in override
 	if ovLen > minLen {
 		for i := minLen; i < ovLen; i++ {
-			opts.CurrentPath = fmt.Sprintf("%s.%d", basePath, i)
+			opts.CurrentPath = pathutils.Append(opts.PathStyle, basePath, pathutils.Index(i))
 			result = reflect.Append(result, overrideVal.Index(i))
 
 			notifyRecursive(ctx, overrideVal.Index(i), model.MergeOperationAdd, opts)
@@ -533,14 +562,14 @@ func mergeSliceByPosition(ctx context.Context, baseVal, overrideVal reflect.Valu
 		if opts.Mode == ServerIsMaster {
 			// ServerIsMaster -> keep
 			for i := minLen; i < baseLen; i++ {
-				opts.CurrentPath = fmt.Sprintf("%s.%d", basePath, i)
+				opts.CurrentPath = pathutils.Append(opts.PathStyle, basePath, pathutils.Index(i))
 				result = reflect.Append(result, baseVal.Index(i))
 
 				notifyRecursive(ctx, baseVal.Index(i), model.MergeOperationNotChanged, opts)
 			}
 		} else /*ClientIsMaster*/ {
 			for i := minLen; i < baseLen; i++ {
-				opts.CurrentPath = fmt.Sprintf("%s.%d", basePath, i)
+				opts.CurrentPath = pathutils.Append(opts.PathStyle, basePath, pathutils.Index(i))
 
 				notifyRecursive(ctx, baseVal.Index(i), model.MergeOperationRemove, opts)
 			}
@@ -639,7 +668,7 @@ func mergeSliceByID(ctx context.Context, baseVal, overrideVal reflect.Value, opt
 		if overrideIdx, exists := overrideMap[id]; exists {
 			// Element exists in both - merge them
 			overrideElem := overrideVal.Index(overrideIdx)
-			opts.CurrentPath = fmt.Sprintf("%s.%s", basePath, id)
+			opts.CurrentPath = pathutils.Append(opts.PathStyle, basePath, pathutils.Key(id))
 
 			mergedElem, err := mergeRecursive(ctx, baseElem, overrideElem, opts)
 			if err != nil {
@@ -650,12 +679,12 @@ func mergeSliceByID(ctx context.Context, baseVal, overrideVal reflect.Value, opt
 			processed[id] = true
 		} else if opts.Mode == ServerIsMaster {
 			// Element only in base and server is master - keep it
-			opts.CurrentPath = fmt.Sprintf("%s.%s", basePath, id)
+			opts.CurrentPath = pathutils.Append(opts.PathStyle, basePath, pathutils.Key(id))
 			result = reflect.Append(result, baseElem)
 			notifyRecursive(ctx, baseElem, model.MergeOperationNotChanged, opts)
 		} else {
 			// Element only in base and client is master - remove it
-			opts.CurrentPath = fmt.Sprintf("%s.%s", basePath, id)
+			opts.CurrentPath = pathutils.Append(opts.PathStyle, basePath, pathutils.Key(id))
 			notifyRecursive(ctx, baseElem, model.MergeOperationRemove, opts)
 		}
 	}
@@ -665,7 +694,7 @@ func mergeSliceByID(ctx context.Context, baseVal, overrideVal reflect.Value, opt
 		if !processed[id] {
 			// Element only in override - add it
 			overrideElem := overrideVal.Index(overrideIdx)
-			opts.CurrentPath = fmt.Sprintf("%s.%s", basePath, id)
+			opts.CurrentPath = pathutils.Append(opts.PathStyle, basePath, pathutils.Key(id))
 			result = reflect.Append(result, overrideElem)
 			notifyRecursive(ctx, overrideElem, model.MergeOperationAdd, opts)
 		}
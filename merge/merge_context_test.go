@@ -0,0 +1,100 @@
+package merge_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/loggers/changelogger"
+	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type largeMapHolder struct {
+	M map[string]*TimestampedMapVal
+}
+
+func newLargeMapHolder(n int, ts time.Time) largeMapHolder {
+	h := largeMapHolder{M: make(map[string]*TimestampedMapVal, n)}
+
+	for i := 0; i < n; i++ {
+		h.M[fmt.Sprintf("key%d", i)] = &TimestampedMapVal{Value: fmt.Sprintf("v%d", i), UpdatedAt: ts}
+	}
+
+	return h
+}
+
+func TestMergeCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	now := time.Now().UTC()
+	_, err := merge.Merge(ctx, newLargeMapHolder(10, now), newLargeMapHolder(10, now.Add(time.Second)), merge.MergeOptions{})
+
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+// cancellingLogger cancels the context after a number of managed values have been logged.
+type cancellingLogger struct {
+	calls  int
+	after  int
+	cancel context.CancelFunc
+}
+
+func (cl *cancellingLogger) Managed(
+	ctx context.Context, path string, operation model.MergeOperation,
+	oldValue, newValue model.ValueAndTimestamp, oldTimeStamp, newTimeStamp time.Time,
+) {
+	cl.calls++
+
+	if cl.calls == cl.after {
+		cl.cancel()
+	}
+}
+
+func (cl *cancellingLogger) Plain(ctx context.Context, path string, operation model.MergeOperation, oldValue, newValue any) {
+}
+
+func TestMergeCancelledWhileWalking(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now().UTC()
+	lg := &cancellingLogger{after: 10, cancel: cancel}
+
+	_, err := merge.Merge(ctx, newLargeMapHolder(5000, now), newLargeMapHolder(5000, now.Add(time.Second)), merge.MergeOptions{
+		Loggers: merge.MergeLoggers{lg},
+	})
+
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Less(t, lg.calls, 5000, "merge should have been aborted before all values were visited")
+}
+
+func TestMergeAddCancelledWhileNotifying(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	_, err := merge.MergeAny(ctx, largeMapHolder{}, newLargeMapHolder(1000, time.Now()), merge.MergeOptions{
+		Loggers: merge.MergeLoggers{changelogger.New()},
+	})
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestDesiredCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	now := time.Now().UTC()
+	_, err := merge.Desired(ctx, newLargeMapHolder(10, now), newLargeMapHolder(10, now), merge.DesiredOptions{})
+
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
@@ -114,3 +114,31 @@ func TestNestedIDBasedSliceMerging(t *testing.T) {
 
 	assert.Equal(t, 22.5, sensorMapServer["temp1"].Value, "temp1 value should be kept")
 }
+
+// TestIgnoreTimestamps tests that the newModel has precedence regardless of the timestamps
+func TestIgnoreTimestamps(t *testing.T) {
+	now := time.Now().UTC()
+
+	oldModel := map[string]*TimestampedMapVal{
+		"sp":   {Value: "20", UpdatedAt: now},
+		"mode": {Value: "heat", UpdatedAt: now},
+	}
+
+	newModel := map[string]*TimestampedMapVal{
+		"sp": {Value: "22", UpdatedAt: now.Add(-time.Hour)},
+	}
+
+	merged, err := merge.Merge(context.Background(), oldModel, newModel, merge.MergeOptions{Mode: merge.ServerIsMaster})
+
+	require.NoError(t, err)
+	assert.Equal(t, "20", merged["sp"].Value, "older value is not merged")
+
+	merged, err = merge.Merge(context.Background(), oldModel, newModel, merge.MergeOptions{
+		Mode:             merge.ServerIsMaster,
+		IgnoreTimestamps: true,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "22", merged["sp"].Value, "newModel has precedence")
+	assert.Equal(t, "heat", merged["mode"].Value)
+}
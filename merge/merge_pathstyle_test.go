@@ -0,0 +1,89 @@
+package merge_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/loggers/changelogger"
+	"github.com/mariotoffia/godeviceshadow/loggers/desirelogger"
+	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type pathStyleHolder struct {
+	Sensors map[string]*TimestampedMapVal `json:"sensors"`
+	Tags    []string                      `json:"tags"`
+}
+
+func TestMergePathStyles(t *testing.T) {
+	now := time.Now().UTC()
+
+	oldObj := pathStyleHolder{
+		Sensors: map[string]*TimestampedMapVal{"in.door": {Value: "old", UpdatedAt: now.Add(-time.Hour)}},
+		Tags:    []string{"a"},
+	}
+	newObj := pathStyleHolder{
+		Sensors: map[string]*TimestampedMapVal{"in.door": {Value: "new", UpdatedAt: now}},
+		Tags:    []string{"b"},
+	}
+
+	tests := []struct {
+		style   pathutils.Style
+		managed string
+		plain   string
+		glob    string
+	}{
+		{pathutils.StyleDotted, "sensors.in.door", "tags.0", "sensors.**"},
+		{pathutils.StyleJSONPointer, "/sensors/in.door", "/tags/0", "/sensors/*"},
+		{pathutils.StyleBracket, `sensors["in.door"]`, "tags[0]", "sensors[*]"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.style.String(), func(t *testing.T) {
+			cl := changelogger.New()
+
+			_, err := merge.Merge(context.Background(), oldObj, newObj, merge.MergeOptions{
+				Mode:      merge.ClientIsMaster,
+				Loggers:   merge.MergeLoggers{cl},
+				PathStyle: tc.style,
+			})
+			require.NoError(t, err)
+
+			require.Len(t, cl.ManagedLog[model.MergeOperationUpdate], 1)
+			assert.Equal(t, tc.managed, cl.ManagedLog[model.MergeOperationUpdate][0].Path)
+
+			require.Len(t, cl.PlainLog[model.MergeOperationUpdate], 1)
+			assert.Equal(t, tc.plain, cl.PlainLog[model.MergeOperationUpdate][0].Path)
+
+			sel, err := cl.ManagedFromGlob(tc.glob, model.MergeOperationUpdate)
+			require.NoError(t, err)
+			assert.Equal(t, 1, sel.Size())
+		})
+	}
+}
+
+func TestDesiredPathStyle(t *testing.T) {
+	now := time.Now().UTC()
+
+	reported := pathStyleHolder{Sensors: map[string]*TimestampedMapVal{"in.door": {Value: "on", UpdatedAt: now}}}
+	desired := pathStyleHolder{Sensors: map[string]*TimestampedMapVal{"in.door": {Value: "on", UpdatedAt: now}}}
+
+	dl := desirelogger.New()
+
+	_, err := merge.Desired(context.Background(), reported, desired, merge.DesiredOptions{
+		Loggers:   merge.DesiredLoggers{dl},
+		PathStyle: pathutils.StyleJSONPointer,
+	})
+	require.NoError(t, err)
+
+	assert.Contains(t, dl.Acknowledged(), "/sensors/in.door")
+
+	acked, err := dl.FromGlob("/sensors/*")
+	require.NoError(t, err)
+	assert.Len(t, acked, 1)
+}
@@ -0,0 +1,99 @@
+package merge_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/merge"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type statsHolder struct {
+	Name    string                           `json:"name"`
+	Sensors map[string]MockValueAndTimestamp `json:"sensors"`
+}
+
+func TestMergeStatsWithoutLoggers(t *testing.T) {
+	now := time.Now()
+
+	oldModel := statsHolder{
+		Name: "hub",
+		Sensors: map[string]MockValueAndTimestamp{
+			"temp":     {Value: 21.0, Timestamp: now.Add(-time.Minute)},
+			"humidity": {Value: 40.0, Timestamp: now.Add(-time.Minute)},
+			"pressure": {Value: 1013.0, Timestamp: now.Add(-time.Minute)},
+		},
+	}
+
+	newModel := statsHolder{
+		Name: "hub",
+		Sensors: map[string]MockValueAndTimestamp{
+			"temp":     {Value: 22.0, Timestamp: now},
+			"humidity": {Value: 40.0, Timestamp: now.Add(-time.Minute)},
+			"co2":      {Value: 410.0, Timestamp: now},
+		},
+	}
+
+	var stats merge.Stats
+
+	_, err := merge.Merge(context.Background(), oldModel, newModel, merge.MergeOptions{
+		Mode:  merge.ClientIsMaster,
+		Stats: &stats,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Added)
+	assert.Equal(t, 1, stats.Updated)
+	assert.Equal(t, 1, stats.Removed)
+	assert.Equal(t, 2, stats.NotChanged) // name + humidity
+	assert.Positive(t, stats.Visited)
+	assert.True(t, stats.Changed())
+}
+
+func TestMergeStatsNoChanges(t *testing.T) {
+	now := time.Now()
+
+	m := statsHolder{
+		Name:    "hub",
+		Sensors: map[string]MockValueAndTimestamp{"temp": {Value: 21.0, Timestamp: now}},
+	}
+
+	var stats merge.Stats
+
+	_, err := merge.Merge(context.Background(), m, m, merge.MergeOptions{Stats: &stats})
+
+	require.NoError(t, err)
+	assert.False(t, stats.Changed())
+	assert.Equal(t, 2, stats.NotChanged)
+}
+
+func TestDesiredStatsAcknowledged(t *testing.T) {
+	reported := TestStruct{
+		Field1: MockValueAndTimestamp{Value: "match", Timestamp: time.Now()},
+		Field2: MockValueAndTimestamp{Value: noMatch, Timestamp: time.Now()},
+	}
+	desired := TestStruct{
+		Field1: MockValueAndTimestamp{Value: "match", Timestamp: time.Now()},
+		Field2: MockValueAndTimestamp{Value: desiredValue, Timestamp: time.Now()},
+	}
+
+	var stats merge.Stats
+
+	_, err := merge.Desired(context.Background(), reported, desired, merge.DesiredOptions{Stats: &stats})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Acknowledged)
+	assert.True(t, stats.Changed())
+}
+
+func TestStatsCombine(t *testing.T) {
+	a := merge.Stats{Added: 1, Updated: 2, Visited: 3, Elapsed: time.Second}
+	b := merge.Stats{Removed: 1, Acknowledged: 2, Visited: 4, Elapsed: time.Second}
+
+	assert.Equal(t, merge.Stats{
+		Added: 1, Updated: 2, Removed: 1, Acknowledged: 2, Visited: 7, Elapsed: 2 * time.Second,
+	}, a.Combine(b))
+}
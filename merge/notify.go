@@ -2,11 +2,11 @@ package merge
 
 import (
 	"context"
-	"fmt"
 	"reflect"
 	"time"
 
 	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
 )
 
 // notifyRecursive will recursively notify the leafs of the _op_ operation.
@@ -14,18 +14,22 @@ import (
 // NOTE: _op_ may only be `model.MergeOperationAdd`, `model.MergeOperationRemove`,  and
 // `model.MergeOperationNotChanged` nothing else.
 func notifyRecursive(ctx context.Context, val reflect.Value, op model.MergeOperation, obj MergeObject) {
-	if len(obj.Loggers) == 0 {
+	if len(obj.Loggers) == 0 && obj.Stats == nil {
+		return
+	}
+
+	if obj.walk.checkContext(ctx) != nil {
 		return
 	}
 
 	if vt, ok := unwrapValueAndTimestamp(val); ok {
 		switch op {
 		case model.MergeOperationAdd:
-			obj.Loggers.NotifyManaged(ctx, obj.CurrentPath, op, nil, vt, time.Time{}, vt.GetTimestamp())
+			obj.notifyManaged(ctx, op, nil, vt, time.Time{}, vt.GetTimestamp())
 		case model.MergeOperationRemove:
-			obj.Loggers.NotifyManaged(ctx, obj.CurrentPath, op, vt, nil, vt.GetTimestamp(), time.Time{})
+			obj.notifyManaged(ctx, op, vt, nil, vt.GetTimestamp(), time.Time{})
 		case model.MergeOperationNotChanged:
-			obj.Loggers.NotifyManaged(ctx, obj.CurrentPath, op, vt, vt, vt.GetTimestamp(), vt.GetTimestamp())
+			obj.notifyManaged(ctx, op, vt, vt, vt.GetTimestamp(), vt.GetTimestamp())
 		}
 
 		return
@@ -49,19 +53,19 @@ func notifyRecursive(ctx context.Context, val reflect.Value, op model.MergeOpera
 				continue // No tag -> skip
 			}
 
-			obj.CurrentPath = concatPath(basePath, tag)
+			obj.CurrentPath = pathutils.Append(obj.PathStyle, basePath, pathutils.Field(tag))
 
 			notifyRecursive(ctx, val.Field(i), op, obj)
 		}
 	case reflect.Map:
 		for _, key := range val.MapKeys() {
-			obj.CurrentPath = concatPath(basePath, formatKey(key))
+			obj.CurrentPath = pathutils.Append(obj.PathStyle, basePath, pathutils.Key(formatKey(key)))
 
 			notifyRecursive(ctx, val.MapIndex(key), op, obj)
 		}
 	case reflect.Slice, reflect.Array:
 		for i := 0; i < val.Len(); i++ {
-			obj.CurrentPath = fmt.Sprintf("%s.%d", basePath, i)
+			obj.CurrentPath = pathutils.Append(obj.PathStyle, basePath, pathutils.Index(i))
 
 			notifyRecursive(ctx, val.Index(i), op, obj)
 		}
@@ -72,11 +76,11 @@ func notifyRecursive(ctx context.Context, val reflect.Value, op model.MergeOpera
 
 		switch op {
 		case model.MergeOperationAdd:
-			obj.Loggers.NotifyPlain(ctx, obj.CurrentPath, op, nil, val.Interface())
+			obj.notifyPlain(ctx, op, nil, val.Interface())
 		case model.MergeOperationRemove:
-			obj.Loggers.NotifyPlain(ctx, obj.CurrentPath, op, val.Interface(), nil)
+			obj.notifyPlain(ctx, op, val.Interface(), nil)
 		case model.MergeOperationNotChanged:
-			obj.Loggers.NotifyPlain(ctx, obj.CurrentPath, op, val.Interface(), val.Interface())
+			obj.notifyPlain(ctx, op, val.Interface(), val.Interface())
 		}
 	}
 }
@@ -0,0 +1,194 @@
+package merge
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
+)
+
+// Reject removes the desired values, that a device refuses to apply, from the desired model. Each
+// _rejections_ entry selects a `model.ValueAndTimestamp` in the _desiredModel_ by its path (rendered
+// using `DesiredOptions.PathStyle`).
+//
+// Each removed value is counted in `Stats.Rejected` and loggers that implements the `model.DesiredRejectLogger`
+// are notified with the value and the rejection. Rejections whose path do not exist in the desired model are
+// ignored.
+//
+// Example:
+//
+//	desired, err := merge.Reject(ctx, desired, []model.RejectedDesire{
+//	    {Path: "climate.sp", Code: "out-of-range", Reason: "max 28 degrees"},
+//	}, merge.DesiredOptions{Loggers: merge.DesiredLoggers{myLogger}})
+//
+// The _ctx_ is checked for cancellation and deadline periodically while walking the model.
+func Reject[T any](ctx context.Context, desiredModel T, rejections []model.RejectedDesire, opts DesiredOptions) (T, error) {
+	//
+	rejectedVal, err := RejectAny(ctx, desiredModel, rejections, opts)
+
+	var zero T
+
+	if err != nil || rejectedVal == nil {
+		return zero, err
+	}
+
+	return rejectedVal.(T), nil
+}
+
+func RejectAny(ctx context.Context, desiredModel any, rejections []model.RejectedDesire, opts DesiredOptions) (any, error) {
+	if desiredModel == nil || len(rejections) == 0 {
+		return desiredModel, nil
+	}
+
+	desiredVal := reflect.ValueOf(desiredModel)
+
+	if (desiredVal.Kind() == reflect.Ptr || desiredVal.Kind() == reflect.Interface) && desiredVal.IsNil() {
+		return desiredModel, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	byPath := make(map[string]model.RejectedDesire, len(rejections))
+
+	for _, r := range rejections {
+		if r.Path == "" {
+			return nil, fmt.Errorf("rejection of desired value must have a path (code: %s)", r.Code)
+		}
+
+		byPath[r.Path] = r
+	}
+
+	start := time.Now()
+
+	obj := DesiredObject{
+		DesiredOptions: opts,
+		walk:           &walkState{},
+	}
+
+	result := rejectRecursive(ctx, desiredVal, byPath, obj)
+
+	if opts.Stats != nil {
+		obj.walk.stats.Elapsed = time.Since(start)
+		*opts.Stats = obj.walk.stats
+	}
+
+	if obj.walk.err != nil {
+		return nil, obj.walk.err
+	}
+
+	if !result.IsValid() || !result.CanInterface() {
+		return nil, nil
+	}
+
+	return result.Interface(), nil
+}
+
+func rejectRecursive(ctx context.Context, desiredVal reflect.Value, byPath map[string]model.RejectedDesire, obj DesiredObject) reflect.Value {
+	if !desiredVal.IsValid() {
+		return reflect.Value{}
+	}
+
+	if obj.walk.checkContext(ctx) != nil {
+		return desiredVal
+	}
+
+	if canBeNil(desiredVal) && desiredVal.IsNil() {
+		return desiredVal
+	}
+
+	if desiredVal.Kind() == reflect.Ptr {
+		if result := rejectRecursive(ctx, desiredVal.Elem(), byPath, obj); result.IsValid() {
+			newPtr := reflect.New(result.Type())
+			newPtr.Elem().Set(result)
+
+			return newPtr
+		}
+
+		return desiredVal
+	}
+
+	if !desiredVal.CanSet() {
+		desiredVal = makeAddressable(desiredVal)
+	}
+
+	if dvt, ok := unwrapValueAndTimestamp(desiredVal); ok {
+		if rejection, ok := byPath[obj.CurrentPath]; ok {
+			obj.walk.stats.Rejected++
+			obj.Loggers.NotifyReject(ctx, obj.CurrentPath, dvt, rejection)
+
+			return reflect.Zero(desiredVal.Type())
+		}
+
+		return desiredVal
+	}
+
+	desiredVal = unwrapReflectValue(desiredVal)
+
+	if !desiredVal.IsValid() {
+		return desiredVal
+	}
+
+	basePath := obj.CurrentPath
+
+	switch desiredVal.Kind() {
+	case reflect.Struct:
+		for i := 0; i < desiredVal.NumField(); i++ {
+			field := desiredVal.Type().Field(i)
+
+			if field.PkgPath != "" {
+				continue // Unexported field -> skip
+			}
+
+			tag := getJSONTag(field)
+
+			if tag == "" {
+				continue // No tag -> skip
+			}
+
+			obj.CurrentPath = pathutils.Append(obj.PathStyle, basePath, pathutils.Field(tag))
+
+			if r := rejectRecursive(ctx, desiredVal.Field(i), byPath, obj); r.IsValid() {
+				desiredVal.Field(i).Set(r)
+			}
+		}
+	case reflect.Map:
+		for _, key := range desiredVal.MapKeys() {
+			obj.CurrentPath = pathutils.Append(obj.PathStyle, basePath, pathutils.Key(formatKey(key)))
+
+			rejected := obj.walk.stats.Rejected
+			result := rejectRecursive(ctx, desiredVal.MapIndex(key), byPath, obj)
+
+			if !result.IsValid() || (result.IsZero() && obj.walk.stats.Rejected > rejected) {
+				desiredVal.SetMapIndex(key, reflect.Value{}) // This deletes the key
+			} else {
+				desiredVal.SetMapIndex(key, result)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < desiredVal.Len(); i++ {
+			item := desiredVal.Index(i)
+
+			if !item.CanSet() {
+				continue
+			}
+
+			// Same path as in the desired walk -> by ID if possible, otherwise by index
+			if idvt, ok := unwrapIdValueAndTimestamp(item); ok {
+				obj.CurrentPath = pathutils.Append(obj.PathStyle, basePath, pathutils.Key(idvt.GetID()))
+			} else {
+				obj.CurrentPath = pathutils.Append(obj.PathStyle, basePath, pathutils.Index(i))
+			}
+
+			if r := rejectRecursive(ctx, item, byPath, obj); r.IsValid() {
+				item.Set(r)
+			}
+		}
+	}
+
+	return desiredVal
+}
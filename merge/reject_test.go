@@ -0,0 +1,75 @@
+package merge_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/loggers/desirelogger"
+	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type rejectHolder struct {
+	Mode      model.ValueAndTimestamp            `json:"mode"`
+	SetPoints map[string]model.ValueAndTimestamp `json:"sp"`
+	Labels    map[string]string                  `json:"labels"`
+}
+
+func TestRejectRemovesAndNotifies(t *testing.T) {
+	now := time.Now()
+
+	desired := rejectHolder{
+		Mode: MockValueAndTimestamp{Value: "eco", Timestamp: now},
+		SetPoints: map[string]model.ValueAndTimestamp{
+			"indoor":  MockValueAndTimestamp{Value: 35.0, Timestamp: now},
+			"outdoor": MockValueAndTimestamp{Value: 10.0, Timestamp: now},
+		},
+		Labels: map[string]string{"empty": ""},
+	}
+
+	dl := desirelogger.New()
+
+	var stats merge.Stats
+
+	result, err := merge.Reject(context.Background(), desired, []model.RejectedDesire{
+		{Path: "sp.indoor", Code: "out-of-range", Reason: "max 28 degrees"},
+		{Path: "sp.missing", Code: "not-supported"},
+	}, merge.DesiredOptions{Loggers: merge.DesiredLoggers{dl}, Stats: &stats})
+
+	require.NoError(t, err)
+	assert.NotNil(t, result.Mode)
+	assert.NotContains(t, result.SetPoints, "indoor")
+	assert.Contains(t, result.SetPoints, "outdoor")
+	assert.Contains(t, result.Labels, "empty") // zero values that are not rejected are kept
+	assert.Equal(t, 1, stats.Rejected)
+
+	rejected := dl.Rejected()
+
+	require.Len(t, rejected, 1)
+	assert.Equal(t, 35.0, rejected["sp.indoor"].Value.GetValue())
+	assert.Equal(t, "out-of-range", rejected["sp.indoor"].Rejection.Code)
+	assert.Equal(t, "max 28 degrees", rejected["sp.indoor"].Rejection.Reason)
+}
+
+func TestRejectStructField(t *testing.T) {
+	desired := rejectHolder{Mode: MockValueAndTimestamp{Value: "turbo", Timestamp: time.Now()}}
+
+	result, err := merge.Reject(context.Background(), desired, []model.RejectedDesire{
+		{Path: "mode", Code: "not-supported"},
+	}, merge.DesiredOptions{})
+
+	require.NoError(t, err)
+	assert.Nil(t, result.Mode)
+}
+
+func TestRejectRequiresPath(t *testing.T) {
+	_, err := merge.Reject(context.Background(), rejectHolder{}, []model.RejectedDesire{
+		{Code: "not-supported"},
+	}, merge.DesiredOptions{})
+
+	assert.Error(t, err)
+}
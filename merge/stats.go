@@ -0,0 +1,84 @@
+package merge
+
+import (
+	"context"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model"
+)
+
+// Stats is a lightweight summary of a merge or desired operation. It is collected by the engine itself
+// and hence do not require any logger to be attached.
+type Stats struct {
+	// Added is the number of values that was added.
+	Added int
+	// Updated is the number of values that was updated.
+	Updated int
+	// Removed is the number of values that was removed.
+	Removed int
+	// NotChanged is the number of values that was left as is.
+	NotChanged int
+	// Acknowledged is the number of desired values that was acknowledged by a reported value.
+	Acknowledged int
+	// Rejected is the number of desired values that was rejected by the device.
+	Rejected int
+	// Visited is the number of paths that the engine did visit.
+	Visited int
+	// Elapsed is the time spent in the merge or desired operation.
+	Elapsed time.Duration
+}
+
+// Changed returns `true` if anything was added, updated, removed, acknowledged or rejected.
+func (s Stats) Changed() bool {
+	return s.Added > 0 || s.Updated > 0 || s.Removed > 0 || s.Acknowledged > 0 || s.Rejected > 0
+}
+
+// Combine will add all counters and elapsed time from _other_ and return the sum.
+func (s Stats) Combine(other Stats) Stats {
+	return Stats{
+		Added:        s.Added + other.Added,
+		Updated:      s.Updated + other.Updated,
+		Removed:      s.Removed + other.Removed,
+		NotChanged:   s.NotChanged + other.NotChanged,
+		Acknowledged: s.Acknowledged + other.Acknowledged,
+		Rejected:     s.Rejected + other.Rejected,
+		Visited:      s.Visited + other.Visited,
+		Elapsed:      s.Elapsed + other.Elapsed,
+	}
+}
+
+func (s *Stats) count(op model.MergeOperation) {
+	switch op {
+	case model.MergeOperationAdd:
+		s.Added++
+	case model.MergeOperationUpdate:
+		s.Updated++
+	case model.MergeOperationRemove:
+		s.Removed++
+	case model.MergeOperationNotChanged:
+		s.NotChanged++
+	}
+}
+
+// notifyManaged counts the operation and notifies the loggers.
+func (obj MergeObject) notifyManaged(
+	ctx context.Context,
+	operation model.MergeOperation,
+	oldValue, newValue model.ValueAndTimestamp,
+	oldTimeStamp, newTimeStamp time.Time,
+) {
+	if obj.walk != nil {
+		obj.walk.stats.count(operation)
+	}
+
+	obj.Loggers.NotifyManaged(ctx, obj.CurrentPath, operation, oldValue, newValue, oldTimeStamp, newTimeStamp)
+}
+
+// notifyPlain counts the operation and notifies the loggers.
+func (obj MergeObject) notifyPlain(ctx context.Context, operation model.MergeOperation, oldValue, newValue any) {
+	if obj.walk != nil {
+		obj.walk.stats.count(operation)
+	}
+
+	obj.Loggers.NotifyPlain(ctx, obj.CurrentPath, operation, oldValue, newValue)
+}
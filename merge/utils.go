@@ -1,6 +1,7 @@
 package merge
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"strings"
@@ -41,3 +42,34 @@ func formatKey(key reflect.Value) string {
 		return fmt.Sprintf("<unsupported key type: %s>", key.Type())
 	}
 }
+
+// contextCheckInterval is the number of visited values between each check of the context.
+const contextCheckInterval = 128
+
+// walkState is shared, by pointer, among all steps of a single merge or desired walk.
+type walkState struct {
+	stats Stats
+	err   error
+	// ackRules are the compiled desired ack policies (if any).
+	ackRules *ackRules
+}
+
+// checkContext counts a visit and checks every `contextCheckInterval` visit if _ctx_ is cancelled or
+// past its deadline. Once it has failed, it will keep returning the same error.
+func (ws *walkState) checkContext(ctx context.Context) error {
+	if ws == nil {
+		return nil
+	}
+
+	if ws.err != nil {
+		return ws.err
+	}
+
+	ws.stats.Visited++
+
+	if ws.stats.Visited%contextCheckInterval == 0 {
+		ws.err = ctx.Err()
+	}
+
+	return ws.err
+}
@@ -0,0 +1,127 @@
+package merge
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
+)
+
+// Values returns all leaf values in _m_ keyed by their path rendered using _style_. Managed values are
+// returned as the `model.ValueAndTimestamp` instance. Plain zero values, e.g. an empty string, are left out
+// since those cannot be told apart from a never set value. Use `Lookup` to get a single value, including a zero value.
+func Values(ctx context.Context, m any, style pathutils.Style) (map[string]any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	values := valueCollector{}
+	walk := &walkState{}
+
+	notifyRecursive(ctx, reflect.ValueOf(m), model.MergeOperationNotChanged, MergeObject{
+		MergeOptions: MergeOptions{Loggers: MergeLoggers{values}, PathStyle: style},
+		walk:         walk,
+	})
+
+	if walk.err != nil {
+		return nil, walk.err
+	}
+
+	return values, nil
+}
+
+// valueCollector is a `model.MergeLogger` that collects the new value of each path.
+type valueCollector map[string]any
+
+func (vc valueCollector) Managed(
+	ctx context.Context,
+	path string,
+	operation model.MergeOperation,
+	oldValue, newValue model.ValueAndTimestamp,
+	oldTimeStamp, newTimeStamp time.Time,
+) {
+	vc[path] = newValue
+}
+
+func (vc valueCollector) Plain(ctx context.Context, path string, operation model.MergeOperation, oldValue, newValue any) {
+	if reflect.ValueOf(newValue).IsZero() {
+		return
+	}
+
+	vc[path] = newValue
+}
+
+// Lookup returns the value at the _path_, rendered using _style_, in _m_. The path is resolved in the same way as the
+// merge renders it i.e. JSON tag names, map keys and slice indexes. Managed values are returned as the
+// `model.ValueAndTimestamp` instance, all other values, including a struct, map or slice, as is.
+//
+// Unlike `Values`, a plain zero value is present. The path is absent when a field, map key or index does not exist
+// or when a pointer, interface, map or slice along the path is `nil`.
+//
+// This is e.g. used to check `model.Precondition` against a stored model.
+func Lookup(m any, path string, style pathutils.Style) (any, bool, error) {
+	p, err := pathutils.Parse(path, style)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	val := reflect.ValueOf(m)
+
+	for _, seg := range p {
+		if val = unwrapReflectValue(val); !val.IsValid() {
+			return nil, false, nil
+		}
+
+		if val = lookupSegment(val, seg); !val.IsValid() {
+			return nil, false, nil
+		}
+	}
+
+	if vt, ok := unwrapValueAndTimestamp(val); ok {
+		return vt, true, nil
+	}
+
+	if val = unwrapReflectValue(val); !val.IsValid() {
+		return nil, false, nil
+	}
+
+	if (val.Kind() == reflect.Map || val.Kind() == reflect.Slice) && val.IsNil() {
+		return nil, false, nil
+	}
+
+	return val.Interface(), true, nil
+}
+
+// lookupSegment returns the value of the _seg_ in _val_ or a invalid value if not found.
+func lookupSegment(val reflect.Value, seg pathutils.Segment) reflect.Value {
+	switch val.Kind() {
+	case reflect.Struct:
+		for i := 0; i < val.NumField(); i++ {
+			field := val.Type().Field(i)
+
+			if field.PkgPath == "" && getJSONTag(field) == seg.Name {
+				return val.Field(i)
+			}
+		}
+	case reflect.Map:
+		iter := val.MapRange()
+
+		for iter.Next() {
+			if formatKey(iter.Key()) == seg.Name {
+				return iter.Value()
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		idx, err := strconv.Atoi(seg.Name)
+
+		if err == nil && idx >= 0 && idx < val.Len() {
+			return val.Index(idx)
+		}
+	}
+
+	return reflect.Value{}
+}
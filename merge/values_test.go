@@ -0,0 +1,98 @@
+package merge_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValuesAndPreconditions(t *testing.T) {
+	now := time.Now()
+
+	values, err := merge.Values(context.Background(), statsHolder{
+		Name: "hub",
+		Sensors: map[string]MockValueAndTimestamp{
+			"temp": {Value: 21.0, Timestamp: now},
+		},
+	}, pathutils.StyleDotted)
+
+	require.NoError(t, err)
+	assert.Equal(t, "hub", values["name"])
+	require.Contains(t, values, "sensors.temp")
+
+	assert.True(t, model.Precondition{Path: "sensors.temp", Operator: model.PreconditionEquals, Value: 21.0}.Check(values))
+	assert.False(t, model.Precondition{Path: "sensors.temp", Operator: model.PreconditionEquals, Value: 22.0}.Check(values))
+	assert.True(t, model.Precondition{Path: "sensors.temp", Operator: model.PreconditionNotEquals, Value: 22.0}.Check(values))
+	assert.True(t, model.Precondition{Path: "sensors", Operator: model.PreconditionPresent}.Check(values))
+	assert.True(t, model.Precondition{Path: "sensors.co2", Operator: model.PreconditionAbsent}.Check(values))
+	assert.False(t, model.Precondition{Path: "sensors.temp", Operator: model.PreconditionAbsent}.Check(values))
+
+	assert.True(t, model.Precondition{Path: "sensors.temp", Predicate: func(value any, present bool) bool {
+		vt, ok := value.(model.ValueAndTimestamp)
+		return present && ok && vt.GetTimestamp().Equal(now)
+	}}.Check(values))
+}
+
+type lookupHolder struct {
+	Count   int                              `json:"count"`
+	Zone    string                           `json:"zone"`
+	Enabled bool                             `json:"enabled"`
+	Boost   *float64                         `json:"boost"`
+	Sensors map[string]MockValueAndTimestamp `json:"sensors"`
+	Tags    []string                         `json:"tags"`
+}
+
+func TestLookupZeroValues(t *testing.T) {
+	now := time.Now()
+	m := lookupHolder{
+		Sensors: map[string]MockValueAndTimestamp{"temp": {Value: 0.0, Timestamp: now}},
+		Tags:    []string{""},
+	}
+
+	value, present, err := merge.Lookup(m, "count", pathutils.StyleDotted)
+	require.NoError(t, err)
+	assert.True(t, present)
+	assert.Equal(t, 0, value)
+
+	for _, p := range []model.Precondition{
+		{Path: "count", Operator: model.PreconditionEquals, Value: 0},
+		{Path: "zone", Operator: model.PreconditionEquals, Value: ""},
+		{Path: "enabled", Operator: model.PreconditionEquals, Value: false},
+		{Path: "sensors.temp", Operator: model.PreconditionEquals, Value: 0.0},
+		{Path: "tags.0", Operator: model.PreconditionEquals, Value: ""},
+		{Path: "count", Operator: model.PreconditionPresent},
+		{Path: "boost", Operator: model.PreconditionAbsent},
+		{Path: "sensors.co2", Operator: model.PreconditionAbsent},
+		{Path: "tags.1", Operator: model.PreconditionAbsent},
+		{Path: "missing", Operator: model.PreconditionAbsent},
+	} {
+		value, present, err := merge.Lookup(&m, p.Path, pathutils.StyleDotted)
+		require.NoError(t, err)
+		assert.True(t, p.Holds(value, present), p.String())
+	}
+
+	for _, p := range []model.Precondition{
+		{Path: "count", Operator: model.PreconditionAbsent},
+		{Path: "sensors.temp", Operator: model.PreconditionAbsent},
+		{Path: "boost", Operator: model.PreconditionPresent},
+	} {
+		value, present, err := merge.Lookup(m, p.Path, pathutils.StyleDotted)
+		require.NoError(t, err)
+		assert.False(t, p.Holds(value, present), p.String())
+	}
+
+	boost := 0.0
+	m.Boost = &boost
+
+	value, present, err = merge.Lookup(m, "boost", pathutils.StyleDotted)
+	require.NoError(t, err)
+	assert.True(t, present)
+	assert.Equal(t, 0.0, value)
+}
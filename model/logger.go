@@ -11,6 +11,14 @@ type DesiredLogger interface {
 	Acknowledge(ctx context.Context, path string, value ValueAndTimestamp)
 }
 
+// DesiredRejectLogger is a optional interface for a `DesiredLogger` that wants to be notified when a
+// device rejects a desired value.
+type DesiredRejectLogger interface {
+	// Reject is called each time a desired value is rejected by a device and it is removed from the
+	// desired model.
+	Reject(ctx context.Context, path string, value ValueAndTimestamp, rejection RejectedDesire)
+}
+
 // MergeLogger is a interface that will be called in the different merge
 // operations that has been performed.
 type MergeLogger interface {
@@ -0,0 +1,127 @@
+package managermodel
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// AlarmsNameSuffix is appended to the shadow name to form the name of the alarm section. The section is always
+// persisted as a separate desired model.
+const AlarmsNameSuffix = ":alarms"
+
+// AlarmState is the state of a alarm instance.
+type AlarmState string
+
+const (
+	// AlarmActive is when the condition of the alarm holds.
+	AlarmActive AlarmState = "active"
+	// AlarmAcknowledged is when a active alarm has been acknowledged by a operator.
+	AlarmAcknowledged AlarmState = "acknowledged"
+	// AlarmCleared is when the value has returned within the hysteresis of the condition.
+	AlarmCleared AlarmState = "cleared"
+)
+
+// Alarm is a alarm instance, i.e. a alarm definition on a single path of a shadow.
+type Alarm struct {
+	// Key is the unique key of the instance within the shadow (see `AlarmKey`).
+	Key string `json:"key"`
+	// Definition is the id of the alarm definition.
+	Definition string `json:"definition"`
+	// Path is the path of the value.
+	Path string `json:"path"`
+	// State is the current state.
+	State AlarmState `json:"state"`
+	// Value is the value that did raise or clear the alarm.
+	Value any `json:"value,omitempty"`
+	// Message is the message of the alarm definition.
+	Message string `json:"message,omitempty"`
+	// RaisedAt is when the alarm became active. It is a Unix64 bit _UTC_ nanosecond timestamp.
+	RaisedAt int64 `json:"raised_at"`
+	// AcknowledgedAt is when the alarm was acknowledged. It is a Unix64 bit _UTC_ nanosecond timestamp.
+	AcknowledgedAt int64 `json:"acknowledged_at,omitempty"`
+	// AcknowledgedBy is the client id that did acknowledge the alarm.
+	AcknowledgedBy string `json:"acknowledged_by,omitempty"`
+	// ClearedAt is when the alarm was cleared. It is a Unix64 bit _UTC_ nanosecond timestamp.
+	ClearedAt int64 `json:"cleared_at,omitempty"`
+}
+
+// Alarms is the alarm section of a shadow.
+type Alarms struct {
+	// Entries are the alarm instances keyed by `Alarm.Key`. A cleared instance is kept until it is raised again.
+	Entries map[string]Alarm `json:"entries"`
+	// Pending are the keys of the instances where the condition holds but the on-delay has not yet passed and since
+	// when, as Unix64 bit _UTC_ nanosecond timestamp, it holds.
+	Pending map[string]int64 `json:"pending,omitempty"`
+}
+
+// List returns the alarms in the _states_, ordered by key. If no _states_, the active and acknowledged alarms are
+// returned.
+func (a Alarms) List(states ...AlarmState) []Alarm {
+	if len(states) == 0 {
+		states = []AlarmState{AlarmActive, AlarmAcknowledged}
+	}
+
+	var res []Alarm
+
+	for _, alarm := range a.Entries {
+		if slices.Contains(states, alarm.State) {
+			res = append(res, alarm)
+		}
+	}
+
+	slices.SortFunc(res, func(x, y Alarm) int {
+		return strings.Compare(x.Key, y.Key)
+	})
+
+	return res
+}
+
+// Acknowledge acknowledges the active alarm with the _key_ on behalf of _clientID_ at _at_, a Unix64 bit _UTC_
+// nanosecond timestamp. If not found, a 404 error is returned and if not active, a 409 error is returned.
+func (a *Alarms) Acknowledge(key, clientID string, at int64) (Alarm, error) {
+	alarm, ok := a.Entries[key]
+
+	if !ok {
+		return Alarm{}, persistencemodel.Error404(fmt.Sprintf("alarm %s not found", key))
+	}
+
+	if alarm.State != AlarmActive {
+		return Alarm{}, persistencemodel.Error409(fmt.Sprintf("alarm %s is %s", key, alarm.State))
+	}
+
+	alarm.State = AlarmAcknowledged
+	alarm.AcknowledgedAt = at
+	alarm.AcknowledgedBy = clientID
+
+	a.Entries[key] = alarm
+
+	return alarm, nil
+}
+
+// AlarmKey returns the key of the alarm instance of the _definition_ on the _path_.
+func AlarmKey(definition, path string) string {
+	return definition + ":" + path
+}
+
+// AlarmsID returns the persistence id of the alarm section for the shadow _id_.
+func AlarmsID(id persistencemodel.ID) persistencemodel.PersistenceID {
+	return persistencemodel.PersistenceID{
+		ID:        id.ID,
+		Name:      id.Name + AlarmsNameSuffix,
+		ModelType: persistencemodel.ModelTypeDesired,
+	}
+}
+
+// Alarmer is a manager that keeps the alarm section of the shadows.
+type Alarmer interface {
+	// ListAlarms returns the alarms of the shadow _id_ in the _states_, ordered by key. If no _states_, the active
+	// and acknowledged alarms are returned.
+	ListAlarms(ctx context.Context, id persistencemodel.ID, states ...AlarmState) ([]Alarm, error)
+	// AcknowledgeAlarm acknowledges the active alarm with the _key_ on behalf of _clientID_. If not found, a 404
+	// error is returned and if not active, a 409 error is returned.
+	AcknowledgeAlarm(ctx context.Context, id persistencemodel.ID, key, clientID string) (Alarm, error)
+}
@@ -0,0 +1,48 @@
+package managermodel
+
+import (
+	"context"
+
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// CopyOperation copies, or moves, a shadow from one id to another.
+type CopyOperation struct {
+	// ClientID is the client id that the copied models are written with.
+	ClientID string
+	// From is the shadow to copy.
+	From persistencemodel.ID
+	// To is where the shadow is copied to. None of the copied models may exist.
+	To persistencemodel.ID
+	// ModelType is the model to copy. If _zero_, both reported and desired are copied.
+	ModelType persistencemodel.ModelType
+	// Move deletes `From` when copied. A move always moves both models and the shadow sections, such as the
+	// desired status and rejections.
+	Move bool
+}
+
+// CopyResult is the result of a copy, move or rename.
+type CopyResult struct {
+	// From is the shadow that was copied.
+	From persistencemodel.ID
+	// To is where the shadow was copied to.
+	To persistencemodel.ID
+	// Models are the written models, in `To`.
+	Models []persistencemodel.WriteResult
+	// Atomic is `true` if the persistence did copy, and delete, all models in one atomic operation.
+	Atomic bool
+}
+
+// Relocator is a manager that can copy, move and rename shadows.
+type Relocator interface {
+	// Copy copies the models of the shadow to another id. If any target exist, it fails with 409 (Conflict) and
+	// nothing is copied.
+	//
+	// When the persistence is a `persistencemodel.Copier` all models are copied atomically. Otherwise it is done
+	// model by model where any copied models are removed on failure.
+	Copy(ctx context.Context, op CopyOperation) (CopyResult, error)
+	// Move moves the shadow, including the sections, to another id e.g. when a device is replaced.
+	Move(ctx context.Context, from, to persistencemodel.ID, clientID string) (CopyResult, error)
+	// Rename moves the shadow to another _name_ under the same id.
+	Rename(ctx context.Context, id persistencemodel.ID, name, clientID string) (CopyResult, error)
+}
@@ -2,6 +2,7 @@ package managermodel
 
 import (
 	"context"
+	"time"
 
 	"github.com/mariotoffia/godeviceshadow/merge"
 	"github.com/mariotoffia/godeviceshadow/model"
@@ -11,6 +12,9 @@ import (
 type DesireOperation struct {
 	// ClientID is a optional client ID.
 	ClientID string
+	// ClientToken is a optional unique token of the operation. When the `Manager` has idempotency enabled, a desire
+	// with a token that was recently applied on the shadow is not applied again. Instead the result has `Duplicate` set.
+	ClientToken string
 	// Model is the new desired model to merge into existing one.
 	Model any
 	// Separation is the separation to use for this operation. If not set it will use the `Manager` default.
@@ -27,6 +31,24 @@ type DesireOperation struct {
 	// TIP: This is useful when removal of items in the desired model is wanted. When `merge.ServerIsMaster` is used, it will only
 	// upsert the model. When `merge.ClientIsMaster` is used, it will add, remove and update items.
 	MergeMode merge.MergeMode
+	// IgnoreTimestamps when set, the values in the `Model` replace the desired values when different, regardless of
+	// their timestamps. This is useful when the `Model` has precedence, e.g. a layered desired model.
+	IgnoreTimestamps bool
+	// Expiry is when set, the time the added or updated desired values may stay pending or delivered before
+	// the desired status is expired. This is only used when the desired status tracking is enabled in the `Manager`.
+	Expiry time.Duration
+	// Version when set to zero -> desire to latest version. Otherwise, it expects the specific version of the desired
+	// model in persistence and if not, it will fail with 409 (Conflict).
+	Version int64
+	// Preconditions are checked against the stored desired model before the merge. If any does not hold, the
+	// operation fails with 412 (Precondition Failed).
+	Preconditions []model.Precondition
+	// ActivateAt when set, the operation is not applied but persisted as a `Schedule` that is applied when due (see
+	// `Scheduler.ApplySchedules`). The result has the `ScheduleID` set.
+	ActivateAt time.Time
+	// Recurrence when set together with `ActivateAt`, the operation is applied again each interval, e.g. _24h_ for
+	// a nightly setback. It must be at least `MinRecurrence` and requires `ActivateAt`.
+	Recurrence time.Duration
 }
 
 type DesireOperationResult struct {
@@ -42,11 +64,21 @@ type DesireOperationResult struct {
 	Model any
 	// Processed is set to `true` if it was changed and persisted.
 	Processed bool
+	// Stats is a summary of the desired merge.
+	Stats merge.Stats
 	// Version is the possibly new version of the model.
 	Version int64
 	// TimeStamp is the timestamp of the model that was written. This is the main timestamp that gets updated
 	// each time a model was created or updated. It is a Unix64 bit _UTC_ nanosecond timestamp.
 	TimeStamp int64
+	// Duplicate is set when the `DesireOperation.ClientToken` was already applied. Only the `Processed`, `Version`
+	// and `TimeStamp` of the original operation is returned and nothing is merged nor logged.
+	Duplicate bool
+	// ScheduleID is set when the operation was scheduled, instead of applied, since it has a `ActivateAt`.
+	ScheduleID string
+	// SectionError is set when the desired model was persisted but a section of the shadow, e.g. the desired
+	// status, could not be written. The operation is applied and shall not be re-tried.
+	SectionError error
 }
 
 // Desireable is when a manager supports upserting a desired model.
@@ -0,0 +1,87 @@
+package managermodel
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// GroupsID is the shadow id where the groups are persisted.
+const GroupsID = "$groups"
+
+// GroupNameSuffix is appended to the group name to form the name of the persisted group. Those are persisted as a
+// separate desired model under `GroupsID`.
+const GroupNameSuffix = ":group"
+
+// Group is a named set of shadows. A shadow is a member when it is in the static `Members` or, when the group is
+// dynamic, it matches all of `ShadowName`, `IDPattern` and `Conditions` that are set.
+type Group struct {
+	// Name is the unique name of the group.
+	Name string `json:"name"`
+	// Members are the static members.
+	Members []persistencemodel.ID `json:"members,omitempty"`
+	// IDPattern is a `path.Match` pattern on the shadow id, e.g. _site-12-*_.
+	IDPattern string `json:"id_pattern,omitempty"`
+	// ShadowName when set, only shadows with this name are dynamic members.
+	ShadowName string `json:"shadow_name,omitempty"`
+	// Conditions are checked against the reported model of the shadow. All must hold.
+	//
+	// NOTE: A `model.Precondition.Predicate` is not kept when the persistence serializes the group.
+	Conditions []model.Precondition `json:"conditions,omitempty"`
+}
+
+// Dynamic returns `true` if shadows may be members by the `ShadowName`, `IDPattern` or the `Conditions`.
+func (g Group) Dynamic() bool {
+	return g.ShadowName != "" || g.IDPattern != "" || len(g.Conditions) > 0
+}
+
+// Validate checks that the group has a name and a valid `IDPattern`.
+func (g Group) Validate() error {
+	if g.Name == "" {
+		return persistencemodel.Error400("group name is required")
+	}
+
+	if g.IDPattern != "" {
+		if _, err := path.Match(g.IDPattern, ""); err != nil {
+			return persistencemodel.Error400(fmt.Sprintf("invalid id pattern: %s", g.IDPattern))
+		}
+	}
+
+	return nil
+}
+
+// GroupID returns the persistence id of the group with _name_.
+func GroupID(name string) persistencemodel.PersistenceID {
+	return persistencemodel.PersistenceID{
+		ID:        GroupsID,
+		Name:      name + GroupNameSuffix,
+		ModelType: persistencemodel.ModelTypeDesired,
+	}
+}
+
+// Grouper is a manager that keeps groups of shadows and may target the members of a group.
+type Grouper interface {
+	// SaveGroup creates, or replaces, the _group_.
+	SaveGroup(ctx context.Context, group Group) error
+	// ReadGroup reads the group with _name_. If not found, a 404 error is returned.
+	ReadGroup(ctx context.Context, name string) (Group, error)
+	// DeleteGroup deletes the group with _name_. The members are not affected.
+	DeleteGroup(ctx context.Context, name string) error
+	// ListGroups returns all groups sorted by name.
+	ListGroups(ctx context.Context) ([]Group, error)
+	// ReadGroupMembers returns the ids, sorted, of all members of the group with _name_.
+	ReadGroupMembers(ctx context.Context, name string) ([]persistencemodel.ID, error)
+	// GroupsOf returns the names, sorted, of all groups that the shadow _id_ is a member of.
+	GroupsOf(ctx context.Context, id persistencemodel.ID) ([]string, error)
+	// GroupsOfEach returns the names, sorted, of all groups that each shadow in _ids_ is a member of. Each group is
+	// evaluated once for all _ids_, hence prefer it over `GroupsOf` for many shadows.
+	GroupsOfEach(ctx context.Context, ids ...persistencemodel.ID) (map[persistencemodel.ID][]string, error)
+	// ListGroup lists the models of all members of the group with _name_.
+	ListGroup(ctx context.Context, name string) ([]persistencemodel.ListResult, error)
+	// DesireGroup desires the _operation_ on each member of the group with _name_. The `DesireOperation.ID` is
+	// ignored and each member gets a copy of the `DesireOperation.Model`. The results are in member order.
+	DesireGroup(ctx context.Context, name string, operation DesireOperation) ([]DesireOperationResult, error)
+}
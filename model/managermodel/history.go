@@ -0,0 +1,15 @@
+package managermodel
+
+import (
+	"context"
+
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// HistoryReceiver is a manager that can list the retained versions of a model. Use `ReadOperation.Version` or
+// `ReadOperation.AsOf` to read a retained version.
+type HistoryReceiver interface {
+	// History returns the retained versions of the model _id_, newest first. It fails with 400 if the persistence
+	// do not retain history.
+	History(ctx context.Context, id persistencemodel.PersistenceID) ([]persistencemodel.ListResult, error)
+}
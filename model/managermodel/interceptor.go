@@ -0,0 +1,129 @@
+package managermodel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// InterceptKind is the `Manager` function that is intercepted.
+type InterceptKind int
+
+const (
+	InterceptReport InterceptKind = 1
+	InterceptDesire InterceptKind = 2
+	InterceptRead   InterceptKind = 3
+	InterceptList   InterceptKind = 4
+	InterceptDelete InterceptKind = 5
+	// InterceptRollback is used by `Rollbacker.Rollback`.
+	InterceptRollback InterceptKind = 6
+	// InterceptCopy is used by `Relocator.Copy`, `Relocator.Move` and `Relocator.Rename`.
+	InterceptCopy InterceptKind = 7
+)
+
+func (k InterceptKind) String() string {
+	switch k {
+	case InterceptReport:
+		return "report"
+	case InterceptDesire:
+		return "desire"
+	case InterceptRead:
+		return "read"
+	case InterceptList:
+		return "list"
+	case InterceptDelete:
+		return "delete"
+	case InterceptRollback:
+		return "rollback"
+	case InterceptCopy:
+		return "copy"
+	}
+
+	return fmt.Sprintf("intercept kind id: %d", int(k))
+}
+
+// InterceptCall is a single operation that is passed through the interceptor chain. Only the operation and result
+// fields of the `Kind` are set.
+type InterceptCall struct {
+	// Kind is the function that is intercepted.
+	Kind InterceptKind
+	// ID is the id of the shadow. It is not set when `InterceptList`.
+	ID persistencemodel.ID
+	// Type is the resolved type of the model when `InterceptReport`, `InterceptDesire` and `InterceptRead`.
+	Type model.TypeEntry
+	// Reported is the stored reported model, loaded before the merge, when `InterceptReport` (and combined `InterceptDesire`).
+	// It is only set in `Interceptor.After`.
+	Reported any
+	// Desired is the stored desired model, loaded before the merge, when `InterceptReport` and `InterceptDesire`.
+	// It is only set in `Interceptor.After`.
+	Desired any
+	// Report is the operation when `InterceptReport`. It may be altered in `Interceptor.Before`.
+	Report *ReportOperation
+	// ReportResult is the result when `InterceptReport`. It is only set in `Interceptor.After`.
+	ReportResult *ReportOperationResult
+	// Desire is the operation when `InterceptDesire`. It may be altered in `Interceptor.Before`.
+	Desire *DesireOperation
+	// DesireResult is the result when `InterceptDesire`. It is only set in `Interceptor.After`.
+	DesireResult *DesireOperationResult
+	// Read is the operation when `InterceptRead`.
+	Read *ReadOperation
+	// ReadResult is the result when `InterceptRead`. It is only set in `Interceptor.After`.
+	ReadResult *ReadOperationResult
+	// List is the options when `InterceptList`.
+	List *ListOptions
+	// ListResult is the result when `InterceptList`. It is only set in `Interceptor.After`.
+	ListResult *ListResults
+	// ListError is the error of the list operation. It is only set in `Interceptor.After`.
+	ListError error
+	// Delete is the operation when `InterceptDelete`.
+	Delete *DeleteOperation
+	// DeleteResult is the result when `InterceptDelete`. It is only set in `Interceptor.After`.
+	DeleteResult *DeleteOperationOperationResult
+	// Rollback is the operation when `InterceptRollback`. The stored models are set in `Reported` and `Desired`.
+	Rollback *RollbackOperation
+	// RollbackResult is the result when `InterceptRollback`. It is only set in `Interceptor.After`.
+	RollbackResult *RollbackResult
+	// RollbackError is the error of the rollback. It is only set in `Interceptor.After`.
+	RollbackError error
+	// Copy is the operation when `InterceptCopy`. The `ID` is the `CopyOperation.From`.
+	Copy *CopyOperation
+	// CopyResult is the result when `InterceptCopy`. It is only set in `Interceptor.After`.
+	CopyResult *CopyResult
+	// CopyError is the error of the copy. It is only set in `Interceptor.After`.
+	CopyError error
+}
+
+// Interceptor wraps the `Manager` functions, e.g. to authorize, audit, validate or enrich operations.
+type Interceptor interface {
+	// Before is invoked, in registration order, before the operation is applied. When desire, it is invoked when the
+	// stored models have been loaded. When report, it is invoked before the stored models are read, such that an
+	// altered operation is the one that is read and merged. The stored models are then only set in `After`.
+	//
+	// If it returns an error, the operation is short-circuited and the error is set on the result of the operation.
+	Before(ctx context.Context, call *InterceptCall) error
+	// After is invoked, in reverse registration order, for each operation when the result is known. It is invoked even
+	// if the operation was short-circuited or failed.
+	After(ctx context.Context, call *InterceptCall)
+}
+
+// InterceptorFuncs is a `Interceptor` made of functions. Both are optional.
+type InterceptorFuncs struct {
+	BeforeFunc func(ctx context.Context, call *InterceptCall) error
+	AfterFunc  func(ctx context.Context, call *InterceptCall)
+}
+
+func (f InterceptorFuncs) Before(ctx context.Context, call *InterceptCall) error {
+	if f.BeforeFunc == nil {
+		return nil
+	}
+
+	return f.BeforeFunc(ctx, call)
+}
+
+func (f InterceptorFuncs) After(ctx context.Context, call *InterceptCall) {
+	if f.AfterFunc != nil {
+		f.AfterFunc(ctx, call)
+	}
+}
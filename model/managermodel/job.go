@@ -0,0 +1,12 @@
+package managermodel
+
+// JobsID is the shadow id where the bulk desire jobs are persisted (see _manager/jobs_).
+const JobsID = "$jobs"
+
+// JobNameSuffix is appended to the job id to form the name of the persisted job. Those are persisted as a
+// separate desired model under `JobsID`.
+const JobNameSuffix = ":job"
+
+// JobPartNameSuffix is appended to the job id and part index to form the name of a persisted part of the job
+// progress. Those are persisted as separate desired models under `JobsID`.
+const JobPartNameSuffix = ":part"
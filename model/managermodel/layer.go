@@ -0,0 +1,5 @@
+package managermodel
+
+// LayersID is the shadow id where the fleet, group and device layers of the desired models are persisted (see
+// _manager/layers_).
+const LayersID = "$layers"
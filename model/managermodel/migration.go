@@ -0,0 +1,5 @@
+package managermodel
+
+// MigrationClientID is the client id that a `Read` writes a model with, when the model was migrated from an older
+// schema version (see `model.Schema`).
+const MigrationClientID = "migration"
@@ -16,6 +16,13 @@ type ReadOperation struct {
 	ModelType string
 	// Version can be set to only return a certain version. If set to _zero_ it will return the latest version.
 	Version int64
+	// AsOf can be set to return the version that was current at this Unix64 bit _UTC_ nanosecond timestamp. This
+	// requires a persistence that retains history, unless it is the latest version (see `persistencemodel.Retention`).
+	AsOf int64
+	// MarkDelivered shall be set when the device fetches the desired model. All pending desired values are then
+	// transitioned into `DesiredStateDelivered`. This is only used when the desired status tracking is enabled in
+	// the `Manager`. It is ignored when a `Version` or `AsOf` is read.
+	MarkDelivered bool
 }
 
 type ReadOperationResult struct {
@@ -32,6 +39,9 @@ type ReadOperationResult struct {
 	// TimeStamp is the timestamp of the model that was written. This is the main timestamp that gets updated
 	// each time a model was created or updated. It is a Unix64 bit _UTC_ nanosecond timestamp.
 	TimeStamp int64
+	// DesiredStatus is the lifecycle status, keyed by path, of the desired values. It is only set when reading a
+	// desired model and the desired status tracking is enabled in the `Manager`.
+	DesiredStatus map[string]DesiredStatus
 }
 
 // Receiver is a manager that allows clients to receive desired and reported states.
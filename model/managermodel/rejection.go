@@ -0,0 +1,44 @@
+package managermodel
+
+import (
+	"context"
+
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// RejectionsNameSuffix is appended to the shadow name to form the name of the rejection section. The section
+// is always persisted as a separate desired model.
+const RejectionsNameSuffix = ":rejections"
+
+// Rejection is a rejected desired value as persisted in the rejection section of a shadow.
+type Rejection struct {
+	model.RejectedDesire
+	// Value is the desired value (`model.ValueAndTimestamp.GetValue`) that was rejected.
+	Value any `json:"value,omitempty"`
+	// ClientID is the client ID of the report operation that rejected the value.
+	ClientID string `json:"client_id,omitempty"`
+	// TimeStamp is when the rejection was processed. It is a Unix64 bit _UTC_ nanosecond timestamp.
+	TimeStamp int64 `json:"ts"`
+}
+
+// Rejections is the rejection section of a shadow. Only the latest rejection per path is kept.
+type Rejections struct {
+	// Entries are the rejections keyed by the path of the rejected desired value.
+	Entries map[string]Rejection `json:"entries"`
+}
+
+// RejectionsID returns the persistence id of the rejection section for the shadow _id_.
+func RejectionsID(id persistencemodel.ID) persistencemodel.PersistenceID {
+	return persistencemodel.PersistenceID{
+		ID:        id.ID,
+		Name:      id.Name + RejectionsNameSuffix,
+		ModelType: persistencemodel.ModelTypeDesired,
+	}
+}
+
+// RejectionReceiver is a manager that allows clients to read the rejection section of a shadow.
+type RejectionReceiver interface {
+	// ReadRejections returns the rejection section of the shadow _id_. If none, it returns an empty `Rejections`.
+	ReadRejections(ctx context.Context, id persistencemodel.ID) (Rejections, error)
+}
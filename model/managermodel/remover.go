@@ -22,6 +22,9 @@ type DeleteOperationOperationResult struct {
 	//
 	// When error, only ID and this property may be valid
 	Error error
+	// SectionError is set when the shadow was deleted but any of its sections, e.g. the rejections, could not be
+	// deleted.
+	SectionError error
 }
 
 // Remover is the interface that a model manager that can delete models must implement.
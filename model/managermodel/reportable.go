@@ -11,8 +11,11 @@ import (
 type ReportOperation struct {
 	// ClientID is a optional client ID.
 	ClientID string
+	// ClientToken is a optional unique token of the operation. When the `Manager` has idempotency enabled, a report
+	// with a token that was recently applied on the shadow is not applied again. Instead the result has `Duplicate` set.
+	ClientToken string
 	// Version when set to zero -> report to latest version. Otherwise, it expects the specific version in persistence and if not,
-	// it will fail with 409 (Conflict).
+	// it will fail with 409 (Conflict). Within a batch, only the operations with a stale version fail.
 	Version int64
 	// Model to report. If any desired values, those will be checked and acknowledged if matched.
 	Model any
@@ -35,6 +38,17 @@ type ReportOperation struct {
 	// TIP: This is useful when removal of items in the reported model is wanted. When `merge.ServerIsMaster` is used, it will only
 	// upsert the model. When `merge.ClientIsMaster` is used, it will add, remove and update items.
 	MergeMode merge.MergeMode
+	// RejectedDesires are desired values that the device refuses to apply. Those are removed from the desired model
+	// and persisted in the rejection section of the shadow (see `RejectionReceiver`).
+	RejectedDesires []model.RejectedDesire
+	// DefaultAckPolicy is the policy used to acknowledge desired values when neither `AckPolicies` nor a struct
+	// tag selects one. Default is `merge.AckOnEqual`.
+	DefaultAckPolicy merge.AckPolicy
+	// AckPolicies are acknowledge policies per path (or glob) of the desired values (see `merge.DesiredOptions.AckPolicies`).
+	AckPolicies map[string]merge.AckPolicy
+	// Preconditions are checked against the stored reported model before the merge. If any does not hold, the
+	// operation fails with 412 (Precondition Failed).
+	Preconditions []model.Precondition
 }
 
 type ReportOperationResult struct {
@@ -60,6 +74,17 @@ type ReportOperationResult struct {
 	ReportModel any
 	// DesiredModel is the resulting model after acknowledge operation of the desired model
 	DesiredModel any
+	// Stats is a summary of the reported merge and the number of acknowledged and rejected desired values.
+	Stats merge.Stats
+	// Rejected are the rejections that was applied on the desired model. If the rejection section could not be
+	// persisted, the `SectionError` is set.
+	Rejected []Rejection
+	// SectionError is set when the models were persisted but a section of the shadow, e.g. the rejections or the
+	// desired status, could not be written. The operation is applied and shall not be re-tried.
+	SectionError error
+	// Duplicate is set when the `ReportOperation.ClientToken` was already applied. Only the `ReportedProcessed`
+	// and `DesiredProcessed` of the original operation is returned and nothing is merged nor logged.
+	Duplicate bool
 }
 
 type Reportable interface {
@@ -0,0 +1,75 @@
+package managermodel
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// RollbackClientIDPrefix is the prefix of the client id that a rollback writes with. It is followed by the
+// version that was restored, e.g. _rollback:12_, and hence recorded as the client token of the new version.
+const RollbackClientIDPrefix = "rollback:"
+
+// RollbackClientID returns the client id that a rollback to _version_ writes with.
+func RollbackClientID(version int64) string {
+	return fmt.Sprintf("%s%d", RollbackClientIDPrefix, version)
+}
+
+// RestoredFrom returns the version that was restored if the _clientID_ (or client token) is of a rollback.
+func RestoredFrom(clientID string) (int64, bool) {
+	s, ok := strings.CutPrefix(clientID, RollbackClientIDPrefix)
+
+	if !ok {
+		return 0, false
+	}
+
+	v, err := strconv.ParseInt(s, 10, 64)
+
+	return v, err == nil
+}
+
+// RollbackOperation is the operation passed to the interceptors when `InterceptRollback`.
+type RollbackOperation struct {
+	// ID is the id of the shadow.
+	ID persistencemodel.ID
+	// ModelType is the model that is rolled back.
+	ModelType persistencemodel.ModelType
+	// ToVersion is the version to restore.
+	ToVersion int64
+}
+
+// RollbackResult is the result of a `Rollbacker.Rollback`.
+type RollbackResult struct {
+	// ID is the id, including the model type, of the rolled back model.
+	ID persistencemodel.PersistenceID
+	// Version is the new version of the model. If the model already was at the restored version, it is the
+	// current version and nothing is written.
+	Version int64
+	// TimeStamp is the timestamp of the write. It is a Unix64 bit _UTC_ nanosecond timestamp.
+	TimeStamp int64
+	// RestoredFrom is the version that was restored.
+	RestoredFrom int64
+	// Model is the restored model.
+	Model any
+	// MergeLoggers are the loggers that was notified with the difference between the current and restored model.
+	MergeLoggers []model.MergeLogger
+	// Stats is the statistics of the difference.
+	Stats merge.Stats
+	// Processed is `true` if a new version was written.
+	Processed bool
+}
+
+// Rollbacker is a manager that can restore a model to a previous version.
+type Rollbacker interface {
+	// Rollback writes the model _toVersion_ as a new version of the model. The persistence must retain the version,
+	// see `persistencemodel.Retention`. The write uses the current version and hence fails with 409 if the model
+	// was changed in between.
+	//
+	// The configured merge loggers are notified with the difference between the current and the restored model.
+	Rollback(ctx context.Context, id persistencemodel.ID, modelType persistencemodel.ModelType, toVersion int64) (RollbackResult, error)
+}
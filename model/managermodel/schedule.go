@@ -0,0 +1,108 @@
+package managermodel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/merge"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/copyutils"
+)
+
+// SchedulesID is the shadow id where the scheduled desire operations are persisted.
+const SchedulesID = "$schedules"
+
+// ScheduleNameSuffix is appended to the schedule id to form the name of the persisted schedule. Those are persisted
+// as a separate desired model under `SchedulesID`.
+const ScheduleNameSuffix = ":schedule"
+
+// MinRecurrence is the shortest `Schedule.Recurrence`.
+const MinRecurrence = time.Second
+
+// Schedule is a desire operation that is applied at its activation time and, when recurring, again each
+// `Recurrence`. Only the serializable parts of the `DesireOperation` is kept.
+type Schedule struct {
+	// ID is the unique id of the schedule.
+	ID string `json:"id"`
+	// Shadow is the shadow to desire.
+	Shadow persistencemodel.ID `json:"shadow"`
+	// ClientID is the client id of the desire operation.
+	ClientID string `json:"client_id,omitempty"`
+	// ModelType is the registered name of the model. It is used to restore the `Model` when the schedule is read
+	// from a persistence that serializes the model.
+	ModelType string `json:"model_type,omitempty"`
+	// Model is the desired model to merge into the shadow.
+	Model any `json:"model"`
+	// MergeMode is the merge mode to use.
+	MergeMode merge.MergeMode `json:"merge_mode,omitempty"`
+	// Expiry is the `DesireOperation.Expiry`.
+	Expiry time.Duration `json:"expiry,omitempty"`
+	// ActivateAt is the next activation. It is a Unix64 bit _UTC_ nanosecond timestamp.
+	ActivateAt int64 `json:"activate_at"`
+	// Recurrence when set, the schedule is applied again each interval. Otherwise it is removed when applied.
+	Recurrence time.Duration `json:"recurrence,omitempty"`
+	// Runs is the number of times the schedule has been applied.
+	Runs int `json:"runs,omitempty"`
+	// LastRunAt is when the schedule was last applied. It is a Unix64 bit _UTC_ nanosecond timestamp.
+	LastRunAt int64 `json:"last_run_at,omitempty"`
+	// LastError is the error of the last run, if any.
+	LastError string `json:"last_error,omitempty"`
+	// CreatedAt is when the schedule was created. It is a Unix64 bit _UTC_ nanosecond timestamp.
+	CreatedAt int64 `json:"created_at"`
+}
+
+// Operation creates the desire operation of the next run with a copy of the `Model`. The client token is unique
+// per run, i.e. a run is not applied twice when the manager has idempotency enabled. Each run has precedence
+// over the current desired values, regardless of the timestamps in the `Model`.
+func (s Schedule) Operation() DesireOperation {
+	return DesireOperation{
+		ClientID:         s.ClientID,
+		ClientToken:      fmt.Sprintf("%s:%d", s.ID, s.Runs+1),
+		ID:               s.Shadow,
+		ModelType:        s.ModelType,
+		Model:            copyutils.DeepCopy(s.Model),
+		MergeMode:        s.MergeMode,
+		IgnoreTimestamps: true,
+		Expiry:           s.Expiry,
+	}
+}
+
+// Due returns `true` if the schedule shall be applied at _now_.
+func (s Schedule) Due(now time.Time) bool {
+	return s.ActivateAt <= now.UTC().UnixNano()
+}
+
+// Next moves the `ActivateAt` of a recurring schedule to the first activation after _now_, i.e. all missed
+// activations are skipped.
+func (s *Schedule) Next(now time.Time) {
+	if s.Recurrence <= 0 || !s.Due(now) {
+		return
+	}
+
+	missed := (now.UTC().UnixNano()-s.ActivateAt)/int64(s.Recurrence) + 1
+	s.ActivateAt += missed * int64(s.Recurrence)
+}
+
+// ScheduleID returns the persistence id of the schedule with _scheduleID_.
+func ScheduleID(scheduleID string) persistencemodel.PersistenceID {
+	return persistencemodel.PersistenceID{
+		ID:        SchedulesID,
+		Name:      scheduleID + ScheduleNameSuffix,
+		ModelType: persistencemodel.ModelTypeDesired,
+	}
+}
+
+// Scheduler is a manager that keeps the desire operations with a `DesireOperation.ActivateAt` until they are due.
+type Scheduler interface {
+	// ListSchedules returns all pending schedules, ordered by activation time. If any _shadows_, only the schedules
+	// of those are returned.
+	ListSchedules(ctx context.Context, shadows ...persistencemodel.ID) ([]Schedule, error)
+	// ReadSchedule reads the schedule with _scheduleID_. If not found, a 404 error is returned.
+	ReadSchedule(ctx context.Context, scheduleID string) (Schedule, error)
+	// CancelSchedule removes the schedule with _scheduleID_. If not found, a 404 error is returned.
+	CancelSchedule(ctx context.Context, scheduleID string) error
+	// ApplySchedules desires all schedules that are due at _now_ and returns them as updated by the run. A recurring
+	// schedule is moved to its next activation after _now_ and the others are removed.
+	ApplySchedules(ctx context.Context, now time.Time) ([]Schedule, error)
+}
@@ -0,0 +1,135 @@
+package managermodel
+
+import (
+	"context"
+
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// DesiredStatusNameSuffix is appended to the shadow name to form the name of the desired status section. The
+// section is always persisted as a separate desired model.
+const DesiredStatusNameSuffix = ":status"
+
+// DesiredState is the lifecycle state of a desired value.
+type DesiredState string
+
+const (
+	// DesiredStatePending is when the value has been desired but not yet fetched by the device.
+	DesiredStatePending DesiredState = "pending"
+	// DesiredStateDelivered is when the device has fetched the desired model.
+	DesiredStateDelivered DesiredState = "delivered"
+	// DesiredStateAcknowledged is when the device reported a value equal to the desired value.
+	DesiredStateAcknowledged DesiredState = "acknowledged"
+	// DesiredStateExpired is when the value was removed from the desired model or passed its expiry
+	// before it was acknowledged or rejected.
+	DesiredStateExpired DesiredState = "expired"
+	// DesiredStateRejected is when the device did reject the desired value.
+	DesiredStateRejected DesiredState = "rejected"
+)
+
+// Final returns `true` if no more transitions are expected unless the value is desired again.
+func (s DesiredState) Final() bool {
+	return s == DesiredStateAcknowledged || s == DesiredStateExpired || s == DesiredStateRejected
+}
+
+// DesiredTransition is a single state transition of a desired value.
+type DesiredTransition struct {
+	// State is the state that was entered.
+	State DesiredState `json:"state"`
+	// TimeStamp is when the state was entered. It is a Unix64 bit _UTC_ nanosecond timestamp.
+	TimeStamp int64 `json:"ts"`
+}
+
+// DesiredStatus is the lifecycle status of a single desired value.
+type DesiredStatus struct {
+	// State is the current state.
+	State DesiredState `json:"state"`
+	// Value is the desired value (`model.ValueAndTimestamp.GetValue`).
+	Value any `json:"value,omitempty"`
+	// ClientID is the client ID of the operation that did the last transition.
+	ClientID string `json:"client_id,omitempty"`
+	// ExpiresAt is when a pending or delivered value expires. Zero is never. It is a Unix64 bit _UTC_
+	// nanosecond timestamp.
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+	// Rejection is set when the state is `DesiredStateRejected`.
+	Rejection *model.RejectedDesire `json:"rejection,omitempty"`
+	// Transitions are all transitions, in order, since the value was last desired.
+	Transitions []DesiredTransition `json:"transitions"`
+}
+
+// Transition will enter the _state_ at _ts_ (Unix64 bit _UTC_ nanosecond timestamp).
+func (s *DesiredStatus) Transition(state DesiredState, ts int64) {
+	s.State = state
+	s.Transitions = append(s.Transitions, DesiredTransition{State: state, TimeStamp: ts})
+}
+
+// TimeStamp returns when the _state_ was last entered.
+func (s DesiredStatus) TimeStamp(state DesiredState) (int64, bool) {
+	for i := len(s.Transitions) - 1; i >= 0; i-- {
+		if s.Transitions[i].State == state {
+			return s.Transitions[i].TimeStamp, true
+		}
+	}
+
+	return 0, false
+}
+
+// DesiredStatuses is the desired status section of a shadow.
+type DesiredStatuses struct {
+	// Entries are the statuses keyed by the path of the desired value.
+	Entries map[string]DesiredStatus `json:"entries"`
+}
+
+// Expire transitions all pending or delivered entries that has passed their expiry, relative _now_, into
+// `DesiredStateExpired`. It returns `true` if any entry was expired.
+func (ds DesiredStatuses) Expire(now int64) bool {
+	var expired bool
+
+	for path, s := range ds.Entries {
+		if s.State.Final() || s.ExpiresAt == 0 || s.ExpiresAt > now {
+			continue
+		}
+
+		s.Transition(DesiredStateExpired, s.ExpiresAt)
+		ds.Entries[path] = s
+		expired = true
+	}
+
+	return expired
+}
+
+// WithState returns all entries that are in any of the _states_.
+func (ds DesiredStatuses) WithState(states ...DesiredState) map[string]DesiredStatus {
+	res := map[string]DesiredStatus{}
+
+	for path, s := range ds.Entries {
+		for _, st := range states {
+			if s.State == st {
+				res[path] = s
+				break
+			}
+		}
+	}
+
+	return res
+}
+
+// DesiredStatusID returns the persistence id of the desired status section for the shadow _id_.
+func DesiredStatusID(id persistencemodel.ID) persistencemodel.PersistenceID {
+	return persistencemodel.PersistenceID{
+		ID:        id.ID,
+		Name:      id.Name + DesiredStatusNameSuffix,
+		ModelType: persistencemodel.ModelTypeDesired,
+	}
+}
+
+// DesiredStatusReceiver is a manager that allows clients to query the lifecycle status of desired values.
+type DesiredStatusReceiver interface {
+	// ReadDesiredStatus returns the desired status section of the shadow _id_. If none, it returns an empty
+	// `DesiredStatuses`.
+	ReadDesiredStatus(ctx context.Context, id persistencemodel.ID) (DesiredStatuses, error)
+	// ReadDesiredStatuses returns the desired status section of each shadow in _ids_ using a single read. A shadow
+	// without a section has an empty `DesiredStatuses`.
+	ReadDesiredStatuses(ctx context.Context, ids ...persistencemodel.ID) (map[persistencemodel.ID]DesiredStatuses, error)
+}
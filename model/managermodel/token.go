@@ -0,0 +1,74 @@
+package managermodel
+
+import (
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// ClientTokensNameSuffix is appended to the shadow name to form the name of the client token section. The section
+// is always persisted as a separate desired model.
+const ClientTokensNameSuffix = ":tokens"
+
+// DefaultTokenWindow is the number of client tokens kept per shadow when no size is given.
+const DefaultTokenWindow = 32
+
+// AppliedToken is the client token of a report or desire that was applied on the shadow.
+type AppliedToken struct {
+	// Token is the `ReportOperation.ClientToken` or `DesireOperation.ClientToken`.
+	Token string `json:"token"`
+	// Kind is either `InterceptReport` or `InterceptDesire`.
+	Kind InterceptKind `json:"kind"`
+	// ReportedProcessed is set when the reported model was persisted by the operation.
+	ReportedProcessed bool `json:"rp,omitempty"`
+	// DesiredProcessed is set when the desired model was persisted by the operation.
+	DesiredProcessed bool `json:"dp,omitempty"`
+	// Version is the version written by a desire operation.
+	Version int64 `json:"version,omitempty"`
+	// TimeStamp is the timestamp of the write of a desire operation.
+	TimeStamp int64 `json:"ts,omitempty"`
+	// AppliedAt is when the operation was applied. It is a Unix64 bit _UTC_ nanosecond timestamp.
+	AppliedAt int64 `json:"applied_at"`
+}
+
+// ClientTokens is the client token section of a shadow. It holds the recently applied tokens, oldest first.
+type ClientTokens struct {
+	Entries []AppliedToken `json:"entries"`
+}
+
+// Find returns the applied _token_ of _kind_ if present.
+func (ct ClientTokens) Find(kind InterceptKind, token string) (AppliedToken, bool) {
+	for _, at := range ct.Entries {
+		if at.Kind == kind && at.Token == token {
+			return at, true
+		}
+	}
+
+	return AppliedToken{}, false
+}
+
+// Trim drops the entries that are older than _maxAge_ (if set) at _now_ and keeps at most the _size_ newest.
+func (ct *ClientTokens) Trim(size int, maxAge time.Duration, now int64) {
+	if maxAge > 0 {
+		n := 0
+
+		for n < len(ct.Entries) && now-ct.Entries[n].AppliedAt > int64(maxAge) {
+			n++
+		}
+
+		ct.Entries = ct.Entries[n:]
+	}
+
+	if size > 0 && len(ct.Entries) > size {
+		ct.Entries = ct.Entries[len(ct.Entries)-size:]
+	}
+}
+
+// ClientTokensID returns the persistence id of the client token section for the shadow _id_.
+func ClientTokensID(id persistencemodel.ID) persistencemodel.PersistenceID {
+	return persistencemodel.PersistenceID{
+		ID:        id.ID,
+		Name:      id.Name + ClientTokensNameSuffix,
+		ModelType: persistencemodel.ModelTypeDesired,
+	}
+}
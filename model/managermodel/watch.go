@@ -0,0 +1,62 @@
+package managermodel
+
+import (
+	"context"
+
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// WatchPolicy is what happens when a watcher does not keep up with the events.
+type WatchPolicy int
+
+const (
+	// WatchDrop drops the event when the buffer is full. The next delivered event has `WatchEvent.Dropped` set.
+	// This is the default.
+	WatchDrop WatchPolicy = 0
+	// WatchBlock blocks the writer until the event is consumed or the watch is cancelled.
+	WatchBlock WatchPolicy = 1
+)
+
+// DefaultWatchBufferSize is the buffer size used when `WatchFilter.BufferSize` is not set.
+const DefaultWatchBufferSize = 16
+
+// WatchFilter controls which events are emitted to a watcher and how.
+type WatchFilter struct {
+	// ModelType is the model type to watch. If _zero_ both reported and desired are watched.
+	ModelType persistencemodel.ModelType
+	// BufferSize is the size of the event channel. Default is `DefaultWatchBufferSize`.
+	BufferSize int
+	// Policy is what happens when the watcher is slow. Default is `WatchDrop`.
+	Policy WatchPolicy
+}
+
+// WatchEvent is emitted after a model was successfully written.
+type WatchEvent struct {
+	// Kind is the operation that changed the model, i.e. `InterceptReport` or `InterceptDesire`.
+	Kind InterceptKind
+	// ID is the id, including the model type, of the written model.
+	ID persistencemodel.PersistenceID
+	// ClientID is the client id of the (last) operation that did write the model.
+	ClientID string
+	// Version is the new version of the model.
+	Version int64
+	// TimeStamp is the timestamp of the write. It is a Unix64 bit _UTC_ nanosecond timestamp.
+	TimeStamp int64
+	// Model is the written model.
+	Model any
+	// MergeLoggers are the loggers of all operations that did contribute to the write e.g. the `changelogger`.
+	MergeLoggers []model.MergeLogger
+	// DesiredLoggers are the desired loggers of all report operations that did contribute to the write.
+	DesiredLoggers []model.DesiredLogger
+	// Dropped is the number of events that was dropped, since the previous delivered event, due to a slow
+	// consumer when `WatchDrop`.
+	Dropped int64
+}
+
+// Watcher is a manager that emits change events in process.
+type Watcher interface {
+	// Watch returns a channel of events for the shadow _id_. The _id_ may contain `*` and `?` wildcards, e.g.
+	// _device-*_, where an empty id or name matches all. The channel is closed when _ctx_ is done.
+	Watch(ctx context.Context, id persistencemodel.ID, filter WatchFilter) <-chan WatchEvent
+}
@@ -14,6 +14,12 @@ const (
 	OperationTypeReport  NotifierOperationType = "report"
 	OperationTypeDesired NotifierOperationType = "desired"
 	OperationTypeDelete  NotifierOperationType = "delete"
+	// OperationTypeRejected is when a device did reject one or more desired values. The rejected values
+	// are found in `DesireLogger.Rejected`.
+	OperationTypeRejected NotifierOperationType = "rejected"
+	// OperationTypeAlarm is when a alarm instance did change state. The `managermodel.Alarm` and the previous state
+	// are found in `Custom`.
+	OperationTypeAlarm NotifierOperationType = "alarm"
 )
 
 type NotifierOperation struct {
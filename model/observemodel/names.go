@@ -0,0 +1,62 @@
+package observemodel
+
+// Span names
+const (
+	// SpanManager is prefixed to the operation, e.g. _manager.report_.
+	SpanManager = "manager."
+	// SpanPersistence is prefixed to the operation, e.g. _persistence.read_.
+	SpanPersistence = "persistence."
+	// SpanNotifier is the span of `Notifier.Process`.
+	SpanNotifier = "notifier.process"
+)
+
+// Metric names
+const (
+	// ManagerOperationDuration is the histogram of the duration of a manager operation.
+	ManagerOperationDuration = "manager.operation.duration"
+	// ManagerOperations is the number of operations, e.g. each `ReportOperation`, by outcome.
+	ManagerOperations = "manager.operations"
+	// ManagerMergeDuration is the histogram of the duration of the merge of a single operation.
+	ManagerMergeDuration = "manager.merge.duration"
+	// ManagerConflicts is the number of operations that failed with 409 (Conflict).
+	ManagerConflicts = "manager.conflicts"
+	// ManagerDuplicates is the number of operations that was a duplicate of an already applied client token.
+	ManagerDuplicates = "manager.duplicates"
+	// PersistenceOperationDuration is the histogram of the duration of a persistence operation.
+	PersistenceOperationDuration = "persistence.operation.duration"
+	// PersistenceBatchSize is the histogram of the number of items in a persistence call or a backend batch.
+	PersistenceBatchSize = "persistence.batch.size"
+	// PersistenceRetries is the number of retried backend requests.
+	PersistenceRetries = "persistence.retries"
+	// PersistenceUnprocessed is the number of unprocessed keys or items returned from the backend.
+	PersistenceUnprocessed = "persistence.unprocessed"
+	// PersistenceErrors is the number of failed items.
+	PersistenceErrors = "persistence.errors"
+	// NotifierDuration is the histogram of the duration of `Notifier.Process`.
+	NotifierDuration = "notifier.duration"
+	// NotifierNotifications is the number of notified operations per target.
+	NotifierNotifications = "notifier.notifications"
+	// NotifierErrors is the number of failed notifications per target.
+	NotifierErrors = "notifier.errors"
+)
+
+// Attribute keys
+const (
+	// AttrOperation is e.g. _report_, _desire_, _read_, _write_.
+	AttrOperation = "operation"
+	// AttrOutcome is either _ok_ or _error_.
+	AttrOutcome = "outcome"
+	// AttrPersistence is the persistence, e.g. _memory_ or _dynamodb_.
+	AttrPersistence = "persistence"
+	// AttrTarget is the name of the notification target.
+	AttrTarget = "target"
+)
+
+// Outcome returns _ok_ or _error_ depending on _err_.
+func Outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+
+	return "ok"
+}
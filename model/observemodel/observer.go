@@ -0,0 +1,72 @@
+package observemodel
+
+import (
+	"context"
+	"time"
+)
+
+// Attribute is a key value pair that is attached to a span or a measurement.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Attr creates a `Attribute`.
+func Attr(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Observer receives spans, counters and histograms from the manager, persistence and notifier. Implementations
+// adapts to a metrics and / or tracing backend and must be safe for concurrent use.
+//
+// The names are dotted, e.g. _manager.operation.duration_, and durations are in seconds.
+type Observer interface {
+	// Start starts a span named _name_ and returns a context that carries it and the span. The span is ended by
+	// the caller.
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+	// Count adds _delta_ to the counter _name_.
+	Count(ctx context.Context, name string, delta int64, attrs ...Attribute)
+	// Record records the _value_ in the histogram _name_.
+	Record(ctx context.Context, name string, value float64, attrs ...Attribute)
+}
+
+// Span is a started span.
+type Span interface {
+	// SetAttributes adds the _attrs_ to the span.
+	SetAttributes(attrs ...Attribute)
+	// End ends the span, if _err_ is not `nil` the span is marked as failed.
+	End(err error)
+}
+
+// Nop is a `Observer` that discards everything. It is used when no observer is set.
+type Nop struct{}
+
+// Start implements the `Observer` interface.
+func (Nop) Start(ctx context.Context, _ string, _ ...Attribute) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+// Count implements the `Observer` interface.
+func (Nop) Count(context.Context, string, int64, ...Attribute) {}
+
+// Record implements the `Observer` interface.
+func (Nop) Record(context.Context, string, float64, ...Attribute) {}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttributes(...Attribute) {}
+func (nopSpan) End(error)                  {}
+
+// OrNop returns the _observer_ or a `Nop` if `nil`.
+func OrNop(observer Observer) Observer {
+	if observer == nil {
+		return Nop{}
+	}
+
+	return observer
+}
+
+// Since records the seconds since _start_ in the histogram _name_.
+func Since(ctx context.Context, observer Observer, name string, start time.Time, attrs ...Attribute) {
+	observer.Record(ctx, name, time.Since(start).Seconds(), attrs...)
+}
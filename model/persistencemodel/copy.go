@@ -0,0 +1,30 @@
+package persistencemodel
+
+import "context"
+
+// CopyOperation copies the model at `From` to `To`.
+type CopyOperation struct {
+	// ClientID is a optional clientID that is set on the written model (if `Persistence` supports it).
+	ClientID string
+	// From is the model to copy. Use the `ModelType` of _zero_ for a combined model.
+	From PersistenceID
+	// To is where the model is copied to. It must have the same `ModelType` as `From` and it must not exist.
+	To PersistenceID
+	// Version is the expected version of `From`. If _zero_ any version is copied.
+	Version int64
+	// Move deletes `From` when copied.
+	Move bool
+	// Optional skips the operation, instead of failing all, when `From` is not found. The result has a 404 error.
+	Optional bool
+}
+
+// Copier is implemented by a `Persistence` that can copy, or move, models atomically.
+type Copier interface {
+	// Copy copies, or moves, the models in one atomic operation. If any operation fails, none is applied and all
+	// results have an error. If a `To` already exists it fails with 409 (Conflict).
+	//
+	// The copied model starts at version one. Retained versions, if any, are not copied.
+	//
+	// It will return the same amount of results as the operations, where the `WriteResult.ID` is the `To`.
+	Copy(ctx context.Context, opt WriteOptions, operations ...CopyOperation) []WriteResult
+}
@@ -22,6 +22,13 @@ func Error400(message string, custom ...int) PersistenceError {
 	return PersistenceError{Code: 400, Message: message}
 }
 
+func Error403(message string, custom ...int) PersistenceError {
+	if len(custom) > 0 {
+		return PersistenceError{Code: 403, Custom: custom[0], Message: message}
+	}
+	return PersistenceError{Code: 403, Message: message}
+}
+
 func Error404(message string, custom ...int) PersistenceError {
 	if len(custom) > 0 {
 		return PersistenceError{Code: 404, Custom: custom[0], Message: message}
@@ -36,6 +43,27 @@ func Error409(message string, custom ...int) PersistenceError {
 	return PersistenceError{Code: 409, Message: message}
 }
 
+func Error412(message string, custom ...int) PersistenceError {
+	if len(custom) > 0 {
+		return PersistenceError{Code: 412, Custom: custom[0], Message: message}
+	}
+	return PersistenceError{Code: 412, Message: message}
+}
+
+func Error413(message string, custom ...int) PersistenceError {
+	if len(custom) > 0 {
+		return PersistenceError{Code: 413, Custom: custom[0], Message: message}
+	}
+	return PersistenceError{Code: 413, Message: message}
+}
+
+func Error422(message string, custom ...int) PersistenceError {
+	if len(custom) > 0 {
+		return PersistenceError{Code: 422, Custom: custom[0], Message: message}
+	}
+	return PersistenceError{Code: 422, Message: message}
+}
+
 func Error500(message string, custom ...int) PersistenceError {
 
 	if len(custom) > 0 {
@@ -0,0 +1,33 @@
+package persistencemodel
+
+import (
+	"context"
+	"time"
+)
+
+// Retention controls how many previous versions a `Persistence` retains. When both are set, a version is
+// dropped as soon as any of them no longer holds. When none is set, no history is retained.
+type Retention struct {
+	// MaxVersions is the number of versions, including the current, to retain.
+	MaxVersions int `json:"max_versions,omitempty"`
+	// MaxAge is the duration a version is retained after it was written.
+	MaxAge time.Duration `json:"max_age,omitempty"`
+}
+
+// Enabled returns `true` if any history is retained.
+func (r Retention) Enabled() bool {
+	return r.MaxVersions > 0 || r.MaxAge > 0
+}
+
+// Retain returns `true` if the version with _age_ and _index_ (zero is the newest) shall be retained.
+func (r Retention) Retain(index int, age time.Duration) bool {
+	return r.Enabled() && (r.MaxVersions <= 0 || index < r.MaxVersions) && (r.MaxAge <= 0 || age <= r.MaxAge)
+}
+
+// HistoryReader is implemented by a `Persistence` that retains previous versions of the models. Those may be read
+// using `ReadOperation.Version` or `ReadOperation.AsOf`.
+type HistoryReader interface {
+	// History returns the retained versions of the model _id_, newest first. Use the `ModelType` of _zero_ for a
+	// combined model. If none is retained, an empty list is returned.
+	History(ctx context.Context, id PersistenceID) ([]ListResult, error)
+}
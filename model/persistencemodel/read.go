@@ -3,6 +3,8 @@ package persistencemodel
 import (
 	"context"
 	"reflect"
+
+	"github.com/mariotoffia/godeviceshadow/model"
 )
 
 // ReadonlyPersistence is when a persistence only allows for listing and reading of models but no writes.
@@ -40,7 +42,16 @@ type ReadOperation struct {
 	// Version is the version of the model that will be read. If 0 or less it will be ignored,
 	// otherwise it will only return the model with the version that matches the `Version`
 	// or a `PersistenceError` with code 404 (Not Found) is returned.
+	//
+	// When the `Persistence` retains history (see `HistoryReader`), any retained version may be read.
 	Version int64
+	// AsOf when set, reads the version that was current at this Unix64 bit _UTC_ nanosecond timestamp. If no such
+	// version is retained, a `PersistenceError` with code 404 (Not Found) is returned. It is ignored when `Version`
+	// is set.
+	AsOf int64
+	// Schema is the schema of the `Model`. When the model was persisted with an older schema version, the persisted
+	// data is migrated before it is decoded into the `Model`.
+	Schema model.Schema
 }
 
 // ReadConfig is the configuration for the `Persistence.Read` operation.
@@ -113,6 +124,9 @@ type ReadResult struct {
 	TimeStamp int64
 	// ClientToken is the last client token write operation (if any)
 	ClientToken string
+	// SchemaVersion is the schema version the model was persisted with. If older than `ReadOperation.Schema`, the
+	// `Model` was migrated.
+	SchemaVersion int
 	// Error is set when the operation failed.
 	Error error
 }
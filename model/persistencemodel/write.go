@@ -65,6 +65,9 @@ type WriteOperation struct {
 	//
 	// The version will always be updated with 1 when the model was successfully written.
 	Version int64
+	// SchemaVersion is the schema version of the `Model` that is persisted together with the model. When combined
+	// models, both shall have the same schema version.
+	SchemaVersion int
 	// Config is where any common or `Persistence` specific configuration is set.
 	Config WriteOperationConfig
 }
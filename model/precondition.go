@@ -0,0 +1,122 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// PreconditionOperator is how a `Precondition` is checked against the stored value.
+type PreconditionOperator int
+
+const (
+	// PreconditionEquals requires the stored value at the path to be equal to `Precondition.Value`.
+	PreconditionEquals PreconditionOperator = 1
+	// PreconditionNotEquals requires the stored value at the path to be absent or not equal to `Precondition.Value`.
+	PreconditionNotEquals PreconditionOperator = 2
+	// PreconditionPresent requires a value to be present at the path.
+	PreconditionPresent PreconditionOperator = 3
+	// PreconditionAbsent requires no value to be present at the path.
+	PreconditionAbsent PreconditionOperator = 4
+)
+
+func (op PreconditionOperator) String() string {
+	switch op {
+	case PreconditionEquals:
+		return "equals"
+	case PreconditionNotEquals:
+		return "not-equals"
+	case PreconditionPresent:
+		return "present"
+	case PreconditionAbsent:
+		return "absent"
+	}
+
+	return fmt.Sprintf("precondition operator id: %d", int(op))
+}
+
+// Precondition is a compare-and-set predicate that is checked against the stored model before an operation
+// is applied. If it does not hold, the operation fails.
+type Precondition struct {
+	// Path is the path to the value, rendered in the same style as the loggers (default dotted) e.g.
+	// _climate.sensors.indoor.sp_. A stored value, including a zero value e.g. an empty string, is present. The path
+	// is absent when it does not exist or a pointer, interface, map or slice along it is `nil`. Hence, optional values
+	// shall be pointers, maps or slices to be tested with `PreconditionAbsent`.
+	Path string `json:"path"`
+	// Operator is how the stored value is checked.
+	Operator PreconditionOperator `json:"op"`
+	// Value is the value to compare with when `PreconditionEquals` or `PreconditionNotEquals`. If it is a
+	// `ValueAndTimestamp`, the `GetValue` is compared (the timestamp is ignored).
+	Value any `json:"value,omitempty"`
+	// Predicate is optional and when set it is used instead of the `Operator`. The _value_ is the stored value
+	// (a `ValueAndTimestamp` when managed) and _present_ is `true` when there is a value at the path.
+	Predicate func(value any, present bool) bool `json:"-"`
+}
+
+// Check checks the precondition against the _values_ keyed by path (see `merge.Values`). Since those do not
+// contain zero values, use `Holds` with the value of `merge.Lookup` to check a stored model.
+func (p Precondition) Check(values map[string]any) bool {
+	value, present := lookupPath(values, p.Path)
+
+	return p.Holds(value, present)
+}
+
+// Holds checks the precondition against the stored _value_ at the path. The _present_ is `true` when there is a
+// value at the path (see `merge.Lookup`).
+func (p Precondition) Holds(value any, present bool) bool {
+	if p.Predicate != nil {
+		return p.Predicate(value, present)
+	}
+
+	switch p.Operator {
+	case PreconditionEquals:
+		return present && equalsPlain(value, p.Value)
+	case PreconditionNotEquals:
+		return !present || !equalsPlain(value, p.Value)
+	case PreconditionPresent:
+		return present
+	case PreconditionAbsent:
+		return !present
+	}
+
+	return false
+}
+
+func (p Precondition) String() string {
+	if p.Predicate != nil {
+		return fmt.Sprintf("%s matches predicate", p.Path)
+	}
+
+	if p.Operator == PreconditionEquals || p.Operator == PreconditionNotEquals {
+		return fmt.Sprintf("%s %s %v", p.Path, p.Operator, p.Value)
+	}
+
+	return fmt.Sprintf("%s %s", p.Path, p.Operator)
+}
+
+// lookupPath returns the value at _path_. If not a leaf, it is present when any path beneath it is present.
+func lookupPath(values map[string]any, path string) (any, bool) {
+	if v, ok := values[path]; ok {
+		return v, true
+	}
+
+	for p := range values {
+		if len(p) > len(path) && strings.HasPrefix(p, path) && strings.ContainsRune("./[", rune(p[len(path)])) {
+			return nil, true
+		}
+	}
+
+	return nil, false
+}
+
+func equalsPlain(stored, expected any) bool {
+	if vt, ok := stored.(ValueAndTimestamp); ok {
+		stored = vt.GetValue()
+	}
+
+	if vt, ok := expected.(ValueAndTimestamp); ok {
+		expected = vt.GetValue()
+	}
+
+	return reflect.DeepEqual(stored, expected)
+}
@@ -0,0 +1,13 @@
+package model
+
+// RejectedDesire is when a device refuses to apply a desired value, e.g. a set-point that is out of
+// range for the device.
+type RejectedDesire struct {
+	// Path is the path to the desired value that is rejected. It must be rendered in the same style
+	// as the desired walk (default dotted) e.g. _climate.sensors.indoor.sp_.
+	Path string `json:"path"`
+	// Code is a reason code such as _out-of-range_ or _not-supported_.
+	Code string `json:"code"`
+	// Reason is a optional human readable reason.
+	Reason string `json:"reason,omitempty"`
+}
@@ -0,0 +1,40 @@
+package model
+
+import "fmt"
+
+// MigrateFunc migrates the persisted _data_ of a model one schema version. The _data_ is the model in the
+// representation of the `Persistence`, e.g. the JSON field names for `mempersistence`.
+type MigrateFunc func(data map[string]any) (map[string]any, error)
+
+// Schema is the schema version of a model type together with the migrations from the older versions.
+type Schema struct {
+	// Version is the current schema version. Zero is a unversioned model.
+	Version int
+	// Migrations are keyed by the version it migrates from, onto the next version. A version without migration is
+	// compatible with the next version.
+	Migrations map[int]MigrateFunc
+}
+
+// Outdated returns `true` if a model persisted with the schema _version_ needs to be migrated.
+func (s Schema) Outdated(version int) bool {
+	return version < s.Version
+}
+
+// Migrate migrates the _data_, persisted with the schema version _from_, onto the current `Version`.
+func (s Schema) Migrate(data map[string]any, from int) (map[string]any, error) {
+	for v := from; v < s.Version; v++ {
+		migrate, ok := s.Migrations[v]
+
+		if !ok {
+			continue
+		}
+
+		var err error
+
+		if data, err = migrate(data); err != nil {
+			return nil, fmt.Errorf("migration from schema version %d failed: %w", v, err)
+		}
+	}
+
+	return data, nil
+}
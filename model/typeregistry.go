@@ -10,6 +10,8 @@ type TypeEntry struct {
 	Name string
 	// Meta is a optional metadata key=value attached when registered.
 	Meta map[string]string
+	// Schema is the schema version of the model and the migrations from older versions.
+	Schema Schema
 }
 
 type TypeRegistry interface {
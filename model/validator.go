@@ -0,0 +1,7 @@
+package model
+
+// Validator may be implemented by a model or any of its values to validate itself before it is persisted.
+type Validator interface {
+	// Validate returns an error when the value is invalid. The error message is used as violation message.
+	Validate() error
+}
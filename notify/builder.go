@@ -1,10 +1,14 @@
 package notify
 
-import "github.com/mariotoffia/godeviceshadow/model/notifiermodel"
+import (
+	"github.com/mariotoffia/godeviceshadow/model/notifiermodel"
+	"github.com/mariotoffia/godeviceshadow/model/observemodel"
+)
 
 type MainBuilder struct {
-	targets []notifiermodel.SelectionTargetImpl
-	err     error
+	targets  []notifiermodel.SelectionTargetImpl
+	observer observemodel.Observer
+	err      error
 }
 
 func NewBuilder() *MainBuilder {
@@ -16,6 +20,12 @@ func (b *MainBuilder) AddSelectionTarget(target notifiermodel.SelectionTargetImp
 	return b
 }
 
+// WithObserver sets the observer that receives a span, the duration and the number of notifications of each `Process`.
+func (b *MainBuilder) WithObserver(observer observemodel.Observer) *MainBuilder {
+	b.observer = observer
+	return b
+}
+
 func (b *MainBuilder) TargetBuilder(target notifiermodel.NotificationTarget) *TargetBuilder {
 	return &TargetBuilder{
 		main:   b,
@@ -25,7 +35,8 @@ func (b *MainBuilder) TargetBuilder(target notifiermodel.NotificationTarget) *Ta
 
 func (b *MainBuilder) Build() *NotifierImpl {
 	return &NotifierImpl{
-		Targets: b.targets,
+		Targets:  b.targets,
+		Observer: b.observer,
 	}
 }
 
@@ -2,8 +2,10 @@ package notify
 
 import (
 	"context"
+	"time"
 
 	"github.com/mariotoffia/godeviceshadow/model/notifiermodel"
+	"github.com/mariotoffia/godeviceshadow/model/observemodel"
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
 )
 
@@ -19,6 +21,9 @@ type NotifierImpl struct {
 	//
 	// When no selection, it will just call the target with all operations.
 	Targets []notifiermodel.SelectionTargetImpl
+	// Observer is when set, receives a span and the duration of each `Process` and the number of notified and
+	// failed operations per target.
+	Observer observemodel.Observer
 }
 
 // Process implements the `notifiermodel.Notifier` interface and will process the operations and notify
@@ -27,7 +32,24 @@ func (n *NotifierImpl) Process(
 	ctx context.Context,
 	tx *persistencemodel.TransactionImpl,
 	operations ...notifiermodel.NotifierOperation,
-) []notifiermodel.NotifierOperationResult {
+) (res []notifiermodel.NotifierOperationResult) {
+	observer := observemodel.OrNop(n.Observer)
+	ctx, span := observer.Start(ctx, observemodel.SpanNotifier)
+	start := time.Now()
+
+	defer func() {
+		var first error
+
+		for _, r := range res {
+			if r.Error != nil && first == nil {
+				first = r.Error
+			}
+		}
+
+		observemodel.Since(ctx, observer, observemodel.NotifierDuration, start)
+		span.End(first)
+	}()
+
 	// for fast lookup
 	targets := make(map[string]notifiermodel.NotificationTarget, len(n.Targets))
 
@@ -58,12 +80,19 @@ func (n *NotifierImpl) Process(
 		size += len(operations)
 	}
 
-	res := make([]notifiermodel.NotifierOperationResult, 0, size)
+	res = make([]notifiermodel.NotifierOperationResult, 0, size)
 
 	for targetName, operations := range ops {
 		target := targets[targetName]
+		attr := observemodel.Attr(observemodel.AttrTarget, targetName)
+
+		observer.Count(ctx, observemodel.NotifierNotifications, int64(len(operations)), attr)
 
 		for _, tr := range target.Notify(ctx, tx, operations...) {
+			if tr.Error != nil {
+				observer.Count(ctx, observemodel.NotifierErrors, 1, attr)
+			}
+
 			res = append(res, notifiermodel.NotifierOperationResult{
 				Target:    tr.Target,
 				Operation: tr.Operation,
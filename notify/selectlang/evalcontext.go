@@ -5,9 +5,10 @@ import (
 	"github.com/mariotoffia/godeviceshadow/model/notifiermodel"
 )
 
-// Custom operation value for acknowledge
+// Custom operation values for acknowledge and reject
 const (
 	MergeOperationAcknowledge model.MergeOperation = 5
+	MergeOperationReject      model.MergeOperation = 6
 )
 
 // LogEntry represents a single log entry in the evaluation context
@@ -0,0 +1,109 @@
+package selectlang_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/loggers/changelogger"
+	"github.com/mariotoffia/godeviceshadow/manager/rules"
+	"github.com/mariotoffia/godeviceshadow/manager/stdmgr"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/managermodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/notify/selectlang"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+	"github.com/mariotoffia/godeviceshadow/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ruleSensor struct {
+	Value     any
+	TimeStamp time.Time
+}
+
+func (sp *ruleSensor) GetTimestamp() time.Time {
+	return sp.TimeStamp
+}
+
+func (sp *ruleSensor) GetValue() any {
+	return sp.Value
+}
+
+type ruleModel struct {
+	Sensors map[string]ruleSensor
+}
+
+func TestRuleWithSelection(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	where, err := selectlang.ToSelection(`
+		SELECT * FROM Notification WHERE
+		obj.Name == 'homeHub' AND log.Path == 'Sensors.indoor' AND log.Value > 28
+	`)
+
+	require.NoError(t, err)
+
+	var firings []rules.Firing
+
+	engine, err := rules.New().
+		WithOnFiring(func(ctx context.Context, firing rules.Firing) { firings = append(firings, firing) }).
+		WithRules(rules.Rule{
+			ID:    "hot-indoor",
+			Where: where,
+			Actions: []rules.Action{
+				rules.DesireAction{
+					Model: ruleModel{Sensors: map[string]ruleSensor{"indoor_temp_sp": {Value: 21.0, TimeStamp: now}}},
+				},
+			},
+		}).
+		Build()
+
+	require.NoError(t, err)
+
+	mgr := stdmgr.New().
+		WithPersistence(mempersistence.New()).
+		WithSeparation(persistencemodel.SeparateModels).
+		WithReportLoggers(changelogger.New()).
+		WithInterceptors(engine).
+		WithTypeRegistryResolver(
+			types.NewRegistry().RegisterResolver(
+				model.NewResolveFunc(func(id, name string) (model.TypeEntry, bool) {
+					return model.TypeEntry{Name: "homeHub", Model: reflect.TypeOf(ruleModel{})}, true
+				}),
+			),
+		).
+		Build()
+
+	engine.Attach(mgr)
+
+	id := persistencemodel.ID{ID: "hub-1", Name: "homeHub"}
+
+	report := func(name string, value float64) {
+		res := mgr.Report(ctx, managermodel.ReportOperation{
+			ClientID: "device",
+			ID:       id,
+			Model:    ruleModel{Sensors: map[string]ruleSensor{name: {Value: value, TimeStamp: time.Now().UTC()}}},
+		})
+
+		require.NoError(t, res[0].Error)
+	}
+
+	report("indoor", 25)
+	report("outdoor", 30)
+	assert.Empty(t, firings, "not hot enough indoor")
+
+	report("indoor", 29.5)
+	require.Len(t, firings, 1)
+	assert.Equal(t, "hot-indoor", firings[0].Rule)
+	assert.NoError(t, firings[0].Error)
+
+	res := mgr.Read(ctx, managermodel.ReadOperation{ID: id.ToPersistenceID(persistencemodel.ModelTypeDesired)})
+
+	require.NoError(t, res[0].Error)
+	assert.Equal(t, 21.0, res[0].Model.(ruleModel).Sensors["indoor_temp_sp"].Value)
+}
@@ -7,6 +7,7 @@ import (
 
 	antlr "github.com/antlr4-go/antlr/v4"
 	"github.com/mariotoffia/godeviceshadow/model/notifiermodel"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
 	"github.com/mariotoffia/godeviceshadow/utils/reutils"
 )
 
@@ -47,9 +48,12 @@ func (p predicateNode) Eval(ctx *EvalContext) bool {
 func (p predicateNode) evalLogEntry(ctx *EvalContext) bool {
 	switch p.field {
 	case "log.Operation":
-		// Special handling for acknowledge operations
-		if ctx.CurrentLog.Operation == MergeOperationAcknowledge {
+		// Special handling for acknowledge and reject operations
+		switch ctx.CurrentLog.Operation {
+		case MergeOperationAcknowledge:
 			return evalBasic("acknowledge", p)
+		case MergeOperationReject:
+			return evalBasic("reject", p)
 		}
 		return evalBasic(ctx.CurrentLog.Operation.String(), p)
 	case "log.Path":
@@ -81,13 +85,8 @@ func (p predicateNode) evalGlobal(ctx *EvalContext) bool {
 		if p.op == "~=" {
 			// Special handling for regex on obj.ID
 			if pattern, ok := p.value.(string); ok {
-				// Handle double backslashes in regex pattern
-				pattern = strings.ReplaceAll(pattern, "\\\\", "\\")
-				re, err := reutils.Shared.GetOrCompile(pattern)
-				if err != nil {
-					return false
-				}
-				return re.MatchString(op.ID.ID)
+				matched, ok := matchPattern(pattern, op.ID.ID)
+				return ok && matched
 			}
 			return false
 		}
@@ -124,17 +123,13 @@ func evalBasic(val string, p predicateNode) bool {
 		}
 	case "~=":
 		if s, ok := p.value.(string); ok {
-			// Handle double backslashes in regex pattern
-			s = strings.ReplaceAll(s, "\\\\", "\\")
-			re, err := reutils.Shared.GetOrCompile(s)
-			return err == nil && re.MatchString(val)
+			matched, ok := matchPattern(s, val)
+			return ok && matched
 		}
 	case "~!=":
 		if s, ok := p.value.(string); ok {
-			// Handle double backslashes in regex pattern
-			s = strings.ReplaceAll(s, "\\\\", "\\")
-			re, err := reutils.Shared.GetOrCompile(s)
-			return err == nil && !re.MatchString(val)
+			matched, ok := matchPattern(s, val)
+			return ok && !matched
 		}
 	case "IN":
 		for _, v := range p.values {
@@ -146,6 +141,30 @@ func evalBasic(val string, p predicateNode) bool {
 	return false
 }
 
+// globPrefix is the prefix of a `~=` or `~!=` pattern that is a path glob (see `pathutils.Glob`)
+// instead of a regexp, e.g. `log.Path ~= 'glob:climate.sensors.*.t'`.
+const globPrefix = "glob:"
+
+// matchPattern matches _val_ against the _pattern_ that is either a regexp or, when prefixed
+// with `globPrefix`, a path glob. The second return value is `false` when the pattern is invalid.
+func matchPattern(pattern, val string) (bool, bool) {
+	if strings.HasPrefix(pattern, globPrefix) {
+		g, err := pathutils.Shared.GetOrCompile(pattern[len(globPrefix):])
+		if err != nil {
+			return false, false
+		}
+		return g.Match(val), true
+	}
+
+	// Handle double backslashes in regex pattern
+	pattern = strings.ReplaceAll(pattern, "\\\\", "\\")
+	re, err := reutils.Shared.GetOrCompile(pattern)
+	if err != nil {
+		return false, false
+	}
+	return re.MatchString(val), true
+}
+
 func toFloat(v any) (float64, bool) {
 	switch t := v.(type) {
 	case float64:
@@ -219,25 +238,15 @@ func evalAny(val any, p predicateNode) bool {
 
 	case "~=":
 		if pattern, ok := p.value.(string); ok {
-			// Handle double backslashes in regex pattern
-			pattern = strings.ReplaceAll(pattern, "\\\\", "\\")
-			re, err := reutils.Shared.GetOrCompile(pattern)
-			if err != nil {
-				return false
-			}
-			return re.MatchString(s)
+			matched, ok := matchPattern(pattern, s)
+			return ok && matched
 		}
 		return false
 
 	case "~!=":
 		if pattern, ok := p.value.(string); ok {
-			// Handle double backslashes in regex pattern
-			pattern = strings.ReplaceAll(pattern, "\\\\", "\\")
-			re, err := reutils.Shared.GetOrCompile(pattern)
-			if err != nil {
-				return false
-			}
-			return !re.MatchString(s)
+			matched, ok := matchPattern(pattern, s)
+			return ok && !matched
 		}
 		return false
 
@@ -609,6 +618,20 @@ func ToSelection(expr string) (notifiermodel.Selection, error) {
 			}
 		}
 
+		// Check for reject operations in the desire logger
+		for path, rv := range op.DesireLogger.Rejected() {
+			logEntry := CreateLogEntry(MergeOperationReject, path, rv.Value.GetValue())
+			logCtx := &EvalContext{
+				OriginalOp:   op,
+				InLogContext: true,
+				CurrentLog:   &logEntry,
+			}
+
+			if n.Eval(logCtx) {
+				return true, nil
+			}
+		}
+
 		return false, nil
 	}), nil
 }
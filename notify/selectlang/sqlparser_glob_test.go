@@ -0,0 +1,67 @@
+package selectlang_test
+
+import (
+	"testing"
+
+	"github.com/mariotoffia/godeviceshadow/loggers/changelogger"
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/notify/selectlang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGlobPattern tests the glob: prefix on the regex operators (~= and ~!=)
+func TestGlobPattern(t *testing.T) {
+	op := createTestOperation()
+
+	op.MergeLogger.PlainLog[model.MergeOperationAdd] = append(
+		op.MergeLogger.PlainLog[model.MergeOperationAdd],
+		changelogger.PlainValue{
+			Path:     "climate.sensors.indoor.t",
+			NewValue: 21.5,
+		},
+	)
+
+	testCases := []struct {
+		name     string
+		query    string
+		expected bool
+	}{
+		{
+			name:     "Single segment wildcard",
+			query:    "SELECT * FROM Notification WHERE log.Path ~= 'glob:climate.sensors.*.t'",
+			expected: true,
+		},
+		{
+			name:     "Single segment wildcard do not match deeper paths",
+			query:    "SELECT * FROM Notification WHERE log.Path ~= 'glob:climate.*.t'",
+			expected: false,
+		},
+		{
+			name:     "Multi segment wildcard",
+			query:    "SELECT * FROM Notification WHERE log.Path ~= 'glob:climate.**'",
+			expected: true,
+		},
+		{
+			name:     "Glob combined with value",
+			query:    "SELECT * FROM Notification WHERE log.Path ~= 'glob:climate.sensors.*.t' AND log.Value > 21",
+			expected: true,
+		},
+		{
+			name:     "Glob not match",
+			query:    "SELECT * FROM Notification WHERE log.Path ~!= 'glob:**'",
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sel, err := selectlang.ToSelection(tc.query)
+			require.NoError(t, err)
+			require.NotNil(t, sel)
+
+			selected, _ := sel.Select(op, false)
+			assert.Equal(t, tc.expected, selected)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package otelobserver
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mariotoffia/godeviceshadow/model/observemodel"
+)
+
+// Observer is a `observemodel.Observer` that starts OpenTelemetry spans and records the counters and histograms
+// using OpenTelemetry instruments. The instruments are created on first use.
+type Observer struct {
+	tracer     trace.Tracer
+	meter      metric.Meter
+	mu         sync.RWMutex
+	counters   map[string]metric.Int64Counter
+	histograms map[string]metric.Float64Histogram
+}
+
+// New creates a observer from the _tracer_ and the _meter_, e.g.
+// `otel.Tracer("godeviceshadow")` and `otel.Meter("godeviceshadow")`.
+func New(tracer trace.Tracer, meter metric.Meter) *Observer {
+	return &Observer{
+		tracer:     tracer,
+		meter:      meter,
+		counters:   map[string]metric.Int64Counter{},
+		histograms: map[string]metric.Float64Histogram{},
+	}
+}
+
+// Start implements the `observemodel.Observer` interface.
+func (o *Observer) Start(ctx context.Context, name string, attrs ...observemodel.Attribute) (context.Context, observemodel.Span) {
+	ctx, s := o.tracer.Start(ctx, name, trace.WithAttributes(toAttributes(attrs)...))
+
+	return ctx, span{span: s}
+}
+
+// Count implements the `observemodel.Observer` interface.
+func (o *Observer) Count(ctx context.Context, name string, delta int64, attrs ...observemodel.Attribute) {
+	o.mu.RLock()
+	c, ok := o.counters[name]
+	o.mu.RUnlock()
+
+	if !ok {
+		o.mu.Lock()
+
+		if c, ok = o.counters[name]; !ok {
+			var err error
+
+			if c, err = o.meter.Int64Counter(name); err != nil {
+				o.mu.Unlock()
+				return
+			}
+
+			o.counters[name] = c
+		}
+
+		o.mu.Unlock()
+	}
+
+	c.Add(ctx, delta, metric.WithAttributes(toAttributes(attrs)...))
+}
+
+// Record implements the `observemodel.Observer` interface.
+func (o *Observer) Record(ctx context.Context, name string, value float64, attrs ...observemodel.Attribute) {
+	o.mu.RLock()
+	h, ok := o.histograms[name]
+	o.mu.RUnlock()
+
+	if !ok {
+		o.mu.Lock()
+
+		if h, ok = o.histograms[name]; !ok {
+			var err error
+
+			if h, err = o.meter.Float64Histogram(name); err != nil {
+				o.mu.Unlock()
+				return
+			}
+
+			o.histograms[name] = h
+		}
+
+		o.mu.Unlock()
+	}
+
+	h.Record(ctx, value, metric.WithAttributes(toAttributes(attrs)...))
+}
+
+type span struct {
+	span trace.Span
+}
+
+func (s span) SetAttributes(attrs ...observemodel.Attribute) {
+	s.span.SetAttributes(toAttributes(attrs)...)
+}
+
+func (s span) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+
+	s.span.End()
+}
+
+func toAttributes(attrs []observemodel.Attribute) []attribute.KeyValue {
+	kv := make([]attribute.KeyValue, len(attrs))
+
+	for i, a := range attrs {
+		kv[i] = attribute.String(a.Key, a.Value)
+	}
+
+	return kv
+}
@@ -0,0 +1,80 @@
+package otelobserver_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/mariotoffia/godeviceshadow/model/observemodel"
+	"github.com/mariotoffia/godeviceshadow/observers/otelobserver"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserver(t *testing.T) {
+	ctx := context.Background()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	o := otelobserver.New(tp.Tracer("godeviceshadow"), mp.Meter("godeviceshadow"))
+	op := observemodel.Attr(observemodel.AttrOperation, "report")
+
+	o.Count(ctx, observemodel.ManagerConflicts, 1, op)
+	o.Count(ctx, observemodel.ManagerConflicts, 2, op)
+	o.Record(ctx, observemodel.ManagerOperationDuration, 0.05, op)
+	o.Record(ctx, observemodel.ManagerOperationDuration, 0.5, op)
+
+	_, span := o.Start(ctx, "manager.report", op)
+	span.SetAttributes(observemodel.Attr("shadow", "device-1"))
+	span.End(errors.New("failed"))
+
+	// Spans
+	spans := exporter.GetSpans()
+
+	require.Len(t, spans, 1)
+	assert.Equal(t, "manager.report", spans[0].Name)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, "failed", spans[0].Status.Description)
+	assert.Contains(t, spans[0].Attributes, attribute.String(observemodel.AttrOperation, "report"))
+	assert.Contains(t, spans[0].Attributes, attribute.String("shadow", "device-1"))
+	require.Len(t, spans[0].Events, 1, "the recorded error")
+
+	// Metrics
+	var rm metricdata.ResourceMetrics
+
+	require.NoError(t, reader.Collect(ctx, &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	metrics := map[string]metricdata.Metrics{}
+
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+
+	sum, ok := metrics[observemodel.ManagerConflicts].Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, sum.DataPoints, 1)
+	assert.Equal(t, int64(3), sum.DataPoints[0].Value)
+
+	value, ok := sum.DataPoints[0].Attributes.Value(attribute.Key(observemodel.AttrOperation))
+	require.True(t, ok)
+	assert.Equal(t, "report", value.AsString())
+
+	histogram, ok := metrics[observemodel.ManagerOperationDuration].Data.(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, histogram.DataPoints, 1)
+	assert.Equal(t, uint64(2), histogram.DataPoints[0].Count)
+	assert.InDelta(t, 0.55, histogram.DataPoints[0].Sum, 1e-9)
+}
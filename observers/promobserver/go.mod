@@ -0,0 +1,14 @@
+module github.com/mariotoffia/godeviceshadow/observers/promobserver
+
+go 1.24
+
+require (
+	github.com/mariotoffia/godeviceshadow v0.0.10
+	github.com/stretchr/testify v1.10.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,263 @@
+package promobserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model/observemodel"
+)
+
+// DefaultBuckets are the histogram upper bounds used when none is given. They suit durations in seconds.
+var DefaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// SpanDuration is the histogram of span durations. Spans are not exported, only their duration, by span name
+// and outcome.
+const SpanDuration = "span.duration"
+
+// Observer is a `observemodel.Observer` that keeps counters and histograms in memory and renders them in the
+// Prometheus text exposition format.
+type Observer struct {
+	namespace  string
+	buckets    []float64
+	mu         sync.Mutex
+	counters   map[string]map[string]*counter
+	histograms map[string]map[string]*histogram
+}
+
+type counter struct {
+	labels []observemodel.Attribute
+	value  int64
+}
+
+type histogram struct {
+	labels []observemodel.Attribute
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// New creates a observer where all metric names are prefixed with _namespace_ (if not empty). When no _buckets_
+// the `DefaultBuckets` are used.
+func New(namespace string, buckets ...float64) *Observer {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	buckets = slices.Clone(buckets)
+	sort.Float64s(buckets)
+
+	return &Observer{
+		namespace:  namespace,
+		buckets:    buckets,
+		counters:   map[string]map[string]*counter{},
+		histograms: map[string]map[string]*histogram{},
+	}
+}
+
+// Start implements the `observemodel.Observer` interface. The duration is recorded in `SpanDuration` when the
+// span is ended.
+func (o *Observer) Start(ctx context.Context, name string, attrs ...observemodel.Attribute) (context.Context, observemodel.Span) {
+	return ctx, &span{observer: o, name: name, start: time.Now()}
+}
+
+// Count implements the `observemodel.Observer` interface.
+func (o *Observer) Count(_ context.Context, name string, delta int64, attrs ...observemodel.Attribute) {
+	key := labelKey(attrs)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	series, ok := o.counters[name]
+
+	if !ok {
+		series = map[string]*counter{}
+		o.counters[name] = series
+	}
+
+	c, ok := series[key]
+
+	if !ok {
+		c = &counter{labels: slices.Clone(attrs)}
+		series[key] = c
+	}
+
+	c.value += delta
+}
+
+// Record implements the `observemodel.Observer` interface.
+func (o *Observer) Record(_ context.Context, name string, value float64, attrs ...observemodel.Attribute) {
+	key := labelKey(attrs)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	series, ok := o.histograms[name]
+
+	if !ok {
+		series = map[string]*histogram{}
+		o.histograms[name] = series
+	}
+
+	h, ok := series[key]
+
+	if !ok {
+		h = &histogram{labels: slices.Clone(attrs), counts: make([]uint64, len(o.buckets))}
+		series[key] = h
+	}
+
+	for i, le := range o.buckets {
+		if value <= le {
+			h.counts[i]++
+		}
+	}
+
+	h.count++
+	h.sum += value
+}
+
+// WriteTo writes all metrics, sorted by name, in the Prometheus text exposition format.
+func (o *Observer) WriteTo(w io.Writer) (int64, error) {
+	var sb strings.Builder
+
+	o.mu.Lock()
+
+	for _, name := range sortedKeys(o.counters) {
+		metric := o.metricName(name) + "_total"
+
+		fmt.Fprintf(&sb, "# TYPE %s counter\n", metric)
+
+		series := o.counters[name]
+
+		for _, key := range sortedKeys(series) {
+			fmt.Fprintf(&sb, "%s%s %d\n", metric, labels(series[key].labels), series[key].value)
+		}
+	}
+
+	for _, name := range sortedKeys(o.histograms) {
+		metric := o.metricName(name)
+
+		fmt.Fprintf(&sb, "# TYPE %s histogram\n", metric)
+
+		series := o.histograms[name]
+
+		for _, key := range sortedKeys(series) {
+			h := series[key]
+
+			for i, le := range o.buckets {
+				fmt.Fprintf(&sb, "%s_bucket%s %d\n", metric,
+					labels(h.labels, observemodel.Attr("le", formatFloat(le))), h.counts[i])
+			}
+
+			fmt.Fprintf(&sb, "%s_bucket%s %d\n", metric, labels(h.labels, observemodel.Attr("le", "+Inf")), h.count)
+			fmt.Fprintf(&sb, "%s_sum%s %s\n", metric, labels(h.labels), formatFloat(h.sum))
+			fmt.Fprintf(&sb, "%s_count%s %d\n", metric, labels(h.labels), h.count)
+		}
+	}
+
+	o.mu.Unlock()
+
+	n, err := io.WriteString(w, sb.String())
+
+	return int64(n), err
+}
+
+// ServeHTTP implements the `http.Handler` interface to be scraped by Prometheus.
+func (o *Observer) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = o.WriteTo(w)
+}
+
+// metricName converts the dotted _name_ into a Prometheus metric name.
+func (o *Observer) metricName(name string) string {
+	if o.namespace != "" {
+		name = o.namespace + "_" + name
+	}
+
+	return sanitize(name)
+}
+
+type span struct {
+	observer *Observer
+	name     string
+	start    time.Time
+}
+
+func (s *span) SetAttributes(...observemodel.Attribute) {}
+
+func (s *span) End(err error) {
+	s.observer.Record(context.Background(), SpanDuration, time.Since(s.start).Seconds(),
+		observemodel.Attr("span", s.name),
+		observemodel.Attr(observemodel.AttrOutcome, observemodel.Outcome(err)),
+	)
+}
+
+// sanitize replaces all characters that are not allowed in a metric or label name with an underscore.
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == ':' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+
+		return '_'
+	}, name)
+}
+
+// labels renders the _attrs_ and the _extra_ as Prometheus labels, e.g. `{operation="report"}`.
+func labels(attrs []observemodel.Attribute, extra ...observemodel.Attribute) string {
+	all := append(slices.Clone(attrs), extra...)
+
+	if len(all) == 0 {
+		return ""
+	}
+
+	parts := make([]string, len(all))
+
+	for i, a := range all {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(a.Value)
+		parts[i] = sanitize(a.Key) + `="` + value + `"`
+	}
+
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// labelKey is the key of the series with the _attrs_.
+func labelKey(attrs []observemodel.Attribute) string {
+	var sb strings.Builder
+
+	for _, a := range attrs {
+		sb.WriteString(a.Key)
+		sb.WriteByte(0)
+		sb.WriteString(a.Value)
+		sb.WriteByte(0)
+	}
+
+	return sb.String()
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+
+	return fmt.Sprintf("%g", f)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,45 @@
+package promobserver_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mariotoffia/godeviceshadow/model/observemodel"
+	"github.com/mariotoffia/godeviceshadow/observers/promobserver"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTo(t *testing.T) {
+	ctx := context.Background()
+	o := promobserver.New("godeviceshadow", 0.1, 1)
+	op := observemodel.Attr(observemodel.AttrOperation, "report")
+
+	o.Count(ctx, observemodel.ManagerConflicts, 1, op)
+	o.Count(ctx, observemodel.ManagerConflicts, 2, op)
+	o.Record(ctx, observemodel.ManagerOperationDuration, 0.05, op)
+	o.Record(ctx, observemodel.ManagerOperationDuration, 0.5, op)
+
+	_, span := o.Start(ctx, "manager.report")
+	span.End(errors.New("failed"))
+
+	var sb strings.Builder
+
+	_, err := o.WriteTo(&sb)
+	require.NoError(t, err)
+
+	out := sb.String()
+
+	assert.Contains(t, out, "# TYPE godeviceshadow_manager_conflicts_total counter\n")
+	assert.Contains(t, out, `godeviceshadow_manager_conflicts_total{operation="report"} 3`)
+	assert.Contains(t, out, "# TYPE godeviceshadow_manager_operation_duration histogram\n")
+	assert.Contains(t, out, `godeviceshadow_manager_operation_duration_bucket{operation="report",le="0.1"} 1`)
+	assert.Contains(t, out, `godeviceshadow_manager_operation_duration_bucket{operation="report",le="1"} 2`)
+	assert.Contains(t, out, `godeviceshadow_manager_operation_duration_bucket{operation="report",le="+Inf"} 2`)
+	assert.Contains(t, out, `godeviceshadow_manager_operation_duration_sum{operation="report"} 0.55`)
+	assert.Contains(t, out, `godeviceshadow_manager_operation_duration_count{operation="report"} 2`)
+	assert.Contains(t, out, `godeviceshadow_span_duration_count{span="manager.report",outcome="error"} 1`)
+}
@@ -2,6 +2,7 @@ package dynamodbpersistence
 
 import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/mariotoffia/godeviceshadow/model/observemodel"
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
 )
 
@@ -39,4 +40,17 @@ type Config struct {
 	//
 	// This is when it return unprocessed keys and it will retry the request. All other errors are not retried.
 	MaxWriteRetries int `json:"write_retries,omitempty"`
+	// History is when set, the retention of previous versions. Each write will, in the same transaction, also write
+	// a copy of the item with the sort key _DS{R|D|C}H#{Name}#{Version}_. Versions older than `Retention.MaxVersions`
+	// are deleted in the same transaction.
+	//
+	// NOTE: Retained versions are kept when the model is deleted and a created model continues after the newest
+	// retained version. A name may not contain a _#_ since the versions are queried by the name prefix.
+	History persistencemodel.Retention `json:"history,omitempty"`
+	// HistoryTTLAttribute is the attribute that is set to the expiry (Unix epoch seconds) on each retained version
+	// when `Retention.MaxAge` is set. TTL must be enabled on the table for this attribute. Default is _ExpiresAt_.
+	HistoryTTLAttribute string `json:"history_ttl,omitempty"`
+	// Observer is when set, receives a span, the duration, the number of items and failures of each operation. It
+	// also receives the size of each DynamoDB batch, the number of retries and the unprocessed keys or items.
+	Observer observemodel.Observer `json:"-"`
 }
@@ -0,0 +1,209 @@
+package dynamodbpersistence
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/persistutils"
+)
+
+const (
+	// conditionNotExistsExpression is the condition that the copied item must not exist.
+	conditionNotExistsExpression = "attribute_not_exists(PK)"
+	// conditionVersionExpression is the condition that the source item is still at the read version.
+	conditionVersionExpression = "Version = :expected_version"
+	// maxTransactionItems is the maximum number of items in a single `TransactWriteItems`.
+	maxTransactionItems = 100
+)
+
+// Copy implements the `persistencemodel.Copier` interface. The source items are read using consistent reads and
+// all copies are written using a single `TransactWriteItems`. Hence, at most 100 items, including the source
+// conditions and the retained versions, may be copied at once. Since a transaction may only touch an item once, a
+// source or target that is used by more than one operation fails all with 400 (Bad Request), as does a copy that
+// exceeds 100 items.
+func (p *Persistence) Copy(
+	ctx context.Context,
+	opt persistencemodel.WriteOptions,
+	operations ...persistencemodel.CopyOperation,
+) (results []persistencemodel.WriteResult) {
+	if len(operations) == 0 {
+		return nil
+	}
+
+	ctx, obs := persistutils.Observe(ctx, p.config.Observer, observedPersistence, "copy", len(operations))
+
+	defer func() {
+		obs.End(results...)
+	}()
+
+	results = make([]persistencemodel.WriteResult, len(operations))
+
+	for i, op := range operations {
+		results[i].ID = op.To
+	}
+
+	fail := func(err error) []persistencemodel.WriteResult {
+		for i := range results {
+			results[i].Error = err
+		}
+
+		return results
+	}
+
+	keys := make(map[string]bool, len(operations)*2)
+
+	for _, op := range operations {
+		for _, id := range []persistencemodel.PersistenceID{op.From, op.To} {
+			key := toPartitionKey(id) + "/" + toCurrentSortKey(id)
+
+			if keys[key] {
+				return fail(persistencemodel.Error400(fmt.Sprintf("%s is used by more than one operation", id)))
+			}
+
+			keys[key] = true
+		}
+	}
+
+	now := time.Now().UTC().UnixNano()
+	versions := make([]int64, len(operations))
+	transactions := make([]types.TransactWriteItem, 0, len(operations)*2)
+
+	for i, op := range operations {
+		if op.From.ModelType != op.To.ModelType {
+			return fail(persistencemodel.Error400(fmt.Sprintf("model type of %s and %s differs", op.From, op.To)))
+		}
+
+		if err := p.validateName(op.To.Name); err != nil {
+			return fail(err)
+		}
+
+		key := map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: toPartitionKey(op.From)},
+			"SK": &types.AttributeValueMemberS{Value: toCurrentSortKey(op.From)},
+		}
+
+		out, err := p.client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(p.config.Table),
+			Key:            key,
+			ConsistentRead: aws.Bool(true),
+		})
+
+		if err != nil {
+			return fail(readBatchErrorFixup(err))
+		}
+
+		if len(out.Item) == 0 {
+			if op.Optional {
+				results[i].Error = persistencemodel.Error404(fmt.Sprintf("%s - Not found", op.From))
+				continue
+			}
+
+			return fail(persistencemodel.Error404(fmt.Sprintf("%s - Not found", op.From)))
+		}
+
+		var stored PartialPersistenceObject
+
+		if err := attributevalue.UnmarshalMap(out.Item, &stored); err != nil {
+			return fail(persistencemodel.Error500(fmt.Sprintf("failed to unmarshal %s: %s", op.From, err)))
+		}
+
+		if op.Version > 0 && stored.Version != op.Version {
+			return fail(persistencemodel.Error409(fmt.Sprintf("Conflict, version mismatch of %s", op.From)))
+		}
+
+		sk := toCurrentSortKey(op.To)
+		version, err := p.nextVersion(ctx, toPartitionKey(op.To), sk, 0)
+
+		if err != nil {
+			return fail(err)
+		}
+
+		item := make(map[string]types.AttributeValue, len(out.Item))
+
+		for k, v := range out.Item {
+			item[k] = v
+		}
+
+		delete(item, p.config.HistoryTTLAttribute)
+		delete(item, "ClientToken")
+
+		item["PK"] = &types.AttributeValueMemberS{Value: toPartitionKey(op.To)}
+		item["SK"] = &types.AttributeValueMemberS{Value: sk}
+		item["Version"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(version, 10)}
+		item["TimeStamp"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(now, 10)}
+
+		if op.ClientID != "" {
+			item["ClientToken"] = &types.AttributeValueMemberS{Value: op.ClientID}
+		}
+
+		versionValues := map[string]types.AttributeValue{
+			expectedVersionValueKey: &types.AttributeValueMemberN{Value: strconv.FormatInt(stored.Version, 10)},
+		}
+
+		transactions = append(transactions, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName:           aws.String(p.config.Table),
+				Item:                item,
+				ConditionExpression: aws.String(conditionNotExistsExpression),
+			},
+		})
+
+		// The source must not change in between
+		if op.Move {
+			transactions = append(transactions, types.TransactWriteItem{
+				Delete: &types.Delete{
+					TableName:                 aws.String(p.config.Table),
+					Key:                       key,
+					ConditionExpression:       aws.String(conditionVersionExpression),
+					ExpressionAttributeValues: versionValues,
+				},
+			})
+		} else {
+			transactions = append(transactions, types.TransactWriteItem{
+				ConditionCheck: &types.ConditionCheck{
+					TableName:                 aws.String(p.config.Table),
+					Key:                       key,
+					ConditionExpression:       aws.String(conditionVersionExpression),
+					ExpressionAttributeValues: versionValues,
+				},
+			})
+		}
+
+		if p.config.History.Enabled() {
+			transactions = append(transactions, p.historyWrites(item, sk, version, now)...)
+		}
+
+		versions[i] = version
+	}
+
+	if len(transactions) > maxTransactionItems {
+		return fail(persistencemodel.Error400(fmt.Sprintf(
+			"copy requires %d items, at most %d may be written at once", len(transactions), maxTransactionItems,
+		)))
+	}
+
+	if len(transactions) > 0 {
+		if _, err := p.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: transactions,
+		}); err != nil {
+			return fail(conditionalWriteErrorFixup(err))
+		}
+	}
+
+	for i := range results {
+		if versions[i] > 0 {
+			results[i].Version = versions[i]
+			results[i].TimeStamp = now
+		}
+	}
+
+	return results
+}
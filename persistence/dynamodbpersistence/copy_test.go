@@ -0,0 +1,197 @@
+//go:build integration
+// +build integration
+
+package dynamodbpersistence_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/persistence/dynamodbpersistence"
+	"github.com/mariotoffia/godeviceshadow/persistence/dynamodbpersistence/dynamodbutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyAndMove(t *testing.T) {
+	ctx := context.Background()
+
+	res := dynamodbutils.NewTestTableResource(ctx, TestTableName)
+	defer res.Dispose(ctx, dynamodbutils.DisposeOpts{DeleteItems: true})
+
+	p, err := dynamodbpersistence.New(ctx, dynamodbpersistence.Config{
+		Table:           res.Table,
+		Client:          res.Client,
+		ModelSeparation: persistencemodel.SeparateModels,
+		History:         persistencemodel.Retention{MaxVersions: 10},
+	})
+	require.NoError(t, err)
+
+	from := persistencemodel.PersistenceID{ID: "deviceC", Name: "shadowC", ModelType: persistencemodel.ModelTypeReported}
+	copied := persistencemodel.PersistenceID{ID: "deviceD", Name: "shadowC", ModelType: persistencemodel.ModelTypeReported}
+	moved := persistencemodel.PersistenceID{ID: "deviceE", Name: "shadowC", ModelType: persistencemodel.ModelTypeReported}
+
+	wr := p.Write(ctx, persistencemodel.WriteOptions{}, persistencemodel.WriteOperation{
+		ClientID: "test", ID: from, Model: TestModel{TimeZone: tz},
+	})
+
+	require.Len(t, wr, 1)
+	require.NoError(t, wr[0].Error)
+
+	read := func(id persistencemodel.PersistenceID) persistencemodel.ReadResult {
+		rr := p.Read(ctx, persistencemodel.ReadOptions{}, persistencemodel.ReadOperation{
+			ID: id, Model: reflect.TypeOf(&TestModel{}),
+		})
+
+		require.Len(t, rr, 1)
+
+		return rr[0]
+	}
+
+	// Stale version of the source
+	cr := p.Copy(ctx, persistencemodel.WriteOptions{}, persistencemodel.CopyOperation{From: from, To: copied, Version: 2})
+
+	var pe persistencemodel.PersistenceError
+
+	require.Len(t, cr, 1)
+	require.ErrorAs(t, cr[0].Error, &pe)
+	assert.Equal(t, 409, pe.Code)
+
+	cr = p.Copy(ctx, persistencemodel.WriteOptions{}, persistencemodel.CopyOperation{
+		From: from, To: copied, Version: 1, ClientID: "installer",
+	})
+
+	require.Len(t, cr, 1)
+	require.NoError(t, cr[0].Error)
+	assert.Equal(t, int64(1), cr[0].Version)
+
+	rr := read(copied)
+	require.NoError(t, rr.Error)
+	assert.Equal(t, tz, rr.Model.(*TestModel).TimeZone)
+	assert.Equal(t, "installer", rr.ClientToken)
+
+	// The target already exists
+	cr = p.Copy(ctx, persistencemodel.WriteOptions{}, persistencemodel.CopyOperation{From: from, To: copied})
+
+	require.Len(t, cr, 1)
+	require.ErrorAs(t, cr[0].Error, &pe)
+	assert.Equal(t, 409, pe.Code)
+
+	// Move and an optional source that is not found
+	cr = p.Copy(ctx, persistencemodel.WriteOptions{},
+		persistencemodel.CopyOperation{From: from, To: moved, Move: true},
+		persistencemodel.CopyOperation{
+			From:     persistencemodel.PersistenceID{ID: "deviceC", Name: "missing", ModelType: persistencemodel.ModelTypeReported},
+			To:       persistencemodel.PersistenceID{ID: "deviceE", Name: "missing", ModelType: persistencemodel.ModelTypeReported},
+			Optional: true,
+		},
+	)
+
+	require.Len(t, cr, 2)
+	require.NoError(t, cr[0].Error)
+	require.ErrorAs(t, cr[1].Error, &pe)
+	assert.Equal(t, 404, pe.Code)
+
+	rr = read(moved)
+	require.NoError(t, rr.Error)
+	assert.Equal(t, tz, rr.Model.(*TestModel).TimeZone)
+
+	require.ErrorAs(t, read(from).Error, &pe)
+	assert.Equal(t, 404, pe.Code)
+
+	// The retained versions of the moved source are kept, the target continues after those
+	cr = p.Copy(ctx, persistencemodel.WriteOptions{}, persistencemodel.CopyOperation{From: moved, To: from})
+
+	require.Len(t, cr, 1)
+	require.NoError(t, cr[0].Error)
+	assert.Equal(t, int64(2), cr[0].Version)
+
+	history, err := p.History(ctx, from)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, []int64{2, 1}, []int64{history[0].Version, history[1].Version})
+}
+
+func TestCopyRejectsDuplicatesAndOversized(t *testing.T) {
+	ctx := context.Background()
+
+	res := dynamodbutils.NewTestTableResource(ctx, TestTableName)
+	defer res.Dispose(ctx, dynamodbutils.DisposeOpts{DeleteItems: true})
+
+	p, err := dynamodbpersistence.New(ctx, dynamodbpersistence.Config{
+		Table:           res.Table,
+		Client:          res.Client,
+		ModelSeparation: persistencemodel.SeparateModels,
+	})
+	require.NoError(t, err)
+
+	id := func(device, name string) persistencemodel.PersistenceID {
+		return persistencemodel.PersistenceID{ID: device, Name: name, ModelType: persistencemodel.ModelTypeReported}
+	}
+
+	copyAll := func(operations ...persistencemodel.CopyOperation) {
+		cr := p.Copy(ctx, persistencemodel.WriteOptions{}, operations...)
+		require.Len(t, cr, len(operations))
+
+		for _, r := range cr {
+			var pe persistencemodel.PersistenceError
+
+			require.ErrorAs(t, r.Error, &pe)
+			assert.Equal(t, 400, pe.Code)
+		}
+	}
+
+	// Same source, same target and a target that is the source of another
+	copyAll(
+		persistencemodel.CopyOperation{From: id("deviceF", "a"), To: id("deviceG", "a")},
+		persistencemodel.CopyOperation{From: id("deviceF", "a"), To: id("deviceG", "b")},
+	)
+	copyAll(
+		persistencemodel.CopyOperation{From: id("deviceF", "a"), To: id("deviceG", "a")},
+		persistencemodel.CopyOperation{From: id("deviceF", "b"), To: id("deviceG", "a")},
+	)
+	copyAll(
+		persistencemodel.CopyOperation{From: id("deviceF", "a"), To: id("deviceG", "a")},
+		persistencemodel.CopyOperation{From: id("deviceG", "a"), To: id("deviceH", "a")},
+	)
+	copyAll(persistencemodel.CopyOperation{From: id("deviceF", "a"), To: id("deviceF", "a")})
+
+	// Two items per copy, 51 copies exceeds a single transaction
+	writes := make([]persistencemodel.WriteOperation, 0, 51)
+	copies := make([]persistencemodel.CopyOperation, 0, 51)
+
+	for i := range 51 {
+		name := fmt.Sprintf("shadow%d", i)
+
+		writes = append(writes, persistencemodel.WriteOperation{ClientID: "test", ID: id("deviceF", name), Model: TestModel{TimeZone: tz}})
+		copies = append(copies, persistencemodel.CopyOperation{From: id("deviceF", name), To: id("deviceG", name)})
+	}
+
+	for _, r := range p.Write(ctx, persistencemodel.WriteOptions{}, writes...) {
+		require.NoError(t, r.Error)
+	}
+
+	copyAll(copies...)
+
+	// Nothing was copied
+	rr := p.Read(ctx, persistencemodel.ReadOptions{}, persistencemodel.ReadOperation{
+		ID: id("deviceG", "shadow0"), Model: reflect.TypeOf(&TestModel{}),
+	})
+
+	var pe persistencemodel.PersistenceError
+
+	require.Len(t, rr, 1)
+	require.ErrorAs(t, rr[0].Error, &pe)
+	assert.Equal(t, 404, pe.Code)
+
+	cr := p.Copy(ctx, persistencemodel.WriteOptions{}, copies[:50]...)
+	require.Len(t, cr, 50)
+
+	for _, r := range cr {
+		require.NoError(t, r.Error)
+	}
+}
@@ -9,20 +9,28 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 
+	"github.com/mariotoffia/godeviceshadow/model/observemodel"
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
 	"github.com/mariotoffia/godeviceshadow/utils"
+	"github.com/mariotoffia/godeviceshadow/utils/persistutils"
 )
 
 func (p *Persistence) Delete(
 	ctx context.Context,
 	opt persistencemodel.WriteOptions,
 	operations ...persistencemodel.WriteOperation,
-) []persistencemodel.WriteResult {
+) (results []persistencemodel.WriteResult) {
 	//
 	if len(operations) == 0 {
 		return nil
 	}
 
+	ctx, obs := persistutils.Observe(ctx, p.config.Observer, observedPersistence, "delete", len(operations))
+
+	defer func() {
+		obs.End(results...)
+	}()
+
 	maxBatchSize := 25
 	maxRetries := 3
 	table := p.config.Table
@@ -45,7 +53,7 @@ func (p *Persistence) Delete(
 		}
 	}
 
-	results := make([]persistencemodel.WriteResult, 0, len(operations))
+	results = make([]persistencemodel.WriteResult, 0, len(operations))
 	batches, errors := prepareDelete(ucOperations, table, maxBatchSize)
 
 	results = append(results, errors...)
@@ -159,6 +167,8 @@ func (p *Persistence) deleteBatch(
 			)
 		}
 
+		p.observeBatch(ctx, "delete", attempt, len(current.RequestItems[table]))
+
 		res, err := p.client.BatchWriteItem(ctx, current)
 
 		if err != nil {
@@ -179,6 +189,8 @@ func (p *Persistence) deleteBatch(
 			return items
 		}
 
+		p.count(ctx, observemodel.PersistenceUnprocessed, "delete", len(unprocessed[table]))
+
 		if attempt == maxRetries {
 			return append(
 				items, p.buildDeleteFailedResults(
@@ -0,0 +1,291 @@
+package dynamodbpersistence
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// toHistoryPrefix renders the sort key prefix of all retained versions of the _sk_ e.g. _DSDH#{Name}#_. Since the
+// prefix differs from the current item prefix (_DSD#_), the retained versions are never listed.
+func toHistoryPrefix(sk string) string {
+	return sk[:3] + "H#" + sk[4:] + "#"
+}
+
+// toHistorySortKey renders the sort key of a retained _version_ of the _sk_. The version is zero padded so the
+// versions are sorted.
+func toHistorySortKey(sk string, version int64) string {
+	return fmt.Sprintf("%s%020d", toHistoryPrefix(sk), version)
+}
+
+// toCurrentSortKey renders the sort key of the current item of the _id_.
+func toCurrentSortKey(id persistencemodel.PersistenceID) string {
+	if id.ModelType == 0 {
+		return "DSC#" + id.Name
+	}
+
+	return toSortKey(id, id.ModelType)
+}
+
+// validateName fails with 400 (Bad Request) when versions are retained and the _name_ contains a _#_. The retained
+// versions are queried by the _DS{R|D|C}H#{Name}#_ prefix, hence such a name would share the prefix of another name.
+func (p *Persistence) validateName(name string) error {
+	if p.config.History.Enabled() && strings.Contains(name, "#") {
+		return persistencemodel.Error400(fmt.Sprintf("name %q may not contain '#' when history is retained", name))
+	}
+
+	return nil
+}
+
+// nextVersion returns the version of the item _sk_ that is written when the stored version is _expected_. A created
+// item, i.e. _expected_ is zero, continues after the newest retained version since those are kept when deleted.
+// Hence, a version is never reused.
+func (p *Persistence) nextVersion(ctx context.Context, pk, sk string, expected int64) (int64, error) {
+	if expected > 0 || !p.config.History.Enabled() {
+		return expected + 1, nil
+	}
+
+	out, err := p.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(p.config.Table),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: pk},
+			":prefix": &types.AttributeValueMemberS{Value: toHistoryPrefix(sk)},
+		},
+		ProjectionExpression: aws.String("Version"),
+		ScanIndexForward:     aws.Bool(false),
+		Limit:                aws.Int32(1),
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("query history failed: %w", err)
+	}
+
+	if len(out.Items) == 0 {
+		return 1, nil
+	}
+
+	var stored PartialPersistenceObject
+
+	if err := attributevalue.UnmarshalMap(out.Items[0], &stored); err != nil {
+		return 0, fmt.Errorf("unmarshal retained version failed: %w", err)
+	}
+
+	return stored.Version + 1, nil
+}
+
+// historyWrites returns the writes that retains the _item_ as _version_ and deletes the version that is no
+// longer retained by `Retention.MaxVersions`. The _timestamp_ is used for the TTL when `Retention.MaxAge` is set.
+func (p *Persistence) historyWrites(
+	item map[string]types.AttributeValue,
+	sk string,
+	version, timestamp int64,
+) []types.TransactWriteItem {
+	retention := p.config.History
+	retained := make(map[string]types.AttributeValue, len(item)+1)
+
+	for k, v := range item {
+		retained[k] = v
+	}
+
+	retained["SK"] = &types.AttributeValueMemberS{Value: toHistorySortKey(sk, version)}
+
+	if retention.MaxAge > 0 {
+		expires := time.Unix(0, timestamp).Add(retention.MaxAge).Unix()
+		retained[p.config.HistoryTTLAttribute] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expires, 10)}
+	}
+
+	writes := []types.TransactWriteItem{
+		{Put: &types.Put{TableName: aws.String(p.config.Table), Item: retained}},
+	}
+
+	if retention.MaxVersions > 0 && version > int64(retention.MaxVersions) {
+		writes = append(writes, types.TransactWriteItem{
+			Delete: &types.Delete{
+				TableName: aws.String(p.config.Table),
+				Key: map[string]types.AttributeValue{
+					"PK": item["PK"],
+					"SK": &types.AttributeValueMemberS{Value: toHistorySortKey(sk, version-int64(retention.MaxVersions))},
+				},
+			},
+		})
+	}
+
+	return writes
+}
+
+// readVersion reads a retained version of the _op_ where _sk_ is the sort key of the current item.
+func (p *Persistence) readVersion(
+	ctx context.Context,
+	op persistencemodel.ReadOperation,
+	sk string,
+) []persistencemodel.ReadResult {
+	out, err := p.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(p.config.Table),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: toPartitionKey(op.ID)},
+			"SK": &types.AttributeValueMemberS{Value: toHistorySortKey(sk, op.Version)},
+		},
+	})
+
+	if err != nil {
+		return []persistencemodel.ReadResult{{ID: op.ID, Error: readBatchErrorFixup(err)}}
+	}
+
+	if len(out.Item) == 0 {
+		return []persistencemodel.ReadResult{{
+			ID:    op.ID,
+			Error: persistencemodel.Error409(fmt.Sprintf("version %d is not retained", op.Version)),
+		}}
+	}
+
+	return p.itemToReadResults(op, out.Item)
+}
+
+// readAsOf reads the version of the _op_ that was current at `ReadOperation.AsOf`. It first checks the current
+// item and then the retained versions, newest first.
+func (p *Persistence) readAsOf(ctx context.Context, op persistencemodel.ReadOperation) []persistencemodel.ReadResult {
+	sk := toCurrentSortKey(op.ID)
+
+	out, err := p.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(p.config.Table),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: toPartitionKey(op.ID)},
+			"SK": &types.AttributeValueMemberS{Value: sk},
+		},
+	})
+
+	if err != nil {
+		return []persistencemodel.ReadResult{{ID: op.ID, Error: readBatchErrorFixup(err)}}
+	}
+
+	if len(out.Item) > 0 {
+		var stored PartialPersistenceObject
+
+		if err := attributevalue.UnmarshalMap(out.Item, &stored); err == nil && stored.TimeStamp <= op.AsOf {
+			return p.itemToReadResults(op, out.Item)
+		}
+	}
+
+	var found map[string]types.AttributeValue
+
+	err = p.queryHistory(ctx, op.ID, sk, aws.String("#ts <= :asof"), map[string]types.AttributeValue{
+		":asof": &types.AttributeValueMemberN{Value: strconv.FormatInt(op.AsOf, 10)},
+	}, func(item map[string]types.AttributeValue) bool {
+		found = item
+		return false
+	})
+
+	if err != nil {
+		return []persistencemodel.ReadResult{{ID: op.ID, Error: readBatchErrorFixup(err)}}
+	}
+
+	if found == nil {
+		return []persistencemodel.ReadResult{{
+			ID:    op.ID,
+			Error: persistencemodel.Error404(fmt.Sprintf("no version as of %d", op.AsOf)),
+		}}
+	}
+
+	return p.itemToReadResults(op, found)
+}
+
+// History implements the `persistencemodel.HistoryReader` interface. The versions are only retained when
+// `Config.History` is set.
+func (p *Persistence) History(ctx context.Context, id persistencemodel.PersistenceID) ([]persistencemodel.ListResult, error) {
+	var res []persistencemodel.ListResult
+
+	err := p.queryHistory(ctx, id, toCurrentSortKey(id), nil, nil, func(item map[string]types.AttributeValue) bool {
+		var stored PartialPersistenceObject
+
+		if err := attributevalue.UnmarshalMap(item, &stored); err == nil {
+			res = append(res, persistencemodel.ListResult{
+				ID:          id,
+				Version:     stored.Version,
+				TimeStamp:   stored.TimeStamp,
+				ClientToken: stored.ClientToken,
+			})
+		}
+
+		return true
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("query history failed: %w", err)
+	}
+
+	return res, nil
+}
+
+// queryHistory queries the retained versions of _sk_, newest first, and invokes _f_ for each until it returns
+// `false`. The optional _filter_ may use _#ts_ for the timestamp attribute.
+func (p *Persistence) queryHistory(
+	ctx context.Context,
+	id persistencemodel.PersistenceID,
+	sk string,
+	filter *string,
+	values map[string]types.AttributeValue,
+	f func(item map[string]types.AttributeValue) bool,
+) error {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(p.config.Table),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: toPartitionKey(id)},
+			":prefix": &types.AttributeValueMemberS{Value: toHistoryPrefix(sk)},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+
+	if filter != nil {
+		input.FilterExpression = filter
+		input.ExpressionAttributeNames = map[string]string{"#ts": "TimeStamp"}
+
+		for k, v := range values {
+			input.ExpressionAttributeValues[k] = v
+		}
+	}
+
+	for {
+		out, err := p.client.Query(ctx, input)
+
+		if err != nil {
+			return err
+		}
+
+		for _, item := range out.Items {
+			if !f(item) {
+				return nil
+			}
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return nil
+		}
+
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// itemToReadResults converts a stored _item_ into read results.
+func (p *Persistence) itemToReadResults(
+	op persistencemodel.ReadOperation,
+	item map[string]types.AttributeValue,
+) []persistencemodel.ReadResult {
+	var stored PartialPersistenceObject
+
+	if err := attributevalue.UnmarshalMap(item, &stored); err != nil {
+		return []persistencemodel.ReadResult{{ID: op.ID, Error: fmt.Errorf("unmarshal persist object failed: %w", err)}}
+	}
+
+	return toReadResults(op, item, stored)
+}
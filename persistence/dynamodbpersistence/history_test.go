@@ -0,0 +1,180 @@
+//go:build integration
+// +build integration
+
+package dynamodbpersistence_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/persistence/dynamodbpersistence"
+	"github.com/mariotoffia/godeviceshadow/persistence/dynamodbpersistence/dynamodbutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryRetainsLastVersions(t *testing.T) {
+	ctx := context.Background()
+
+	res := dynamodbutils.NewTestTableResource(ctx, TestTableName)
+	defer res.Dispose(ctx, dynamodbutils.DisposeOpts{DeleteItems: true})
+
+	p, err := dynamodbpersistence.New(ctx, dynamodbpersistence.Config{
+		Table:           res.Table,
+		Client:          res.Client,
+		ModelSeparation: persistencemodel.SeparateModels,
+		History:         persistencemodel.Retention{MaxVersions: 3},
+	})
+	require.NoError(t, err)
+
+	id := persistencemodel.PersistenceID{ID: "deviceH", Name: "shadowH", ModelType: persistencemodel.ModelTypeDesired}
+	zones := []string{"UTC", "Europe/Stockholm", "Europe/Oslo", "Europe/Helsinki", "Europe/Berlin"}
+	timestamps := make([]int64, 0, len(zones))
+
+	for i, zone := range zones {
+		wr := p.Write(ctx, persistencemodel.WriteOptions{}, persistencemodel.WriteOperation{
+			ClientID: "test", ID: id, Model: TestModel{TimeZone: zone}, Version: int64(i),
+		})
+
+		require.Len(t, wr, 1)
+		require.NoError(t, wr[0].Error)
+		require.Equal(t, int64(i+1), wr[0].Version)
+
+		timestamps = append(timestamps, wr[0].TimeStamp)
+		time.Sleep(time.Millisecond)
+	}
+
+	history, err := p.History(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, []int64{5, 4, 3}, []int64{history[0].Version, history[1].Version, history[2].Version})
+
+	read := func(op persistencemodel.ReadOperation) persistencemodel.ReadResult {
+		op.ID, op.Model = id, reflect.TypeOf(&TestModel{})
+		rr := p.Read(ctx, persistencemodel.ReadOptions{}, op)
+		require.Len(t, rr, 1)
+
+		return rr[0]
+	}
+
+	rr := read(persistencemodel.ReadOperation{Version: 3})
+	require.NoError(t, rr.Error)
+	assert.Equal(t, int64(3), rr.Version)
+	assert.Equal(t, "Europe/Oslo", rr.Model.(*TestModel).TimeZone)
+
+	// Not retained
+	rr = read(persistencemodel.ReadOperation{Version: 2})
+	assert.Error(t, rr.Error)
+
+	// As of the time when version 4 was written
+	rr = read(persistencemodel.ReadOperation{AsOf: timestamps[3]})
+	require.NoError(t, rr.Error)
+	assert.Equal(t, int64(4), rr.Version)
+	assert.Equal(t, "Europe/Helsinki", rr.Model.(*TestModel).TimeZone)
+
+	// Latest
+	rr = read(persistencemodel.ReadOperation{AsOf: time.Now().UTC().UnixNano()})
+	require.NoError(t, rr.Error)
+	assert.Equal(t, int64(5), rr.Version)
+
+	// Before first version
+	rr = read(persistencemodel.ReadOperation{AsOf: timestamps[0] - 1})
+
+	var pe persistencemodel.PersistenceError
+
+	require.ErrorAs(t, rr.Error, &pe)
+	assert.Equal(t, 404, pe.Code)
+}
+
+func TestHistoryDeleteRecreateContinuesVersion(t *testing.T) {
+	ctx := context.Background()
+
+	res := dynamodbutils.NewTestTableResource(ctx, TestTableName)
+	defer res.Dispose(ctx, dynamodbutils.DisposeOpts{DeleteItems: true})
+
+	p, err := dynamodbpersistence.New(ctx, dynamodbpersistence.Config{
+		Table:           res.Table,
+		Client:          res.Client,
+		ModelSeparation: persistencemodel.SeparateModels,
+		History:         persistencemodel.Retention{MaxVersions: 10},
+	})
+	require.NoError(t, err)
+
+	id := persistencemodel.PersistenceID{ID: "deviceR", Name: "shadowR", ModelType: persistencemodel.ModelTypeDesired}
+
+	write := func(zone string, version int64) int64 {
+		wr := p.Write(ctx, persistencemodel.WriteOptions{}, persistencemodel.WriteOperation{
+			ClientID: "test", ID: id, Model: TestModel{TimeZone: zone}, Version: version,
+		})
+
+		require.Len(t, wr, 1)
+		require.NoError(t, wr[0].Error)
+
+		return wr[0].Version
+	}
+
+	read := func(version int64) persistencemodel.ReadResult {
+		rr := p.Read(ctx, persistencemodel.ReadOptions{}, persistencemodel.ReadOperation{
+			ID: id, Model: reflect.TypeOf(&TestModel{}), Version: version,
+		})
+
+		require.Len(t, rr, 1)
+
+		return rr[0]
+	}
+
+	require.Equal(t, int64(1), write("UTC", 0))
+	require.Equal(t, int64(2), write("Europe/Stockholm", 1))
+
+	dr := p.Delete(ctx, persistencemodel.WriteOptions{}, persistencemodel.WriteOperation{ID: id})
+	require.Len(t, dr, 1)
+	require.NoError(t, dr[0].Error)
+
+	// The new model continues after the deleted one
+	require.Equal(t, int64(3), write("Europe/Oslo", 0))
+
+	rr := read(2)
+	require.NoError(t, rr.Error)
+	assert.Equal(t, "Europe/Stockholm", rr.Model.(*TestModel).TimeZone)
+
+	rr = read(3)
+	require.NoError(t, rr.Error)
+	assert.Equal(t, "Europe/Oslo", rr.Model.(*TestModel).TimeZone)
+
+	history, err := p.History(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, []int64{3, 2, 1}, []int64{history[0].Version, history[1].Version, history[2].Version})
+}
+
+func TestHistoryRejectsHashInName(t *testing.T) {
+	ctx := context.Background()
+
+	res := dynamodbutils.NewTestTableResource(ctx, TestTableName)
+	defer res.Dispose(ctx, dynamodbutils.DisposeOpts{DeleteItems: true})
+
+	p, err := dynamodbpersistence.New(ctx, dynamodbpersistence.Config{
+		Table:           res.Table,
+		Client:          res.Client,
+		ModelSeparation: persistencemodel.SeparateModels,
+		History:         persistencemodel.Retention{MaxVersions: 10},
+	})
+	require.NoError(t, err)
+
+	wr := p.Write(ctx, persistencemodel.WriteOptions{}, persistencemodel.WriteOperation{
+		ClientID: "test",
+		ID:       persistencemodel.PersistenceID{ID: "deviceX", Name: "shadow#1", ModelType: persistencemodel.ModelTypeReported},
+		Model:    TestModel{TimeZone: tz},
+	})
+
+	require.Len(t, wr, 1)
+
+	var pe persistencemodel.PersistenceError
+
+	require.ErrorAs(t, wr[0].Error, &pe)
+	assert.Equal(t, 400, pe.Code)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/persistutils"
 )
 
 // List retrieves models according to the provided ListOptions.
@@ -25,6 +26,18 @@ func (p *Persistence) List(
 	ctx context.Context,
 	opt persistencemodel.ListOptions,
 ) (*persistencemodel.ListResults, error) {
+	ctx, obs := persistutils.Observe(ctx, p.config.Observer, observedPersistence, "list", 1)
+
+	res, err := p.list(ctx, opt)
+	obs.EndErrors(err)
+
+	return res, err
+}
+
+func (p *Persistence) list(
+	ctx context.Context,
+	opt persistencemodel.ListOptions,
+) (*persistencemodel.ListResults, error) {
 
 	var (
 		results      []persistencemodel.ListResult
@@ -0,0 +1,38 @@
+package dynamodbpersistence
+
+import (
+	"context"
+
+	"github.com/mariotoffia/godeviceshadow/model/observemodel"
+)
+
+// observedPersistence is the value of the `observemodel.AttrPersistence` attribute.
+const observedPersistence = "dynamodb"
+
+// count adds _delta_ to the counter _name_ of the _operation_ when a `Config.Observer` is set.
+func (p *Persistence) count(ctx context.Context, name, operation string, delta int) {
+	if p.config.Observer == nil || delta == 0 {
+		return
+	}
+
+	p.config.Observer.Count(ctx, name, int64(delta),
+		observemodel.Attr(observemodel.AttrPersistence, observedPersistence),
+		observemodel.Attr(observemodel.AttrOperation, operation),
+	)
+}
+
+// observeBatch records the _size_ of a DynamoDB batch request and, when _attempt_ is greater than one, a retry.
+func (p *Persistence) observeBatch(ctx context.Context, operation string, attempt, size int) {
+	if p.config.Observer == nil {
+		return
+	}
+
+	p.config.Observer.Record(ctx, observemodel.PersistenceBatchSize, float64(size),
+		observemodel.Attr(observemodel.AttrPersistence, observedPersistence),
+		observemodel.Attr(observemodel.AttrOperation, operation),
+	)
+
+	if attempt > 1 {
+		p.count(ctx, observemodel.PersistenceRetries, operation, 1)
+	}
+}
@@ -8,8 +8,10 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 
+	"github.com/mariotoffia/godeviceshadow/model/observemodel"
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
 	"github.com/mariotoffia/godeviceshadow/utils"
+	"github.com/mariotoffia/godeviceshadow/utils/persistutils"
 )
 
 // Read uses BatchGetItem to fetch the items for each ReadOperation.
@@ -18,12 +20,18 @@ func (p *Persistence) Read(
 	ctx context.Context,
 	opt persistencemodel.ReadOptions,
 	operations ...persistencemodel.ReadOperation,
-) []persistencemodel.ReadResult {
+) (results []persistencemodel.ReadResult) {
 	if len(operations) == 0 {
 		return nil
 	}
 
-	results := make([]persistencemodel.ReadResult, 0, len(operations))
+	ctx, obs := persistutils.Observe(ctx, p.config.Observer, observedPersistence, "read", len(operations))
+
+	defer func() {
+		obs.EndRead(results...)
+	}()
+
+	results = make([]persistencemodel.ReadResult, 0, len(operations))
 
 	table := p.config.Table
 	maxBatchSize := 100
@@ -37,7 +45,18 @@ func (p *Persistence) Read(
 		maxRetries = p.config.MaxReadRetries
 	}
 
-	items, errors := prepareRead(operations, table, maxBatchSize)
+	// Reads as of a timestamp cannot be batched
+	current := make([]persistencemodel.ReadOperation, 0, len(operations))
+
+	for _, op := range operations {
+		if op.AsOf > 0 && op.Version <= 0 {
+			results = append(results, p.readAsOf(ctx, op)...)
+		} else {
+			current = append(current, op)
+		}
+	}
+
+	items, errors := prepareRead(current, table, maxBatchSize)
 	results = append(results, errors...)
 
 	for _, req := range items {
@@ -80,7 +99,13 @@ func (p *Persistence) readBatch(ctx context.Context, batch ReadBatch, table stri
 			continue
 		}
 
-		// Ensure version matches -> 409
+		// Ensure version matches -> retained version or 409
+		if op.Version > 0 && op.Version != stored.Version && p.config.History.Enabled() {
+			results = append(results, p.readVersion(ctx, op, item["SK"].(*types.AttributeValueMemberS).Value)...)
+
+			continue
+		}
+
 		if op.Version > 0 && op.Version != stored.Version {
 			results = append(results, persistencemodel.ReadResult{
 				ID: persistencemodel.PersistenceID{ID: op.ID.ID, Name: name, ModelType: op.ID.ModelType},
@@ -92,37 +117,7 @@ func (p *Persistence) readBatch(ctx context.Context, batch ReadBatch, table stri
 			continue
 		}
 
-		if isMapValue(item, "Desired") {
-			if res, err := unmarshalFromMap(item["Desired"], op.Model); err != nil {
-				results = append(results, persistencemodel.ReadResult{
-					ID: op.ID.ToPersistenceID(persistencemodel.ModelTypeDesired), Error: fmt.Errorf("unmarshal desired failed: %w", err),
-				})
-			} else {
-				results = append(results, persistencemodel.ReadResult{
-					ID:          op.ID.ToPersistenceID(persistencemodel.ModelTypeDesired),
-					Model:       res,
-					Version:     stored.Version,
-					TimeStamp:   stored.TimeStamp,
-					ClientToken: stored.ClientToken,
-				})
-			}
-		}
-
-		if isMapValue(item, "Reported") {
-			if res, err := unmarshalFromMap(item["Reported"], op.Model); err != nil {
-				results = append(results, persistencemodel.ReadResult{
-					ID: op.ID.ToPersistenceID(persistencemodel.ModelTypeReported), Error: fmt.Errorf("unmarshal reported failed: %w", err),
-				})
-			} else {
-				results = append(results, persistencemodel.ReadResult{
-					ID:          op.ID.ToPersistenceID(persistencemodel.ModelTypeReported),
-					Model:       res,
-					Version:     stored.Version,
-					TimeStamp:   stored.TimeStamp,
-					ClientToken: stored.ClientToken,
-				})
-			}
-		}
+		results = append(results, toReadResults(op, item, stored)...)
 	}
 
 	return results
@@ -155,6 +150,8 @@ func (p *Persistence) batchGetItems(
 			return items, p.buildFailedResults(batch, current.RequestItems[table].Keys, err)
 		}
 
+		p.observeBatch(ctx, "read", attempts, len(current.RequestItems[table].Keys))
+
 		res, err := p.client.BatchGetItem(ctx, current)
 
 		if err != nil {
@@ -173,6 +170,8 @@ func (p *Persistence) batchGetItems(
 			return items, nil
 		}
 
+		p.count(ctx, observemodel.PersistenceUnprocessed, "read", len(unprocessed.Keys))
+
 		// max retries -> all unprocessed keys marked as failed
 		if attempts == maxRetries {
 			return items, p.buildFailedResults(batch, unprocessed.Keys,
@@ -332,3 +331,48 @@ func missing(operations []persistencemodel.ReadOperation, results []persistencem
 
 	return missing
 }
+
+// toReadResults converts the stored _item_ into one result for each model (desired, reported) in it.
+func toReadResults(
+	op persistencemodel.ReadOperation,
+	item map[string]types.AttributeValue,
+	stored PartialPersistenceObject,
+) []persistencemodel.ReadResult {
+	var results []persistencemodel.ReadResult
+
+	if isMapValue(item, "Desired") {
+		if res, err := unmarshalModel(item["Desired"], op, stored.SchemaVersion); err != nil {
+			results = append(results, persistencemodel.ReadResult{
+				ID: op.ID.ToPersistenceID(persistencemodel.ModelTypeDesired), Error: fmt.Errorf("unmarshal desired failed: %w", err),
+			})
+		} else {
+			results = append(results, persistencemodel.ReadResult{
+				ID:            op.ID.ToPersistenceID(persistencemodel.ModelTypeDesired),
+				Model:         res,
+				Version:       stored.Version,
+				TimeStamp:     stored.TimeStamp,
+				ClientToken:   stored.ClientToken,
+				SchemaVersion: stored.SchemaVersion,
+			})
+		}
+	}
+
+	if isMapValue(item, "Reported") {
+		if res, err := unmarshalModel(item["Reported"], op, stored.SchemaVersion); err != nil {
+			results = append(results, persistencemodel.ReadResult{
+				ID: op.ID.ToPersistenceID(persistencemodel.ModelTypeReported), Error: fmt.Errorf("unmarshal reported failed: %w", err),
+			})
+		} else {
+			results = append(results, persistencemodel.ReadResult{
+				ID:            op.ID.ToPersistenceID(persistencemodel.ModelTypeReported),
+				Model:         res,
+				Version:       stored.Version,
+				TimeStamp:     stored.TimeStamp,
+				ClientToken:   stored.ClientToken,
+				SchemaVersion: stored.SchemaVersion,
+			})
+		}
+	}
+
+	return results
+}
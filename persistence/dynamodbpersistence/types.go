@@ -32,6 +32,10 @@ func New(ctx context.Context, config Config) (*Persistence, error) {
 		cfg.ModelSeparation = persistencemodel.CombinedModels
 	}
 
+	if cfg.HistoryTTLAttribute == "" {
+		cfg.HistoryTTLAttribute = "ExpiresAt"
+	}
+
 	if cfg.Client == nil {
 		awscfg, err := awsconfig.LoadDefaultConfig(ctx)
 
@@ -58,6 +62,8 @@ type PersistenceObject struct {
 	TimeStamp int64 `json:"timestamp"`
 	// ClientToken is a unique token for the client that initiated the request
 	ClientToken string `json:"clientToken,omitempty"`
+	// SchemaVersion is the schema version of the model(s).
+	SchemaVersion int `json:"schema,omitempty"`
 	// Desired is the desired model (if such is present). Depending on how the persistence is
 	// configured, this may be stored separately from the reported model.
 	Desired any `json:"desired,omitempty"`
@@ -74,4 +80,6 @@ type PartialPersistenceObject struct {
 	TimeStamp int64 `json:"timestamp"`
 	// ClientToken is a unique token for the client that initiated the request
 	ClientToken string `json:"clientToken,omitempty"`
+	// SchemaVersion is the schema version of the model(s).
+	SchemaVersion int `json:"schema,omitempty"`
 }
@@ -113,6 +113,40 @@ func unmarshalFromMap(m types.AttributeValue, t reflect.Type) (any, error) {
 	return nil, fmt.Errorf("expected AttributeValueMemberM but got: %T", m)
 }
 
+// unmarshalModel unmarshals the model _m_ into the `ReadOperation.Model`. If persisted with an older _schemaVersion_
+// than the `ReadOperation.Schema`, it is unmarshalled into a map, migrated and then unmarshalled into the model.
+func unmarshalModel(m types.AttributeValue, op persistencemodel.ReadOperation, schemaVersion int) (any, error) {
+	if !op.Schema.Outdated(schemaVersion) {
+		return unmarshalFromMap(m, op.Model)
+	}
+
+	des, ok := m.(*types.AttributeValueMemberM)
+
+	if !ok || des == nil {
+		return nil, fmt.Errorf("expected AttributeValueMemberM but got: %T", m)
+	}
+
+	var data map[string]any
+
+	if err := attributevalue.UnmarshalMap(des.Value, &data); err != nil {
+		return nil, fmt.Errorf("unmarshal for migration failed: %w", err)
+	}
+
+	data, err := op.Schema.Migrate(data, schemaVersion)
+
+	if err != nil {
+		return nil, err
+	}
+
+	migrated, err := attributevalue.MarshalMap(data)
+
+	if err != nil {
+		return nil, fmt.Errorf("marshal of migrated model failed: %w", err)
+	}
+
+	return unmarshalFromMap(&types.AttributeValueMemberM{Value: migrated}, op.Model)
+}
+
 // diffWriteRequest returns the items in `write` that are NOT in `unprocessed`.
 func diffWriteRequest(write, unprocessed []types.WriteRequest) []types.WriteRequest {
 	if len(unprocessed) == 0 {
@@ -27,7 +27,13 @@ func (p *Persistence) Write(
 	ctx context.Context,
 	opt persistencemodel.WriteOptions,
 	operations ...persistencemodel.WriteOperation,
-) []persistencemodel.WriteResult {
+) (res []persistencemodel.WriteResult) {
+	ctx, obs := persistutils.Observe(ctx, p.config.Observer, observedPersistence, "write", len(operations))
+
+	defer func() {
+		obs.End(res...)
+	}()
+
 	// Use default separation
 	sep := p.config.ModelSeparation
 
@@ -47,6 +53,10 @@ func (p *Persistence) Write(
 		}
 
 		groups[i].Error = persistutils.Validate(groups[i])
+
+		if groups[i].Error == nil {
+			groups[i].Error = p.validateName(groups[i].Name)
+		}
 	}
 
 	maxParallelism := p.config.MaxWriteParallelism
@@ -54,7 +64,7 @@ func (p *Persistence) Write(
 		maxParallelism = 1
 	}
 
-	res := make([]persistencemodel.WriteResult, 0, len(operations))
+	res = make([]persistencemodel.WriteResult, 0, len(operations))
 
 	if maxParallelism == 1 {
 		// Single thread
@@ -142,6 +152,26 @@ func (p *Persistence) dynamoDbPut(
 		expectedVersionValueKey: &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", expectedVersion)},
 	}
 
+	if p.config.History.Enabled() {
+		// Write the item and the retained version atomically
+		transactions := append([]types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:                 aws.String(p.config.Table),
+					Item:                      item,
+					ConditionExpression:       aws.String(conditionWriteExpression),
+					ExpressionAttributeValues: expressionValues,
+				},
+			},
+		}, p.historyWrites(item, sk, obj.Version, obj.TimeStamp)...)
+
+		_, err = p.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: transactions,
+		})
+
+		return err
+	}
+
 	// Execute the conditional write
 	_, err = p.client.PutItem(ctx, &dynamodb.PutItemInput{
 		TableName:                 aws.String(p.config.Table),
@@ -22,19 +22,29 @@ func (p *Persistence) writeCombined(
 	reported := group.GetByModelType(persistencemodel.ModelTypeReported)
 	desired := group.GetByModelType(persistencemodel.ModelTypeDesired)
 
-	// Create PersistenceObject with both Desired and Reported
 	now := time.Now().UTC().UnixNano()
 
+	next, err := p.nextVersion(ctx, pk, sk, reported.Version)
+
+	if err != nil {
+		return []persistencemodel.WriteResult{
+			{ID: reported.ID, Version: reported.Version, TimeStamp: now, Error: err},
+			{ID: desired.ID, Version: reported.Version, TimeStamp: now, Error: err},
+		}
+	}
+
+	// Create PersistenceObject with both Desired and Reported
 	obj := PersistenceObject{
-		Version:     reported.Version + 1,
-		TimeStamp:   now,
-		ClientToken: reported.ClientID,
-		Desired:     desired.Model,
-		Reported:    reported.Model,
+		Version:       next,
+		TimeStamp:     now,
+		ClientToken:   reported.ClientID,
+		Desired:       desired.Model,
+		Reported:      reported.Model,
+		SchemaVersion: max(desired.SchemaVersion, reported.SchemaVersion),
 	}
 
 	// Perform conditional write
-	err := p.dynamoDbPut(ctx, pk, sk, obj, reported.Version)
+	err = p.dynamoDbPut(ctx, pk, sk, obj, reported.Version)
 
 	version := obj.Version
 
@@ -45,10 +45,17 @@ func (p *Persistence) writeSingle(
 	sk := toSortKey(op.ID, modelType)
 	now := time.Now().UTC().UnixNano()
 
+	next, err := p.nextVersion(ctx, pk, sk, op.Version)
+
+	if err != nil {
+		return persistencemodel.WriteResult{ID: op.ID, Version: op.Version, TimeStamp: now, Error: err}
+	}
+
 	obj := PersistenceObject{
-		Version:     op.Version + 1,
-		TimeStamp:   now,
-		ClientToken: op.ClientID,
+		Version:       next,
+		TimeStamp:     now,
+		ClientToken:   op.ClientID,
+		SchemaVersion: op.SchemaVersion,
 	}
 
 	if modelType == persistencemodel.ModelTypeReported {
@@ -57,7 +64,7 @@ func (p *Persistence) writeSingle(
 		obj.Desired = op.Model
 	}
 
-	err := p.dynamoDbPut(ctx, pk, sk, obj, op.Version)
+	err = p.dynamoDbPut(ctx, pk, sk, obj, op.Version)
 
 	version := obj.Version
 
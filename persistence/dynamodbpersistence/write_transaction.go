@@ -25,17 +25,33 @@ func (p *Persistence) writeTransactional(
 
 	now := time.Now().UTC().UnixNano()
 
+	reportedNext, err := p.nextVersion(ctx, pk, reportedKey, reported.Version)
+	desiredNext, err2 := p.nextVersion(ctx, pk, desiredKey, desired.Version)
+
+	if err == nil && err2 != nil {
+		err = err2
+	}
+
+	if err != nil {
+		return []persistencemodel.WriteResult{
+			{ID: reported.ID, Version: reported.Version, TimeStamp: now, Error: err},
+			{ID: desired.ID, Version: desired.Version, TimeStamp: now, Error: err},
+		}
+	}
+
 	reportedObj := PersistenceObject{
-		Version:     reported.Version + 1,
-		TimeStamp:   now,
-		ClientToken: reported.ClientID,
-		Reported:    reported.Model,
+		Version:       reportedNext,
+		TimeStamp:     now,
+		ClientToken:   reported.ClientID,
+		Reported:      reported.Model,
+		SchemaVersion: reported.SchemaVersion,
 	}
 	desiredObj := PersistenceObject{
-		Version:     desired.Version + 1,
-		TimeStamp:   now,
-		ClientToken: desired.ClientID,
-		Desired:     desired.Model,
+		Version:       desiredNext,
+		TimeStamp:     now,
+		ClientToken:   desired.ClientID,
+		Desired:       desired.Model,
+		SchemaVersion: desired.SchemaVersion,
 	}
 
 	reportItem, err := marshalDynamoDBItem(reportedKey, pk, reportedObj)
@@ -86,6 +102,11 @@ func (p *Persistence) writeTransactional(
 		},
 	}
 
+	if p.config.History.Enabled() {
+		transactions = append(transactions, p.historyWrites(reportItem, reportedKey, reportedObj.Version, now)...)
+		transactions = append(transactions, p.historyWrites(desiredItem, desiredKey, desiredObj.Version, now)...)
+	}
+
 	_, err = p.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
 		TransactItems: transactions,
 	})
@@ -0,0 +1,124 @@
+package mempersistence
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/persistutils"
+)
+
+// Copy implements the `persistencemodel.Copier` interface. All operations are applied under a single lock and
+// hence atomically.
+func (p *Persistence) Copy(
+	ctx context.Context,
+	opt persistencemodel.WriteOptions,
+	operations ...persistencemodel.CopyOperation,
+) (results []persistencemodel.WriteResult) {
+	_, obs := persistutils.Observe(ctx, p.opt.Observer, "memory", "copy", len(operations))
+
+	defer func() {
+		obs.End(results...)
+	}()
+
+	results = make([]persistencemodel.WriteResult, len(operations))
+
+	for i, op := range operations {
+		results[i].ID = op.To
+	}
+
+	if opt.Tx != nil {
+		for i := range results {
+			results[i].Error = persistencemodel.Error400("Transactions are not supported")
+		}
+
+		return results
+	}
+
+	if len(operations) == 0 {
+		return nil
+	}
+
+	entries, err := p.store.CopyEntries(time.Now().UTC().UnixNano(), operations...)
+
+	for i, entry := range entries {
+		switch {
+		case err != nil:
+			results[i].Error = err
+		case entry == nil:
+			results[i].Error = persistencemodel.Error404(fmt.Sprintf("%s - Not found", operations[i].From))
+		default:
+			results[i].Version = entry.version
+			results[i].TimeStamp = entry.timestamp
+		}
+	}
+
+	return results
+}
+
+// CopyEntries copies, or moves, the entries of the _operations_. Either all are applied or none. The entry of an
+// optional operation, where the source is not found, is `nil`.
+func (s *Store) CopyEntries(now int64, operations ...persistencemodel.CopyOperation) ([]*modelEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]*modelEntry, len(operations))
+	targets := map[string]bool{}
+
+	for i, op := range operations {
+		if op.From.ModelType != op.To.ModelType {
+			return entries, persistencemodel.Error400(fmt.Sprintf("model type of %s and %s differs", op.From, op.To))
+		}
+
+		src, ok := s.partitions[op.From.ID][renderSortKey(op.From.ModelType, op.From.Name)]
+
+		if !ok {
+			if op.Optional {
+				continue
+			}
+
+			return entries, persistencemodel.Error404(fmt.Sprintf("%s - Not found", op.From))
+		}
+
+		if op.Version > 0 && src.version != op.Version {
+			return entries, persistencemodel.Error409(fmt.Sprintf("Conflict, version mismatch of %s", op.From))
+		}
+
+		target := op.To.ID + "/" + renderSortKey(op.To.ModelType, op.To.Name)
+
+		if _, ok := s.partitions[op.To.ID][renderSortKey(op.To.ModelType, op.To.Name)]; ok || targets[target] {
+			return entries, persistencemodel.Error409(fmt.Sprintf("Conflict, %s already exists", op.To))
+		}
+
+		targets[target] = true
+
+		entry := src.copy()
+		entry.version = s.nextVersion(op.To.ID, renderSortKey(op.To.ModelType, op.To.Name))
+		entry.timestamp = now
+		entry.clientToken = op.ClientID
+
+		entries[i] = entry
+	}
+
+	for i, op := range operations {
+		if entries[i] == nil {
+			continue
+		}
+
+		if op.Move {
+			delete(s.partitions[op.From.ID], renderSortKey(op.From.ModelType, op.From.Name))
+		}
+
+		id := renderSortKey(op.To.ModelType, op.To.Name)
+
+		if _, ok := s.partitions[op.To.ID]; !ok {
+			s.partitions[op.To.ID] = Partition{}
+		}
+
+		s.partitions[op.To.ID][id] = entries[i]
+		s.retain(op.To.ID, id, entries[i])
+	}
+
+	return entries, nil
+}
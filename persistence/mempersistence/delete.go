@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/persistutils"
 )
 
 // Delete deletes models from the in-memory persistence. Supports optional version constraints.
@@ -11,8 +12,14 @@ func (p *Persistence) Delete(
 	ctx context.Context,
 	opt persistencemodel.WriteOptions,
 	operations ...persistencemodel.WriteOperation,
-) []persistencemodel.WriteResult {
-	results := make([]persistencemodel.WriteResult, len(operations))
+) (results []persistencemodel.WriteResult) {
+	_, obs := persistutils.Observe(ctx, p.opt.Observer, "memory", "delete", len(operations))
+
+	defer func() {
+		obs.End(results...)
+	}()
+
+	results = make([]persistencemodel.WriteResult, len(operations))
 
 	if opt.Tx != nil {
 		for i, op := range operations {
@@ -0,0 +1,165 @@
+package mempersistence_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/persistence/mempersistence"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryRetainsLastVersions(t *testing.T) {
+	ctx := context.Background()
+	persistence := mempersistence.New(mempersistence.PersistenceOpts{
+		Separation: persistencemodel.SeparateModels,
+		History:    persistencemodel.Retention{MaxVersions: 3},
+	})
+
+	id := persistencemodel.PersistenceID{ID: "device123", Name: "HomeHub", ModelType: persistencemodel.ModelTypeDesired}
+	model := map[string]any{}
+	timestamps := make([]int64, 0, 5)
+
+	for i := range 5 {
+		model["sp"] = 20.0 + float64(i) // same instance is modified, the history shall not be
+
+		wr := persistence.Write(ctx, persistencemodel.WriteOptions{}, persistencemodel.WriteOperation{
+			ID: id, Model: model, Version: int64(i),
+		})
+
+		require.Len(t, wr, 1)
+		require.NoError(t, wr[0].Error)
+		require.Equal(t, int64(i+1), wr[0].Version)
+
+		timestamps = append(timestamps, wr[0].TimeStamp)
+		time.Sleep(time.Millisecond)
+	}
+
+	history, err := persistence.History(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, []int64{5, 4, 3}, []int64{history[0].Version, history[1].Version, history[2].Version})
+
+	read := func(op persistencemodel.ReadOperation) persistencemodel.ReadResult {
+		op.ID, op.Model = id, reflect.TypeOf(map[string]any{})
+		rr := persistence.Read(ctx, persistencemodel.ReadOptions{}, op)
+		require.Len(t, rr, 1)
+
+		return rr[0]
+	}
+
+	rr := read(persistencemodel.ReadOperation{Version: 3})
+	require.NoError(t, rr.Error)
+	assert.Equal(t, 22.0, rr.Model.(map[string]any)["sp"])
+
+	// Not retained
+	rr = read(persistencemodel.ReadOperation{Version: 2})
+	assert.Error(t, rr.Error)
+
+	// As of the time when version 4 was written
+	rr = read(persistencemodel.ReadOperation{AsOf: timestamps[3]})
+	require.NoError(t, rr.Error)
+	assert.Equal(t, int64(4), rr.Version)
+	assert.Equal(t, 23.0, rr.Model.(map[string]any)["sp"])
+
+	// Latest
+	rr = read(persistencemodel.ReadOperation{AsOf: time.Now().UTC().UnixNano()})
+	require.NoError(t, rr.Error)
+	assert.Equal(t, int64(5), rr.Version)
+
+	// Before first version
+	rr = read(persistencemodel.ReadOperation{AsOf: timestamps[0] - 1})
+	assert.Error(t, rr.Error)
+
+	// History is kept when deleted
+	dr := persistence.Delete(ctx, persistencemodel.WriteOptions{}, persistencemodel.WriteOperation{ID: id})
+	require.NoError(t, dr[0].Error)
+
+	rr = read(persistencemodel.ReadOperation{AsOf: timestamps[4]})
+	require.NoError(t, rr.Error)
+	assert.Equal(t, int64(5), rr.Version)
+}
+
+func TestHistoryMaxAge(t *testing.T) {
+	ctx := context.Background()
+	persistence := mempersistence.New(mempersistence.PersistenceOpts{
+		Separation: persistencemodel.SeparateModels,
+		History:    persistencemodel.Retention{MaxAge: 20 * time.Millisecond},
+	})
+
+	id := persistencemodel.PersistenceID{ID: "device123", Name: "HomeHub", ModelType: persistencemodel.ModelTypeReported}
+
+	for i := range 3 {
+		wr := persistence.Write(ctx, persistencemodel.WriteOptions{}, persistencemodel.WriteOperation{
+			ID: id, Model: map[string]any{"t": i}, Version: int64(i),
+		})
+
+		require.NoError(t, wr[0].Error)
+
+		if i == 0 {
+			time.Sleep(40 * time.Millisecond)
+		}
+	}
+
+	history, err := persistence.History(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, int64(3), history[0].Version)
+	assert.Equal(t, int64(2), history[1].Version)
+}
+
+func TestHistoryDeleteRecreateContinuesVersion(t *testing.T) {
+	ctx := context.Background()
+	persistence := mempersistence.New(mempersistence.PersistenceOpts{
+		Separation: persistencemodel.SeparateModels,
+		History:    persistencemodel.Retention{MaxVersions: 10},
+	})
+
+	id := persistencemodel.PersistenceID{ID: "device123", Name: "HomeHub", ModelType: persistencemodel.ModelTypeDesired}
+
+	write := func(sp float64, version int64) int64 {
+		wr := persistence.Write(ctx, persistencemodel.WriteOptions{}, persistencemodel.WriteOperation{
+			ID: id, Model: map[string]any{"sp": sp}, Version: version,
+		})
+
+		require.NoError(t, wr[0].Error)
+
+		return wr[0].Version
+	}
+
+	read := func(version int64) persistencemodel.ReadResult {
+		rr := persistence.Read(ctx, persistencemodel.ReadOptions{}, persistencemodel.ReadOperation{
+			ID: id, Model: reflect.TypeOf(map[string]any{}), Version: version,
+		})
+
+		require.Len(t, rr, 1)
+
+		return rr[0]
+	}
+
+	require.Equal(t, int64(1), write(20, 0))
+	require.Equal(t, int64(2), write(21, 1))
+
+	dr := persistence.Delete(ctx, persistencemodel.WriteOptions{}, persistencemodel.WriteOperation{ID: id})
+	require.NoError(t, dr[0].Error)
+
+	// The new model continues after the deleted one
+	require.Equal(t, int64(3), write(30, 0))
+
+	rr := read(2)
+	require.NoError(t, rr.Error)
+	assert.Equal(t, 21.0, rr.Model.(map[string]any)["sp"])
+
+	rr = read(3)
+	require.NoError(t, rr.Error)
+	assert.Equal(t, 30.0, rr.Model.(map[string]any)["sp"])
+
+	history, err := persistence.History(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, []int64{3, 2, 1}, []int64{history[0].Version, history[1].Version, history[2].Version})
+}
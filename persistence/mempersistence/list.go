@@ -4,14 +4,20 @@ import (
 	"context"
 
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/persistutils"
 )
 
 // List lists models in the in-memory persistence. SearchExpr is not supported.
 func (p *Persistence) List(
 	ctx context.Context,
 	opt persistencemodel.ListOptions,
-) (persistencemodel.ListResults, error) {
-	//
+) (res persistencemodel.ListResults, err error) {
+	_, obs := persistutils.Observe(ctx, p.opt.Observer, "memory", "list", 1)
+
+	defer func() {
+		obs.EndErrors(err)
+	}()
+
 	if opt.SearchExpr != "" {
 		return persistencemodel.ListResults{}, persistencemodel.Error400("SearchExpr is not supported")
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/persistutils"
 )
 
 // Read reads models from the in-memory persistence by ID and ModelType.
@@ -11,9 +12,14 @@ func (p *Persistence) Read(
 	ctx context.Context,
 	opt persistencemodel.ReadOptions,
 	operations ...persistencemodel.ReadOperation,
-) []persistencemodel.ReadResult {
-	//
-	results := make([]persistencemodel.ReadResult, 0, len(operations))
+) (results []persistencemodel.ReadResult) {
+	ctx, obs := persistutils.Observe(ctx, p.opt.Observer, "memory", "read", len(operations))
+
+	defer func() {
+		obs.EndRead(results...)
+	}()
+
+	results = make([]persistencemodel.ReadResult, 0, len(operations))
 
 	if opt.Tx != nil {
 		for _, op := range operations {
@@ -34,22 +40,38 @@ func (p *Persistence) Read(
 		return nil
 	}
 
-	toResult := func(entry *modelEntry, id persistencemodel.PersistenceID, mt persistencemodel.ModelType, model any) persistencemodel.ReadResult {
-		return persistencemodel.ReadResult{
-			ID: persistencemodel.PersistenceID{
-				ID:        id.ID,
-				Name:      id.Name,
-				ModelType: mt,
-			},
-			Model:       model,
-			Version:     entry.version,
-			TimeStamp:   entry.timestamp,
-			ClientToken: entry.clientToken,
+	for _, op := range operations {
+		toResult := func(entry *modelEntry, id persistencemodel.PersistenceID, mt persistencemodel.ModelType, model any) persistencemodel.ReadResult {
+			res := persistencemodel.ReadResult{
+				ID: persistencemodel.PersistenceID{
+					ID:        id.ID,
+					Name:      id.Name,
+					ModelType: mt,
+				},
+				Model:         model,
+				Version:       entry.version,
+				TimeStamp:     entry.timestamp,
+				ClientToken:   entry.clientToken,
+				SchemaVersion: entry.schemaVersion,
+			}
+
+			if op.Schema.Outdated(entry.schemaVersion) && op.Model != nil {
+				res.Model, res.Error = migrate(model, entry.schemaVersion, op.Schema, op.Model)
+			}
+
+			return res
 		}
-	}
 
-	for _, op := range operations {
-		entry, err := p.store.GetEntry(op.ID.ModelType, op.ID.ID, op.ID.Name, op.Version)
+		var (
+			entry *modelEntry
+			err   error
+		)
+
+		if op.AsOf > 0 && op.Version <= 0 {
+			entry, err = p.store.GetEntryAsOf(op.ID.ModelType, op.ID.ID, op.ID.Name, op.AsOf)
+		} else {
+			entry, err = p.store.GetEntry(op.ID.ModelType, op.ID.ID, op.ID.Name, op.Version)
+		}
 
 		if err != nil {
 			results = append(results, persistencemodel.ReadResult{
@@ -85,3 +107,21 @@ func (p *Persistence) Read(
 
 	return results
 }
+
+// History implements the `persistencemodel.HistoryReader` interface. The versions are only retained when
+// `PersistenceOpts.History` is set.
+func (p *Persistence) History(ctx context.Context, id persistencemodel.PersistenceID) ([]persistencemodel.ListResult, error) {
+	entries := p.store.History(id.ModelType, id.ID, id.Name)
+	res := make([]persistencemodel.ListResult, 0, len(entries))
+
+	for _, e := range entries {
+		res = append(res, persistencemodel.ListResult{
+			ID:          id,
+			Version:     e.version,
+			TimeStamp:   e.timestamp,
+			ClientToken: e.clientToken,
+		})
+	}
+
+	return res, nil
+}
@@ -2,6 +2,8 @@ package mempersistence
 
 import (
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
 )
@@ -93,6 +95,12 @@ func (s *Store) GetEntry(mt persistencemodel.ModelType, pk string, sk string, ve
 	if partition, ok := s.partitions[pk]; ok {
 		if entry, ok := partition[renderSortKey(mt, sk)]; ok {
 			if version > 0 && entry.version != version {
+				if h := s.historyEntry(pk, renderSortKey(mt, sk), func(e *modelEntry) bool {
+					return e.version == version
+				}); h != nil {
+					return h, nil
+				}
+
 				return nil, persistencemodel.Error409("Conflict, version mismatch")
 			}
 
@@ -103,6 +111,82 @@ func (s *Store) GetEntry(mt persistencemodel.ModelType, pk string, sk string, ve
 	return nil, persistencemodel.Error404("Not found")
 }
 
+// GetEntryAsOf returns the entry that was current at _asOf_ (Unix64 bit nanosecond timestamp). It searches the
+// current entry and then the retained history.
+func (s *Store) GetEntryAsOf(mt persistencemodel.ModelType, pk string, sk string, asOf int64) (*modelEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id := renderSortKey(mt, sk)
+
+	if partition, ok := s.partitions[pk]; ok {
+		if entry, ok := partition[id]; ok && entry.timestamp <= asOf {
+			return entry, nil
+		}
+	}
+
+	if h := s.historyEntry(pk, id, func(e *modelEntry) bool { return e.timestamp <= asOf }); h != nil {
+		return h, nil
+	}
+
+	return nil, persistencemodel.Error404(fmt.Sprintf("no version as of %d", asOf))
+}
+
+// History returns the retained versions, newest first.
+func (s *Store) History(mt persistencemodel.ModelType, pk, sk string) []*modelEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Clone(s.history[pk][renderSortKey(mt, sk)])
+}
+
+// historyEntry returns a copy of the newest retained entry that matches _f_. The lock must be held.
+func (s *Store) historyEntry(pk, id string, f func(e *modelEntry) bool) *modelEntry {
+	for _, e := range s.history[pk][id] {
+		if f(e) {
+			return e.copy()
+		}
+	}
+
+	return nil
+}
+
+// nextVersion returns the version of an entry that is created. It continues after the newest retained version,
+// if any, since the history is kept when deleted. Hence, a version is never reused. The lock must be held.
+func (s *Store) nextVersion(pk, id string) int64 {
+	if versions := s.history[pk][id]; len(versions) > 0 {
+		return versions[0].version + 1
+	}
+
+	return 1
+}
+
+// retain adds a copy of the _entry_ to the history and drops the versions that is no longer retained. The lock
+// must be held.
+func (s *Store) retain(pk, id string, entry *modelEntry) {
+	if !s.retention.Enabled() {
+		return
+	}
+
+	partition, ok := s.history[pk]
+
+	if !ok {
+		partition = map[string][]*modelEntry{}
+		s.history[pk] = partition
+	}
+
+	versions := append([]*modelEntry{entry.copy()}, partition[id]...)
+
+	for i, e := range versions {
+		if !s.retention.Retain(i, time.Duration(entry.timestamp-e.timestamp)) {
+			versions = versions[:i]
+			break
+		}
+	}
+
+	partition[id] = versions
+}
+
 func (s *Store) StoreEntry(mt persistencemodel.ModelType, pk, sk string, entry *modelEntry) (*modelEntry, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -110,24 +194,26 @@ func (s *Store) StoreEntry(mt persistencemodel.ModelType, pk, sk string, entry *
 	id := renderSortKey(mt, sk)
 
 	if p, ok := s.partitions[pk]; ok {
-		if s, ok := p[renderSortKey(mt, sk)]; ok {
-			if entry.version > 0 && s.version != entry.version {
+		if current, ok := p[id]; ok {
+			if entry.version > 0 && current.version != entry.version {
 				return nil, persistencemodel.Error409("Conflict, version mismatch")
 			}
 
-			entry.version++
+			entry.version = current.version + 1
 			entry.modelType = mt
 
 			p[id] = entry
 		} else {
-			entry.version = 1
+			entry.version = s.nextVersion(pk, id)
 			p[id] = entry
 		}
 	} else {
-		entry.version = 1
+		entry.version = s.nextVersion(pk, id)
 		entry.modelType = mt
 		s.partitions[pk] = Partition{id: entry}
 	}
 
+	s.retain(pk, id, entry)
+
 	return entry, nil
 }
@@ -3,14 +3,19 @@ package mempersistence
 import (
 	"sync"
 
+	"github.com/mariotoffia/godeviceshadow/model/observemodel"
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/copyutils"
 )
 
 type Partition map[string]*modelEntry
 
 type Store struct {
 	partitions map[string]Partition
-	mu         sync.RWMutex
+	// history are the retained versions, newest first, keyed by partition and sort key.
+	history   map[string]map[string][]*modelEntry
+	retention persistencemodel.Retention
+	mu        sync.RWMutex
 }
 
 // Persistence is a in memory persistence, that stores the model without cloning.
@@ -26,11 +31,28 @@ type modelEntry struct {
 	version     int64
 	timestamp   int64
 	clientToken string
+	// schemaVersion is the schema version of the model(s).
+	schemaVersion int
+}
+
+// copy returns a copy where the models are deep copied.
+func (e *modelEntry) copy() *modelEntry {
+	c := *e
+	c.reported = copyutils.DeepCopy(e.reported)
+	c.desired = copyutils.DeepCopy(e.desired)
+
+	return &c
 }
 
 type PersistenceOpts struct {
 	// Separation is the model separation strategy. Default is `CombinedModels`.
 	Separation persistencemodel.ModelSeparation
+	// History is when set, the retention of previous versions. Those are copied when written, and read, and
+	// hence not shared with the current model. They are kept when the model is deleted and a model that is created
+	// again continues after the newest retained version.
+	History persistencemodel.Retention
+	// Observer is when set, receives a span, the duration, the number of items and failures of each operation.
+	Observer observemodel.Observer
 }
 
 // New creates a new instance of InMemoryReadonlyPersistence.
@@ -46,7 +68,11 @@ func New(opts ...PersistenceOpts) *Persistence {
 	}
 
 	return &Persistence{
-		opt:   opt,
-		store: Store{partitions: map[string]Partition{}},
+		opt: opt,
+		store: Store{
+			partitions: map[string]Partition{},
+			history:    map[string]map[string][]*modelEntry{},
+			retention:  opt.History,
+		},
 	}
 }
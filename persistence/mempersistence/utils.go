@@ -1,8 +1,11 @@
 package mempersistence
 
 import (
+	"encoding/json"
 	"fmt"
+	"reflect"
 
+	"github.com/mariotoffia/godeviceshadow/model"
 	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
 )
 
@@ -18,3 +21,45 @@ func renderSortKey(mt persistencemodel.ModelType, sk string) string {
 
 	panic(fmt.Sprintf("unknown model type: %s", mt.String()))
 }
+
+// migrate migrates the _data_, persisted with the schema version _from_, using its JSON representation and decodes
+// it into a new instance of _rt_.
+func migrate(data any, from int, schema model.Schema, rt reflect.Type) (any, error) {
+	b, err := json.Marshal(data)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal model for migration: %w", err)
+	}
+
+	var m map[string]any
+
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal model for migration: %w", err)
+	}
+
+	if m, err = schema.Migrate(m, from); err != nil {
+		return nil, err
+	}
+
+	if b, err = json.Marshal(m); err != nil {
+		return nil, fmt.Errorf("failed to marshal migrated model: %w", err)
+	}
+
+	ptr := rt.Kind() == reflect.Ptr
+
+	if ptr {
+		rt = rt.Elem()
+	}
+
+	value := reflect.New(rt)
+
+	if err := json.Unmarshal(b, value.Interface()); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal migrated model: %w", err)
+	}
+
+	if ptr {
+		return value.Interface(), nil
+	}
+
+	return value.Elem().Interface(), nil
+}
@@ -13,9 +13,14 @@ func (p *Persistence) Write(
 	ctx context.Context,
 	opt persistencemodel.WriteOptions,
 	operations ...persistencemodel.WriteOperation,
-) []persistencemodel.WriteResult {
-	//
-	results := make([]persistencemodel.WriteResult, 0, len(operations))
+) (results []persistencemodel.WriteResult) {
+	_, obs := persistutils.Observe(ctx, p.opt.Observer, "memory", "write", len(operations))
+
+	defer func() {
+		obs.End(results...)
+	}()
+
+	results = make([]persistencemodel.WriteResult, 0, len(operations))
 	sep := p.opt.Separation
 
 	if opt.Config.Separation != 0 {
@@ -91,27 +96,35 @@ func (p *Persistence) writeCombined(group persistutils.GroupedWriteOperation) []
 	}
 
 	var (
-		version  int64
-		des, rep any
+		version       int64
+		des, rep      any
+		clientToken   string
+		schemaVersion int
 	)
 
 	if desired != nil {
 		version = desired.Version
 		des = desired.Model
+		clientToken = desired.ClientID
+		schemaVersion = desired.SchemaVersion
 	}
 
 	if reported != nil {
 		version = reported.Version
 		rep = reported.Model
+		clientToken = reported.ClientID
+		schemaVersion = max(schemaVersion, reported.SchemaVersion)
 	}
 
 	now := time.Now().UTC().UnixNano()
 	entry, err := p.store.StoreEntry(0 /*combined*/, group.ID, group.Name, &modelEntry{
-		version:   version,
-		timestamp: now,
-		modelType: 0, // Combined
-		desired:   des,
-		reported:  rep,
+		version:       version,
+		timestamp:     now,
+		modelType:     0, // Combined
+		desired:       des,
+		reported:      rep,
+		clientToken:   clientToken,
+		schemaVersion: schemaVersion,
 	})
 
 	if entry == nil {
@@ -141,9 +154,11 @@ func (p *Persistence) writeSingle(op persistencemodel.WriteOperation) persistenc
 	now := time.Now().UTC().UnixNano()
 
 	entry := modelEntry{
-		version:   op.Version,
-		timestamp: now,
-		modelType: op.ID.ModelType,
+		version:       op.Version,
+		timestamp:     now,
+		modelType:     op.ID.ModelType,
+		clientToken:   op.ClientID,
+		schemaVersion: op.SchemaVersion,
 	}
 
 	if op.ID.ModelType == persistencemodel.ModelTypeDesired {
@@ -2,6 +2,7 @@ package types
 
 import (
 	"fmt"
+	"reflect"
 	"sync"
 
 	"github.com/mariotoffia/godeviceshadow/model"
@@ -13,7 +14,9 @@ type TypeRegistryImpl struct {
 	ids       map[string]model.TypeEntry
 	id_names  map[string]model.TypeEntry
 	resolvers []model.TypeRegistryResolver
-	mtx       *sync.RWMutex
+	// schemas are the schemas per model type.
+	schemas map[reflect.Type]model.Schema
+	mtx     *sync.RWMutex
 }
 
 func NewRegistry() *TypeRegistryImpl {
@@ -21,6 +24,7 @@ func NewRegistry() *TypeRegistryImpl {
 		types:    map[string]model.TypeEntry{},
 		ids:      map[string]model.TypeEntry{},
 		id_names: map[string]model.TypeEntry{},
+		schemas:  map[reflect.Type]model.Schema{},
 		mtx:      &sync.RWMutex{},
 	}
 }
@@ -43,6 +47,10 @@ func (r *TypeRegistryImpl) Get(name string) (model.TypeEntry, bool) {
 
 	t, ok := r.types[name]
 
+	if ok {
+		t = r.withSchema(t)
+	}
+
 	r.mtx.RUnlock()
 
 	return t, ok
@@ -64,20 +72,20 @@ func (r *TypeRegistryImpl) ResolveByID(id, name string) (model.TypeEntry, bool)
 
 	for _, resolver := range r.resolvers {
 		if te, ok := resolver.ResolveByID(id, name); ok {
-			return te, true
+			return r.withSchema(te), true
 		}
 	}
 
 	if te, ok := r.id_names[id+name]; ok {
-		return te, true
+		return r.withSchema(te), true
 	}
 
 	if te, ok := r.types[name]; ok {
-		return te, true
+		return r.withSchema(te), true
 	}
 
 	if te, ok := r.ids[id]; ok {
-		return te, true
+		return r.withSchema(te), true
 	}
 
 	return model.TypeEntry{}, false
@@ -159,3 +167,40 @@ func (r *TypeRegistryImpl) RegisterResolver(resolver model.TypeRegistryResolver)
 
 	return r
 }
+
+// RegisterSchema registers the _schema_ of the model type _t_. It is set on all `model.TypeEntry` of the type,
+// regardless of how it is registered or resolved, that do not already have a schema.
+func (r *TypeRegistryImpl) RegisterSchema(t any, schema model.Schema) *TypeRegistryImpl {
+	rt := reflect.TypeOf(t)
+
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+
+	r.mtx.Lock()
+
+	r.schemas[rt] = schema
+
+	r.mtx.Unlock()
+
+	return r
+}
+
+// withSchema sets the registered schema on the _te_ unless it already has one. The lock must be held.
+func (r *TypeRegistryImpl) withSchema(te model.TypeEntry) model.TypeEntry {
+	if te.Schema.Version > 0 || te.Model == nil {
+		return te
+	}
+
+	rt := te.Model
+
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+
+	if schema, ok := r.schemas[rt]; ok {
+		te.Schema = schema
+	}
+
+	return te
+}
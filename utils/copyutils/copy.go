@@ -0,0 +1,75 @@
+package copyutils
+
+import "reflect"
+
+// DeepCopy copies _v_ including maps, slices, pointers and interfaces. This is used where a model is handed
+// to something that may hold on to, and modify, it e.g. a persistence that do not serialize the model.
+//
+// NOTE: Unexported fields are copied shallow.
+func DeepCopy(v any) any {
+	if v == nil {
+		return nil
+	}
+
+	return copyValue(reflect.ValueOf(v)).Interface()
+}
+
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(copyValue(v.Elem()))
+
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.New(v.Type()).Elem()
+		c.Set(copyValue(v.Elem()))
+
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+
+		for iter := v.MapRange(); iter.Next(); {
+			c.SetMapIndex(iter.Key(), copyValue(iter.Value()))
+		}
+
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+
+		for i := range v.Len() {
+			c.Index(i).Set(copyValue(v.Index(i)))
+		}
+
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				c.Field(i).Set(copyValue(v.Field(i)))
+			}
+		}
+
+		return c
+	}
+
+	return v
+}
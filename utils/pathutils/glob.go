@@ -0,0 +1,199 @@
+package pathutils
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultGlobCacheSize is the max number of globs in the `Shared` cache.
+const DefaultGlobCacheSize = 1024
+
+// Shared is a shared cache of compiled globs. It is bounded to `DefaultGlobCacheSize` globs since the patterns
+// may come from clients, e.g. in a query.
+var Shared = &GlobCache{MaxSize: DefaultGlobCacheSize}
+
+// Glob is a compiled glob pattern that matches paths segment by segment.
+//
+// Within a segment `*` matches any number of characters and `?` matches a single character. A segment
+// that is exactly `**` matches zero or more segments. For example `climate.sensors.*.t`
+// matches `climate.sensors.indoor.t` but not `climate.sensors.indoor.h` and `climate.**` matches
+// everything beneath `climate`.
+type Glob struct {
+	pattern  string
+	style    Style
+	segments []string
+}
+
+// CompileGlob compiles the _pattern_. If no _style_ is provided, it is detected from the _pattern_
+// using `DetectStyle`. Paths that are matched are parsed using the same style.
+func CompileGlob(pattern string, style ...Style) (*Glob, error) {
+	st := DetectStyle(pattern)
+
+	if len(style) > 0 {
+		st = style[0]
+	}
+
+	p, err := Parse(pattern, st)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Glob{pattern: pattern, style: st, segments: p.Names()}, nil
+}
+
+// MustCompileGlob is same as `CompileGlob` but panics on error.
+func MustCompileGlob(pattern string, style ...Style) *Glob {
+	g, err := CompileGlob(pattern, style...)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return g
+}
+
+// String returns the original pattern.
+func (g *Glob) String() string {
+	return g.pattern
+}
+
+// Style returns the style used to parse the pattern and the matched paths.
+func (g *Glob) Style() Style {
+	return g.style
+}
+
+// Match returns `true` if the _p_ matches the glob. If _p_ cannot be parsed, it returns `false`.
+func (g *Glob) Match(p string) bool {
+	parsed, err := Parse(p, g.style)
+
+	if err != nil {
+		return false
+	}
+
+	return g.MatchPath(parsed)
+}
+
+// MatchPath returns `true` if the already parsed _p_ matches the glob.
+func (g *Glob) MatchPath(p Path) bool {
+	return matchSegments(g.segments, p.Names())
+}
+
+func matchSegments(pattern, names []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse consecutive ** and try every possible split
+			rest := pattern[1:]
+
+			for i := 0; i <= len(names); i++ {
+				if matchSegments(rest, names[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(names) == 0 {
+			return false
+		}
+
+		if !matchSegment(pattern[0], names[0]) {
+			return false
+		}
+
+		pattern = pattern[1:]
+		names = names[1:]
+	}
+
+	return len(names) == 0
+}
+
+// matchSegment matches a single segment _name_ against _pattern_ where `*` matches any
+// number of characters and `?` exactly one.
+func matchSegment(pattern, name string) bool {
+	if pattern == "*" {
+		return true
+	}
+
+	p, n := []rune(pattern), []rune(name)
+	pi, ni := 0, 0
+	star, mark := -1, 0
+
+	for ni < len(n) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == n[ni]):
+			pi++
+			ni++
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, ni
+			pi++
+		case star != -1:
+			// Backtrack and let the last star consume one more character
+			pi = star + 1
+			mark++
+			ni = mark
+		default:
+			return false
+		}
+	}
+
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+
+	return pi == len(p)
+}
+
+// GlobCache is a cache for compiled globs.
+type GlobCache struct {
+	// MaxSize is the max number of cached globs. When full, the cache is cleared before the next glob is added.
+	// If zero, the cache is unbounded.
+	MaxSize int
+
+	cache sync.Map
+	size  atomic.Int64
+}
+
+// GetOrCompile returns a cached glob for _pattern_ or compiles (and caches) it. The style
+// is detected from the _pattern_.
+func (gc *GlobCache) GetOrCompile(pattern string) (*Glob, error) {
+	if v, ok := gc.cache.Load(pattern); ok {
+		return v.(*Glob), nil
+	}
+
+	g, err := CompileGlob(pattern)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if gc.MaxSize > 0 && gc.size.Load() >= int64(gc.MaxSize) {
+		gc.cache.Clear()
+		gc.size.Store(0)
+	}
+
+	if _, loaded := gc.cache.LoadOrStore(pattern, g); !loaded {
+		gc.size.Add(1)
+	}
+
+	return g, nil
+}
+
+// MustCompile is same as `GetOrCompile` but panics on error.
+func (gc *GlobCache) MustCompile(pattern string) *Glob {
+	g, err := gc.GetOrCompile(pattern)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return g
+}
+
+// Delete removes the glob of _pattern_ from the cache.
+func (gc *GlobCache) Delete(pattern string) {
+	if _, loaded := gc.cache.LoadAndDelete(pattern); loaded {
+		gc.size.Add(-1)
+	}
+}
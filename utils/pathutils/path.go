@@ -0,0 +1,315 @@
+package pathutils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Style is how a path is rendered into, or parsed from, a string.
+type Style int
+
+const (
+	// StyleDotted is the default style where all segments are separated by a dot
+	// e.g. _climate.sensors.0.t_. It cannot represent keys that contain dots.
+	StyleDotted Style = 0
+	// StyleJSONPointer renders the path as a _RFC 6901_ JSON Pointer e.g. _/climate/sensors/0/t_.
+	StyleJSONPointer Style = 1
+	// StyleBracket renders fields dotted and map keys and slice indexes in brackets
+	// e.g. _climate.sensors["indoor.t"][0]_.
+	StyleBracket Style = 2
+)
+
+func (s Style) String() string {
+	switch s {
+	case StyleDotted:
+		return "dotted"
+	case StyleJSONPointer:
+		return "json-pointer"
+	case StyleBracket:
+		return "bracket"
+	}
+
+	return fmt.Sprintf("style id: %d", int(s))
+}
+
+// SegmentKind specifies what a path segment refers to in the model.
+type SegmentKind int
+
+const (
+	// SegmentField is a struct field (JSON tag name or field name).
+	SegmentField SegmentKind = 0
+	// SegmentKey is a map key or an ID of a slice element that is merged by ID.
+	SegmentKey SegmentKind = 1
+	// SegmentIndex is a slice or array index.
+	SegmentIndex SegmentKind = 2
+)
+
+// Segment is a single step in a `Path`.
+type Segment struct {
+	// Name is the field name, map key or the index as a string.
+	Name string
+	// Kind is the kind of segment.
+	Kind SegmentKind
+}
+
+// Path is a parsed path of segments.
+type Path []Segment
+
+// Field creates a `SegmentField` segment.
+func Field(name string) Segment {
+	return Segment{Name: name, Kind: SegmentField}
+}
+
+// Key creates a `SegmentKey` segment.
+func Key(key string) Segment {
+	return Segment{Name: key, Kind: SegmentKey}
+}
+
+// Index creates a `SegmentIndex` segment.
+func Index(i int) Segment {
+	return Segment{Name: strconv.Itoa(i), Kind: SegmentIndex}
+}
+
+// Append renders _seg_ onto the already rendered _path_ using _style_. This is used when
+// walking a model to build the path incrementally.
+func Append(style Style, path string, seg Segment) string {
+	switch style {
+	case StyleJSONPointer:
+		return path + "/" + escapePointer(seg.Name)
+	case StyleBracket:
+		switch seg.Kind {
+		case SegmentIndex:
+			return path + "[" + seg.Name + "]"
+		case SegmentKey:
+			return path + "[" + strconv.Quote(seg.Name) + "]"
+		default:
+			if path == "" {
+				return seg.Name
+			}
+
+			return path + "." + seg.Name
+		}
+	default:
+		if path == "" {
+			return seg.Name
+		}
+
+		return path + "." + seg.Name
+	}
+}
+
+// Render renders the _p_ using the _style_.
+func (p Path) Render(style Style) string {
+	var s string
+
+	for _, seg := range p {
+		s = Append(style, s, seg)
+	}
+
+	return s
+}
+
+// String renders the path using `StyleDotted`.
+func (p Path) String() string {
+	return p.Render(StyleDotted)
+}
+
+// Names returns the names of all segments.
+func (p Path) Names() []string {
+	names := make([]string, 0, len(p))
+
+	for _, seg := range p {
+		names = append(names, seg.Name)
+	}
+
+	return names
+}
+
+// Parse parses the _s_ in the _style_. Since the dotted and JSON Pointer styles do not carry any
+// kind information, all numeric segments are returned as `SegmentIndex` and the rest as `SegmentField`.
+func Parse(s string, style Style) (Path, error) {
+	switch style {
+	case StyleDotted:
+		return parseDotted(s), nil
+	case StyleJSONPointer:
+		return parsePointer(s)
+	case StyleBracket:
+		return parseBracket(s)
+	}
+
+	return nil, fmt.Errorf("unknown path style: %s", style)
+}
+
+// Convert will parse _s_ in the _from_ style and render it in the _to_ style.
+func Convert(s string, from, to Style) (string, error) {
+	p, err := Parse(s, from)
+
+	if err != nil {
+		return "", err
+	}
+
+	return p.Render(to), nil
+}
+
+// DetectStyle makes a best effort guess of the style of _s_. If it starts with a slash it is
+// a `StyleJSONPointer`, if it contains a bracket it is a `StyleBracket` otherwise `StyleDotted`.
+func DetectStyle(s string) Style {
+	if strings.HasPrefix(s, "/") {
+		return StyleJSONPointer
+	}
+
+	if strings.Contains(s, "[") {
+		return StyleBracket
+	}
+
+	return StyleDotted
+}
+
+func kindOf(name string) SegmentKind {
+	if name == "" {
+		return SegmentField
+	}
+
+	for _, r := range name {
+		if r < '0' || r > '9' {
+			return SegmentField
+		}
+	}
+
+	return SegmentIndex
+}
+
+func parseDotted(s string) Path {
+	if s == "" {
+		return Path{}
+	}
+
+	parts := strings.Split(s, ".")
+	p := make(Path, 0, len(parts))
+
+	for _, part := range parts {
+		p = append(p, Segment{Name: part, Kind: kindOf(part)})
+	}
+
+	return p
+}
+
+func parsePointer(s string) (Path, error) {
+	if s == "" {
+		return Path{}, nil
+	}
+
+	if s[0] != '/' {
+		return nil, fmt.Errorf("json pointer must start with '/': %s", s)
+	}
+
+	parts := strings.Split(s[1:], "/")
+	p := make(Path, 0, len(parts))
+
+	for _, part := range parts {
+		name := unescapePointer(part)
+		p = append(p, Segment{Name: name, Kind: kindOf(name)})
+	}
+
+	return p, nil
+}
+
+func parseBracket(s string) (Path, error) {
+	p := Path{}
+	i := 0
+
+	for i < len(s) {
+		switch s[i] {
+		case '.':
+			if i == 0 || i == len(s)-1 {
+				return nil, fmt.Errorf("unexpected '.' at offset %d in: %s", i, s)
+			}
+
+			i++
+		case '[':
+			end, seg, err := parseBracketSegment(s, i)
+
+			if err != nil {
+				return nil, err
+			}
+
+			p = append(p, seg)
+			i = end
+		default:
+			start := i
+
+			for i < len(s) && s[i] != '.' && s[i] != '[' {
+				i++
+			}
+
+			p = append(p, Field(s[start:i]))
+		}
+	}
+
+	return p, nil
+}
+
+// parseBracketSegment parses a `[...]` segment starting at _start_ and returns the offset
+// just after the closing bracket.
+func parseBracketSegment(s string, start int) (int, Segment, error) {
+	i := start + 1
+
+	if i < len(s) && s[i] == '"' {
+		// Quoted key -> find the closing (unescaped) quote
+		j := i + 1
+
+		for j < len(s) && s[j] != '"' {
+			if s[j] == '\\' {
+				j++
+			}
+
+			j++
+		}
+
+		if j+1 >= len(s) || s[j+1] != ']' {
+			return 0, Segment{}, fmt.Errorf("unterminated key at offset %d in: %s", start, s)
+		}
+
+		key, err := strconv.Unquote(s[i : j+1])
+
+		if err != nil {
+			return 0, Segment{}, fmt.Errorf("invalid key at offset %d in: %s: %w", start, s, err)
+		}
+
+		return j + 2, Key(key), nil
+	}
+
+	end := strings.IndexByte(s[i:], ']')
+
+	if end == -1 {
+		return 0, Segment{}, fmt.Errorf("unterminated index at offset %d in: %s", start, s)
+	}
+
+	name := s[i : i+end]
+
+	if kindOf(name) != SegmentIndex {
+		// Allow unquoted keys such as [temp] and wildcards in globs such as [*]
+		return i + end + 1, Key(name), nil
+	}
+
+	return i + end + 1, Segment{Name: name, Kind: SegmentIndex}, nil
+}
+
+func escapePointer(s string) string {
+	if !strings.ContainsAny(s, "~/") {
+		return s
+	}
+
+	s = strings.ReplaceAll(s, "~", "~0")
+	return strings.ReplaceAll(s, "/", "~1")
+}
+
+func unescapePointer(s string) string {
+	if !strings.Contains(s, "~") {
+		return s
+	}
+
+	s = strings.ReplaceAll(s, "~1", "/")
+	return strings.ReplaceAll(s, "~0", "~")
+}
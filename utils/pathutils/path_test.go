@@ -0,0 +1,117 @@
+package pathutils_test
+
+import (
+	"testing"
+
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderStyles(t *testing.T) {
+	p := pathutils.Path{
+		pathutils.Field("climate"),
+		pathutils.Field("sensors"),
+		pathutils.Key("indoor.t/x"),
+		pathutils.Index(2),
+	}
+
+	assert.Equal(t, "climate.sensors.indoor.t/x.2", p.Render(pathutils.StyleDotted))
+	assert.Equal(t, "/climate/sensors/indoor.t~1x/2", p.Render(pathutils.StyleJSONPointer))
+	assert.Equal(t, `climate.sensors["indoor.t/x"][2]`, p.Render(pathutils.StyleBracket))
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	p := pathutils.Path{
+		pathutils.Field("climate"),
+		pathutils.Key(`we"ird~key`),
+		pathutils.Index(0),
+		pathutils.Field("t"),
+	}
+
+	for _, style := range []pathutils.Style{pathutils.StyleJSONPointer, pathutils.StyleBracket} {
+		parsed, err := pathutils.Parse(p.Render(style), style)
+		require.NoError(t, err, style.String())
+		assert.Equal(t, p.Names(), parsed.Names(), style.String())
+	}
+
+	parsed, err := pathutils.Parse(p.Render(pathutils.StyleBracket), pathutils.StyleBracket)
+	require.NoError(t, err)
+	assert.Equal(t, p, parsed)
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := pathutils.Parse("climate/sensors", pathutils.StyleJSONPointer)
+	assert.Error(t, err)
+
+	_, err = pathutils.Parse(`climate["sensors`, pathutils.StyleBracket)
+	assert.Error(t, err)
+
+	_, err = pathutils.Parse(`climate[0`, pathutils.StyleBracket)
+	assert.Error(t, err)
+}
+
+func TestConvert(t *testing.T) {
+	s, err := pathutils.Convert("/Sensors/temp/0", pathutils.StyleJSONPointer, pathutils.StyleBracket)
+	require.NoError(t, err)
+	assert.Equal(t, "Sensors.temp[0]", s)
+}
+
+func TestDetectStyle(t *testing.T) {
+	assert.Equal(t, pathutils.StyleJSONPointer, pathutils.DetectStyle("/a/b"))
+	assert.Equal(t, pathutils.StyleBracket, pathutils.DetectStyle(`a["b"]`))
+	assert.Equal(t, pathutils.StyleDotted, pathutils.DetectStyle("a.b"))
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"climate.sensors.*.t", "climate.sensors.indoor.t", true},
+		{"climate.sensors.*.t", "climate.sensors.indoor.h", false},
+		{"climate.sensors.*.t", "climate.sensors.t", false},
+		{"climate.**", "climate", true},
+		{"climate.**", "climate.sensors.indoor.t", true},
+		{"**.t", "climate.sensors.indoor.t", true},
+		{"climate.**.t", "climate.t", true},
+		{"climate.sensors.in*", "climate.sensors.indoor", true},
+		{"climate.sensors.?ndoor", "climate.sensors.indoor", true},
+		{"climate.sensors.*door*", "climate.sensors.indoor-1", true},
+		{"climate.sensors.i*r", "climate.sensors.indoor-1", false},
+		{"/climate/*/t", "/climate/indoor/t", true},
+		{"/climate/*/t", "/climate/in~1door/t", true},
+		{`climate.sensors[*].t`, `climate.sensors["in.door"].t`, true},
+		{`climate.sensors[*].t`, `climate.sensors[0].h`, false},
+	}
+
+	for _, tc := range tests {
+		g, err := pathutils.CompileGlob(tc.pattern)
+		require.NoError(t, err, tc.pattern)
+		assert.Equal(t, tc.match, g.Match(tc.path), "%s -> %s", tc.pattern, tc.path)
+	}
+}
+
+func TestGlobCache(t *testing.T) {
+	g1, err := pathutils.Shared.GetOrCompile("a.*.c")
+	require.NoError(t, err)
+
+	g2 := pathutils.Shared.MustCompile("a.*.c")
+	assert.Same(t, g1, g2)
+
+	_, err = pathutils.CompileGlob("a/*/c", pathutils.StyleJSONPointer)
+	assert.Error(t, err)
+}
+
+func TestGlobCacheBounded(t *testing.T) {
+	gc := &pathutils.GlobCache{MaxSize: 2}
+
+	g1 := gc.MustCompile("a.*")
+	gc.MustCompile("b.*")
+	assert.Same(t, g1, gc.MustCompile("a.*"))
+
+	gc.MustCompile("c.*") // full, cleared before added
+	assert.NotSame(t, g1, gc.MustCompile("a.*"))
+}
@@ -0,0 +1,101 @@
+package persistutils
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model/observemodel"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// Observation is a started persistence operation.
+type Observation struct {
+	ctx      context.Context
+	observer observemodel.Observer
+	span     observemodel.Span
+	attrs    []observemodel.Attribute
+	start    time.Time
+}
+
+// Observe starts a span for the _operation_ on _persistence_, e.g. _memory_, and records the number of _items_. If
+// the _observer_ is `nil`, nothing is recorded.
+func Observe(
+	ctx context.Context,
+	observer observemodel.Observer,
+	persistence, operation string,
+	items int,
+) (context.Context, *Observation) {
+	observer = observemodel.OrNop(observer)
+
+	attrs := []observemodel.Attribute{
+		observemodel.Attr(observemodel.AttrPersistence, persistence),
+		observemodel.Attr(observemodel.AttrOperation, operation),
+	}
+
+	ctx, span := observer.Start(ctx, observemodel.SpanPersistence+operation, attrs...)
+	observer.Record(ctx, observemodel.PersistenceBatchSize, float64(items), attrs...)
+
+	return ctx, &Observation{ctx: ctx, observer: observer, span: span, attrs: attrs, start: time.Now()}
+}
+
+// Count adds _delta_ to the counter _name_ with the persistence and operation attributes.
+func (o *Observation) Count(name string, delta int64) {
+	if delta != 0 {
+		o.observer.Count(o.ctx, name, delta, o.attrs...)
+	}
+}
+
+// Record records the _value_ in the histogram _name_ with the persistence and operation attributes.
+func (o *Observation) Record(name string, value float64) {
+	o.observer.Record(o.ctx, name, value, o.attrs...)
+}
+
+// End records the duration and the number of failed _results_ and ends the span.
+func (o *Observation) End(results ...persistencemodel.WriteResult) {
+	errs := make([]error, len(results))
+
+	for i, r := range results {
+		errs[i] = r.Error
+	}
+
+	o.EndErrors(errs...)
+}
+
+// EndRead records the duration and the number of failed _results_ and ends the span. A model that is not found is
+// not a failure.
+func (o *Observation) EndRead(results ...persistencemodel.ReadResult) {
+	errs := make([]error, len(results))
+
+	for i, r := range results {
+		var pe persistencemodel.PersistenceError
+
+		if !errors.As(r.Error, &pe) || pe.Code != 404 {
+			errs[i] = r.Error
+		}
+	}
+
+	o.EndErrors(errs...)
+}
+
+// EndErrors records the duration and the number of _errs_ and ends the span. The span fails with the first error.
+func (o *Observation) EndErrors(errs ...error) {
+	var (
+		first  error
+		failed int64
+	)
+
+	for _, err := range errs {
+		if err != nil {
+			failed++
+
+			if first == nil {
+				first = err
+			}
+		}
+	}
+
+	o.Count(observemodel.PersistenceErrors, failed)
+	observemodel.Since(o.ctx, o.observer, observemodel.PersistenceOperationDuration, o.start, o.attrs...)
+	o.span.End(first)
+}
@@ -0,0 +1,56 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+)
+
+// Violation is a single validation failure.
+type Violation struct {
+	// Path is the path to the invalid value e.g. _climate.sensors.indoor.t_.
+	Path string `json:"path"`
+	// Message is a human readable description of the violation.
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	if v.Path == "" {
+		return v.Message
+	}
+
+	return fmt.Sprintf("%s: %s", v.Path, v.Message)
+}
+
+// ValidationError is returned when a model has one or more violations. It unwraps into a
+// `persistencemodel.PersistenceError` with code 422 (Unprocessable Entity).
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.String())
+	}
+
+	return fmt.Sprintf("validation failed: %s", strings.Join(msgs, "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return persistencemodel.Error422(e.Error())
+}
+
+// Violations returns the violations in _err_ if it is, or wraps, a `ValidationError`.
+func Violations(err error) []Violation {
+	var ve *ValidationError
+
+	if errors.As(err, &ve) {
+		return ve.Violations
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package validate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
+)
+
+// Limits are the size limits of a model. A limit of _zero_ is not checked.
+type Limits struct {
+	// MaxSize is the maximum size, in bytes, of the JSON serialized model.
+	MaxSize int `json:"max_size,omitempty"`
+	// MaxMapEntries is the maximum number of entries in any map.
+	MaxMapEntries int `json:"max_map_entries,omitempty"`
+	// MaxSliceLength is the maximum length of any slice or array.
+	MaxSliceLength int `json:"max_slice_length,omitempty"`
+	// MaxDepth is the maximum nesting of structs, maps and slices where the model itself is at depth one. Managed
+	// values (`model.ValueAndTimestamp`) and empty maps and slices are leafs and not counted.
+	MaxDepth int `json:"max_depth,omitempty"`
+	// MaxNamedShadows is the maximum number of named shadows per id. It is not checked by `CheckLimits` since it
+	// needs the persistence, instead it is checked by the manager when a shadow is created.
+	MaxNamedShadows int `json:"max_named_shadows,omitempty"`
+}
+
+// Enabled returns `true` if any limit is set.
+func (l Limits) Enabled() bool {
+	return l.MaxSize > 0 || l.MaxMapEntries > 0 || l.MaxSliceLength > 0 || l.MaxDepth > 0 || l.MaxNamedShadows > 0
+}
+
+// LimitError is returned when a limit is exceeded. It unwraps into a `persistencemodel.PersistenceError` with
+// code 413 (Content Too Large).
+type LimitError struct {
+	// Path is the path to the offending value. When the size is exceeded, it is the largest top level value.
+	Path string `json:"path"`
+	// Limit is the name of the exceeded limit e.g. _MaxMapEntries_.
+	Limit string `json:"limit"`
+	// Value is the actual size, entries, length, depth or number of named shadows.
+	Value int `json:"value"`
+	// Max is the limit.
+	Max int `json:"max"`
+}
+
+func (e *LimitError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("limit exceeded: %s is %d, max %d", e.Limit, e.Value, e.Max)
+	}
+
+	return fmt.Sprintf("limit exceeded: %s: %s is %d, max %d", e.Path, e.Limit, e.Value, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return persistencemodel.Error413(e.Error())
+}
+
+// CheckLimits checks the _m_ against the _limits_, except `Limits.MaxNamedShadows`, and returns a `*LimitError`
+// for the first exceeded limit. The structure is checked before the size.
+func CheckLimits(ctx context.Context, m any, limits Limits, style pathutils.Style) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if limits.MaxMapEntries > 0 || limits.MaxSliceLength > 0 || limits.MaxDepth > 0 {
+		lw := &limitWalker{limits: limits, style: style}
+
+		if lw.walk(reflect.ValueOf(m), "", 1); lw.err != nil {
+			return lw.err
+		}
+	}
+
+	if limits.MaxSize <= 0 || m == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(m)
+
+	if err != nil {
+		return persistencemodel.Error500(fmt.Sprintf("failed to serialize model: %s", err))
+	}
+
+	if len(data) > limits.MaxSize {
+		return &LimitError{Path: largestChild(reflect.ValueOf(m), style), Limit: "MaxSize", Value: len(data), Max: limits.MaxSize}
+	}
+
+	return nil
+}
+
+type limitWalker struct {
+	limits Limits
+	style  pathutils.Style
+	err    *LimitError
+}
+
+func (lw *limitWalker) walk(val reflect.Value, path string, depth int) {
+	if lw.err != nil {
+		return
+	}
+
+	if _, ok := asInterface[model.ValueAndTimestamp](val); ok {
+		return // Managed values are leafs
+	}
+
+	val = indirect(val)
+
+	switch val.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		if val.Len() == 0 {
+			return // Empty containers do not nest
+		}
+
+		fallthrough
+	case reflect.Struct:
+		if lw.limits.MaxDepth > 0 && depth > lw.limits.MaxDepth {
+			lw.err = &LimitError{Path: path, Limit: "MaxDepth", Value: depth, Max: lw.limits.MaxDepth}
+			return
+		}
+	}
+
+	switch val.Kind() {
+	case reflect.Struct:
+		for i := 0; i < val.NumField(); i++ {
+			field := val.Type().Field(i)
+
+			if name := jsonName(field); field.PkgPath == "" && name != "" {
+				lw.walk(val.Field(i), pathutils.Append(lw.style, path, pathutils.Field(name)), depth+1)
+			}
+		}
+	case reflect.Map:
+		if lw.limits.MaxMapEntries > 0 && val.Len() > lw.limits.MaxMapEntries {
+			lw.err = &LimitError{Path: path, Limit: "MaxMapEntries", Value: val.Len(), Max: lw.limits.MaxMapEntries}
+			return
+		}
+
+		for _, k := range sortedKeys(val) {
+			lw.walk(val.MapIndex(k), pathutils.Append(lw.style, path, pathutils.Key(fmt.Sprint(k.Interface()))), depth+1)
+		}
+	case reflect.Slice, reflect.Array:
+		if lw.limits.MaxSliceLength > 0 && val.Len() > lw.limits.MaxSliceLength {
+			lw.err = &LimitError{Path: path, Limit: "MaxSliceLength", Value: val.Len(), Max: lw.limits.MaxSliceLength}
+			return
+		}
+
+		for i := 0; i < val.Len(); i++ {
+			lw.walk(val.Index(i), pathutils.Append(lw.style, path, pathutils.Index(i)), depth+1)
+		}
+	}
+}
+
+// largestChild returns the path of the top level field, or entry, that has the largest serialized size.
+func largestChild(val reflect.Value, style pathutils.Style) string {
+	var (
+		path    string
+		largest int
+	)
+
+	measure := func(v reflect.Value, seg pathutils.Segment) {
+		if data, err := json.Marshal(v.Interface()); err == nil && len(data) > largest {
+			path, largest = pathutils.Append(style, "", seg), len(data)
+		}
+	}
+
+	val = indirect(val)
+
+	switch val.Kind() {
+	case reflect.Struct:
+		for i := 0; i < val.NumField(); i++ {
+			field := val.Type().Field(i)
+
+			if name := jsonName(field); field.PkgPath == "" && name != "" {
+				measure(val.Field(i), pathutils.Field(name))
+			}
+		}
+	case reflect.Map:
+		for _, k := range sortedKeys(val) {
+			measure(val.MapIndex(k), pathutils.Key(fmt.Sprint(k.Interface())))
+		}
+	}
+
+	return path
+}
+
+// indirect dereferences pointers and interfaces. A `nil` results in an invalid value.
+func indirect(val reflect.Value) reflect.Value {
+	for val.IsValid() && (val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface) {
+		if val.IsNil() {
+			return reflect.Value{}
+		}
+
+		val = val.Elem()
+	}
+
+	return val
+}
+
+// sortedKeys returns the keys of the map _val_ sorted by their string representation.
+func sortedKeys(val reflect.Value) []reflect.Value {
+	keys := val.MapKeys()
+
+	sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface()) })
+
+	return keys
+}
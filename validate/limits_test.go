@@ -0,0 +1,69 @@
+package validate_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
+	"github.com/mariotoffia/godeviceshadow/validate"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Node struct {
+	Name     string            `json:"name"`
+	Tags     []string          `json:"tags,omitempty"`
+	Children map[string]*Node  `json:"children,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+func TestCheckLimits(t *testing.T) {
+	ctx := context.Background()
+	node := Node{
+		Name:   "root",
+		Tags:   []string{"a", "b", "c"},
+		Labels: map[string]string{"x": "1", "y": "2"},
+		Children: map[string]*Node{
+			"kitchen": {Name: "kitchen", Children: map[string]*Node{"fridge": {Name: strings.Repeat("f", 100)}}},
+		},
+	}
+
+	tests := []struct {
+		limits validate.Limits
+		path   string
+		limit  string
+	}{
+		{validate.Limits{MaxSliceLength: 2}, "tags", "MaxSliceLength"},
+		{validate.Limits{MaxMapEntries: 1}, "labels", "MaxMapEntries"},
+		{validate.Limits{MaxDepth: 3}, "children.kitchen.children", "MaxDepth"},
+		{validate.Limits{MaxSize: 100}, "children", "MaxSize"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.limit, func(t *testing.T) {
+			err := validate.CheckLimits(ctx, node, tt.limits, pathutils.StyleDotted)
+			require.Error(t, err)
+
+			var le *validate.LimitError
+
+			require.True(t, errors.As(err, &le))
+			assert.Equal(t, tt.path, le.Path)
+			assert.Equal(t, tt.limit, le.Limit)
+
+			var pe persistencemodel.PersistenceError
+
+			require.True(t, errors.As(err, &pe))
+			assert.Equal(t, 413, pe.Code)
+		})
+	}
+
+	err := validate.CheckLimits(ctx, node, validate.Limits{
+		MaxSize: 1000, MaxMapEntries: 2, MaxSliceLength: 3, MaxDepth: 5,
+	}, pathutils.StyleDotted)
+
+	assert.NoError(t, err)
+}
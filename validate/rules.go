@@ -0,0 +1,161 @@
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mariotoffia/godeviceshadow/utils/reutils"
+)
+
+// Tag is the struct tag with the comma separated rules of a field e.g. `validate:"required,min=-40,max=85"`.
+//
+// The rules are:
+//
+//   - required: the value must not be a zero value (or empty map / slice).
+//   - min=<number>: numbers must be greater or equal, strings must have at least the length.
+//   - max=<number>: numbers must be less or equal, strings must have at most the length.
+//   - enum=<a|b|c>: the value, formatted using `fmt.Sprint`, must be one of the options.
+//   - regex=<expr>: the value, formatted using `fmt.Sprint`, must match the expression. It must be the last
+//     rule since the expression may contain commas.
+//
+// When the field is a map or slice, all rules but _required_ are applied on each element. When the value is a
+// `model.ValueAndTimestamp`, the rules are applied on the `GetValue`.
+const Tag = "validate"
+
+type rules struct {
+	required bool
+	min, max *float64
+	enum     []string
+	regex    *regexp.Regexp
+}
+
+// rulesCache caches the parsed rules keyed by the tag.
+var rulesCache sync.Map
+
+func parseRules(tag string) (*rules, error) {
+	if r, ok := rulesCache.Load(tag); ok {
+		return r.(*rules), nil
+	}
+
+	r := &rules{}
+	rest := tag
+
+	for rest != "" {
+		var part string
+
+		if strings.HasPrefix(rest, "regex=") {
+			part, rest = rest, ""
+		} else if i := strings.IndexByte(rest, ','); i >= 0 {
+			part, rest = rest[:i], rest[i+1:]
+		} else {
+			part, rest = rest, ""
+		}
+
+		name, arg, _ := strings.Cut(part, "=")
+
+		switch strings.TrimSpace(name) {
+		case "":
+			continue
+		case "required":
+			r.required = true
+		case "min", "max":
+			f, err := strconv.ParseFloat(strings.TrimSpace(arg), 64)
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s rule: %s", name, arg)
+			}
+
+			if name == "min" {
+				r.min = &f
+			} else {
+				r.max = &f
+			}
+		case "enum":
+			r.enum = strings.Split(arg, "|")
+		case "regex":
+			re, err := reutils.Shared.GetOrCompile(arg)
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex rule: %s: %w", arg, err)
+			}
+
+			r.regex = re
+		default:
+			return nil, fmt.Errorf("unknown validation rule: %s", name)
+		}
+	}
+
+	rulesCache.Store(tag, r)
+
+	return r, nil
+}
+
+// elements returns the rules that applies on each element in a map or slice.
+func (r *rules) elements() *rules {
+	if r == nil || !r.required {
+		return r
+	}
+
+	e := *r
+	e.required = false
+
+	return &e
+}
+
+// check returns the violation messages of the leaf _val_.
+func (r *rules) check(val reflect.Value) []string {
+	var msgs []string
+
+	if r.required && (!val.IsValid() || val.IsZero()) {
+		return append(msgs, "is required")
+	}
+
+	if !val.IsValid() {
+		return nil
+	}
+
+	if n, ok := measure(val); ok {
+		if r.min != nil && n < *r.min {
+			msgs = append(msgs, fmt.Sprintf("must be at least %v", *r.min))
+		}
+
+		if r.max != nil && n > *r.max {
+			msgs = append(msgs, fmt.Sprintf("must be at most %v", *r.max))
+		}
+	}
+
+	if len(r.enum) > 0 || r.regex != nil {
+		s := fmt.Sprint(val.Interface())
+
+		if len(r.enum) > 0 && !slices.Contains(r.enum, s) {
+			msgs = append(msgs, fmt.Sprintf("must be one of %s", strings.Join(r.enum, ", ")))
+		}
+
+		if r.regex != nil && !r.regex.MatchString(s) {
+			msgs = append(msgs, fmt.Sprintf("must match %s", r.regex))
+		}
+	}
+
+	return msgs
+}
+
+// measure returns the number to compare with min and max. Strings are measured by their length.
+func measure(val reflect.Value) (float64, bool) {
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(val.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(val.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return val.Float(), true
+	case reflect.String:
+		return float64(len(val.String())), true
+	}
+
+	return 0, false
+}
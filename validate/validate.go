@@ -0,0 +1,214 @@
+package validate
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mariotoffia/godeviceshadow/model"
+	"github.com/mariotoffia/godeviceshadow/utils/pathutils"
+)
+
+// Options controls the validation.
+type Options struct {
+	// PathStyle is how the paths of the violations are rendered. Default is `pathutils.StyleDotted`.
+	PathStyle pathutils.Style
+	// Partial is set when the model only holds some of the values, e.g. a desired model. Zero values are
+	// then treated as not set and hence neither _required_ nor any other rule is checked on them.
+	Partial bool
+}
+
+// Validate validates the _m_ using the `Tag` rules and all values that implements `model.Validator`.
+//
+// It returns a `*ValidationError` with all violations, if any. Other errors are returned as is, e.g. an
+// invalid rule or a cancelled _ctx_.
+func Validate(ctx context.Context, m any, opts Options) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	w := &walker{Options: opts}
+
+	w.walk(reflect.ValueOf(m), "", nil)
+
+	if w.err != nil {
+		return w.err
+	}
+
+	if len(w.violations) > 0 {
+		return &ValidationError{Violations: w.violations}
+	}
+
+	return nil
+}
+
+type walker struct {
+	Options
+	violations []Violation
+	err        error
+}
+
+func (w *walker) violation(path, message string) {
+	w.violations = append(w.violations, Violation{Path: path, Message: message})
+}
+
+func (w *walker) walk(val reflect.Value, path string, r *rules) {
+	if w.err != nil {
+		return
+	}
+
+	if w.Partial && (!val.IsValid() || val.IsZero()) {
+		return
+	}
+
+	w.validator(val, path)
+
+	if vt, ok := asInterface[model.ValueAndTimestamp](val); ok {
+		// Managed values are leafs
+		if r != nil {
+			w.check(r, reflect.ValueOf(vt.GetValue()), path)
+		}
+
+		return
+	}
+
+	for val.IsValid() && (val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface) {
+		if val.IsNil() {
+			val = reflect.Value{}
+			break
+		}
+
+		val = val.Elem()
+	}
+
+	switch val.Kind() {
+	case reflect.Struct:
+		if r != nil && r.required && val.IsZero() {
+			w.violation(path, "is required")
+		}
+
+		for i := 0; i < val.NumField(); i++ {
+			field := val.Type().Field(i)
+
+			if field.PkgPath != "" {
+				continue // Unexported field -> skip
+			}
+
+			name := jsonName(field)
+
+			if name == "" {
+				continue
+			}
+
+			var fr *rules
+
+			if tag, ok := field.Tag.Lookup(Tag); ok {
+				if fr, w.err = parseRules(tag); w.err != nil {
+					w.err = fmt.Errorf("field %s: %w", field.Name, w.err)
+					return
+				}
+			}
+
+			w.walk(val.Field(i), pathutils.Append(w.PathStyle, path, pathutils.Field(name)), fr)
+		}
+	case reflect.Map:
+		if r != nil && r.required && val.Len() == 0 {
+			w.violation(path, "is required")
+		}
+
+		keys := val.MapKeys()
+		names := make([]string, len(keys))
+
+		for i, k := range keys {
+			names[i] = fmt.Sprint(k.Interface())
+		}
+
+		idx := make([]int, len(keys))
+
+		for i := range idx {
+			idx[i] = i
+		}
+
+		// Sort to get the violations in a deterministic order
+		sort.Slice(idx, func(i, j int) bool { return names[idx[i]] < names[idx[j]] })
+
+		for _, i := range idx {
+			w.walk(val.MapIndex(keys[i]), pathutils.Append(w.PathStyle, path, pathutils.Key(names[i])), r.elements())
+		}
+	case reflect.Slice, reflect.Array:
+		if r != nil && r.required && val.Len() == 0 {
+			w.violation(path, "is required")
+		}
+
+		for i := 0; i < val.Len(); i++ {
+			w.walk(val.Index(i), pathutils.Append(w.PathStyle, path, pathutils.Index(i)), r.elements())
+		}
+	default:
+		if r != nil {
+			w.check(r, val, path)
+		}
+	}
+}
+
+func (w *walker) check(r *rules, val reflect.Value, path string) {
+	for _, msg := range r.check(val) {
+		w.violation(path, msg)
+	}
+}
+
+// validator invokes the `model.Validator` if implemented by _val_.
+func (w *walker) validator(val reflect.Value, path string) {
+	if v, ok := asInterface[model.Validator](val); ok {
+		if err := v.Validate(); err != nil {
+			w.violation(path, err.Error())
+		}
+	}
+}
+
+// asInterface returns _val_ as _T_ when it, or a pointer to it, implements _T_.
+func asInterface[T any](val reflect.Value) (T, bool) {
+	var zero T
+
+	if !val.IsValid() || ((val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface) && val.IsNil()) {
+		return zero, false
+	}
+
+	if val.Kind() == reflect.Interface {
+		val = val.Elem()
+	}
+
+	if t, ok := val.Interface().(T); ok {
+		return t, true
+	}
+
+	if val.Kind() == reflect.Ptr {
+		return zero, false
+	}
+
+	// Pointer receiver
+	ptr := reflect.New(val.Type())
+	ptr.Elem().Set(val)
+
+	t, ok := ptr.Interface().(T)
+
+	return t, ok
+}
+
+func jsonName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+
+	if tag == "" {
+		return field.Name
+	}
+
+	if tag == "-" {
+		return ""
+	}
+
+	// Same as the merge, comma -> ignore the rest
+	name, _, _ := strings.Cut(tag, ",")
+
+	return name
+}
@@ -0,0 +1,97 @@
+package validate_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mariotoffia/godeviceshadow/model/persistencemodel"
+	"github.com/mariotoffia/godeviceshadow/validate"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Reading struct {
+	Value     float64   `json:"v"`
+	TimeStamp time.Time `json:"ts"`
+}
+
+func (r *Reading) GetTimestamp() time.Time { return r.TimeStamp }
+func (r *Reading) GetValue() any           { return r.Value }
+
+type Firmware struct {
+	Version string `json:"version" validate:"required,regex=^\\d+\\.\\d+\\.\\d+$"`
+	Channel string `json:"channel" validate:"enum=stable|beta"`
+}
+
+func (f Firmware) Validate() error {
+	if f.Channel == "beta" && f.Version == "1.0.0" {
+		return errors.New("beta channel requires a newer version")
+	}
+
+	return nil
+}
+
+type Device struct {
+	Name     string             `json:"name" validate:"required,max=8"`
+	Sensors  map[string]Reading `json:"sensors" validate:"required,min=-40,max=85"`
+	Firmware Firmware           `json:"fw"`
+}
+
+func TestValidateValid(t *testing.T) {
+	err := validate.Validate(context.Background(), Device{
+		Name:     "hub",
+		Sensors:  map[string]Reading{"temp": {Value: 21.5}},
+		Firmware: Firmware{Version: "1.2.3", Channel: "stable"},
+	}, validate.Options{})
+
+	assert.NoError(t, err)
+}
+
+func TestValidateViolations(t *testing.T) {
+	err := validate.Validate(context.Background(), Device{
+		Name:     "very-long-name",
+		Sensors:  map[string]Reading{"temp": {Value: 120}, "outdoor": {Value: -50}},
+		Firmware: Firmware{Version: "1.0.0", Channel: "beta"},
+	}, validate.Options{})
+
+	require.Error(t, err)
+
+	var pe persistencemodel.PersistenceError
+
+	require.ErrorAs(t, err, &pe)
+	assert.Equal(t, 422, pe.Code)
+
+	assert.Equal(t, []validate.Violation{
+		{Path: "name", Message: "must be at most 8"},
+		{Path: "sensors.outdoor", Message: "must be at least -40"},
+		{Path: "sensors.temp", Message: "must be at most 85"},
+		{Path: "fw", Message: "beta channel requires a newer version"},
+	}, validate.Violations(err))
+}
+
+func TestValidatePartialSkipsNotSet(t *testing.T) {
+	ctx := context.Background()
+
+	err := validate.Validate(ctx, Device{Firmware: Firmware{Channel: "stable"}}, validate.Options{})
+	assert.Len(t, validate.Violations(err), 3)
+
+	err = validate.Validate(ctx, Device{Firmware: Firmware{Channel: "stable"}}, validate.Options{Partial: true})
+	assert.NoError(t, err)
+
+	err = validate.Validate(ctx, Device{Firmware: Firmware{Channel: "alpha"}}, validate.Options{Partial: true})
+	assert.Equal(t, []validate.Violation{{Path: "fw.channel", Message: "must be one of stable, beta"}}, validate.Violations(err))
+}
+
+func TestValidateInvalidRule(t *testing.T) {
+	type bad struct {
+		Value int `validate:"between=1"`
+	}
+
+	err := validate.Validate(context.Background(), bad{}, validate.Options{})
+
+	require.Error(t, err)
+	assert.Nil(t, validate.Violations(err))
+}